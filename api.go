@@ -3,4 +3,6 @@ package forge
 type APIResponse struct {
 	Msg any
 	Err string
+	// MovedTo is the current path of the requested entry, when it is moved.
+	MovedTo string `json:",omitempty"`
 }
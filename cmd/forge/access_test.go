@@ -0,0 +1,1109 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/imagvfx/forge"
+)
+
+func TestAccessModes(t *testing.T) {
+	db, server, err := testDB(t)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	bgCtx := context.Background()
+	adminCtx := forge.ContextWithUserName(bgCtx, "admin@imagvfx.com")
+	// first user who was added to the db becomes an admin
+	for _, user := range []string{"admin@imagvfx.com", "artist@imagvfx.com", "lead@imagvfx.com", "client@imagvfx.com"} {
+		err = server.AddUser(bgCtx, &forge.User{Name: user})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	groupMembers := map[string][]string{
+		"artists": {"artist@imagvfx.com", "lead@imagvfx.com"},
+		"leads":   {"lead@imagvfx.com"},
+		"clients": {"client@imagvfx.com"},
+	}
+	for group, members := range groupMembers {
+		err = server.AddGroup(adminCtx, &forge.Group{Name: group})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, member := range members {
+			err = server.AddGroupMember(adminCtx, group, member)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	err = server.AddEntryType(adminCtx, "part")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, pth := range []string{"/show", "/show/secret", "/show/secret/sub", "/show/secret/open", "/show/team", "/show/client"} {
+		err = server.AddEntry(adminCtx, pth, "part")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	testAddAccess := []struct {
+		path     string
+		accessor string
+		mode     string
+		wantErr  error
+	}{
+		{path: "/", accessor: "artists", mode: "r"},
+		{path: "/", accessor: "clients", mode: "r"},
+		{path: "/show", accessor: "leads", mode: "rw"},
+		// none blocks inheritance of the group access.
+		{path: "/show/secret", accessor: "artists", mode: "none"},
+		// the most permissive mode wins between groups of a same entry.
+		{path: "/show/secret", accessor: "leads", mode: "r"},
+		// lower entry has precedence to higher entry.
+		{path: "/show/secret/open", accessor: "artists", mode: "r"},
+		// user accessor has precedence to group accessor, in both directions.
+		{path: "/show/team", accessor: "artists", mode: "none"},
+		{path: "/show/team", accessor: "artist@imagvfx.com", mode: "r"},
+		{path: "/show/client", accessor: "clients", mode: "rw"},
+		{path: "/show/client", accessor: "client@imagvfx.com", mode: "none"},
+		{path: "/show", accessor: "clients", mode: "hidden", wantErr: errors.New("unknown access type")},
+	}
+	for _, c := range testAddAccess {
+		err := server.AddAccess(adminCtx, c.path, c.accessor, c.mode)
+		if !equalError(c.wantErr, err) {
+			t.Fatalf("add access %v to %v: want err %q, got %q", c.accessor, c.path, errorString(c.wantErr), errorString(err))
+		}
+	}
+	acc, err := server.GetAccess(adminCtx, "/show/secret", "artists")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if acc.Value != "none" {
+		t.Fatalf("get access: want none, got %v", acc.Value)
+	}
+	// mode is "", "r" or "rw". "" means the user cannot see the entry.
+	testModes := []struct {
+		user string
+		path string
+		mode string
+	}{
+		{user: "artist@imagvfx.com", path: "/show", mode: "r"},
+		{user: "artist@imagvfx.com", path: "/show/secret", mode: ""},
+		{user: "artist@imagvfx.com", path: "/show/secret/sub", mode: ""},
+		{user: "artist@imagvfx.com", path: "/show/secret/open", mode: "r"},
+		{user: "artist@imagvfx.com", path: "/show/team", mode: "r"},
+		{user: "lead@imagvfx.com", path: "/show", mode: "rw"},
+		{user: "lead@imagvfx.com", path: "/show/secret", mode: "r"},
+		{user: "lead@imagvfx.com", path: "/show/secret/sub", mode: "r"},
+		{user: "lead@imagvfx.com", path: "/show/secret/open", mode: "r"},
+		{user: "lead@imagvfx.com", path: "/show/team", mode: ""},
+		{user: "client@imagvfx.com", path: "/show", mode: "r"},
+		{user: "client@imagvfx.com", path: "/show/client", mode: ""},
+		{user: "client@imagvfx.com", path: "/show/secret", mode: "r"},
+	}
+	checkModes := func() {
+		for _, c := range testModes {
+			ctx := forge.ContextWithUserName(bgCtx, c.user)
+			mode := ""
+			if server.UserRead(ctx, c.path) == nil {
+				mode = "r"
+				if server.UserWrite(ctx, c.path) == nil {
+					mode = "rw"
+				}
+			}
+			if mode != c.mode {
+				t.Fatalf("%v on %v: want mode %q, got %q", c.user, c.path, c.mode, mode)
+			}
+		}
+	}
+	checkModes()
+	// explain the decisions above.
+	// a step is written as 'path: matches', and '*' marks the decisive match.
+	testExplains := []struct {
+		ctxUser    string
+		user       string
+		path       string
+		wantMode   string
+		wantReason string
+		wantSteps  []string
+		wantErr    error
+	}{
+		{
+			ctxUser:    "admin@imagvfx.com",
+			user:       "artist@imagvfx.com",
+			path:       "/show/secret/sub",
+			wantMode:   "",
+			wantReason: "group artists has none access at /show/secret",
+			wantSteps:  []string{"/show/secret/sub:", "/show/secret: artists(group)=none*"},
+		},
+		{
+			ctxUser:    "admin@imagvfx.com",
+			user:       "lead@imagvfx.com",
+			path:       "/show/secret",
+			wantMode:   "r",
+			wantReason: "group leads has r access at /show/secret",
+			wantSteps:  []string{"/show/secret: artists(group)=none leads(group)=r*"},
+		},
+		{
+			ctxUser:    "admin@imagvfx.com",
+			user:       "artist@imagvfx.com",
+			path:       "/show/team",
+			wantMode:   "r",
+			wantReason: "user artist@imagvfx.com has r access at /show/team",
+			wantSteps:  []string{"/show/team: artist@imagvfx.com(user)=r*"},
+		},
+		{
+			// users can explain their own access.
+			ctxUser:    "client@imagvfx.com",
+			user:       "client@imagvfx.com",
+			path:       "/show",
+			wantMode:   "r",
+			wantReason: "group clients has r access at /",
+			wantSteps:  []string{"/show:", "/: clients(group)=r*"},
+		},
+		{
+			ctxUser:    "admin@imagvfx.com",
+			user:       "admin@imagvfx.com",
+			path:       "/show/secret",
+			wantMode:   "rw",
+			wantReason: "admin can read and write any entry",
+			wantSteps:  []string{},
+		},
+		{
+			ctxUser: "artist@imagvfx.com",
+			user:    "lead@imagvfx.com",
+			path:    "/show",
+			wantErr: errors.New("only admins can explain access of other users"),
+		},
+		{
+			ctxUser: "admin@imagvfx.com",
+			user:    "artist@imagvfx.com",
+			path:    "/show/not-exist",
+			wantErr: errors.New("entry not found: /show/not-exist"),
+		},
+	}
+	for _, c := range testExplains {
+		ctx := forge.ContextWithUserName(bgCtx, c.ctxUser)
+		exp, err := server.ExplainAccess(ctx, c.user, c.path)
+		if !equalError(c.wantErr, err) {
+			t.Fatalf("explain access of %v on %v: want err %q, got %q", c.user, c.path, errorString(c.wantErr), errorString(err))
+		}
+		if err != nil {
+			continue
+		}
+		if exp.Mode != c.wantMode {
+			t.Fatalf("explain access of %v on %v: want mode %q, got %q", c.user, c.path, c.wantMode, exp.Mode)
+		}
+		if exp.Reason != c.wantReason {
+			t.Fatalf("explain access of %v on %v: want reason %q, got %q", c.user, c.path, c.wantReason, exp.Reason)
+		}
+		steps := make([]string, 0, len(exp.Steps))
+		for _, s := range exp.Steps {
+			step := s.EntryPath + ":"
+			for _, m := range s.Matches {
+				step += " " + m.Name + "(" + m.Type + ")=" + m.Mode
+				if m.Decisive {
+					step += "*"
+				}
+			}
+			steps = append(steps, step)
+		}
+		if !reflect.DeepEqual(steps, c.wantSteps) {
+			t.Fatalf("explain access of %v on %v: want steps %q, got %q", c.user, c.path, c.wantSteps, steps)
+		}
+	}
+	err = server.UpdateUserDisabled(adminCtx, "client@imagvfx.com", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp, err := server.ExplainAccess(adminCtx, "client@imagvfx.com", "/show")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !exp.Disabled || exp.Mode != "" || exp.Reason != "user disabled" {
+		t.Fatalf("explain access of disabled user: got disabled %v, mode %q, reason %q", exp.Disabled, exp.Mode, exp.Reason)
+	}
+	// hidden entries shouldn't be found as a sub entry either.
+	artistCtx := forge.ContextWithUserName(bgCtx, "artist@imagvfx.com")
+	subs, err := server.SubEntries(artistCtx, "/show")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make([]string, 0, len(subs))
+	for _, e := range subs {
+		got = append(got, e.Path)
+	}
+	sort.Strings(got)
+	want := []string{"/show/client", "/show/team"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("sub entries: want %v, got %v", want, got)
+	}
+	// revoke the deny.
+	err = server.UpdateAccess(adminCtx, "/show/secret", "artists", "r")
+	if err != nil {
+		t.Fatal(err)
+	}
+	testModes = []struct {
+		user string
+		path string
+		mode string
+	}{
+		{user: "artist@imagvfx.com", path: "/show/secret", mode: "r"},
+		{user: "artist@imagvfx.com", path: "/show/secret/sub", mode: "r"},
+	}
+	checkModes()
+}
+
+func TestNestedGroups(t *testing.T) {
+	db, server, err := testDB(t)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	bgCtx := context.Background()
+	adminCtx := forge.ContextWithUserName(bgCtx, "admin@imagvfx.com")
+	// first user who was added to the db becomes an admin
+	for _, user := range []string{"admin@imagvfx.com", "complead@imagvfx.com", "comp@imagvfx.com", "paint@imagvfx.com", "sup@imagvfx.com", "outsider@imagvfx.com"} {
+		err = server.AddUser(bgCtx, &forge.User{Name: user})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, group := range []string{"2d", "comp", "comp-leads", "paint", "supervisors"} {
+		err = server.AddGroup(adminCtx, &forge.Group{Name: group})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	testAddMembers := []struct {
+		group   string
+		member  string
+		wantErr error
+	}{
+		{group: "2d", member: "comp"},
+		{group: "2d", member: "paint"},
+		{group: "comp", member: "comp-leads"},
+		{group: "comp-leads", member: "complead@imagvfx.com"},
+		// complead is a member of 2d through several paths.
+		{group: "2d", member: "complead@imagvfx.com"},
+		{group: "comp", member: "comp@imagvfx.com"},
+		{group: "paint", member: "paint@imagvfx.com"},
+		{group: "supervisors", member: "sup@imagvfx.com"},
+		{group: "admin", member: "supervisors"},
+		{group: "comp", member: "comp", wantErr: errors.New("group cannot be a member of itself: comp")},
+		{group: "comp-leads", member: "2d", wantErr: errors.New(`cannot add group "2d" to "comp-leads": "comp-leads" is a sub group of "2d"`)},
+		{group: "2d", member: "everyone", wantErr: errors.New("everyone or everyone@{domain} group cannot be a member of another group")},
+		{group: "2d", member: "unknown", wantErr: errors.New("accessor not found: unknown")},
+	}
+	for _, c := range testAddMembers {
+		err := server.AddGroupMember(adminCtx, c.group, c.member)
+		if !equalError(c.wantErr, err) {
+			t.Fatalf("add %v to %v: want err %q, got %q", c.member, c.group, errorString(c.wantErr), errorString(err))
+		}
+	}
+	mems, err := server.GroupMembers(adminCtx, "2d")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make([]string, 0)
+	for _, m := range mems {
+		got = append(got, fmt.Sprintf("%v(group=%v)", m.Member, m.IsGroup))
+	}
+	sort.Strings(got)
+	want := []string{"comp(group=true)", "complead@imagvfx.com(group=false)", "paint(group=true)"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("members of 2d: want %v, got %v", want, got)
+	}
+	// a user is found once, with the nearest path.
+	expanded, err := server.ExpandedGroupMembers(adminCtx, "2d")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got = make([]string, 0)
+	for _, m := range expanded {
+		got = append(got, m.Member+" via "+m.Via)
+	}
+	sort.Strings(got)
+	want = []string{"comp@imagvfx.com via comp", "complead@imagvfx.com via ", "paint@imagvfx.com via paint"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("expanded members of 2d: want %v, got %v", want, got)
+	}
+	// a member of a sub group of admin is an admin.
+	isAdmin, err := server.IsAdmin(adminCtx, "sup@imagvfx.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !isAdmin {
+		t.Fatalf("sup@imagvfx.com should be an admin through supervisors group")
+	}
+	err = server.AddEntryType(adminCtx, "part")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, pth := range []string{"/show", "/show/comp", "/show/comp/secret", "/show/paint", "/show/lookdev"} {
+		err = server.AddEntry(adminCtx, pth, "part")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, a := range []struct {
+		path     string
+		accessor string
+		mode     string
+	}{
+		{path: "/show", accessor: "2d", mode: "r"},
+		{path: "/show/comp", accessor: "comp", mode: "rw"},
+		// the most permissive mode wins, even when the groups are nested.
+		{path: "/show/comp/secret", accessor: "comp", mode: "none"},
+		{path: "/show/comp/secret", accessor: "comp-leads", mode: "rw"},
+		{path: "/show/paint", accessor: "2d", mode: "none"},
+		{path: "/show/paint", accessor: "paint", mode: "rw"},
+		// user accessor has precedence to any group accessor.
+		{path: "/show/lookdev", accessor: "2d", mode: "rw"},
+		{path: "/show/lookdev", accessor: "complead@imagvfx.com", mode: "r"},
+	} {
+		err := server.AddAccess(adminCtx, a.path, a.accessor, a.mode)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	testModes := []struct {
+		user string
+		path string
+		mode string
+	}{
+		{user: "complead@imagvfx.com", path: "/show", mode: "r"},
+		{user: "complead@imagvfx.com", path: "/show/comp", mode: "rw"},
+		{user: "complead@imagvfx.com", path: "/show/comp/secret", mode: "rw"},
+		{user: "complead@imagvfx.com", path: "/show/paint", mode: ""},
+		{user: "complead@imagvfx.com", path: "/show/lookdev", mode: "r"},
+		{user: "comp@imagvfx.com", path: "/show", mode: "r"},
+		{user: "comp@imagvfx.com", path: "/show/comp", mode: "rw"},
+		{user: "comp@imagvfx.com", path: "/show/comp/secret", mode: ""},
+		{user: "comp@imagvfx.com", path: "/show/lookdev", mode: "rw"},
+		{user: "comp@imagvfx.com", path: "/show/paint", mode: ""},
+		{user: "paint@imagvfx.com", path: "/show/paint", mode: "rw"},
+		{user: "paint@imagvfx.com", path: "/show/comp", mode: "r"},
+		{user: "outsider@imagvfx.com", path: "/show", mode: ""},
+		{user: "sup@imagvfx.com", path: "/show/paint", mode: "rw"},
+	}
+	checkModes := func() {
+		for _, c := range testModes {
+			ctx := forge.ContextWithUserName(bgCtx, c.user)
+			mode := ""
+			if server.UserRead(ctx, c.path) == nil {
+				mode = "r"
+				if server.UserWrite(ctx, c.path) == nil {
+					mode = "rw"
+				}
+			}
+			if mode != c.mode {
+				t.Fatalf("%v on %v: want mode %q, got %q", c.user, c.path, c.mode, mode)
+			}
+		}
+	}
+	checkModes()
+	// explanation shows the sub groups, through which the user is a member of the matched groups.
+	testExplains := []struct {
+		user string
+		path string
+		want []string
+	}{
+		{user: "complead@imagvfx.com", path: "/show/comp/secret", want: []string{"comp=none via [comp-leads]", "comp-leads=rw via []"}},
+		// complead is a direct member of 2d as well.
+		{user: "complead@imagvfx.com", path: "/show", want: []string{"2d=r via []"}},
+		{user: "comp@imagvfx.com", path: "/show", want: []string{"2d=r via [comp]"}},
+		{user: "complead@imagvfx.com", path: "/show/lookdev", want: []string{"complead@imagvfx.com=r via []"}},
+	}
+	for _, c := range testExplains {
+		exp, err := server.ExplainAccess(adminCtx, c.user, c.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := make([]string, 0)
+		for _, m := range exp.Steps[0].Matches {
+			got = append(got, fmt.Sprintf("%v=%v via %v", m.Name, m.Mode, m.Via))
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Fatalf("explain access of %v on %v: want matches %v, got %v", c.user, c.path, c.want, got)
+		}
+	}
+	// search agrees with the single checks.
+	compCtx := forge.ContextWithUserName(bgCtx, "comp@imagvfx.com")
+	ents, err := server.SearchEntries(compCtx, "/show", "type=part")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got = make([]string, 0)
+	for _, e := range ents {
+		got = append(got, e.Path)
+	}
+	want = []string{"/show/comp", "/show/lookdev"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("search of comp@imagvfx.com: want %v, got %v", want, got)
+	}
+	// members of comp lose access through 2d.
+	err = server.DeleteGroupMember(adminCtx, "2d", "comp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	testModes = []struct {
+		user string
+		path string
+		mode string
+	}{
+		{user: "comp@imagvfx.com", path: "/show", mode: ""},
+		{user: "comp@imagvfx.com", path: "/show/comp", mode: "rw"},
+		{user: "comp@imagvfx.com", path: "/show/lookdev", mode: ""},
+		// complead is still a direct member of 2d.
+		{user: "complead@imagvfx.com", path: "/show", mode: "r"},
+	}
+	checkModes()
+	// admin group should have at least 1 admin, directly or through a sub group.
+	err = server.DeleteGroupMember(adminCtx, "admin", "admin@imagvfx.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	supCtx := forge.ContextWithUserName(bgCtx, "sup@imagvfx.com")
+	err = server.DeleteGroupMember(supCtx, "admin", "supervisors")
+	want1 := errors.New("need at least 1 admin")
+	if !equalError(want1, err) {
+		t.Fatalf("delete last admin: want err %q, got %q", errorString(want1), errorString(err))
+	}
+}
+
+func TestImpersonation(t *testing.T) {
+	db, server, err := testDB(t)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	bgCtx := context.Background()
+	adminCtx := forge.ContextWithUserName(bgCtx, "admin@imagvfx.com")
+	// first user who was added to the db becomes an admin
+	for _, user := range []string{"admin@imagvfx.com", "artist@imagvfx.com", "lead@imagvfx.com"} {
+		err = server.AddUser(bgCtx, &forge.User{Name: user})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = server.AddGroupMember(adminCtx, "admin", "lead@imagvfx.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = server.AddEntryType(adminCtx, "part")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, pth := range []string{"/show", "/show/secret"} {
+		err = server.AddEntry(adminCtx, pth, "part")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = server.AddAccess(adminCtx, "/show", "artist@imagvfx.com", "rw")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = server.AddAccess(adminCtx, "/show/secret", "artist@imagvfx.com", "none")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// impersonation is read-only by default.
+	ctx, err := sessionContext(bgCtx, server, map[string]string{
+		"user":        "admin@imagvfx.com",
+		"impersonate": "artist@imagvfx.com",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := forge.UserNameFromContext(ctx)
+	if user != "artist@imagvfx.com" {
+		t.Fatalf("context user: want artist@imagvfx.com, got %v", user)
+	}
+	imp := forge.ImpersonationFromContext(ctx)
+	if imp == nil || imp.Admin != "admin@imagvfx.com" || imp.Writable {
+		t.Fatalf("impersonation: want read-only impersonation by admin@imagvfx.com, got %v", imp)
+	}
+	// the admin sees what the user sees.
+	err = server.UserRead(ctx, "/show/secret")
+	want := errors.New("cannot access to entry: /show/secret")
+	if !equalError(want, err) {
+		t.Fatalf("read as impersonated user: want err %q, got %q", errorString(want), errorString(err))
+	}
+	err = server.AddEntry(ctx, "/show/part", "part")
+	want = errors.New("read-only impersonation of artist@imagvfx.com by admin@imagvfx.com")
+	if !equalError(want, err) {
+		t.Fatalf("write in read-only impersonation: want err %q, got %q", errorString(want), errorString(err))
+	}
+	err = server.UserWrite(ctx, "/show")
+	if !equalError(want, err) {
+		t.Fatalf("check write in read-only impersonation: want err %q, got %q", errorString(want), errorString(err))
+	}
+	// writable impersonation writes as the user, and logs both of them.
+	ctx, err = sessionContext(bgCtx, server, map[string]string{
+		"user":                 "admin@imagvfx.com",
+		"impersonate":          "artist@imagvfx.com",
+		"impersonate_writable": "1",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = server.AddEntry(ctx, "/show/part", "part")
+	if err != nil {
+		t.Fatal(err)
+	}
+	logs, err := server.EntryLogs(adminCtx, "/show/part")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(logs) == 0 {
+		t.Fatalf("logs of /show/part: want some, got none")
+	}
+	for _, l := range logs {
+		if l.User != "artist@imagvfx.com" || l.Impersonator != "admin@imagvfx.com" {
+			t.Fatalf("log of impersonated action: want artist@imagvfx.com by admin@imagvfx.com, got %v by %v", l.User, l.Impersonator)
+		}
+	}
+	// an admin who is removed from admin group cannot keep the impersonation.
+	leadSession := map[string]string{
+		"user":                 "lead@imagvfx.com",
+		"impersonate":          "artist@imagvfx.com",
+		"impersonate_writable": "1",
+	}
+	_, err = sessionContext(bgCtx, server, leadSession)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = server.DeleteGroupMember(adminCtx, "admin", "lead@imagvfx.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = sessionContext(bgCtx, server, leadSession)
+	want = errors.New("impersonation is not allowed to lead@imagvfx.com anymore")
+	if !equalError(want, err) {
+		t.Fatalf("impersonation of a demoted admin: want err %q, got %q", errorString(want), errorString(err))
+	}
+	err = server.UpdateUserDisabled(adminCtx, "lead@imagvfx.com", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = sessionContext(bgCtx, server, leadSession)
+	if !equalError(want, err) {
+		t.Fatalf("impersonation of a disabled admin: want err %q, got %q", errorString(want), errorString(err))
+	}
+	// without impersonation
+	logs, err = server.EntryLogs(adminCtx, "/show")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, l := range logs {
+		if l.Impersonator != "" {
+			t.Fatalf("log of normal action: want no impersonator, got %v", l.Impersonator)
+		}
+	}
+}
+
+func TestPropertyAccess(t *testing.T) {
+	db, server, err := testDB(t)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	bgCtx := context.Background()
+	adminCtx := forge.ContextWithUserName(bgCtx, "admin@imagvfx.com")
+	artistCtx := forge.ContextWithUserName(bgCtx, "artist@imagvfx.com")
+	producerCtx := forge.ContextWithUserName(bgCtx, "producer@imagvfx.com")
+	// first user who was added to the db becomes an admin
+	for _, user := range []string{"admin@imagvfx.com", "artist@imagvfx.com", "producer@imagvfx.com"} {
+		err = server.AddUser(bgCtx, &forge.User{Name: user})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	for group, member := range map[string]string{"artists": "artist@imagvfx.com", "production": "producer@imagvfx.com"} {
+		err = server.AddGroup(adminCtx, &forge.Group{Name: group})
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = server.AddGroupMember(adminCtx, group, member)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = server.AddEntryType(adminCtx, "shot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"bid", "artist_note", "status"} {
+		err = server.AddDefault(adminCtx, &forge.Default{EntryType: "shot", Category: "property", Name: name, Type: "text"})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = server.AddDefault(adminCtx, &forge.Default{EntryType: "shot", Category: "property", Name: "owner", Type: "user"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = server.AddGlobal(adminCtx, "shot", "property_owner", "text", "artist_note: .owner\nstatus: .owner")
+	if err != nil {
+		t.Fatal(err)
+	}
+	testMeta := []struct {
+		name    string
+		meta    *forge.PropertyMeta
+		wantErr error
+	}{
+		{name: "bid", meta: &forge.PropertyMeta{ReadGroups: []string{"production"}, WriteGroups: []string{"production"}}},
+		{name: "artist_note", meta: &forge.PropertyMeta{WriteGroups: []string{"artists"}}},
+		{name: "status", meta: &forge.PropertyMeta{ReadGroups: []string{"not-exist"}}, wantErr: errors.New("group not found: not-exist")},
+	}
+	for _, c := range testMeta {
+		err = server.UpdateDefault(adminCtx, forge.DefaultUpdater{EntryType: "shot", Category: "property", Name: c.name, Meta: c.meta})
+		if !equalError(c.wantErr, err) {
+			t.Fatalf("update meta of %v: want err %q, got %q", c.name, errorString(c.wantErr), errorString(err))
+		}
+	}
+	for _, pth := range []string{"/show", "/show/sh0010"} {
+		err = server.AddEntry(adminCtx, pth, "shot")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = server.AddAccess(adminCtx, "/", "artists", "r")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = server.AddAccess(adminCtx, "/", "production", "rw")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = server.UpdateProperty(adminCtx, "/show/sh0010", "bid", "100")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// hidden properties cannot be seen.
+	_, err = server.GetProperty(artistCtx, "/show/sh0010", "bid")
+	want := errors.New("property not found: /show/sh0010.bid")
+	if !equalError(want, err) {
+		t.Fatalf("get hidden property: want err %q, got %q", errorString(want), errorString(err))
+	}
+	props, err := server.EntryProperties(artistCtx, "/show/sh0010")
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := make([]string, 0, len(props))
+	for _, p := range props {
+		names = append(names, p.Name)
+	}
+	sort.Strings(names)
+	if !reflect.DeepEqual(names, []string{"artist_note", "owner", "status"}) {
+		t.Fatalf("properties of artist: want [artist_note owner status], got %v", names)
+	}
+	_, err = server.GetProperty(producerCtx, "/show/sh0010", "bid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	testUpdates := []struct {
+		ctx     context.Context
+		name    string
+		value   string
+		wantErr error
+	}{
+		// write groups don't give write access to the entry.
+		{ctx: artistCtx, name: "artist_note", wantErr: errors.New("entry modification not allowed: /show/sh0010")},
+		{ctx: artistCtx, name: "status", wantErr: errors.New("entry modification not allowed: /show/sh0010")},
+		{ctx: artistCtx, name: "bid", wantErr: errors.New("property not found: /show/sh0010.bid")},
+		// others cannot modify the property even with write access.
+		{ctx: producerCtx, name: "artist_note", wantErr: errors.New("property modification not allowed: /show/sh0010.artist_note")},
+		{ctx: producerCtx, name: "bid"},
+		{ctx: producerCtx, name: "status"},
+		{ctx: adminCtx, name: "artist_note"},
+		// property owner can modify the properties with read access,
+		// as long as the owner is a member of the write groups.
+		{ctx: producerCtx, name: "owner", value: "artist@imagvfx.com"},
+		{ctx: artistCtx, name: "artist_note"},
+		{ctx: artistCtx, name: "status"},
+	}
+	for _, c := range testUpdates {
+		val := c.value
+		if val == "" {
+			val = "200"
+		}
+		err := server.UpdateProperty(c.ctx, "/show/sh0010", c.name, val)
+		if !equalError(c.wantErr, err) {
+			t.Fatalf("update %v as %v: want err %q, got %q", c.name, forge.UserNameFromContext(c.ctx), errorString(c.wantErr), errorString(err))
+		}
+	}
+	// bulk updates are checked as well, and are reverted together.
+	v := "300"
+	err = server.UpdateProperties(artistCtx, []forge.PropertyUpdater{
+		{EntryPath: "/show/sh0010", Name: "artist_note", Value: &v},
+		{EntryPath: "/show/sh0010", Name: "bid", Value: &v},
+	})
+	if !equalError(want, err) {
+		t.Fatalf("update properties with hidden property: want err %q, got %q", errorString(want), errorString(err))
+	}
+	// hidden properties cannot be matched by search.
+	testSearches := []struct {
+		ctx   context.Context
+		query string
+		want  []string
+	}{
+		{ctx: producerCtx, query: "bid=200", want: []string{"/show/sh0010"}},
+		{ctx: producerCtx, query: "200", want: []string{"/show/sh0010"}},
+		{ctx: artistCtx, query: "bid=200", want: []string{}},
+		{ctx: artistCtx, query: "bid!=100", want: []string{}},
+		{ctx: artistCtx, query: "artist_note=200", want: []string{"/show/sh0010"}},
+		{ctx: artistCtx, query: "status=200", want: []string{"/show/sh0010"}},
+	}
+	for _, c := range testSearches {
+		ents, err := server.SearchEntries(c.ctx, "/", c.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := make([]string, 0, len(ents))
+		for _, e := range ents {
+			got = append(got, e.Path)
+			if _, ok := e.Property["bid"]; ok && c.ctx == artistCtx {
+				t.Fatalf("search %q: hidden property is in the result", c.query)
+			}
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Fatalf("search %q as %v: want %v, got %v", c.query, forge.UserNameFromContext(c.ctx), c.want, got)
+		}
+	}
+	// hidden properties cannot be seen from logs.
+	_, err = server.GetLogs(artistCtx, "/show/sh0010", "property", "bid")
+	want = errors.New("log not found")
+	if !equalError(want, err) {
+		t.Fatalf("logs of hidden property: want err %q, got %q", errorString(want), errorString(err))
+	}
+	_, err = server.GetLogs(producerCtx, "/show/sh0010", "property", "bid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	logs, err := server.EntryLogs(artistCtx, "/show/sh0010")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, l := range logs {
+		if l.Name == "bid" {
+			t.Fatalf("entry logs of artist: hidden property is in the logs: %v", l)
+		}
+	}
+	// hidden properties cannot be seen through formulas.
+	err = server.AddDefault(adminCtx, &forge.Default{EntryType: "shot", Category: "property", Name: "bid_x", Type: "formula", Value: "bid * 1"})
+	want = errors.New("formula cannot refer to a property limited to read groups: bid")
+	if !equalError(want, err) {
+		t.Fatalf("formula refers hidden property: want err %q, got %q", errorString(want), errorString(err))
+	}
+	err = server.AddDefault(adminCtx, &forge.Default{EntryType: "shot", Category: "property", Name: "bid_count", Type: "formula", Value: `count("bid=200")`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, ctx := range []context.Context{artistCtx, producerCtx} {
+		p, err := server.GetProperty(ctx, "/show", "bid_count")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.Value != "0" {
+			t.Fatalf("formula searches hidden property: want 0, got %v", p.Value)
+		}
+	}
+	err = server.AddDefault(adminCtx, &forge.Default{EntryType: "shot", Category: "property", Name: "status_x", Type: "formula", Value: "status * 1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = server.UpdateDefault(adminCtx, forge.DefaultUpdater{EntryType: "shot", Category: "property", Name: "status", Meta: &forge.PropertyMeta{ReadGroups: []string{"production"}}})
+	want = errors.New("property is referred by formula shot.status_x, cannot limit it to read groups")
+	if !equalError(want, err) {
+		t.Fatalf("limit property referred by formula: want err %q, got %q", errorString(want), errorString(err))
+	}
+}
+
+// BenchmarkAccessCheck compares checking access of entries one by one,
+// with checking them at once in a listing or a search, for a non-admin user.
+func BenchmarkAccessCheck(b *testing.B) {
+	db, server, err := testDB(b)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer db.Close()
+	bgCtx := context.Background()
+	adminCtx := forge.ContextWithUserName(bgCtx, "admin@imagvfx.com")
+	artistCtx := forge.ContextWithUserName(bgCtx, "artist@imagvfx.com")
+	for _, user := range []string{"admin@imagvfx.com", "artist@imagvfx.com"} {
+		err = server.AddUser(bgCtx, &forge.User{Name: user})
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+	for _, group := range []string{"artists", "comp", "fx", "lighting"} {
+		err = server.AddGroup(adminCtx, &forge.Group{Name: group})
+		if err != nil {
+			b.Fatal(err)
+		}
+		err = server.AddGroupMember(adminCtx, group, "artist@imagvfx.com")
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+	err = server.AddEntryType(adminCtx, "part")
+	if err != nil {
+		b.Fatal(err)
+	}
+	paths := make([]string, 0)
+	for _, pth := range []string{"/show", "/show/shot", "/show/shot/seq"} {
+		err = server.AddEntry(adminCtx, pth, "part")
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+	for i := range 200 {
+		pth := fmt.Sprintf("/show/shot/seq/shot%04d", i)
+		err = server.AddEntry(adminCtx, pth, "part")
+		if err != nil {
+			b.Fatal(err)
+		}
+		paths = append(paths, pth)
+	}
+	err = server.AddAccess(adminCtx, "/show", "artists", "r")
+	if err != nil {
+		b.Fatal(err)
+	}
+	err = server.AddAccess(adminCtx, "/show/shot", "comp", "rw")
+	if err != nil {
+		b.Fatal(err)
+	}
+	err = server.AddAccess(adminCtx, "/show/shot/seq", "fx", "r")
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Run("each", func(b *testing.B) {
+		for range b.N {
+			for _, pth := range paths {
+				_, err := server.GetEntry(artistCtx, pth)
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+	b.Run("sub", func(b *testing.B) {
+		for range b.N {
+			ents, err := server.SubEntries(artistCtx, "/show/shot/seq")
+			if err != nil {
+				b.Fatal(err)
+			}
+			if len(ents) != len(paths) {
+				b.Fatalf("want %v entries, got %v", len(paths), len(ents))
+			}
+		}
+	})
+	b.Run("search", func(b *testing.B) {
+		for range b.N {
+			ents, err := server.SearchEntries(artistCtx, "/show", "type=part")
+			if err != nil {
+				b.Fatal(err)
+			}
+			if len(ents) != len(paths)+2 {
+				b.Fatalf("want %v entries, got %v", len(paths)+2, len(ents))
+			}
+		}
+	})
+}
+
+func TestInheritAccess(t *testing.T) {
+	db, server, err := testDB(t)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	bgCtx := context.Background()
+	adminCtx := forge.ContextWithUserName(bgCtx, "admin@imagvfx.com")
+	artistCtx := forge.ContextWithUserName(bgCtx, "artist@imagvfx.com")
+	// first user who was added to the db becomes an admin
+	for _, user := range []string{"admin@imagvfx.com", "artist@imagvfx.com"} {
+		err = server.AddUser(bgCtx, &forge.User{Name: user})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = server.AddGroup(adminCtx, &forge.Group{Name: "production"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, typ := range []string{"show", "shot"} {
+		err = server.AddEntryType(adminCtx, typ)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = server.AddDefault(adminCtx, &forge.Default{EntryType: typ, Category: "property", Name: "client", Type: "text"})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	// client of a show is only visible to production.
+	err = server.UpdateDefault(adminCtx, forge.DefaultUpdater{EntryType: "show", Category: "property", Name: "client", Meta: &forge.PropertyMeta{ReadGroups: []string{"production"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = server.UpdateDefault(adminCtx, forge.DefaultUpdater{EntryType: "shot", Category: "property", Name: "client", Inheritable: ptr(true)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range []struct{ path, typ string }{{"/show", "show"}, {"/show/secret", "shot"}, {"/show/secret/open", "shot"}} {
+		err = server.AddEntry(adminCtx, e.path, e.typ)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	for pth, client := range map[string]string{"/show": "netflix", "/show/secret": "hbo"} {
+		err = server.UpdateProperty(adminCtx, pth, "client", client)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	for pth, mode := range map[string]string{"/show": "r", "/show/secret": "none", "/show/secret/open": "r"} {
+		err = server.AddAccess(adminCtx, pth, "artist@imagvfx.com", mode)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	check := func(ctx context.Context, want, from string) {
+		t.Helper()
+		p, err := server.GetProperty(ctx, "/show/secret/open", "client")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.Eval != want || p.InheritedFrom != from {
+			t.Fatalf("%v: want %q from %q, got %q from %q", forge.UserNameFromContext(ctx), want, from, p.Eval, p.InheritedFrom)
+		}
+	}
+	check(adminCtx, "hbo", "/show/secret")
+	// ancestors those the user cannot read, or their properties, are skipped.
+	check(artistCtx, "", "")
+	err = server.UpdateDefault(adminCtx, forge.DefaultUpdater{EntryType: "show", Category: "property", Name: "client", Meta: &forge.PropertyMeta{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	check(artistCtx, "netflix", "/show")
+}
+
+func TestAddEntriesAccess(t *testing.T) {
+	db, server, err := testDB(t)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	bgCtx := context.Background()
+	adminCtx := forge.ContextWithUserName(bgCtx, "admin@imagvfx.com")
+	artistCtx := forge.ContextWithUserName(bgCtx, "artist@imagvfx.com")
+	// first user who was added to the db becomes an admin
+	for _, user := range []string{"admin@imagvfx.com", "artist@imagvfx.com"} {
+		err = server.AddUser(bgCtx, &forge.User{Name: user})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, typ := range []string{"show", "shot"} {
+		err = server.AddEntryType(adminCtx, typ)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, e := range []struct{ path, typ string }{{"/show", "show"}, {"/show/sh0010", "shot"}, {"/show/sh0020", "shot"}} {
+		err = server.AddEntry(adminCtx, e.path, e.typ)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	for pth, mode := range map[string]string{"/show": "rw", "/show/sh0020": "none"} {
+		err = server.AddAccess(adminCtx, pth, "artist@imagvfx.com", mode)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	adds, exists, err := server.DryRunAddEntries(artistCtx, "/show", "sh0010,sh0030", "shot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(adds, []string{"/show/sh0030"}) || !reflect.DeepEqual(exists, []string{"/show/sh0010"}) {
+		t.Fatalf("dry run add entries: want [/show/sh0030] to add and [/show/sh0010] exist, got %v and %v", adds, exists)
+	}
+	// entries hidden from the user shouldn't be revealed by their paths.
+	wantErr := errors.New("cannot add entries: conflict with existing entries")
+	_, _, err = server.DryRunAddEntries(artistCtx, "/show", "sh[0020-0030:10]", "shot")
+	if !equalError(wantErr, err) {
+		t.Fatalf("dry run add entries: want err %q, got %q", errorString(wantErr), errorString(err))
+	}
+	_, err = server.AddEntries(artistCtx, "/show", "sh[0020-0030:10]", "shot")
+	if !equalError(wantErr, err) {
+		t.Fatalf("add entries: want err %q, got %q", errorString(wantErr), errorString(err))
+	}
+	_, exists, err = server.DryRunAddEntries(adminCtx, "/show", "sh[0020-0030:10]", "shot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(exists, []string{"/show/sh0020"}) {
+		t.Fatalf("dry run add entries: admin: want [/show/sh0020] exist, got %v", exists)
+	}
+}
+
+func TestAssigneeAccess(t *testing.T) {
+	db, server, err := testDB(t)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	bgCtx := context.Background()
+	adminCtx := forge.ContextWithUserName(bgCtx, "admin@imagvfx.com")
+	// first user who was added to the db becomes an admin
+	for _, user := range []string{"admin@imagvfx.com", "artist@imagvfx.com"} {
+		err = server.AddUser(bgCtx, &forge.User{Name: user})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = server.AddGroup(adminCtx, &forge.Group{Name: "artists"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = server.AddEntryType(adminCtx, "shot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = server.AddDefault(adminCtx, &forge.Default{EntryType: "shot", Category: "property", Name: "assignee", Type: "users"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = server.AddEntry(adminCtx, "/sh0010", "shot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = server.UpdateProperty(adminCtx, "/sh0010", "assignee", "+artists\n+artist@imagvfx.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// assigned users can modify the entry.
+	a, err := server.GetAccess(adminCtx, "/sh0010", "artist@imagvfx.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Value != "rw" {
+		t.Fatalf("want rw access for the assigned user, got %v", a.Value)
+	}
+	// but members of assigned groups cannot.
+	_, err = server.GetAccess(adminCtx, "/sh0010", "artists")
+	if !equalError(errors.New("access control not found"), err) {
+		t.Fatalf("want no access for the assigned group, got err %q", errorString(err))
+	}
+}
@@ -0,0 +1,628 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/imagvfx/forge"
+	"github.com/imagvfx/forge/service/sqlite"
+)
+
+func TestDeleteGroup(t *testing.T) {
+	db, server, err := testDB(t)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	bgCtx := context.Background()
+	adminCtx := forge.ContextWithUserName(bgCtx, "admin@imagvfx.com")
+	// first user who was added to the db becomes an admin
+	for _, user := range []string{"admin@imagvfx.com", "artist@imagvfx.com"} {
+		err = server.AddUser(bgCtx, &forge.User{Name: user})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, group := range []string{"2d", "comp", "unused"} {
+		err = server.AddGroup(adminCtx, &forge.Group{Name: group})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, m := range []forge.Member{
+		{Group: "2d", Member: "comp"},
+		{Group: "comp", Member: "artist@imagvfx.com"},
+	} {
+		err = server.AddGroupMember(adminCtx, m.Group, m.Member)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, typ := range []string{"shot", "part"} {
+		err = server.AddEntryType(adminCtx, typ)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, d := range []struct {
+		ctg string
+		k   string
+		t   string
+		v   string
+	}{
+		{ctg: "property", k: "crew", t: "users", v: ""},
+		{ctg: "property", k: "note", t: "text", v: ""},
+		{ctg: "property", k: "bid", t: "text", v: ""},
+		{ctg: "access", k: "comp", t: "group", v: "r"},
+	} {
+		err = server.AddDefault(adminCtx, &forge.Default{EntryType: "shot", Category: d.ctg, Name: d.k, Type: d.t, Value: d.v})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = server.UpdateDefault(adminCtx, forge.DefaultUpdater{EntryType: "shot", Category: "property", Name: "note", Meta: &forge.PropertyMeta{ReadGroups: []string{"2d"}, WriteGroups: []string{"comp"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = server.UpdateDefault(adminCtx, forge.DefaultUpdater{EntryType: "shot", Category: "property", Name: "bid", Meta: &forge.PropertyMeta{ReadGroups: []string{"comp"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = server.AddEntry(adminCtx, "/show", "shot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = server.AddEntry(adminCtx, "/lib", "part")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = server.AddAccess(adminCtx, "/lib", "comp", "rw")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = server.UpdateProperty(adminCtx, "/show", "crew", "+comp\n+artist@imagvfx.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	testDeletes := []struct {
+		group    string
+		cascade  bool
+		wantRefs *forge.GroupReferences
+		wantErr  error
+	}{
+		{group: "admin", wantErr: errors.New("delete 'admin' or 'everyone[@host]' group is not supported: admin")},
+		{group: "everyone@imagvfx.com", wantErr: errors.New("delete 'admin' or 'everyone[@host]' group is not supported: everyone@imagvfx.com")},
+		{group: "not-exist", wantErr: errors.New("group not found: not-exist")},
+		{
+			group:   "comp",
+			wantErr: errors.New("group is referenced by 2 accesses, 1 default accesses, 1 parent groups, 1 properties, 2 default properties, delete it with cascade to remove them: comp"),
+		},
+		{
+			group:   "comp",
+			cascade: true,
+			wantRefs: &forge.GroupReferences{
+				Group:             "comp",
+				Accesses:          []string{"/lib", "/show"},
+				DefaultAccesses:   []string{"shot"},
+				Parents:           []string{"2d"},
+				Properties:        []string{"/show.crew"},
+				DefaultProperties: []string{"shot.bid", "shot.note"},
+			},
+		},
+		{
+			group: "unused",
+			wantRefs: &forge.GroupReferences{
+				Group:             "unused",
+				Accesses:          []string{},
+				DefaultAccesses:   []string{},
+				Parents:           []string{},
+				Properties:        []string{},
+				DefaultProperties: []string{},
+			},
+		},
+	}
+	for _, c := range testDeletes {
+		refs, err := server.DeleteGroup(adminCtx, c.group, c.cascade)
+		if !equalError(c.wantErr, err) {
+			t.Fatalf("delete group %v: want err %q, got %q", c.group, errorString(c.wantErr), errorString(err))
+		}
+		if !reflect.DeepEqual(refs, c.wantRefs) {
+			t.Fatalf("delete group %v: want refs %v, got %v", c.group, c.wantRefs, refs)
+		}
+	}
+	_, err = server.GetGroup(adminCtx, "comp")
+	want := errors.New("group not exist: comp")
+	if !equalError(want, err) {
+		t.Fatalf("get deleted group: want err %q, got %q", errorString(want), errorString(err))
+	}
+	_, err = server.GetAccess(adminCtx, "/lib", "comp")
+	if err == nil {
+		t.Fatalf("access of deleted group shouldn't exist")
+	}
+	crew, err := server.GetProperty(adminCtx, "/show", "crew")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if crew.Value != "artist@imagvfx.com" {
+		t.Fatalf("crew: want %q, got %q", "artist@imagvfx.com", crew.Value)
+	}
+	defs, err := server.Defaults(adminCtx, "shot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// properties limited only to the deleted group are limited to admins, instead of being open to everyone.
+	wantGroups := map[string][2][]string{
+		"note": {{"2d"}, {"admin"}},
+		"bid":  {{"admin"}, nil},
+	}
+	for _, d := range defs {
+		want, ok := wantGroups[d.Name]
+		if d.Category != "property" || !ok {
+			continue
+		}
+		if !reflect.DeepEqual(d.ReadGroups, want[0]) || !reflect.DeepEqual(d.WriteGroups, want[1]) {
+			t.Fatalf("groups of %v: want %v and %v, got %v and %v", d.Name, want[0], want[1], d.ReadGroups, d.WriteGroups)
+		}
+	}
+	// the members of the deleted group aren't members of the parent group anymore.
+	mems, err := server.ExpandedGroupMembers(adminCtx, "2d")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mems) != 0 {
+		t.Fatalf("members of 2d: want none, got %v", len(mems))
+	}
+}
+
+func TestRenameAndMergeUsers(t *testing.T) {
+	db, server, err := testDB(t)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	bgCtx := context.Background()
+	adminCtx := forge.ContextWithUserName(bgCtx, "admin@imagvfx.com")
+	// first user who was added to the db becomes an admin
+	for _, user := range []string{"admin@imagvfx.com", "artist@imagvfx.com", "artist@imagvfx.io"} {
+		err = server.AddUser(bgCtx, &forge.User{Name: user})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = server.AddGroup(adminCtx, &forge.Group{Name: "comp"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = server.AddGroupMember(adminCtx, "comp", "artist@imagvfx.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = server.AddEntryType(adminCtx, "shot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, d := range []struct {
+		k string
+		t string
+	}{
+		{k: "lead", t: "user"},
+		{k: "crew", t: "users"},
+		{k: "note", t: "text"},
+	} {
+		err = server.AddDefault(adminCtx, &forge.Default{EntryType: "shot", Category: "property", Name: d.k, Type: d.t})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, pth := range []string{"/show", "/lib"} {
+		err = server.AddEntry(adminCtx, pth, "shot")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, a := range []struct {
+		path     string
+		accessor string
+		mode     string
+	}{
+		{path: "/show", accessor: "artist@imagvfx.com", mode: "rw"},
+		{path: "/lib", accessor: "artist@imagvfx.com", mode: "r"},
+		{path: "/show", accessor: "artist@imagvfx.io", mode: "r"},
+	} {
+		err = server.AddAccess(adminCtx, a.path, a.accessor, a.mode)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = server.UpdateProperty(adminCtx, "/show", "lead", "artist@imagvfx.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = server.UpdateProperty(adminCtx, "/show", "crew", "+artist@imagvfx.com\n+admin@imagvfx.com\n+artist@imagvfx.io")
+	if err != nil {
+		t.Fatal(err)
+	}
+	artistCtx := forge.ContextWithUserName(bgCtx, "artist@imagvfx.com")
+	err = server.UpdateProperty(artistCtx, "/show", "note", "hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for user, typ := range map[string]string{"artist@imagvfx.com": "shot", "artist@imagvfx.io": "part"} {
+		ctx := forge.ContextWithUserName(bgCtx, user)
+		err = server.UpdateUserSetting(ctx, user, "entry_page_search_entry_type", typ)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	testRenames := []struct {
+		name    string
+		newName string
+		wantErr error
+	}{
+		{name: "artist@imagvfx.com", newName: "admin@imagvfx.com", wantErr: errors.New("accessor already exists: admin@imagvfx.com")},
+		{name: "artist@imagvfx.com", newName: "comp", wantErr: errors.New("username should be '{user}@{domain}' form: comp")},
+		{name: "comp", newName: "comp@imagvfx.com", wantErr: errors.New("user not found")},
+		{name: "artist@imagvfx.com", newName: "artist@imagvfx.net"},
+	}
+	for _, c := range testRenames {
+		err := server.RenameUser(adminCtx, c.name, c.newName)
+		if !equalError(c.wantErr, err) {
+			t.Fatalf("rename %v to %v: want err %q, got %q", c.name, c.newName, errorString(c.wantErr), errorString(err))
+		}
+	}
+	// the renamed user keeps everything.
+	lead, err := server.GetProperty(adminCtx, "/show", "lead")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lead.Value != "artist@imagvfx.net" {
+		t.Fatalf("lead after rename: want %q, got %q", "artist@imagvfx.net", lead.Value)
+	}
+	acc, err := server.GetAccess(adminCtx, "/show", "artist@imagvfx.net")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if acc.Value != "rw" {
+		t.Fatalf("access after rename: want rw, got %v", acc.Value)
+	}
+	logs, err := server.GetLogs(adminCtx, "/show", "property", "note")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(logs) == 0 || logs[len(logs)-1].User != "artist@imagvfx.net" {
+		t.Fatalf("logs of the renamed user should have the new name")
+	}
+	_, err = server.GetGroup(adminCtx, "everyone@imagvfx.net")
+	if err != nil {
+		t.Fatal(err)
+	}
+	testMerges := []struct {
+		from    string
+		to      string
+		wantErr error
+	}{
+		{from: "admin@imagvfx.com", to: "artist@imagvfx.io", wantErr: errors.New("cannot merge the context user into another user: admin@imagvfx.com")},
+		{from: "artist@imagvfx.io", to: "artist@imagvfx.io", wantErr: errors.New("cannot merge a user into the same user: artist@imagvfx.io")},
+		{from: "artist@imagvfx.net", to: "not-exist@imagvfx.com", wantErr: errors.New("user not found")},
+		{from: "artist@imagvfx.net", to: "artist@imagvfx.io"},
+	}
+	for _, c := range testMerges {
+		err := server.MergeUsers(adminCtx, c.from, c.to)
+		if !equalError(c.wantErr, err) {
+			t.Fatalf("merge %v into %v: want err %q, got %q", c.from, c.to, errorString(c.wantErr), errorString(err))
+		}
+	}
+	_, err = server.GetUser(adminCtx, "artist@imagvfx.net")
+	want := errors.New("user not found")
+	if !equalError(want, err) {
+		t.Fatalf("get merged user: want err %q, got %q", errorString(want), errorString(err))
+	}
+	testProps := []struct {
+		name  string
+		value string
+	}{
+		{name: "lead", value: "artist@imagvfx.io"},
+		// the merged user is removed from the list, as the target is in it already.
+		{name: "crew", value: "artist@imagvfx.io\nadmin@imagvfx.com"},
+	}
+	for _, c := range testProps {
+		p, err := server.GetProperty(adminCtx, "/show", c.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.Value != c.value {
+			t.Fatalf("%v after merge: want %q, got %q", c.name, c.value, p.Value)
+		}
+	}
+	testAccess := []struct {
+		path string
+		mode string
+	}{
+		// the target's own access is kept.
+		{path: "/show", mode: "r"},
+		{path: "/lib", mode: "r"},
+	}
+	for _, c := range testAccess {
+		acc, err := server.GetAccess(adminCtx, c.path, "artist@imagvfx.io")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if acc.Value != c.mode {
+			t.Fatalf("access on %v after merge: want %v, got %v", c.path, c.mode, acc.Value)
+		}
+	}
+	mems, err := server.GroupMembers(adminCtx, "comp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make([]string, 0)
+	for _, m := range mems {
+		got = append(got, m.Member)
+	}
+	sort.Strings(got)
+	if !reflect.DeepEqual(got, []string{"artist@imagvfx.io"}) {
+		t.Fatalf("members of comp after merge: want [artist@imagvfx.io], got %v", got)
+	}
+	setting, err := server.GetUserSetting(adminCtx, "artist@imagvfx.io")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if setting.EntryPageSearchEntryType != "part" {
+		t.Fatalf("setting after merge: want part, got %v", setting.EntryPageSearchEntryType)
+	}
+	logs, err = server.GetLogs(adminCtx, "/show", "property", "note")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(logs) == 0 || logs[len(logs)-1].User != "artist@imagvfx.io" {
+		t.Fatalf("logs of the merged user should have the target name")
+	}
+	_, err = server.UserMergeLogs(forge.ContextWithUserName(bgCtx, "artist@imagvfx.io"), "artist@imagvfx.io")
+	want = errors.New("only admins can see logs of user merges")
+	if !equalError(want, err) {
+		t.Fatalf("user merge logs by non admin: want err %q, got %q", errorString(want), errorString(err))
+	}
+	logs, err = server.UserMergeLogs(adminCtx, "artist@imagvfx.io")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(logs) != 1 || logs[0].Value != "artist@imagvfx.net" || logs[0].User != "admin@imagvfx.com" {
+		t.Fatalf("user merge logs: want a log of merging artist@imagvfx.net by admin@imagvfx.com, got %v", logs)
+	}
+}
+
+func TestGroupSync(t *testing.T) {
+	db, _, err := testDB(t)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	cfg := &forge.Config{
+		Forge: &forge.ForgeConfig{
+			GroupSync: forge.GroupSyncConfig{
+				Claims: []string{"groups"},
+				Groups: map[string]string{
+					"vfx-comp":    "comp",
+					"vfx-light":   "light",
+					"vfx-missing": "missing",
+				},
+			},
+		},
+	}
+	server := forge.NewServer(sqlite.NewService(db), cfg)
+	bgCtx := context.Background()
+	adminCtx := forge.ContextWithUserName(bgCtx, "admin@imagvfx.com")
+	artistCtx := forge.ContextWithUserName(bgCtx, "artist@imagvfx.com")
+	// first user who was added to the db becomes an admin
+	for _, user := range []string{"admin@imagvfx.com", "artist@imagvfx.com", "other@imagvfx.com"} {
+		err = server.AddUser(bgCtx, &forge.User{Name: user})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, group := range []string{"comp", "light", "manual"} {
+		err = server.AddGroup(adminCtx, &forge.Group{Name: group})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, group := range []string{"light", "manual"} {
+		err = server.AddGroupMember(adminCtx, group, "artist@imagvfx.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	otherCtx := forge.ContextWithUserName(bgCtx, "other@imagvfx.com")
+	err = server.SyncGroupMembers(otherCtx, "artist@imagvfx.com", []string{"vfx-comp"})
+	if !errors.As(err, new(*forge.UnauthorizedError)) {
+		t.Fatalf("sync of another user: want unauthorized error, got %v", err)
+	}
+	// sync twice, the second one shouldn't change anything.
+	for range 2 {
+		err = server.SyncGroupMembers(artistCtx, "artist@imagvfx.com", []string{"vfx-comp", "vfx-missing", "unmapped"})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := map[string]bool{"comp": true, "light": false, "manual": true}
+	for group, in := range want {
+		members, err := server.GroupMembers(adminCtx, group)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := false
+		for _, m := range members {
+			if m.Member == "artist@imagvfx.com" {
+				got = true
+			}
+		}
+		if got != in {
+			t.Fatalf("artist in %v: want %v, got %v", group, in, got)
+		}
+	}
+	_, err = server.GroupMemberLogs(artistCtx, "comp")
+	if !errors.As(err, new(*forge.UnauthorizedError)) {
+		t.Fatalf("logs of group members: want unauthorized error for non admin, got %v", err)
+	}
+	for group, action := range map[string]string{"comp": "add", "light": "delete", "manual": ""} {
+		logs, err := server.GroupMemberLogs(adminCtx, group)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := make([]string, 0)
+		for _, l := range logs {
+			got = append(got, l.Action+" "+l.Type+" "+l.Value+" by "+l.User)
+		}
+		want := []string{}
+		if action != "" {
+			want = []string{action + " sync artist@imagvfx.com by artist@imagvfx.com"}
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("logs of %v: want %v, got %v", group, want, got)
+		}
+	}
+	op := &OIDCPayload{Claims: map[string]any{
+		"groups": []any{"vfx-comp", 1, "vfx-light"},
+		"role":   "vfx-comp",
+	}}
+	for claim, want := range map[string][]string{
+		"groups": {"vfx-comp", "vfx-light"},
+		"role":   {"vfx-comp"},
+		"none":   {},
+	} {
+		got := op.ClaimValues(claim)
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("values of claim %v: want %v, got %v", claim, want, got)
+		}
+	}
+}
+
+func TestSignUpUser(t *testing.T) {
+	db, _, err := testDB(t)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	signup := forge.SignupConfig{
+		AllowedDomains:  []string{"imagvfx.com"},
+		RequireApproval: true,
+		DefaultGroups: []forge.DefaultGroupRule{
+			{Groups: []string{"artist"}},
+			{Domain: "imagvfx.com", Groups: []string{"staff", "missing"}},
+			{Domain: "other.com", Groups: []string{"guest"}},
+		},
+	}
+	server := forge.NewServer(sqlite.NewService(db), &forge.Config{Forge: &forge.ForgeConfig{Signup: signup}})
+	bgCtx := context.Background()
+	adminCtx := forge.ContextWithUserName(bgCtx, "admin@imagvfx.com")
+	// the first user becomes an admin, it shouldn't wait for approval.
+	err = server.SignUpUser(adminCtx, &forge.User{Name: "admin@imagvfx.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, group := range []string{"artist", "staff", "guest"} {
+		err = server.AddGroup(adminCtx, &forge.Group{Name: group})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	outsiderCtx := forge.ContextWithUserName(bgCtx, "outsider@gmail.com")
+	err = server.SignUpUser(outsiderCtx, &forge.User{Name: "outsider@gmail.com"})
+	if !errors.As(err, new(*forge.UnauthorizedError)) {
+		t.Fatalf("sign up of disallowed domain: want unauthorized error, got %v", err)
+	}
+	for _, user := range []string{"newbie@imagvfx.com", "spam@imagvfx.com"} {
+		ctx := forge.ContextWithUserName(bgCtx, user)
+		err = server.SignUpUser(ctx, &forge.User{Name: user})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	userNames := func(users []*forge.User, err error) []string {
+		if err != nil {
+			t.Fatal(err)
+		}
+		names := make([]string, 0)
+		for _, u := range users {
+			names = append(names, u.Name)
+		}
+		return names
+	}
+	got := userNames(server.PendingUsers(adminCtx))
+	want := []string{"newbie@imagvfx.com", "spam@imagvfx.com"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("pending users: want %v, got %v", want, got)
+	}
+	got = userNames(server.DisabledUsers(adminCtx))
+	if len(got) != 0 {
+		t.Fatalf("disabled users shouldn't have pending users, got %v", got)
+	}
+	newbieCtx := forge.ContextWithUserName(bgCtx, "newbie@imagvfx.com")
+	_, err = server.GetEntry(newbieCtx, "/")
+	if err == nil {
+		t.Fatalf("pending user shouldn't be able to access entries")
+	}
+	err = server.UpdateUserDisabled(adminCtx, "newbie@imagvfx.com", false)
+	want1 := "user is pending, approve or reject the user instead: newbie@imagvfx.com"
+	if !equalError(err, errors.New(want1)) {
+		t.Fatalf("enable pending user: want %v, got %v", want1, err)
+	}
+	err = server.ApproveUser(newbieCtx, "newbie@imagvfx.com")
+	if !errors.As(err, new(*forge.UnauthorizedError)) {
+		t.Fatalf("approve by non admin: want unauthorized error, got %v", err)
+	}
+	err = server.ApproveUser(adminCtx, "newbie@imagvfx.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = server.ApproveUser(adminCtx, "newbie@imagvfx.com")
+	want1 = "user is not pending: newbie@imagvfx.com"
+	if !equalError(err, errors.New(want1)) {
+		t.Fatalf("approve again: want %v, got %v", want1, err)
+	}
+	err = server.RejectUser(adminCtx, "spam@imagvfx.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got = userNames(server.ActiveUsers(adminCtx))
+	want = []string{"admin@imagvfx.com", "newbie@imagvfx.com"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("active users: want %v, got %v", want, got)
+	}
+	got = userNames(server.DisabledUsers(adminCtx))
+	want = []string{"spam@imagvfx.com"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("disabled users: want %v, got %v", want, got)
+	}
+	got = userNames(server.PendingUsers(adminCtx))
+	if len(got) != 0 {
+		t.Fatalf("pending users should be empty, got %v", got)
+	}
+	// without approval, a new user is added to the default groups right away.
+	signup.RequireApproval = false
+	server = forge.NewServer(sqlite.NewService(db), &forge.Config{Forge: &forge.ForgeConfig{Signup: signup}})
+	err = server.SignUpUser(bgCtx, &forge.User{Name: "direct@imagvfx.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, user := range []string{"newbie@imagvfx.com", "direct@imagvfx.com"} {
+		for group, in := range map[string]bool{"artist": true, "staff": true, "guest": false} {
+			members, err := server.GroupMembers(adminCtx, group)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := false
+			for _, m := range members {
+				if m.Member == user {
+					got = true
+				}
+			}
+			if got != in {
+				t.Fatalf("%v in %v: want %v, got %v", user, group, in, got)
+			}
+		}
+	}
+}
@@ -10,7 +10,7 @@ import (
 )
 
 // testDB returns a sql db and a server for a test, caller is responsible for close when the process ends.
-func testDB(t *testing.T) (*sql.DB, *forge.Server, error) {
+func testDB(t testing.TB) (*sql.DB, *forge.Server, error) {
 	tempDir := t.TempDir()
 	tempDB := filepath.Join(tempDir, "temp.db")
 	db, err := sqlite.Open(tempDB)
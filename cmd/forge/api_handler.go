@@ -49,8 +49,15 @@ func (h *apiHandler) Handler(handleFunc func(ctx context.Context, w http.Respons
 				}
 				session = s
 			}
-			user := session["user"]
-			ctx := forge.ContextWithUserName(r.Context(), user)
+			ctx, err := sessionContext(r.Context(), h.server, session)
+			if err != nil {
+				revokeImpersonation(w, r, session)
+				return nil, err
+			}
+			imp := forge.ImpersonationFromContext(ctx)
+			if imp != nil {
+				log.Printf("%v as %v: %v", imp.Admin, forge.UserNameFromContext(ctx), r.URL.Path)
+			}
 			return handleFunc(ctx, w, r)
 		}()
 		h.WriteResponse(w, msg, err)
@@ -63,7 +70,12 @@ func (h *apiHandler) WriteResponse(w http.ResponseWriter, m any, e error) {
 	if e != nil {
 		errStr = e.Error()
 	}
-	resp, _ := json.Marshal(forge.APIResponse{Msg: m, Err: errStr})
+	movedTo := ""
+	var moved *forge.MovedError
+	if errors.As(e, &moved) {
+		movedTo = moved.NewPath
+	}
+	resp, _ := json.Marshal(forge.APIResponse{Msg: m, Err: errStr, MovedTo: movedTo})
 	_, err := w.Write(resp)
 	if err != nil {
 		log.Print(err)
@@ -105,27 +117,120 @@ func (h *apiHandler) handleDeleteEntryType(ctx context.Context, w http.ResponseW
 	return nil, err
 }
 
-func (h *apiHandler) handleAddDefault(ctx context.Context, w http.ResponseWriter, r *http.Request) (any, error) {
+func (h *apiHandler) handleGetDefaults(ctx context.Context, w http.ResponseWriter, r *http.Request) (any, error) {
 	entType := r.FormValue("entry_type")
-	ctg := r.FormValue("category")
-	name := r.FormValue("name")
-	typ := r.FormValue("type")
-	value := r.FormValue("value")
-	err := h.server.AddDefault(ctx, entType, ctg, name, typ, value)
+	return h.server.Defaults(ctx, entType)
+}
+
+func (h *apiHandler) handleAddDefault(ctx context.Context, w http.ResponseWriter, r *http.Request) (any, error) {
+	d := &forge.Default{
+		EntryType:  r.FormValue("entry_type"),
+		Category:   r.FormValue("category"),
+		Name:       r.FormValue("name"),
+		Type:       r.FormValue("type"),
+		Value:      r.FormValue("value"),
+		Constraint: r.FormValue("constraint"),
+	}
+	meta, inheritable, err := defaultPropertyMeta(r)
+	if err != nil {
+		return nil, err
+	}
+	if meta != nil {
+		d.PropertyMeta = *meta
+	}
+	if inheritable != nil {
+		d.Inheritable = *inheritable
+	}
+	err = h.server.AddDefault(ctx, d)
 	return nil, err
 }
 
 func (h *apiHandler) handleUpdateDefault(ctx context.Context, w http.ResponseWriter, r *http.Request) (any, error) {
-	entType := r.FormValue("entry_type")
-	ctg := r.FormValue("category")
-	name := r.FormValue("name")
 	newName := r.FormValue("new_name")
 	typ := r.FormValue("type")
 	value := r.FormValue("value")
-	err := h.server.UpdateDefault(ctx, entType, ctg, name, &newName, &typ, &value)
+	upd := forge.DefaultUpdater{
+		EntryType: r.FormValue("entry_type"),
+		Category:  r.FormValue("category"),
+		Name:      r.FormValue("name"),
+		NewName:   &newName,
+		Type:      &typ,
+		Value:     &value,
+	}
+	if r.Form.Has("constraint") {
+		c := r.FormValue("constraint")
+		upd.Constraint = &c
+	}
+	meta, inheritable, err := defaultPropertyMeta(r)
+	if err != nil {
+		return nil, err
+	}
+	upd.Meta = meta
+	upd.Inheritable = inheritable
+	err = h.server.UpdateDefault(ctx, upd)
 	return nil, err
 }
 
+// defaultPropertyMeta parses meta and inheritable of a property default from the request.
+// They are nil when the request doesn't have them.
+func defaultPropertyMeta(r *http.Request) (*forge.PropertyMeta, *bool, error) {
+	var meta *forge.PropertyMeta
+	if r.Form.Has("label") {
+		// an unchecked read_only checkbox doesn't send the field.
+		readOnly := false
+		if v := r.FormValue("read_only"); v != "" {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return nil, nil, fmt.Errorf("invalid read_only value: %v", v)
+			}
+			readOnly = b
+		}
+		order := 0
+		if v := strings.TrimSpace(r.FormValue("order")); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return nil, nil, fmt.Errorf("order should be an integer: %v", v)
+			}
+			order = n
+		}
+		// groups are separated by comma.
+		groups := func(v string) []string {
+			gs := make([]string, 0)
+			for _, g := range strings.Split(v, ",") {
+				g = strings.TrimSpace(g)
+				if g == "" {
+					continue
+				}
+				gs = append(gs, g)
+			}
+			return gs
+		}
+		meta = &forge.PropertyMeta{
+			Label:       r.FormValue("label"),
+			Description: r.FormValue("description"),
+			Section:     r.FormValue("section"),
+			Order:       order,
+			ReadOnly:    readOnly,
+			ReadGroups:  groups(r.FormValue("read_groups")),
+			WriteGroups: groups(r.FormValue("write_groups")),
+		}
+	}
+	var inheritable *bool
+	if r.Form.Has("inheritable") || r.Form.Has("label") {
+		// an unchecked inheritable checkbox of a property form doesn't send the field.
+		inherit := false
+		if v := r.FormValue("inheritable"); v != "" {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return nil, nil, fmt.Errorf("invalid inheritable value: %v", v)
+			}
+			inherit = b
+		}
+		inheritable = &inherit
+	}
+	return meta, inheritable, nil
+}
+
 func (h *apiHandler) handleDeleteDefault(ctx context.Context, w http.ResponseWriter, r *http.Request) (any, error) {
 	entType := r.FormValue("entry_type")
 	ctg := r.FormValue("category")
@@ -164,18 +269,150 @@ func (h *apiHandler) handleDeleteGlobal(ctx context.Context, w http.ResponseWrit
 	return nil, err
 }
 
+// entryPath returns the entry path of the request, from "path" or "id" form-value.
+// The id is the entry's stable id that follows the entry when it's renamed.
+func (h *apiHandler) entryPath(ctx context.Context, r *http.Request) (string, error) {
+	id := r.FormValue("id")
+	if id == "" {
+		return r.FormValue("path"), nil
+	}
+	return h.entryPathByID(ctx, id)
+}
+
+// entryPaths returns the entry paths of the request, from "path" and/or "id" form-values.
+func (h *apiHandler) entryPaths(ctx context.Context, r *http.Request) ([]string, error) {
+	r.FormValue("") // To parse multipart form.
+	paths := r.PostForm["path"]
+	for _, id := range r.PostForm["id"] {
+		pth, err := h.entryPathByID(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, pth)
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("path not defined")
+	}
+	return paths, nil
+}
+
+func (h *apiHandler) entryPathByID(ctx context.Context, id string) (string, error) {
+	n, err := strconv.Atoi(id)
+	if err != nil {
+		return "", fmt.Errorf("invalid entry id: %v", id)
+	}
+	ent, err := h.server.GetEntryByID(ctx, n)
+	if err != nil {
+		return "", err
+	}
+	return ent.Path, nil
+}
+
 func (h *apiHandler) handleCountAllSubEntries(ctx context.Context, w http.ResponseWriter, r *http.Request) (any, error) {
-	path := r.FormValue("path")
+	path, err := h.entryPath(ctx, r)
+	if err != nil {
+		return nil, err
+	}
 	return h.server.CountAllSubEntries(ctx, path)
 }
 
+func (h *apiHandler) handleFindReferences(ctx context.Context, w http.ResponseWriter, r *http.Request) (any, error) {
+	path, err := h.entryPath(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	return h.server.FindReferences(ctx, path)
+}
+
+func (h *apiHandler) handleEntryRelations(ctx context.Context, w http.ResponseWriter, r *http.Request) (any, error) {
+	path, err := h.entryPath(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	return h.server.EntryRelations(ctx, path)
+}
+
+// relationEnds returns the entries of a relation, from "path" (or "id") and "to" (or "to_id") form-values.
+func (h *apiHandler) relationEnds(ctx context.Context, r *http.Request) (string, string, error) {
+	from, err := h.entryPath(ctx, r)
+	if err != nil {
+		return "", "", err
+	}
+	to := r.FormValue("to")
+	if id := r.FormValue("to_id"); id != "" {
+		to, err = h.entryPathByID(ctx, id)
+		if err != nil {
+			return "", "", err
+		}
+	}
+	return from, to, nil
+}
+
+func (h *apiHandler) handleAddRelation(ctx context.Context, w http.ResponseWriter, r *http.Request) (any, error) {
+	from, to, err := h.relationEnds(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	err = h.server.AddRelation(ctx, from, to, r.FormValue("type"))
+	return nil, err
+}
+
+func (h *apiHandler) handleDeleteRelation(ctx context.Context, w http.ResponseWriter, r *http.Request) (any, error) {
+	from, to, err := h.relationEnds(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	err = h.server.DeleteRelation(ctx, from, to, r.FormValue("type"))
+	return nil, err
+}
+
+func (h *apiHandler) handleRelationGraph(ctx context.Context, w http.ResponseWriter, r *http.Request) (any, error) {
+	path, err := h.entryPath(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	find, err := relationGraphFinder(r, path)
+	if err != nil {
+		return nil, err
+	}
+	return h.server.RelationGraph(ctx, find)
+}
+
+// relationGraphFinder returns the finder of a relation graph request.
+// Direction is upstream by default, and "type" form-values can be multiple.
+func relationGraphFinder(r *http.Request, path string) (forge.RelationGraphFinder, error) {
+	r.FormValue("") // To parse multipart form.
+	find := forge.RelationGraphFinder{
+		Path:      path,
+		Direction: r.FormValue("direction"),
+		Types:     r.Form["type"],
+	}
+	if find.Direction == "" {
+		find.Direction = "upstream"
+	}
+	if depth := r.FormValue("depth"); depth != "" {
+		n, err := strconv.Atoi(depth)
+		if err != nil {
+			return find, fmt.Errorf("invalid relation depth: %v", depth)
+		}
+		find.Depth = n
+	}
+	return find, nil
+}
+
 func (h *apiHandler) handleSubEntries(ctx context.Context, w http.ResponseWriter, r *http.Request) (any, error) {
-	path := r.FormValue("path")
+	path, err := h.entryPath(ctx, r)
+	if err != nil {
+		return nil, err
+	}
 	return h.server.SubEntries(ctx, path)
 }
 
 func (h *apiHandler) handleParentEntries(ctx context.Context, w http.ResponseWriter, r *http.Request) (any, error) {
-	path := r.FormValue("path")
+	path, err := h.entryPath(ctx, r)
+	if err != nil {
+		return nil, err
+	}
 	return h.server.ParentEntries(ctx, path)
 }
 
@@ -191,15 +428,18 @@ func (h *apiHandler) handleSearchEntries(ctx context.Context, w http.ResponseWri
 }
 
 func (h *apiHandler) handleGetEntry(ctx context.Context, w http.ResponseWriter, r *http.Request) (any, error) {
-	path := r.FormValue("path") // To parse multipart form.
+	path, err := h.entryPath(ctx, r)
+	if err != nil {
+		return nil, err
+	}
 	return h.server.GetEntry(ctx, path)
 }
 
 func (h *apiHandler) handleGetEntries(ctx context.Context, w http.ResponseWriter, r *http.Request) (any, error) {
 	r.FormValue("")
-	entPaths := r.PostForm["path"]
-	if len(entPaths) == 0 {
-		return nil, fmt.Errorf("path not defined")
+	entPaths, err := h.entryPaths(ctx, r)
+	if err != nil {
+		return nil, err
 	}
 	ents := make([]*forge.Entry, 0)
 	for _, pth := range entPaths {
@@ -225,46 +465,134 @@ func (h *apiHandler) handleAddEntry(ctx context.Context, w http.ResponseWriter,
 	if len(entTypes) != len(entPaths) {
 		return nil, fmt.Errorf("number of types not matched to paths")
 	}
+	// The entry named as '+' will be named with auto numbering,
+	// return the actual paths.
+	added := make([]string, 0, len(entPaths))
 	for i, entPath := range entPaths {
 		typ := entTypes[i]
+		if path.Base(entPath) == "+" {
+			newPath, err := h.server.AddNumberedEntry(ctx, path.Dir(entPath), typ)
+			if err != nil {
+				return nil, err
+			}
+			added = append(added, newPath)
+			continue
+		}
 		err := h.server.AddEntry(ctx, entPath, typ)
 		if err != nil {
 			return nil, err
 		}
+		added = append(added, entPath)
 	}
-	return nil, nil
+	return added, nil
+}
+
+// handleAddEntries adds entries under a parent, named with a pattern like 'sh[0010-0600:10]'.
+// See forge.ExpandEntryPattern for the pattern.
+//
+// The result has paths of the added entries.
+// With non-empty "dryrun" form-value, it doesn't add the entries,
+// but returns what entries will be added, and what entries already exist in the following format.
+//
+//	{
+//		"Err": "",
+//		"Msg": {
+//			"add": ["{entry}", ...],
+//			"exist": ["{entry}", ...]
+//		}
+//	}
+func (h *apiHandler) handleAddEntries(ctx context.Context, w http.ResponseWriter, r *http.Request) (any, error) {
+	parent := r.FormValue("parent")
+	pattern := r.FormValue("pattern")
+	typ := r.FormValue("type")
+	if r.FormValue("dryrun") != "" {
+		add, exist, err := h.server.DryRunAddEntries(ctx, parent, pattern, typ)
+		if err != nil {
+			return nil, err
+		}
+		result := map[string][]string{
+			"add":   add,
+			"exist": exist,
+		}
+		return result, nil
+	}
+	return h.server.AddEntries(ctx, parent, pattern, typ)
 }
 
 func (h *apiHandler) handleRenameEntry(ctx context.Context, w http.ResponseWriter, r *http.Request) (any, error) {
-	entPath := r.FormValue("path")
+	entPath, err := h.entryPath(ctx, r)
+	if err != nil {
+		return nil, err
+	}
 	newName := r.FormValue("new-name")
-	err := h.server.RenameEntry(ctx, entPath, newName)
+	err = h.server.RenameEntry(ctx, entPath, newName)
 	return nil, err
 }
 
+// handleRenameEntries renames sub entries of a parent at once.
+//
+// It takes renames as pairs of "name" and "new-name" form-values,
+// or a "rule" with optional "name" form-values those to be renamed. See forge.RenameMap for the rule.
+// The result is the map of old names to new names.
+func (h *apiHandler) handleRenameEntries(ctx context.Context, w http.ResponseWriter, r *http.Request) (any, error) {
+	r.FormValue("") // To parse multipart form.
+	parent := r.FormValue("parent")
+	names := r.Form["name"]
+	rule := r.FormValue("rule")
+	if rule != "" {
+		return h.server.RenameEntriesWithRule(ctx, parent, names, rule)
+	}
+	newNames := r.Form["new-name"]
+	if len(newNames) != len(names) {
+		return nil, fmt.Errorf("number of new names not matched to names")
+	}
+	renames := make(map[string]string)
+	for i, name := range names {
+		renames[name] = newNames[i]
+	}
+	err := h.server.RenameEntries(ctx, parent, renames)
+	if err != nil {
+		return nil, err
+	}
+	return renames, nil
+}
+
 func (h *apiHandler) handleArchiveEntry(ctx context.Context, w http.ResponseWriter, r *http.Request) (any, error) {
-	entPath := r.FormValue("path")
-	err := h.server.ArchiveEntry(ctx, entPath)
+	entPath, err := h.entryPath(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	err = h.server.ArchiveEntry(ctx, entPath)
 	return nil, err
 }
 
 func (h *apiHandler) handleUnarchiveEntry(ctx context.Context, w http.ResponseWriter, r *http.Request) (any, error) {
-	entPath := r.FormValue("path")
-	err := h.server.UnarchiveEntry(ctx, entPath)
+	entPath, err := h.entryPath(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	err = h.server.UnarchiveEntry(ctx, entPath)
 	return nil, err
 }
 
 func (h *apiHandler) handleDeleteEntry(ctx context.Context, w http.ResponseWriter, r *http.Request) (any, error) {
 	r.FormValue("") // To parse multipart form.
-	entPaths := r.PostForm["path"]
-	if len(entPaths) == 0 {
-		return nil, fmt.Errorf("path not defined")
+	entPaths, err := h.entryPaths(ctx, r)
+	if err != nil {
+		return nil, err
 	}
 	delFn := h.server.DeleteEntry
 	recursive := r.FormValue("recursive")
 	if recursive != "" {
 		delFn = h.server.DeleteEntryRecursive
 	}
+	if r.FormValue("force") != "" {
+		// force deletes entries even when other entries refer to them, only for admins.
+		if recursive == "" {
+			return nil, fmt.Errorf("force deletion should be recursive")
+		}
+		delFn = h.server.ForceDeleteEntryRecursive
+	}
 	for _, pth := range entPaths {
 		err := delFn(ctx, pth)
 		if err != nil {
@@ -276,9 +604,9 @@ func (h *apiHandler) handleDeleteEntry(ctx context.Context, w http.ResponseWrite
 
 func (h *apiHandler) handleUpdateProperty(ctx context.Context, w http.ResponseWriter, r *http.Request) (any, error) {
 	r.FormValue("") // To parse multipart form.
-	entPaths := r.PostForm["path"]
-	if len(entPaths) == 0 {
-		return nil, fmt.Errorf("path not defined")
+	entPaths, err := h.entryPaths(ctx, r)
+	if err != nil {
+		return nil, err
 	}
 	name := r.FormValue("name")
 	value := r.FormValue("value")
@@ -300,7 +628,10 @@ func (h *apiHandler) handleUpdateProperty(ctx context.Context, w http.ResponseWr
 }
 
 func (h *apiHandler) handleGetProperty(ctx context.Context, w http.ResponseWriter, r *http.Request) (any, error) {
-	entPath := r.FormValue("path")
+	entPath, err := h.entryPath(ctx, r)
+	if err != nil {
+		return nil, err
+	}
 	name := r.FormValue("name")
 	return h.server.GetProperty(ctx, entPath, name)
 }
@@ -332,9 +663,9 @@ func (h *apiHandler) handleGetProperties(ctx context.Context, w http.ResponseWri
 
 func (h *apiHandler) handleAddEnviron(ctx context.Context, w http.ResponseWriter, r *http.Request) (any, error) {
 	r.FormValue("") // To parse multipart form.
-	entPaths := r.PostForm["path"]
-	if len(entPaths) == 0 {
-		return nil, fmt.Errorf("path not defined")
+	entPaths, err := h.entryPaths(ctx, r)
+	if err != nil {
+		return nil, err
 	}
 	name := r.FormValue("name")
 	typ := r.FormValue("type")
@@ -351,9 +682,9 @@ func (h *apiHandler) handleAddEnviron(ctx context.Context, w http.ResponseWriter
 
 func (h *apiHandler) handleUpdateEnviron(ctx context.Context, w http.ResponseWriter, r *http.Request) (any, error) {
 	r.FormValue("") // To parse multipart form.
-	entPaths := r.PostForm["path"]
-	if len(entPaths) == 0 {
-		return nil, fmt.Errorf("path not defined")
+	entPaths, err := h.entryPaths(ctx, r)
+	if err != nil {
+		return nil, err
 	}
 	name := r.FormValue("name")
 	value := r.FormValue("value")
@@ -369,9 +700,9 @@ func (h *apiHandler) handleUpdateEnviron(ctx context.Context, w http.ResponseWri
 
 func (h *apiHandler) handleAddOrUpdateEnviron(ctx context.Context, w http.ResponseWriter, r *http.Request) (any, error) {
 	r.FormValue("") // To parse multipart form.
-	entPaths := r.PostForm["path"]
-	if len(entPaths) == 0 {
-		return nil, fmt.Errorf("path not defined")
+	entPaths, err := h.entryPaths(ctx, r)
+	if err != nil {
+		return nil, err
 	}
 	name := r.FormValue("name")
 	value := r.FormValue("value")
@@ -401,21 +732,27 @@ func (h *apiHandler) handleAddOrUpdateEnviron(ctx context.Context, w http.Respon
 }
 
 func (h *apiHandler) handleGetEnviron(ctx context.Context, w http.ResponseWriter, r *http.Request) (any, error) {
-	entPath := r.FormValue("path")
+	entPath, err := h.entryPath(ctx, r)
+	if err != nil {
+		return nil, err
+	}
 	name := r.FormValue("name")
 	return h.server.GetEnviron(ctx, entPath, name)
 }
 
 func (h *apiHandler) handleEntryEnvirons(ctx context.Context, w http.ResponseWriter, r *http.Request) (any, error) {
-	entPath := r.FormValue("path")
+	entPath, err := h.entryPath(ctx, r)
+	if err != nil {
+		return nil, err
+	}
 	return h.server.EntryEnvirons(ctx, entPath)
 }
 
 func (h *apiHandler) handleDeleteEnviron(ctx context.Context, w http.ResponseWriter, r *http.Request) (any, error) {
 	r.FormValue("") // To parse multipart form.
-	entPaths := r.PostForm["path"]
-	if len(entPaths) == 0 {
-		return nil, fmt.Errorf("path not defined")
+	entPaths, err := h.entryPaths(ctx, r)
+	if err != nil {
+		return nil, err
 	}
 	name := r.FormValue("name")
 	generous := r.FormValue("generous") != ""
@@ -442,9 +779,9 @@ func (h *apiHandler) handleDeleteEnviron(ctx context.Context, w http.ResponseWri
 
 func (h *apiHandler) handleAddAccess(ctx context.Context, w http.ResponseWriter, r *http.Request) (any, error) {
 	r.FormValue("") // To parse multipart form.
-	entPaths := r.PostForm["path"]
-	if len(entPaths) == 0 {
-		return nil, fmt.Errorf("path not defined")
+	entPaths, err := h.entryPaths(ctx, r)
+	if err != nil {
+		return nil, err
 	}
 	accessor := r.FormValue("name")
 	mode := r.FormValue("value")
@@ -460,9 +797,9 @@ func (h *apiHandler) handleAddAccess(ctx context.Context, w http.ResponseWriter,
 
 func (h *apiHandler) handleUpdateAccess(ctx context.Context, w http.ResponseWriter, r *http.Request) (any, error) {
 	r.FormValue("") // To parse multipart form.
-	entPaths := r.PostForm["path"]
-	if len(entPaths) == 0 {
-		return nil, fmt.Errorf("path not defined")
+	entPaths, err := h.entryPaths(ctx, r)
+	if err != nil {
+		return nil, err
 	}
 	accessor := r.FormValue("name")
 	mode := r.FormValue("value")
@@ -478,9 +815,9 @@ func (h *apiHandler) handleUpdateAccess(ctx context.Context, w http.ResponseWrit
 
 func (h *apiHandler) handleAddOrUpdateAccess(ctx context.Context, w http.ResponseWriter, r *http.Request) (any, error) {
 	r.FormValue("") // To parse multipart form.
-	entPaths := r.PostForm["path"]
-	if len(entPaths) == 0 {
-		return nil, fmt.Errorf("path not defined")
+	entPaths, err := h.entryPaths(ctx, r)
+	if err != nil {
+		return nil, err
 	}
 	accessor := r.FormValue("name")
 	mode := r.FormValue("value")
@@ -509,21 +846,39 @@ func (h *apiHandler) handleAddOrUpdateAccess(ctx context.Context, w http.Respons
 }
 
 func (h *apiHandler) handleGetAccess(ctx context.Context, w http.ResponseWriter, r *http.Request) (any, error) {
-	entPath := r.FormValue("path")
+	entPath, err := h.entryPath(ctx, r)
+	if err != nil {
+		return nil, err
+	}
 	accessor := r.FormValue("name")
 	return h.server.GetAccess(ctx, entPath, accessor)
 }
 
 func (h *apiHandler) handleEntryAccessList(ctx context.Context, w http.ResponseWriter, r *http.Request) (any, error) {
-	entPath := r.FormValue("path")
+	entPath, err := h.entryPath(ctx, r)
+	if err != nil {
+		return nil, err
+	}
 	return h.server.EntryAccessList(ctx, entPath)
 }
 
+func (h *apiHandler) handleExplainAccess(ctx context.Context, w http.ResponseWriter, r *http.Request) (any, error) {
+	entPath, err := h.entryPath(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	user := r.FormValue("user")
+	if user == "" {
+		user = forge.UserNameFromContext(ctx)
+	}
+	return h.server.ExplainAccess(ctx, user, entPath)
+}
+
 func (h *apiHandler) handleDeleteAccess(ctx context.Context, w http.ResponseWriter, r *http.Request) (any, error) {
 	r.FormValue("") // To parse multipart form.
-	entPaths := r.PostForm["path"]
-	if len(entPaths) == 0 {
-		return nil, fmt.Errorf("path not defined")
+	entPaths, err := h.entryPaths(ctx, r)
+	if err != nil {
+		return nil, err
 	}
 	name := r.FormValue("name")
 	generous := r.FormValue("generous") != ""
@@ -549,19 +904,28 @@ func (h *apiHandler) handleDeleteAccess(ctx context.Context, w http.ResponseWrit
 }
 
 func (h *apiHandler) handleGetPropertyHistory(ctx context.Context, w http.ResponseWriter, r *http.Request) (any, error) {
-	pth := r.FormValue("path")
+	pth, err := h.entryPath(ctx, r)
+	if err != nil {
+		return nil, err
+	}
 	prop := r.FormValue("property")
 	return h.server.GetLogs(ctx, pth, "property", prop)
 }
 
 func (h *apiHandler) handleGetEnvironHistory(ctx context.Context, w http.ResponseWriter, r *http.Request) (any, error) {
-	pth := r.FormValue("path")
+	pth, err := h.entryPath(ctx, r)
+	if err != nil {
+		return nil, err
+	}
 	env := r.FormValue("environ")
 	return h.server.GetLogs(ctx, pth, "environ", env)
 }
 
 func (h *apiHandler) handleGetAccessHistory(ctx context.Context, w http.ResponseWriter, r *http.Request) (any, error) {
-	pth := r.FormValue("path")
+	pth, err := h.entryPath(ctx, r)
+	if err != nil {
+		return nil, err
+	}
 	acc := r.FormValue("access")
 	return h.server.GetLogs(ctx, pth, "access", acc)
 }
@@ -586,8 +950,34 @@ func (h *apiHandler) handleRenameGroup(ctx context.Context, w http.ResponseWrite
 	return nil, err
 }
 
+func (h *apiHandler) handleDeleteGroup(ctx context.Context, w http.ResponseWriter, r *http.Request) (any, error) {
+	group := r.FormValue("group")
+	cascade := false
+	v := r.FormValue("cascade")
+	if v != "" {
+		var err error
+		cascade, err = strconv.ParseBool(v)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return h.server.DeleteGroup(ctx, group, cascade)
+}
+
 func (h *apiHandler) handleGetGroupMembers(ctx context.Context, w http.ResponseWriter, r *http.Request) (any, error) {
 	group := r.FormValue("group")
+	expand := false
+	v := r.FormValue("expand")
+	if v != "" {
+		var err error
+		expand, err = strconv.ParseBool(v)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if expand {
+		return h.server.ExpandedGroupMembers(ctx, group)
+	}
 	return h.server.GroupMembers(ctx, group)
 }
 
@@ -606,7 +996,10 @@ func (h *apiHandler) handleDeleteGroupMember(ctx context.Context, w http.Respons
 }
 
 func (h *apiHandler) handleAddThumbnail(ctx context.Context, w http.ResponseWriter, r *http.Request) (any, error) {
-	entPath := r.FormValue("path")
+	entPath, err := h.entryPath(ctx, r)
+	if err != nil {
+		return nil, err
+	}
 	KiB := int64(1 << 10)
 	r.ParseMultipartForm(100 * KiB) // 100KiB buffer size
 	file, _, err := r.FormFile("file")
@@ -622,12 +1015,18 @@ func (h *apiHandler) handleAddThumbnail(ctx context.Context, w http.ResponseWrit
 }
 
 func (h *apiHandler) handleGetThumbnail(ctx context.Context, w http.ResponseWriter, r *http.Request) (any, error) {
-	entPath := r.FormValue("path")
+	entPath, err := h.entryPath(ctx, r)
+	if err != nil {
+		return nil, err
+	}
 	return h.server.GetThumbnail(ctx, entPath)
 }
 
 func (h *apiHandler) handleUpdateThumbnail(ctx context.Context, w http.ResponseWriter, r *http.Request) (any, error) {
-	entPath := r.FormValue("path")
+	entPath, err := h.entryPath(ctx, r)
+	if err != nil {
+		return nil, err
+	}
 	KiB := int64(1 << 10)
 	r.ParseMultipartForm(100 * KiB) // 100KiB buffer size
 	file, _, err := r.FormFile("file")
@@ -643,8 +1042,11 @@ func (h *apiHandler) handleUpdateThumbnail(ctx context.Context, w http.ResponseW
 }
 
 func (h *apiHandler) handleDeleteThumbnail(ctx context.Context, w http.ResponseWriter, r *http.Request) (any, error) {
-	entPath := r.FormValue("path")
-	err := h.server.DeleteThumbnail(ctx, entPath)
+	entPath, err := h.entryPath(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	err = h.server.DeleteThumbnail(ctx, entPath)
 	return nil, err
 }
 
@@ -670,6 +1072,10 @@ func (h *apiHandler) handleGetDisabledUsers(ctx context.Context, w http.Response
 	return h.server.DisabledUsers(ctx)
 }
 
+func (h *apiHandler) handleGetPendingUsers(ctx context.Context, w http.ResponseWriter, r *http.Request) (any, error) {
+	return h.server.PendingUsers(ctx)
+}
+
 func (h *apiHandler) handleAddUser(ctx context.Context, w http.ResponseWriter, r *http.Request) (any, error) {
 	ctxUser := forge.UserNameFromContext(ctx)
 	isAdmin, err := h.server.IsAdmin(ctx, ctxUser)
@@ -731,6 +1137,108 @@ func (h *apiHandler) handleUpdateUserDisabled(ctx context.Context, w http.Respon
 	return nil, err
 }
 
+func (h *apiHandler) handleRenameUser(ctx context.Context, w http.ResponseWriter, r *http.Request) (any, error) {
+	user := r.FormValue("user")
+	newName := r.FormValue("new-name")
+	err := h.server.RenameUser(ctx, user, newName)
+	return nil, err
+}
+
+func (h *apiHandler) handleMergeUsers(ctx context.Context, w http.ResponseWriter, r *http.Request) (any, error) {
+	from := r.FormValue("from")
+	to := r.FormValue("to")
+	err := h.server.MergeUsers(ctx, from, to)
+	return nil, err
+}
+
+func (h *apiHandler) handleApproveUser(ctx context.Context, w http.ResponseWriter, r *http.Request) (any, error) {
+	user := r.FormValue("user")
+	err := h.server.ApproveUser(ctx, user)
+	return nil, err
+}
+
+func (h *apiHandler) handleRejectUser(ctx context.Context, w http.ResponseWriter, r *http.Request) (any, error) {
+	user := r.FormValue("user")
+	err := h.server.RejectUser(ctx, user)
+	return nil, err
+}
+
+// handleStartImpersonation lets an admin view forge as another user, until it is stopped.
+// The impersonation is read-only unless 'writable' is set.
+func (h *apiHandler) handleStartImpersonation(ctx context.Context, w http.ResponseWriter, r *http.Request) (any, error) {
+	if r.FormValue("session") != "" {
+		return nil, fmt.Errorf("impersonation is only available in browser")
+	}
+	admin := forge.UserNameFromContext(ctx)
+	imp := forge.ImpersonationFromContext(ctx)
+	if imp != nil {
+		admin = imp.Admin
+	}
+	isAdmin, err := h.server.IsAdmin(ctx, admin)
+	if err != nil {
+		return nil, err
+	}
+	if !isAdmin {
+		return nil, forge.Unauthorized("non-admin user cannot impersonate another user: %v", admin)
+	}
+	user := r.FormValue("user")
+	if user == "" {
+		return nil, fmt.Errorf("user not specified")
+	}
+	if user == admin {
+		return nil, fmt.Errorf("cannot impersonate oneself")
+	}
+	u, err := h.server.GetUser(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	if u.Disabled {
+		return nil, fmt.Errorf("cannot impersonate a disabled user: %v", user)
+	}
+	writable := false
+	v := r.FormValue("writable")
+	if v != "" {
+		writable, err = strconv.ParseBool(v)
+		if err != nil {
+			return nil, err
+		}
+	}
+	session, err := getSession(r)
+	if err != nil {
+		return nil, err
+	}
+	session["impersonate"] = user
+	session["impersonate_writable"] = ""
+	if writable {
+		session["impersonate_writable"] = "1"
+	}
+	err = setSession(w, session)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("%v started impersonation of %v (writable: %v)", admin, user, writable)
+	return nil, nil
+}
+
+func (h *apiHandler) handleStopImpersonation(ctx context.Context, w http.ResponseWriter, r *http.Request) (any, error) {
+	imp := forge.ImpersonationFromContext(ctx)
+	if imp == nil {
+		return nil, fmt.Errorf("not impersonating a user")
+	}
+	session, err := getSession(r)
+	if err != nil {
+		return nil, err
+	}
+	session["impersonate"] = ""
+	session["impersonate_writable"] = ""
+	err = setSession(w, session)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("%v stopped impersonation of %v", imp.Admin, forge.UserNameFromContext(ctx))
+	return nil, nil
+}
+
 func (h *apiHandler) handleGetUserSetting(ctx context.Context, w http.ResponseWriter, r *http.Request) (any, error) {
 	user := r.FormValue("user")
 	return h.server.GetUserSetting(ctx, user)
@@ -993,10 +1501,12 @@ func (h *apiHandler) handleDeleteUserData(ctx context.Context, w http.ResponseWr
 	return nil, err
 }
 
-// handleCheckBulkUpdate checks what will happen when bulk update of entries processed from uploaded excel file.
+// handleDryRunBulkUpdate checks what will happen when bulk update of entries processed from uploaded excel file.
 //
 // The result shows what entries will be added, and what entries will be updated in the following format.
 // Entries not in any of the list is already match with given data.
+// Entries in "invalid" list cannot be updated with the data, because of validation or constraint of the properties.
+// Type of an entry to be added is empty when it cannot be guessed with the parent.
 // Msg is null when Err is not empty.
 //
 //	{
@@ -1010,11 +1520,14 @@ func (h *apiHandler) handleDeleteUserData(ctx context.Context, w http.ResponseWr
 //				"{entry-type}": ["{entry}", ...],
 //				...
 //			}
+//			"invalid": {
+//				"{entry}": "{error}",
+//				...
+//			}
 //		}
 //	}
 func (h *apiHandler) handleDryRunBulkUpdate(ctx context.Context, w http.ResponseWriter, r *http.Request) (any, error) {
-	// TODO
-	return nil, nil
+	return h.bulkUpdate(ctx, r, true)
 }
 
 // handleBulkUpdate adds and/or updates multiple entries at once from uploaded excel file.
@@ -1031,6 +1544,17 @@ func (h *apiHandler) handleDryRunBulkUpdate(ctx context.Context, w http.Response
 //
 // NOTE: With non-empty "dryrun" form-value, like "dryrun=1", it will perform handleDryRunBulkUpdate and return it's result instead.
 func (h *apiHandler) handleBulkUpdate(ctx context.Context, w http.ResponseWriter, r *http.Request) (any, error) {
+	if r.FormValue("dryrun") != "" {
+		return h.handleDryRunBulkUpdate(ctx, w, r)
+	}
+	return h.bulkUpdate(ctx, r, false)
+}
+
+// bulkUpdate updates entries from uploaded excel file. See handleBulkUpdate and handleDryRunBulkUpdate.
+func (h *apiHandler) bulkUpdate(ctx context.Context, r *http.Request, dryrun bool) (any, error) {
+	add := make(map[string][]string)
+	update := make(map[string][]string)
+	invalid := make(map[string]string)
 	KiB := int64(1 << 10)
 	r.ParseMultipartForm(100 * KiB) // 100KiB buffer size
 	file, _, err := r.FormFile("file")
@@ -1177,14 +1701,23 @@ func (h *apiHandler) handleBulkUpdate(ctx context.Context, w http.ResponseWriter
 			if !errors.As(err, &e) {
 				return nil, err
 			}
-			if addMode {
-				err := h.server.AddEntry(ctx, entPath, "")
-				if err != nil {
-					return nil, err
-				}
-			} else {
+			if !addMode {
 				return nil, fmt.Errorf("add a new entry is not allowed in update mode: %s", entPath)
 			}
+			if dryrun {
+				// properties of the entry cannot be checked before it is created.
+				typ := ""
+				sub, err := h.server.GetProperty(ctx, path.Dir(entPath), ".sub_entry_types")
+				if err == nil && !strings.Contains(sub.Value, ",") {
+					typ = strings.TrimSpace(sub.Value)
+				}
+				add[typ] = append(add[typ], entPath)
+				continue
+			}
+			err := h.server.AddEntry(ctx, entPath, "")
+			if err != nil {
+				return nil, err
+			}
 			// To check entry type, need to get the entry.
 			ent, err = h.server.GetEntry(ctx, entPath)
 			if err != nil {
@@ -1192,7 +1725,7 @@ func (h *apiHandler) handleBulkUpdate(ctx context.Context, w http.ResponseWriter
 			}
 		}
 		// Update the entry's thumbnail.
-		if thumbnailIdx != -1 {
+		if thumbnailIdx != -1 && !dryrun {
 			err := func() error {
 				// Excel coordinate starts from 1.
 				thumbCell, err := excelize.CoordinatesToCellName(thumbnailIdx+1, row+1)
@@ -1296,10 +1829,36 @@ func (h *apiHandler) handleBulkUpdate(ctx context.Context, w http.ResponseWriter
 			})
 
 		}
+		if dryrun {
+			err := h.server.DryRunUpdateProperties(ctx, upds)
+			if err != nil {
+				invalid[entPath] = err.Error()
+				continue
+			}
+			for _, upd := range upds {
+				old, err := h.server.GetProperty(ctx, entPath, upd.Name)
+				if err != nil {
+					return nil, err
+				}
+				if *upd.Value != old.Value && *upd.Value != old.Eval {
+					update[ent.Type] = append(update[ent.Type], entPath)
+					break
+				}
+			}
+			continue
+		}
 		err = h.server.UpdateProperties(ctx, upds)
 		if err != nil {
 			return nil, err
 		}
 	}
+	if dryrun {
+		result := map[string]any{
+			"add":     add,
+			"update":  update,
+			"invalid": invalid,
+		}
+		return result, nil
+	}
 	return nil, nil
 }
@@ -7,6 +7,7 @@ import (
 	"path"
 	"reflect"
 	"sort"
+	"sync"
 	"testing"
 	"time"
 
@@ -96,6 +97,7 @@ type testDefault struct {
 
 var testDefaults = []testDefault{
 	{typ: "show", ctg: "property", k: "sup", t: "user", v: ""},
+	{typ: "show", ctg: "property", k: "client", t: "text", v: ""},
 	{typ: "show", ctg: "environ", k: "LIBRARY_ROOT", t: "text", v: "/mnt/imag/lib"},
 	// TODO: add 'entry_name' environ makes the test fail, caused by validation of default property.
 	{typ: "shot", ctg: "property", k: "cg", t: "text", v: ""},
@@ -108,14 +110,69 @@ var testDefaults = []testDefault{
 	{typ: "shot", ctg: "property", k: "undistort_resolution", t: "text", v: ""},
 	{typ: "shot", ctg: "property", k: "SHOT_PATH", t: "entry_path", v: ""},
 	{typ: "shot", ctg: "property", k: "SHOT", t: "entry_name", v: ""},
-	{typ: "shot", ctg: "global", k: "property_owner", t: "text", v: "undistort_resolution: match.assignee"},
+	{typ: "shot", ctg: "global", k: "property_owner", t: "text", v: "undistort_resolution: match.assignee\ndirection: match.artists"},
+	{typ: "shot", ctg: "property", k: "linked_due", t: "lookup", v: ""},
+	{typ: "shot", ctg: "property", k: "resolution", t: "text", v: ""},
+	{typ: "shot", ctg: "property", k: "priority", t: "int", v: ""},
+	{typ: "shot", ctg: "property", k: "code", t: "text", v: ""},
+	{typ: "shot", ctg: "property", k: "client", t: "text", v: ""},
+	{typ: "shot", ctg: "property", k: "parts", t: "formula", v: `count("type=part")`},
+	{typ: "group", ctg: "property", k: "shots", t: "formula", v: `count("type=shot")`},
+	{typ: "group", ctg: "property", k: "parts_done", t: "formula", v: `count("type=part status=done")`},
+	{typ: "group", ctg: "property", k: "total_duration", t: "formula", v: `sum(duration, "type=shot")`},
+	{typ: "group", ctg: "property", k: "total_seconds", t: "formula", v: "total_duration / env.FPS"},
+	{typ: "group", ctg: "property", k: "broken", t: "formula", v: "total_duration +", want: errors.New("invalid formula: unexpected end of formula")},
 	{typ: "part", ctg: "property", k: "assignee", t: "user", v: ""},
+	{typ: "part", ctg: "property", k: "artists", t: "users", v: ""},
 	{typ: "part", ctg: "property", k: "status", t: "text", v: ""},
+	{typ: "part", ctg: "property", k: "shot_due", t: "lookup", v: "^shot.due"},
+	{typ: "part", ctg: "property", k: "broken_lookup", t: "lookup", v: "^lol.due", want: errors.New("invalid lookup: entry type not found: lol")},
 	{typ: "part", ctg: "property", k: "direction", t: "text", v: ""},
+	{typ: "part", ctg: "property", k: "part_due", t: "date", v: ""},
+	{typ: "part", ctg: "property", k: "client", t: "text", v: ""},
 	{typ: "lol", ctg: "property", k: "assignee", t: "user", v: "", want: errors.New("entry type not found: lol")},
 	{typ: "", ctg: "property", k: "assignee", t: "user", v: "", want: errors.New("default entry type not specified")},
 }
 
+var testTimecodeFrameRates = []struct {
+	label  string
+	path   string
+	fps    string
+	v      string
+	want   error
+	expect string
+}{
+	{label: "25 fps", path: "/prop_owner/shot/cg/0040", fps: "25", v: "+25", expect: "00:00:01:00"},
+	{label: "29.97 drop frame", path: "/prop_owner/shot/cg/0040", fps: "29.97", v: "00:00:59:29", expect: "00:00:59;29"},
+	{label: "29.97 drop frame skips frame numbers", path: "/prop_owner/shot/cg/0040", fps: "29.97", v: "+1", expect: "00:01:00;02"},
+	{label: "29.97 drop frame duration", path: "/prop_owner/shot/cg/0040", fps: "29.97", v: "+00:09:00:00", expect: "00:10:00;18"},
+	{label: "29.97 drop frame not existing", path: "/prop_owner/shot/cg/0040", fps: "29.97", v: "00:01:00;00", want: errors.New("invalid timecode string: timecode doesn't exist in drop frame: 00:01:00:00")},
+	{label: "29.97 non drop frame", path: "/prop_owner/shot/cg/0040", fps: "29.97ndf", v: "00:01:00;00", expect: "00:01:00:00"},
+	{label: "invalid fps", path: "/prop_owner/shot/cg/0040", fps: "fast", v: "00:00:00:00", want: errors.New("FPS environ: invalid frame rate: fast")},
+}
+
+var testFormulas = []struct {
+	label    string
+	path     string
+	k, v     string
+	want     error
+	fpath    string
+	fk       string
+	expect   string
+	valueErr error
+}{
+	{label: "count", fpath: "/test/shot/cg", fk: "shots", expect: "3"},
+	{label: "count sub entries", fpath: "/test/shot/cg/0010", fk: "parts", expect: "4"},
+	{label: "count with query", fpath: "/test/shot/cg", fk: "parts_done", expect: "1"},
+	{label: "count with query updated", path: "/test/shot/cg/0020/ani", k: "status", v: "done", fpath: "/test/shot/cg", fk: "parts_done", expect: "2"},
+	{label: "count with query reverted", path: "/test/shot/cg/0020/ani", k: "status", v: "", fpath: "/test/shot/cg", fk: "parts_done", expect: "1"},
+	{label: "sum of empty values", fpath: "/test/shot/cg", fk: "total_duration", expect: "0"},
+	{label: "sum", path: "/test/shot/cg/0010", k: "duration", v: "24", fpath: "/test/shot/cg", fk: "total_duration", expect: "24"},
+	{label: "sum updated", path: "/test/shot/cg/0020", k: "duration", v: "48", fpath: "/test/shot/cg", fk: "total_duration", expect: "72"},
+	{label: "environ not exist", fpath: "/test/shot/cg", fk: "total_seconds", valueErr: errors.New("environ not found: FPS")},
+	{label: "read only", path: "/test/shot/cg", k: "shots", v: "5", want: errors.New("formula property is read-only: shots")},
+}
+
 type testEntry struct {
 	path  string
 	typ   string
@@ -123,6 +180,40 @@ type testEntry struct {
 	want  error
 }
 
+var testConstraints = []struct {
+	typ, k, c string
+	want      error
+}{
+	{typ: "shot", k: "resolution", c: "pattern: \\d+x\\d+\nmax_length: 9"},
+	{typ: "shot", k: "priority", c: "required\nmin: 1\nmax: 5"},
+	{typ: "shot", k: "code", c: "unique"},
+	{typ: "part", k: "part_due", c: "required: status=wip"},
+	{typ: "shot", k: "resolution", c: "min: 1", want: errors.New("invalid constraint: min not supported for text type")},
+	{typ: "shot", k: "priority", c: "max: high", want: errors.New("invalid constraint: max should be an integer: high")},
+	{typ: "shot", k: "priority", c: "optional", want: errors.New("invalid constraint: unknown key: optional")},
+}
+
+var testConstraintProps = []testProperty{
+	{path: "/test/shot/cg/0010", k: "resolution", v: "1920x1080", expect: "1920x1080"},
+	{path: "/test/shot/cg/0010", k: "resolution", v: "1920*1080", want: errors.New("invalid value for resolution: value doesn't match the pattern \\d+x\\d+: 1920*1080")},
+	{path: "/test/shot/cg/0010", k: "resolution", v: "19200x10800", want: errors.New("invalid value for resolution: value is longer than 9 characters: 19200x10800")},
+	{path: "/test/shot/cg/0010", k: "resolution", v: "", expect: ""},
+	{path: "/test/shot/cg/0010", k: "priority", v: "3", expect: "3"},
+	{path: "/test/shot/cg/0010", k: "priority", v: "0", want: errors.New("invalid value for priority: value should be greater than or equal to 1: 0")},
+	{path: "/test/shot/cg/0010", k: "priority", v: "6", want: errors.New("invalid value for priority: value should be less than or equal to 5: 6")},
+	{path: "/test/shot/cg/0010", k: "priority", v: "", want: errors.New("invalid value for priority: value is required")},
+	{path: "/test/shot/cg/0010", k: "code", v: "A", expect: "A"},
+	{path: "/test/shot/cg/0020", k: "code", v: "A", want: errors.New("invalid value for code: value should be unique among siblings, but /test/shot/cg/0010 has it: A")},
+	{path: "/test/shot/cg/0020", k: "code", v: "B", expect: "B"},
+	{path: "/prop_owner/shot/cg/0010", k: "code", v: "A", expect: "A"},
+	{path: "/test/shot/cg/0030/ani", k: "status", v: "wip", want: errors.New("invalid value for part_due: value is required when status is wip")},
+	{path: "/test/shot/cg/0030/ani", k: "part_due", v: "2024/01/01", expect: "2024/01/01"},
+	{path: "/test/shot/cg/0030/ani", k: "status", v: "wip", expect: "wip"},
+	{path: "/test/shot/cg/0030/ani", k: "part_due", v: "", want: errors.New("invalid value for part_due: value is required when status is wip")},
+	{path: "/test/shot/cg/0030/ani", k: "status", v: "", expect: ""},
+	{path: "/test/shot/cg/0030/ani", k: "part_due", v: "", expect: ""},
+}
+
 var testEntries = []testEntry{
 	{path: "/test", typ: "show"},
 	{path: "/test/shot", typ: "category"},
@@ -197,6 +288,12 @@ var testUpdateProps = []testProperty{
 	{path: "/test/shot/cg/0010", k: "timecode", v: "00:00:00:00", expect: "00:00:00:00"},
 	{path: "/test/shot/cg/0010", k: "timecode", v: "00000000", expect: "00:00:00:00"},
 	{path: "/test/shot/cg/0010", k: "timecode", v: "00:00", want: errors.New("invalid timecode string: 00:00")},
+	{path: "/test/shot/cg/0010", k: "timecode", v: "00:00:00:24", want: errors.New("invalid timecode string: timecode out of range for 24 fps: 00:00:00:24")},
+	{path: "/test/shot/cg/0010", k: "timecode", v: "+24", expect: "00:00:01:00"},
+	{path: "/test/shot/cg/0010", k: "timecode", v: "+00:00:01:12", expect: "00:00:02:12"},
+	{path: "/test/shot/cg/0010", k: "timecode", v: "-60", expect: "00:00:00:00"},
+	{path: "/test/shot/cg/0010", k: "timecode", v: "-1", want: errors.New("invalid timecode operation: frame out of timecode range for 24 fps: -1")},
+	{path: "/test/shot/cg/0010", k: "timecode", v: "+ab", want: errors.New("invalid timecode operation: +/- operation needs frames or timecode, got: ab")},
 	{path: "/test/shot/cg/0010", k: "timecode", v: "", expect: ""},
 	{path: "/test/shot/cg/0010", k: "duration", v: "24", expect: "24"},
 	{path: "/test/shot/cg/0010", k: "duration", v: "24.1", want: errors.New("cannot convert to int: 24.1")},
@@ -247,6 +344,28 @@ var testUpdateProps = []testProperty{
 	{path: "/test/shot/cg/0010", k: "due", v: "2022/08/19", expect: "2022/08/19"},
 	{path: "/test/shot/cg/0020", k: "due", v: "2023/06/19", expect: "2023/06/19"},
 	{path: "/test/shot/cg/0030", k: "due", v: "2023/08/19", expect: "2023/08/19"},
+	{path: "/test/shot/cg/0010/ani", k: "shot_due", v: "^shot.due", expect: "2022/08/19"},
+	{path: "/test/shot/cg/0010/ani", k: "shot_due", v: "...due", expect: "2022/08/19"},
+	{path: "/test/shot/cg/0010/ani", k: "shot_due", v: "../../0020.due", expect: "2023/06/19"},
+	{path: "/test/shot/cg/0010/ani", k: "shot_due", v: "/test/shot/cg/0030.due", expect: "2023/08/19"},
+	{path: "/test/shot/cg/0010/ani", k: "shot_due", v: "due", want: errors.New("invalid lookup: want <entry>.<property>, got due")},
+	{path: "/test/shot/cg/0010/ani", k: "shot_due", v: "^.due", want: errors.New("invalid lookup: ancestor type not specified: ^.due")},
+	{path: "/test/shot/cg/0010/ani", k: "shot_due", v: "../../0020..due", want: errors.New("invalid lookup: hidden property cannot be looked up: ../../0020..due")},
+	{path: "/test/shot/cg/0010/ani", k: "shot_due", v: "^shot..due", want: errors.New("invalid lookup: hidden property cannot be looked up: ^shot..due")},
+	{path: "/test/shot/cg/0010/ani", k: "shot_due", v: "....due", want: errors.New("invalid lookup: hidden property cannot be looked up: ....due")},
+	{path: "/test/shot/cg/0010/ani", k: "shot_due", v: "^group.due", expect: ""}, // group doesn't have due
+	{path: "/test/shot/cg/0010/ani", k: "shot_due", v: "../../not-exist.due", want: errors.New("invalid lookup: entry not found: /test/shot/cg/not-exist")},
+	{path: "/test/shot/cg/0010/ani", k: "shot_due", v: "^shot.due", expect: "2022/08/19"},
+	{path: "/test/shot/cg/0010", k: "linked_due", v: "SHOT_PATH.due", expect: "2022/08/19"},
+	{path: "/test/shot/cg/0010", k: "linked_due", v: "cg.due", expect: ""}, // cg is not an entry_path property
+	{path: "/test/shot/cg/0010", k: "linked_due", v: "", expect: ""},
+	{path: "/test/shot/cg/0010/mdl", k: "artists", v: "+reader@imagvfx.com", expect: "reader@imagvfx.com"},
+	{path: "/test/shot/cg/0010/mdl", k: "artists", v: "+readwriter@imagvfx.com\n+reader@imagvfx.com", expect: "reader@imagvfx.com\nreadwriter@imagvfx.com"},
+	{path: "/test/shot/cg/0010/mdl", k: "artists", v: "-reader@imagvfx.com", expect: "readwriter@imagvfx.com"},
+	{path: "/test/shot/cg/0010/mdl", k: "artists", v: "+reader@imagvfx.com", expect: "readwriter@imagvfx.com\nreader@imagvfx.com"},
+	{path: "/test/shot/cg/0010/mdl", k: "artists", v: "reader@imagvfx.com", want: errors.New("users property line should start with '+' or '-': reader@imagvfx.com")},
+	{path: "/test/shot/cg/0010/mdl", k: "artists", v: "+not-exist@imagvfx.com", want: errors.New("accessor not found: not-exist@imagvfx.com")},
+	{path: "/test/shot/cg/0020/ani", k: "artists", v: "+readers", expect: "readers"},
 	{path: "/test/shot/cg/0020", k: "asset", v: "+/test/asset/char/human1", expect: "/test/asset/char/human1"},
 	{path: "/test/shot/cg/0020", k: "asset", v: "+/test/asset/char/human2", expect: "/test/asset/char/human1\n/test/asset/char/human2"},
 	{path: "/test/shot/cg/0030", k: "asset", v: "+/test/asset/char/human1", expect: "/test/asset/char/human1"},
@@ -260,6 +379,12 @@ var testUpdateProps = []testProperty{
 	{updater: "reader@imagvfx.com", path: "/prop_owner/shot/cg/0020", k: "undistort_resolution", v: "2880*1352", expect: "2880*1352"},
 	{updater: "reader@imagvfx.com", path: "/prop_owner/shot/cg/0020", k: "due", v: "2024/12/22", want: errors.New("entry modification not allowed: /prop_owner/shot/cg/0020")},                 // not property owner of due
 	{updater: "reader@imagvfx.com", path: "/prop_owner/shot/cg/0030", k: "undistort_resolution", v: "2880*1352", want: errors.New("entry modification not allowed: /prop_owner/shot/cg/0030")}, // not match.assignee of the entry
+	{path: "/prop_owner/shot/cg/0010/match", k: "artists", v: "+reader@imagvfx.com", expect: "reader@imagvfx.com"},
+	{path: "/prop_owner/shot/cg/0020/match", k: "artists", v: "+writers\n+readers", expect: "writers\nreaders"},
+	{path: "/prop_owner/shot/cg/0030/match", k: "artists", v: "+writers", expect: "writers"},
+	{updater: "reader@imagvfx.com", path: "/prop_owner/shot/cg/0010", k: "direction", v: "more fog", expect: "more fog"},
+	{updater: "reader@imagvfx.com", path: "/prop_owner/shot/cg/0020", k: "direction", v: "more fog", expect: "more fog"},                                                                       // member of readers
+	{updater: "reader@imagvfx.com", path: "/prop_owner/shot/cg/0030", k: "direction", v: "more fog", want: errors.New("entry modification not allowed: /prop_owner/shot/cg/0030")},             // not a member of writers
 	{updater: "reader@imagvfx.com", path: "/prop_owner/shot/cg/0040", k: "undistort_resolution", v: "2880*1352", want: errors.New("entry modification not allowed: /prop_owner/shot/cg/0040")}, // no sub entry named match
 }
 
@@ -292,6 +417,14 @@ var testSearches = []testSearch{
 	{path: "/test", query: "assignee=,admin@imagvfx.com", wantRes: []string{"/test/shot/cg/0010/ani", "/test/shot/cg/0010/lgt", "/test/shot/cg/0010/mdl", "/test/shot/cg/0010/match", "/test/shot/cg/0030/ani"}},
 	{path: "/test", query: "assignee:", wantRes: []string{"/test/shot/cg/0010/mdl", "/test/shot/cg/0010/match", "/test/shot/cg/0010/ani", "/test/shot/cg/0010/lgt", "/test/shot/cg/0020/ani", "/test/shot/cg/0030/ani"}},
 	{path: "/test", query: "ani.assignee=admin@imagvfx.com", wantRes: []string{"/test/shot/cg/0010"}},
+	{path: "/test", query: "artists=readwriter@imagvfx.com", wantRes: []string{"/test/shot/cg/0010/mdl", "/test/shot/cg/0020/ani"}}, // member of readers
+	{path: "/test", query: "artists=reader@imagvfx.com", wantRes: []string{"/test/shot/cg/0010/mdl", "/test/shot/cg/0020/ani"}},     // member of readers
+	{path: "/test", query: "artists=readers", wantRes: []string{"/test/shot/cg/0020/ani"}},
+	{path: "/test", query: "artists:writer", wantRes: []string{"/test/shot/cg/0010/mdl"}},
+	{path: "/test", query: "artists!=readers", wantRes: []string{"/test/shot/cg/0010/mdl", "/test/shot/cg/0010/match", "/test/shot/cg/0010/ani", "/test/shot/cg/0010/lgt", "/test/shot/cg/0030/ani"}},
+	{path: "/test", query: "artists=", wantRes: []string{"/test/shot/cg/0010/match", "/test/shot/cg/0010/ani", "/test/shot/cg/0010/lgt", "/test/shot/cg/0030/ani"}},
+	{path: "/test", query: "readwriter", wantRes: []string{"/test/shot/cg/0010/mdl"}},
+	{path: "/test", query: "type=shot (sub).artists=readwriter@imagvfx.com", wantRes: []string{"/test/shot/cg/0010", "/test/shot/cg/0020"}},
 	{path: "/test", query: "mdl.assignee:admin", wantRes: []string{}},
 	{path: "/test", query: "type=shot (sub).assignee=admin@imagvfx.com", wantRes: []string{"/test/shot/cg/0010"}},
 	{path: "/test", query: "type=shot (sub).assignee=", wantRes: []string{"/test/shot/cg/0010", "/test/shot/cg/0030"}},
@@ -352,8 +485,20 @@ var testSearches = []testSearch{
 	{path: "/test", query: "due!=", wantRes: []string{"/test/shot/cg/0010", "/test/shot/cg/0020", "/test/shot/cg/0030"}},
 	{path: "/test", query: "asset=", wantRes: []string{"/test/shot/cg/0010"}},
 	{path: "/test", query: "asset!=", wantRes: []string{"/test/shot/cg/0020", "/test/shot/cg/0030"}},
+	{path: "/test", query: "shots=3", wantRes: []string{"/test/shot/cg"}},
+	{path: "/test", query: "shot_due=2023/06/19", wantRes: []string{"/test/shot/cg/0020/ani"}},
+	{path: "/test", query: "shot_due:2023", wantRes: []string{"/test/shot/cg/0020/ani", "/test/shot/cg/0030/ani"}},
+	{path: "/test", query: "shot_due!=2023/06/19", wantRes: []string{"/test/shot/cg/0010/mdl", "/test/shot/cg/0010/match", "/test/shot/cg/0010/ani", "/test/shot/cg/0010/lgt", "/test/shot/cg/0030/ani"}},
+	{path: "/test", query: "shot_due<2023", wantRes: []string{"/test/shot/cg/0010/mdl", "/test/shot/cg/0010/match", "/test/shot/cg/0010/ani", "/test/shot/cg/0010/lgt"}},
+	{path: "/test", query: "shot_due>=2023/08", wantRes: []string{"/test/shot/cg/0030/ani"}},
+	{path: "/test", query: "total_duration=72", wantRes: []string{"/test/shot/cg"}},
 	{path: "/test", query: "asset=/test/asset/char/human1", wantRes: []string{"/test/shot/cg/0020", "/test/shot/cg/0030"}},
 	{path: "/test", query: "asset=/test/asset/not-existing", wantRes: []string{}},
+	{path: "/test", query: "refs=/test/asset/char/human1", wantRes: []string{"/test/shot/cg/0020", "/test/shot/cg/0030"}},
+	{path: "/test", query: "refs:/test/asset/set/cabin", wantRes: []string{"/test/shot/cg/0030"}},
+	{path: "/test", query: "refs=asset/char/human2", wantRes: []string{"/test/shot/cg/0020"}},
+	{path: "/test", query: "refs=/test/asset/char/human1,/test/asset/set/cabin refs!=/test/asset/char/human2", wantRes: []string{"/test/shot/cg/0030"}},
+	{path: "/test", query: "refs=/test/shot/cg/0030", wantRes: []string{}},
 	{path: "/test", query: "asset:human", wantRes: []string{"/test/shot/cg/0020", "/test/shot/cg/0030"}},
 	{path: "/test", query: "asset:/set/", wantRes: []string{"/test/shot/cg/0030"}},
 	{path: "/test", query: "asset!:/set/", wantRes: []string{"/test/shot/cg/0010", "/test/shot/cg/0020"}},
@@ -648,7 +793,7 @@ func TestEntries(t *testing.T) {
 		if def.ctg == "global" {
 			err = server.AddGlobal(adminCtx, def.typ, def.k, def.t, def.v)
 		} else {
-			err = server.AddDefault(adminCtx, def.typ, def.ctg, def.k, def.t, def.v)
+			err = server.AddDefault(adminCtx, &forge.Default{EntryType: def.typ, Category: def.ctg, Name: def.k, Type: def.t, Value: def.v})
 		}
 		if !equalError(def.want, err) {
 			t.Fatalf("want err %q, got %q", errorString(def.want), errorString(err))
@@ -681,150 +826,1281 @@ func TestEntries(t *testing.T) {
 		}
 	}
 
-	// test renames and revert it back.
-	for _, rename := range testRenames {
-		dir := path.Dir(rename.path)
-		oldName := path.Base(rename.path)
-		err := server.RenameEntry(adminCtx, rename.path, rename.newName)
-		if !equalError(rename.wantErr, err) {
-			t.Fatalf("rename %q to %q: want err %q, got %q", rename.path, rename.newName, errorString(rename.wantErr), errorString(err))
-		}
+	err = server.AddEnviron(adminCtx, "/prop_owner", "FPS", "text", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range testTimecodeFrameRates {
+		err := server.UpdateEnviron(adminCtx, "/prop_owner", "FPS", c.fps)
 		if err != nil {
-			// The rename wasn't done, no need to revert.
+			t.Fatalf("timecode: %q: %v", c.label, err)
+		}
+		err = server.UpdateProperty(adminCtx, c.path, "timecode", c.v)
+		if !equalError(c.want, err) {
+			t.Fatalf("timecode: %q: want err %q, got %q", c.label, errorString(c.want), errorString(err))
+		}
+		if c.want != nil {
 			continue
 		}
-		// revert
-		newPath := path.Join(dir, rename.newName)
-		err = server.RenameEntry(adminCtx, newPath, oldName)
+		got, err := server.GetProperty(adminCtx, c.path, "timecode")
 		if err != nil {
-			t.Fatalf("rename %q to %q: revert got unwanted err: %v", rename.path, rename.newName, err)
+			t.Fatalf("timecode: %q: %v", c.label, err)
+		}
+		if got.Eval != c.expect {
+			t.Fatalf("timecode: %q: want value %q, got %q", c.label, c.expect, got.Eval)
 		}
 	}
+	err = server.DeleteEnviron(adminCtx, "/prop_owner", "FPS")
+	if err != nil {
+		t.Fatal(err)
+	}
 
-	// search
-	whoCanRead := []string{"admin@imagvfx.com", "readwriter@imagvfx.com", "reader@imagvfx.com"}
-	for _, user := range whoCanRead {
-		ctx := forge.ContextWithUserName(bgCtx, user)
-		for _, s := range testSearches {
-			ents, err := server.SearchEntries(ctx, s.path, s.query)
-			if !equalError(s.wantErr, err) {
-				t.Fatalf("searched %q from %q: got error %q, want %q", s.query, s.path, errorString(err), errorString(s.wantErr))
-			}
-			got := make([]string, 0)
-			for _, e := range ents {
-				got = append(got, e.Path)
+	for _, c := range testFormulas {
+		if c.path != "" {
+			err := server.UpdateProperty(adminCtx, c.path, c.k, c.v)
+			if !equalError(c.want, err) {
+				t.Fatalf("formula: %q: want err %q, got %q", c.label, errorString(c.want), errorString(err))
 			}
-			sort.Strings(got)
-			sort.Strings(s.wantRes)
-			if !reflect.DeepEqual(got, s.wantRes) {
-				t.Fatalf("searched %q from %q: got %q, want %q", s.query, s.path, got, s.wantRes)
+			if c.want != nil {
+				continue
 			}
 		}
-	}
-	whoCannotRead := []string{"uninvited@imagvfx.com"}
-	for _, user := range whoCannotRead {
-		ctx := forge.ContextWithUserName(bgCtx, user)
-		for _, s := range testSearches {
-			ents, _ := server.SearchEntries(ctx, s.path, s.query)
-			got := make([]string, 0)
-			for _, e := range ents {
-				got = append(got, e.Path)
-			}
-			if len(got) != 0 {
-				if len(got) == 1 && got[0] == "/" {
-					// anyone can search root, even to an uninvited user
-					continue
-				}
-				t.Fatalf("searched %q from %q: uninvited user shouldn't be able to search child entries, got: %v", s.query, s.path, got)
-			}
+		got, err := server.GetProperty(adminCtx, c.fpath, c.fk)
+		if err != nil {
+			t.Fatalf("formula: %q: %v", c.label, err)
+		}
+		if !equalError(c.valueErr, got.ValueError) {
+			t.Fatalf("formula: %q: want value err %q, got %q", c.label, errorString(c.valueErr), errorString(got.ValueError))
+		}
+		if got.Eval != c.expect {
+			t.Fatalf("formula: %q: want value %q, got %q", c.label, c.expect, got.Eval)
 		}
 	}
-
-	// test find
-	for _, f := range testFinds {
-		ents, err := server.FindEntries(adminCtx, f.finder)
+	// formulas are updated when related environs or entries are changed.
+	err = server.AddEnviron(adminCtx, "/test", "FPS", "text", "24")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = server.AddEntry(adminCtx, "/test/shot/cg/0040", "shot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = server.UpdateProperty(adminCtx, "/test/shot/cg/0040", "duration", "24")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k, want := range map[string]string{"shots": "4", "total_duration": "96", "total_seconds": "4"} {
+		got, err := server.GetProperty(adminCtx, "/test/shot/cg", k)
 		if err != nil {
-			t.Fatalf("find: %v", err)
+			t.Fatal(err)
 		}
-		got := make([]string, 0)
-		for _, e := range ents {
-			got = append(got, e.Path)
+		if got.Eval != want {
+			t.Fatalf("formula: %v: want value %q, got %q", k, want, got.Eval)
 		}
-		sort.Strings(got)
-		sort.Strings(f.wantRes)
-		if !reflect.DeepEqual(got, f.wantRes) {
-			t.Fatalf("find: %v: got %q, want %q", f.label, got, f.wantRes)
+	}
+	err = server.DeleteEntry(adminCtx, "/test/shot/cg/0040")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k, want := range map[string]string{"shots": "3", "total_duration": "72", "total_seconds": "3"} {
+		got, err := server.GetProperty(adminCtx, "/test/shot/cg", k)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Eval != want {
+			t.Fatalf("formula: %v: want value %q, got %q", k, want, got.Eval)
 		}
 	}
 
-	// test delete
-	for _, delete := range testDeletes {
-		err := server.DeleteEntry(adminCtx, delete.path)
-		if !equalError(delete.wantErr, err) {
-			t.Fatalf("delete %q: want err %q, got %q", delete.path, errorString(delete.wantErr), errorString(err))
-		}
+	// lookup with an entry path follows the entry when it is renamed.
+	err = server.UpdateProperty(adminCtx, "/test/shot/cg/0010", "linked_due", "../0020.due")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = server.RenameEntry(adminCtx, "/test/shot/cg/0020", "0021")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := server.GetProperty(adminCtx, "/test/shot/cg/0010", "linked_due")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Value != "../0021.due" || got.Eval != "2023/06/19" {
+		t.Fatalf("lookup after rename: got value %q, eval %q", got.Value, got.Eval)
+	}
+	err = server.RenameEntry(adminCtx, "/test/shot/cg/0021", "0020")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = server.UpdateProperty(adminCtx, "/test/shot/cg/0010", "linked_due", "")
+	if err != nil {
+		t.Fatal(err)
 	}
 
-	// test user data
-	for _, c := range userDataCases {
-		err := server.SetUserData(adminCtx, c.user, c.section, c.key, c.value)
-		if !equalError(c.wantErr, err) {
-			t.Fatalf("add %q: want err %q, got %q", c.label, errorString(c.wantErr), errorString(err))
+	for _, c := range testConstraints {
+		err := server.UpdateDefault(adminCtx, forge.DefaultUpdater{EntryType: c.typ, Category: "property", Name: c.k, Constraint: &c.c})
+		if !equalError(c.want, err) {
+			t.Fatalf("constraint: want err %q, got %q", errorString(c.want), errorString(err))
 		}
-		if c.wantErr != nil {
+	}
+	err = server.UpdateDefault(adminCtx, forge.DefaultUpdater{EntryType: "show", Category: "environ", Name: "LIBRARY_ROOT", Constraint: ptr("required")})
+	if !equalError(errors.New("constraint is only available for property defaults"), err) {
+		t.Fatalf("constraint: got unexpected error: %v", err)
+	}
+	// a new entry doesn't have to fill required properties at creation.
+	err = server.AddEntry(adminCtx, "/test/shot/cg/0040", "shot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = server.DeleteEntry(adminCtx, "/test/shot/cg/0040")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, prop := range testConstraintProps {
+		err := server.UpdateProperty(adminCtx, prop.path, prop.k, prop.v)
+		if !equalError(prop.want, err) {
+			t.Fatalf("constraint: want err %q, got %q", errorString(prop.want), errorString(err))
+		}
+		if prop.want != nil {
 			continue
 		}
-		value, err := server.GetUserData(adminCtx, c.user, c.section, c.key)
+		got, err := server.GetProperty(adminCtx, prop.path, prop.k)
 		if err != nil {
-			t.Fatalf("get %q: %v", c.label, err)
+			t.Fatal(err)
 		}
-		if value != c.value {
-			t.Fatalf("get %q: want %q, got %q", c.label, c.value, value)
+		if got.Eval != prop.expect {
+			t.Fatalf("constraint: want value %q, got %q", prop.expect, got.Eval)
 		}
-		err = server.SetUserData(adminCtx, c.user, c.section, c.key, "")
-		if err != nil {
-			t.Fatalf("update %q: %v", c.label, err)
+	}
+	// required properties can be filled with the condition at once.
+	wip := []forge.PropertyUpdater{
+		{EntryPath: "/test/shot/cg/0030/ani", Name: "status", Value: ptr("wip")},
+	}
+	err = server.DryRunUpdateProperties(adminCtx, wip)
+	if !equalError(errors.New("invalid value for part_due: value is required when status is wip"), err) {
+		t.Fatalf("constraint: got unexpected error: %v", err)
+	}
+	wip = append(wip, forge.PropertyUpdater{EntryPath: "/test/shot/cg/0030/ani", Name: "part_due", Value: ptr("2024/01/01")})
+	err = server.DryRunUpdateProperties(adminCtx, wip)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// dry run shouldn't change anything.
+	got, err = server.GetProperty(adminCtx, "/test/shot/cg/0030/ani", "status")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Eval != "" {
+		t.Fatalf("constraint: dry run changed the value: %q", got.Eval)
+	}
+	// clean-up
+	for _, c := range testConstraints {
+		if c.want != nil {
+			continue
 		}
-		value, err = server.GetUserData(adminCtx, c.user, c.section, c.key)
+		err := server.UpdateDefault(adminCtx, forge.DefaultUpdater{EntryType: c.typ, Category: "property", Name: c.k, Constraint: ptr("")})
 		if err != nil {
-			t.Fatalf("get after update %q: %v", c.label, err)
+			t.Fatal(err)
 		}
-		if value != "" {
-			t.Fatalf("get after update %q: want empty string, got %q", c.label, value)
+	}
+	for _, p := range []string{"/test/shot/cg/0010", "/test/shot/cg/0020", "/prop_owner/shot/cg/0010"} {
+		for _, k := range []string{"priority", "code"} {
+			err := server.UpdateProperty(adminCtx, p, k, "")
+			if err != nil {
+				t.Fatal(err)
+			}
 		}
 	}
-	for _, c := range userDataCases {
-		if c.wantErr != nil {
-			continue
+	// constraint can be set when adding a default.
+	err = server.AddDefault(adminCtx, &forge.Default{EntryType: "shot", Category: "environ", Name: "SHOT_ROOT", Type: "text", Constraint: "required"})
+	if !equalError(errors.New("constraint is only available for property defaults"), err) {
+		t.Fatalf("constraint: got unexpected error: %v", err)
+	}
+	err = server.AddDefault(adminCtx, &forge.Default{EntryType: "shot", Category: "property", Name: "lens", Type: "int", Constraint: "max: lol"})
+	if !equalError(errors.New("invalid constraint: max should be an integer: lol"), err) {
+		t.Fatalf("constraint: got unexpected error: %v", err)
+	}
+	_, err = server.GetProperty(adminCtx, "/test/shot/cg/0010", "lens")
+	if !equalError(errors.New("property not found: /test/shot/cg/0010.lens"), err) {
+		t.Fatalf("constraint: default with invalid constraint shouldn't be added: %v", errorString(err))
+	}
+	err = server.AddDefault(adminCtx, &forge.Default{EntryType: "shot", Category: "property", Name: "lens", Type: "int", Constraint: "max: 100"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = server.UpdateProperty(adminCtx, "/test/shot/cg/0010", "lens", "200")
+	if !equalError(errors.New("invalid value for lens: value should be less than or equal to 100: 200"), err) {
+		t.Fatalf("constraint: got unexpected error: %v", err)
+	}
+	err = server.DeleteDefault(adminCtx, "shot", "property", "lens")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// property meta is brought from the default.
+	meta := &forge.PropertyMeta{Label: "Resolution", Description: "undistorted plate size", Section: "plate", Order: 1, ReadOnly: true}
+	err = server.UpdateDefault(adminCtx, forge.DefaultUpdater{EntryType: "shot", Category: "environ", Name: "LIBRARY_ROOT", Meta: meta})
+	if !equalError(errors.New("meta is only available for property defaults"), err) {
+		t.Fatalf("meta: got unexpected error: %v", err)
+	}
+	err = server.UpdateDefault(adminCtx, forge.DefaultUpdater{EntryType: "shot", Category: "property", Name: "resolution", Meta: meta})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defs, err := server.Defaults(adminCtx, "shot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, d := range defs {
+		if d.Category == "property" && d.Name == "resolution" && !reflect.DeepEqual(d.PropertyMeta, *meta) {
+			t.Fatalf("meta: want default meta %v, got %v", *meta, d.PropertyMeta)
 		}
-		err = server.DeleteUserData(adminCtx, c.user, c.section, c.key)
+	}
+	got, err = server.GetProperty(adminCtx, "/test/shot/cg/0010", "resolution")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got.PropertyMeta, *meta) {
+		t.Fatalf("meta: want property meta %v, got %v", *meta, got.PropertyMeta)
+	}
+	writerCtx := forge.ContextWithUserName(bgCtx, "readwriter@imagvfx.com")
+	err = server.UpdateProperty(writerCtx, "/test/shot/cg/0010", "resolution", "1920x1080")
+	if !equalError(errors.New("property is read-only for non-admins: resolution"), err) {
+		t.Fatalf("meta: got unexpected error: %v", err)
+	}
+	err = server.UpdateProperty(adminCtx, "/test/shot/cg/0010", "resolution", "1920x1080")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = server.UpdateDefault(adminCtx, forge.DefaultUpdater{EntryType: "shot", Category: "property", Name: "resolution", Meta: &forge.PropertyMeta{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = server.UpdateProperty(writerCtx, "/test/shot/cg/0010", "resolution", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// meta and inheritable can be set when adding a default.
+	err = server.AddDefault(adminCtx, &forge.Default{EntryType: "shot", Category: "environ", Name: "PLATE_ROOT", Type: "text", PropertyMeta: *meta})
+	if !equalError(errors.New("meta is only available for property defaults"), err) {
+		t.Fatalf("meta: got unexpected error: %v", err)
+	}
+	err = server.AddDefault(adminCtx, &forge.Default{EntryType: "shot", Category: "property", Name: "plate", Type: "text", PropertyMeta: *meta, Inheritable: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err = server.GetProperty(adminCtx, "/test/shot/cg/0010", "plate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got.PropertyMeta, *meta) || !got.Inheritable {
+		t.Fatalf("meta: want property meta %v and inheritable, got %v and %v", *meta, got.PropertyMeta, got.Inheritable)
+	}
+	err = server.DeleteDefault(adminCtx, "shot", "property", "plate")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// an empty inheritable property takes the value of the nearest ancestor.
+	err = server.UpdateDefault(adminCtx, forge.DefaultUpdater{EntryType: "shot", Category: "property", Name: "lol", Inheritable: ptr(true)})
+	if !equalError(errors.New("default not found: shot: lol"), err) {
+		t.Fatalf("inherit: got unexpected error: %v", err)
+	}
+	err = server.UpdateDefault(adminCtx, forge.DefaultUpdater{EntryType: "shot", Category: "environ", Name: "LIBRARY_ROOT", Inheritable: ptr(true)})
+	if !equalError(errors.New("inheritable is only available for property defaults"), err) {
+		t.Fatalf("inherit: got unexpected error: %v", err)
+	}
+	for _, typ := range []string{"shot", "part"} {
+		err = server.UpdateDefault(adminCtx, forge.DefaultUpdater{EntryType: typ, Category: "property", Name: "client", Inheritable: ptr(true)})
 		if err != nil {
-			t.Fatalf("delete %q: %v", c.label, err)
+			t.Fatal(err)
 		}
 	}
-	data, err := server.FindUserData(adminCtx, forge.UserDataFinder{User: "admin@imagvfx.com"})
+	err = server.UpdateProperty(adminCtx, "/test", "client", "netflix")
 	if err != nil {
-		t.Fatalf("find: %v", err)
+		t.Fatal(err)
 	}
-	if len(data) != 0 {
-		t.Fatalf("find: want section length 0, got %v", len(data))
+	err = server.UpdateProperty(adminCtx, "/test/shot/cg/0010", "client", "hbo")
+	if err != nil {
+		t.Fatal(err)
 	}
-
-	// test environ after user data as environ overrided by user data.
-	for _, c := range testEntryEnviron {
-		envs, err := server.EntryEnvirons(adminCtx, c.path)
-		if !equalError(c.wantErr, err) {
-			t.Fatalf("environ: %q: %v", c.label, err)
+	testInherits := []struct {
+		path string
+		want string
+		from string
+	}{
+		{path: "/test/shot/cg/0010", want: "hbo", from: ""},
+		{path: "/test/shot/cg/0010/ani", want: "hbo", from: "/test/shot/cg/0010"},
+		{path: "/test/shot/cg/0020", want: "netflix", from: "/test"},
+		{path: "/test/shot/cg/0020/ani", want: "netflix", from: "/test"},
+		{path: "/prop_owner/shot/cg/0010", want: "", from: ""},
+	}
+	for _, c := range testInherits {
+		got, err := server.GetProperty(adminCtx, c.path, "client")
+		if err != nil {
+			t.Fatal(err)
 		}
-		var got string
-		for _, e := range envs {
-			if e.Name == c.env {
-				got = e.Eval
-				break
-			}
+		if got.Eval != c.want || got.InheritedFrom != c.from {
+			t.Fatalf("inherit: %v: want %q from %q, got %q from %q", c.path, c.want, c.from, got.Eval, got.InheritedFrom)
 		}
-		if got != c.want {
-			t.Fatalf("environ: %q: want %q, got %q", c.label, c.want, got)
+	}
+	testInheritSearches := []struct {
+		query string
+		want  []string
+	}{
+		{query: "type=shot client=netflix", want: []string{"/test/shot/cg/0020", "/test/shot/cg/0030"}},
+		{query: "type=shot client:flix", want: []string{"/test/shot/cg/0020", "/test/shot/cg/0030"}},
+		{query: "type=shot client!=netflix", want: []string{"/test/shot/cg/0010"}},
+		{query: "type=part client=hbo", want: []string{"/test/shot/cg/0010/ani", "/test/shot/cg/0010/lgt", "/test/shot/cg/0010/match", "/test/shot/cg/0010/mdl"}},
+	}
+	for _, c := range testInheritSearches {
+		ents, err := server.SearchEntries(adminCtx, "/test", c.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := make([]string, 0)
+		for _, e := range ents {
+			got = append(got, e.Path)
+		}
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, c.want) {
+			t.Fatalf("inherit: search %q: want %v, got %v", c.query, c.want, got)
 		}
 	}
+	err = server.UpdateDefault(adminCtx, forge.DefaultUpdater{EntryType: "part", Category: "property", Name: "client", Inheritable: ptr(false)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err = server.GetProperty(adminCtx, "/test/shot/cg/0010/ani", "client")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Eval != "" || got.InheritedFrom != "" {
+		t.Fatalf("inherit: not inheritable property got %q from %q", got.Eval, got.InheritedFrom)
+	}
+	err = server.UpdateDefault(adminCtx, forge.DefaultUpdater{EntryType: "shot", Category: "property", Name: "client", Inheritable: ptr(false)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []string{"/test", "/test/shot/cg/0010"} {
+		err = server.UpdateProperty(adminCtx, p, "client", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// default values could be templates, those are expanded at creation of an entry.
+	err = server.AddEnviron(adminCtx, "/test", "SHOW", "text", "TEST")
+	if err != nil {
+		t.Fatal(err)
+	}
+	testTemplateDefaults := []testDefault{
+		{typ: "asset", ctg: "property", k: "code", t: "text", v: "@{env.SHOW}_{parent.name}_{name}"},
+		{typ: "asset", ctg: "property", k: "asset_no", t: "text", v: "@A{seq:3}"},
+		{typ: "asset", ctg: "property", k: "asset_due", t: "date", v: "@today+14"},
+		{typ: "asset", ctg: "property", k: "asset_owner", t: "user", v: "@user"},
+		{typ: "asset", ctg: "property", k: "bad", t: "text", v: "@{lol}", want: errors.New("invalid template: unknown key: lol")},
+		{typ: "asset", ctg: "property", k: "bad", t: "text", v: "@{seq}{seq}", want: errors.New("invalid template: only one sequence is allowed: {seq}{seq}")},
+		{typ: "asset", ctg: "property", k: "bad", t: "text", v: "@{name", want: errors.New("invalid template: unclosed '{': {name")},
+	}
+	for _, def := range testTemplateDefaults {
+		err := server.AddDefault(adminCtx, &forge.Default{EntryType: def.typ, Category: def.ctg, Name: def.k, Type: def.t, Value: def.v})
+		if !equalError(def.want, err) {
+			t.Fatalf("template: want err %q, got %q", errorString(def.want), errorString(err))
+		}
+	}
+	for _, pth := range []string{"/test/asset/char/robot", "/test/asset/set/tree"} {
+		err = server.AddEntry(adminCtx, pth, "asset")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	due := time.Now().Local().Add(14 * 24 * time.Hour).Format("2006/01/02")
+	testTemplateValues := []struct {
+		path string
+		k    string
+		want string
+	}{
+		// existing entries have expanded values as well.
+		{path: "/test/asset/char/yb", k: "code", want: "TEST_char_yb"},
+		{path: "/test/asset/set/cabin", k: "asset_no", want: "A001"},
+		{path: "/test/asset/char/robot", k: "code", want: "TEST_char_robot"},
+		{path: "/test/asset/char/robot", k: "asset_no", want: "A005"},
+		{path: "/test/asset/set/tree", k: "asset_no", want: "A002"},
+		{path: "/test/asset/char/robot", k: "asset_due", want: due},
+		{path: "/test/asset/char/robot", k: "asset_owner", want: "admin@imagvfx.com"},
+	}
+	for _, c := range testTemplateValues {
+		got, err := server.GetProperty(adminCtx, c.path, c.k)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Value != c.want {
+			t.Fatalf("template: %v.%v: want %q, got %q", c.path, c.k, c.want, got.Value)
+		}
+	}
+	for _, pth := range []string{"/test/asset/char/robot", "/test/asset/set/tree"} {
+		err = server.DeleteEntry(adminCtx, pth)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, def := range testTemplateDefaults {
+		if def.want != nil {
+			continue
+		}
+		err = server.DeleteDefault(adminCtx, def.typ, def.ctg, def.k)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = server.DeleteEnviron(adminCtx, "/test", "SHOW")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// templates are opt-in, values having braces without '@' are literal.
+	err = server.AddDefault(adminCtx, &forge.Default{EntryType: "asset", Category: "property", Name: "extra", Type: "text", Value: "{}"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = server.AddEntry(adminCtx, "/test/asset/char/robot", "asset")
+	if err != nil {
+		t.Fatal(err)
+	}
+	testLiteralValues := []struct {
+		value string
+		want  string
+	}{
+		{value: "", want: "{}"},
+		// update of the default value propagates to the entries having the old value.
+		{value: `{"a": 1}`, want: `{"a": 1}`},
+	}
+	for _, c := range testLiteralValues {
+		if c.value != "" {
+			err = server.UpdateDefault(adminCtx, forge.DefaultUpdater{EntryType: "asset", Category: "property", Name: "extra", Value: &c.value})
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		for _, pth := range []string{"/test/asset/char/yb", "/test/asset/char/robot"} {
+			got, err := server.GetProperty(adminCtx, pth, "extra")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Value != c.want {
+				t.Fatalf("literal default: %v: want %q, got %q", pth, c.want, got.Value)
+			}
+		}
+	}
+	err = server.DeleteEntry(adminCtx, "/test/asset/char/robot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = server.DeleteDefault(adminCtx, "asset", "property", "extra")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// test naming rules and auto numbering.
+	err = server.AddGlobal(adminCtx, "shot", "naming_rule", "text", "step: ten")
+	if !equalError(errors.New("invalid naming rule: step should be a positive integer: ten"), err) {
+		t.Fatalf("naming rule: want err about step, got %q", errorString(err))
+	}
+	err = server.AddGlobal(adminCtx, "shot", "naming_rule", "text", "padding: 4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	testNamingRules := []struct {
+		path    string
+		typ     string
+		wantErr error
+	}{
+		{path: "/test/shot/cg/x1", typ: "shot", wantErr: errors.New("invalid entry name for shot: name violates the naming rule (padding: 4): x1")},
+		{path: "/test/shot/cg/+", typ: "shot", wantErr: errors.New("cannot allocate entry name for shot: auto numbering is not enabled, naming rule should have step")},
+		{path: "/test/shot/cg/0010/+", typ: "part", wantErr: errors.New("cannot allocate entry name for part: auto numbering is not enabled, naming rule should have step")},
+	}
+	for _, c := range testNamingRules {
+		err := server.AddEntry(adminCtx, c.path, c.typ)
+		if !equalError(c.wantErr, err) {
+			t.Fatalf("naming rule: add %v: want err %q, got %q", c.path, errorString(c.wantErr), errorString(err))
+		}
+	}
+	err = server.RenameEntry(adminCtx, "/test/shot/cg/0030", "sh0030")
+	if !equalError(errors.New("invalid entry name for shot: name violates the naming rule (padding: 4): sh0030"), err) {
+		t.Fatalf("naming rule: rename: want err about padding, got %q", errorString(err))
+	}
+	err = server.UpdateGlobal(adminCtx, "shot", "naming_rule", "text", "suffix: _v")
+	if !equalError(errors.New("invalid naming rule: unknown key: suffix"), err) {
+		t.Fatalf("naming rule: want err about suffix, got %q", errorString(err))
+	}
+	err = server.UpdateGlobal(adminCtx, "shot", "naming_rule", "text", "padding: 4\nstep: 10")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pth, err := server.AddNumberedEntry(adminCtx, "/test/shot/cg", "shot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pth != "/test/shot/cg/0040" {
+		t.Fatalf("naming rule: want /test/shot/cg/0040, got %v", pth)
+	}
+	// concurrent allocations shouldn't get the same name.
+	nAlloc := 8
+	allocated := make(chan string, nAlloc)
+	allocErrs := make(chan error, nAlloc)
+	var wg sync.WaitGroup
+	for i := 0; i < nAlloc; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			pth, err := server.AddNumberedEntry(adminCtx, "/test/shot/cg", "shot")
+			if err != nil {
+				allocErrs <- err
+				return
+			}
+			allocated <- pth
+		}()
+	}
+	wg.Wait()
+	close(allocated)
+	close(allocErrs)
+	for err := range allocErrs {
+		t.Fatalf("naming rule: concurrent allocation: %v", err)
+	}
+	allocPaths := []string{pth}
+	for p := range allocated {
+		allocPaths = append(allocPaths, p)
+	}
+	sort.Strings(allocPaths)
+	wantPaths := make([]string, 0)
+	for i := 0; i <= nAlloc; i++ {
+		wantPaths = append(wantPaths, fmt.Sprintf("/test/shot/cg/%04d", 40+i*10))
+	}
+	if !reflect.DeepEqual(allocPaths, wantPaths) {
+		t.Fatalf("naming rule: concurrent allocation: want %v, got %v", wantPaths, allocPaths)
+	}
+	for _, p := range allocPaths {
+		err = server.DeleteEntry(adminCtx, p)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = server.DeleteGlobal(adminCtx, "shot", "naming_rule")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// test batch creation of entries with a pattern.
+	adds, exists, err := server.DryRunAddEntries(adminCtx, "/test/shot/cg", "[0010-0050:10]", "shot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(adds, []string{"/test/shot/cg/0040", "/test/shot/cg/0050"}) {
+		t.Fatalf("dry run add entries: unexpected paths to add: %v", adds)
+	}
+	if !reflect.DeepEqual(exists, []string{"/test/shot/cg/0010", "/test/shot/cg/0020", "/test/shot/cg/0030"}) {
+		t.Fatalf("dry run add entries: unexpected existing paths: %v", exists)
+	}
+	testAddEntries := []struct {
+		pattern string
+		want    []string
+		wantErr error
+	}{
+		{pattern: "[0010-0050:10]", wantErr: errors.New("entries exist: /test/shot/cg/0010, /test/shot/cg/0020, /test/shot/cg/0030")},
+		// nothing should be added when one of them fails.
+		{pattern: "[0040-0050:10],#0060", wantErr: errors.New("entry name has invalid character '#': /test/shot/cg/#0060")},
+		{pattern: "[0040-0050:10", wantErr: errors.New("invalid entry pattern: unclosed '[': [0040-0050:10")},
+		{pattern: "[0040-0060:10]", want: []string{"/test/shot/cg/0040", "/test/shot/cg/0050", "/test/shot/cg/0060"}},
+	}
+	for _, c := range testAddEntries {
+		got, err := server.AddEntries(adminCtx, "/test/shot/cg", c.pattern, "shot")
+		if !equalError(c.wantErr, err) {
+			t.Fatalf("add entries %q: want err %q, got %q", c.pattern, errorString(c.wantErr), errorString(err))
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Fatalf("add entries %q: want %v, got %v", c.pattern, c.want, got)
+		}
+	}
+	// defaults should be applied to the entries.
+	for _, pth := range []string{"/test/shot/cg/0040", "/test/shot/cg/0050", "/test/shot/cg/0060"} {
+		_, err := server.GetProperty(adminCtx, pth, "resolution")
+		if err != nil {
+			t.Fatalf("add entries: %v", err)
+		}
+		err = server.DeleteEntry(adminCtx, pth)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// test batch renames.
+	err = server.UpdateProperty(adminCtx, "/test/shot/cg/0030", "SHOT_PATH", "/test/shot/cg/0010")
+	if err != nil {
+		t.Fatal(err)
+	}
+	testBatchRenames := []struct {
+		renames map[string]string
+		wantErr error
+	}{
+		{renames: map[string]string{"0010": "0020"}, wantErr: errors.New("rename target path already exists: /test/shot/cg/0020")},
+		{renames: map[string]string{"0010": "0040", "0020": "0040"}, wantErr: errors.New("cannot rename both 0010 and 0020 to 0040")},
+		// an entry keeping its name still occupies the name.
+		{renames: map[string]string{"0010": "0020", "0020": "0020"}, wantErr: errors.New("rename target path already exists: /test/shot/cg/0020")},
+		{renames: map[string]string{"0010": "#0010"}, wantErr: errors.New("entry name has invalid character '#': #0010")},
+		{renames: map[string]string{"0040": "0050"}, wantErr: errors.New("entry not found: /test/shot/cg/0040")},
+		// rotate the names.
+		{renames: map[string]string{"0010": "0020", "0020": "0030", "0030": "0010"}},
+	}
+	for _, c := range testBatchRenames {
+		err := server.RenameEntries(adminCtx, "/test/shot/cg", c.renames)
+		if !equalError(c.wantErr, err) {
+			t.Fatalf("batch rename %v: want err %q, got %q", c.renames, errorString(c.wantErr), errorString(err))
+		}
+	}
+	// sub entries and references should follow the renamed entries.
+	_, err = server.GetEntry(adminCtx, "/test/shot/cg/0020/lgt")
+	if err != nil {
+		t.Fatalf("batch rename: %v", err)
+	}
+	got, err = server.GetProperty(adminCtx, "/test/shot/cg/0010", "SHOT_PATH")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// entry_path shows the path relative to the entry.
+	if got.Value != "../0020" {
+		t.Fatalf("batch rename: want reference to ../0020, got %q", got.Value)
+	}
+	renameLogs, err := server.GetLogs(adminCtx, "/test/shot/cg", "sub_entry", "renames")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(renameLogs) != 1 || renameLogs[0].Value != "0010,0020\n0020,0030\n0030,0010" {
+		t.Fatalf("batch rename: unexpected logs: %v", renameLogs)
+	}
+	err = server.RenameEntries(adminCtx, "/test/shot/cg", map[string]string{"0020": "0010", "0030": "0020", "0010": "0030"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	renames, err := server.RenameEntriesWithRule(adminCtx, "/test/shot/cg", nil, "renumber 15 10")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(renames, map[string]string{"0010": "0015", "0020": "0025", "0030": "0035"}) {
+		t.Fatalf("batch rename with rule: unexpected renames: %v", renames)
+	}
+	_, err = server.GetEntry(adminCtx, "/test/shot/cg/0015/lgt")
+	if err != nil {
+		t.Fatalf("batch rename with rule: %v", err)
+	}
+	_, err = server.RenameEntriesWithRule(adminCtx, "/test/shot/cg", nil, "renumber 10 10")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = server.UpdateProperty(adminCtx, "/test/shot/cg/0030", "SHOT_PATH", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// test entry ids those survive renames.
+	ent, err := server.GetEntry(adminCtx, "/test/shot/cg/0010")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = server.RenameEntry(adminCtx, "/test/shot/cg/0010", "0011")
+	if err != nil {
+		t.Fatal(err)
+	}
+	idEnt, err := server.GetEntryByID(adminCtx, ent.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if idEnt.Path != "/test/shot/cg/0011" {
+		t.Fatalf("get entry by id: want /test/shot/cg/0011, got %v", idEnt.Path)
+	}
+	err = server.RenameEntry(adminCtx, "/test/shot/cg/0011", "0010")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = server.GetEntryByID(adminCtx, -1)
+	if !equalError(errors.New("entry not found: -1"), err) {
+		t.Fatalf("get entry by id: want not found error, got %q", errorString(err))
+	}
+	// old paths should lead to the current paths.
+	testMoved := []struct {
+		path    string
+		wantErr error
+	}{
+		{path: "/test/shot/cg/0011", wantErr: errors.New("entry moved to /test/shot/cg/0010: /test/shot/cg/0011")},
+		{path: "/test/shot/cg/0011/lgt", wantErr: errors.New("entry moved to /test/shot/cg/0010/lgt: /test/shot/cg/0011/lgt")},
+		// renamed by batch renames above.
+		{path: "/test/shot/cg/0035", wantErr: errors.New("entry moved to /test/shot/cg/0030: /test/shot/cg/0035")},
+		{path: "/test/shot/cg/0012", wantErr: errors.New("entry not found: /test/shot/cg/0012")},
+	}
+	for _, c := range testMoved {
+		_, err := server.GetEntry(adminCtx, c.path)
+		if !equalError(c.wantErr, err) {
+			t.Fatalf("get moved entry %v: want err %q, got %q", c.path, errorString(c.wantErr), errorString(err))
+		}
+		var e *forge.NotFoundError
+		if !errors.As(err, &e) {
+			t.Fatalf("get moved entry %v: should be a not found error", c.path)
+		}
+	}
+
+	// referenced entries cannot be deleted.
+	err = server.UpdateProperty(adminCtx, "/test/shot/cg/0010/ani", "shot_due", "../../0020.due")
+	if err != nil {
+		t.Fatal(err)
+	}
+	testRefs := []struct {
+		path string
+		want []string
+	}{
+		{path: "/test/asset/char/human1", want: []string{"/test/shot/cg/0020.asset", "/test/shot/cg/0030.asset"}},
+		{path: "/test/asset/char/android", want: []string{}},
+		{path: "/test/shot/cg/0020", want: []string{"/test/shot/cg/0010/ani.shot_due"}},
+		// "." of SHOT_PATH is the entry itself.
+		{path: "/test/shot/cg/0010", want: []string{}},
+	}
+	for _, c := range testRefs {
+		refs, err := server.FindReferences(adminCtx, c.path)
+		if err != nil {
+			t.Fatalf("find references of %v: %v", c.path, err)
+		}
+		got := make([]string, 0, len(refs))
+		for _, r := range refs {
+			if r.Target != c.path {
+				t.Fatalf("find references of %v: got reference to %v", c.path, r.Target)
+			}
+			got = append(got, r.EntryPath+"."+r.Name)
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Fatalf("find references of %v: want %v, got %v", c.path, c.want, got)
+		}
+	}
+	err = server.DeleteEntry(adminCtx, "/test/asset/char/human1")
+	want := errors.New("entry is referenced by other entries, remove the references first: /test/asset/char/human1: /test/shot/cg/0020.asset, /test/shot/cg/0030.asset")
+	if !equalError(want, err) {
+		t.Fatalf("delete referenced entry: want err %q, got %q", errorString(want), errorString(err))
+	}
+	err = server.DeleteEntryRecursive(adminCtx, "/test/asset")
+	want = errors.New("entry is referenced by other entries, remove the references first: /test/asset: /test/shot/cg/0020.asset, /test/shot/cg/0030.asset")
+	if !equalError(want, err) {
+		t.Fatalf("delete entry with referenced sub entries: want err %q, got %q", errorString(want), errorString(err))
+	}
+	err = server.DeleteEntryRecursive(adminCtx, "/test/shot/cg/0020")
+	want = errors.New("entry is referenced by other entries, remove the references first: /test/shot/cg/0020: /test/shot/cg/0010/ani.shot_due")
+	if !equalError(want, err) {
+		t.Fatalf("delete referenced entry: want err %q, got %q", errorString(want), errorString(err))
+	}
+	err = server.UpdateProperty(adminCtx, "/test/shot/cg/0010/ani", "shot_due", "^shot.due")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// relations
+	testAddRelations := []struct {
+		from    string
+		to      string
+		typ     string
+		wantErr error
+	}{
+		{from: "/test/shot/cg/0010", to: "/test/asset/char/human1", typ: "uses"},
+		{from: "/test/shot/cg/0010", to: "/test/shot/cg/0020", typ: "depends_on"},
+		{from: "/test/shot/cg/0020", to: "/test/asset/char/human1", typ: "uses"},
+		{from: "/test/asset/char/human1", to: "/test/asset/char/android", typ: "derives_from"},
+		{from: "/test/asset/char/android", to: "/test/shot/cg/0010", typ: "relates_to"},
+		{from: "/test/asset/char/android", to: "/test/shot/cg/0010", typ: "derives_from", wantErr: errors.New("relation makes a dependency cycle: /test/asset/char/android derives_from /test/shot/cg/0010")},
+		{from: "/test/shot/cg/0020", to: "/test/shot/cg/0010", typ: "depends_on", wantErr: errors.New("relation makes a dependency cycle: /test/shot/cg/0020 depends_on /test/shot/cg/0010")},
+		{from: "/test/shot/cg/0010", to: "/test/asset/char/human1", typ: "uses", wantErr: errors.New("relation already exists: /test/shot/cg/0010 uses /test/asset/char/human1")},
+		{from: "/test/shot/cg/0010", to: "/test/shot/cg/0010", typ: "relates_to", wantErr: errors.New("cannot relate an entry to itself: /test/shot/cg/0010")},
+		{from: "/test/shot/cg/0010", to: "/test/asset/char/yb", typ: "likes", wantErr: errors.New("invalid relation type: likes")},
+		{from: "/test/shot/cg/0010", to: "/test/asset/char/not-exist", typ: "uses", wantErr: errors.New("entry not found: /test/asset/char/not-exist")},
+	}
+	for _, c := range testAddRelations {
+		err := server.AddRelation(adminCtx, c.from, c.to, c.typ)
+		if !equalError(c.wantErr, err) {
+			t.Fatalf("add relation %v %v %v: want err %q, got %q", c.from, c.typ, c.to, errorString(c.wantErr), errorString(err))
+		}
+	}
+	relStrings := func(rels []*forge.Relation) []string {
+		s := make([]string, 0, len(rels))
+		for _, r := range rels {
+			s = append(s, r.From+" "+r.Type+" "+r.To)
+		}
+		return s
+	}
+	rels, err := server.EntryRelations(adminCtx, "/test/asset/char/human1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantRels := []string{
+		"/test/asset/char/human1 derives_from /test/asset/char/android",
+		"/test/shot/cg/0010 uses /test/asset/char/human1",
+		"/test/shot/cg/0020 uses /test/asset/char/human1",
+	}
+	if !reflect.DeepEqual(relStrings(rels), wantRels) {
+		t.Fatalf("entry relations: want %v, got %v", wantRels, relStrings(rels))
+	}
+	testGraphs := []struct {
+		find    forge.RelationGraphFinder
+		want    []string
+		wantErr error
+	}{
+		{
+			find: forge.RelationGraphFinder{Path: "/test/shot/cg/0010", Direction: "upstream"},
+			want: []string{
+				"/test/shot/cg/0010 depends_on /test/shot/cg/0020",
+				"/test/shot/cg/0010 uses /test/asset/char/human1",
+				"/test/shot/cg/0020 uses /test/asset/char/human1",
+				"/test/asset/char/human1 derives_from /test/asset/char/android",
+				"/test/asset/char/android relates_to /test/shot/cg/0010",
+			},
+		},
+		{
+			find: forge.RelationGraphFinder{Path: "/test/shot/cg/0010", Direction: "upstream", Depth: 1},
+			want: []string{
+				"/test/shot/cg/0010 depends_on /test/shot/cg/0020",
+				"/test/shot/cg/0010 uses /test/asset/char/human1",
+			},
+		},
+		{
+			find: forge.RelationGraphFinder{Path: "/test/shot/cg/0010", Direction: "upstream", Types: []string{"uses", "derives_from"}},
+			want: []string{
+				"/test/shot/cg/0010 uses /test/asset/char/human1",
+				"/test/asset/char/human1 derives_from /test/asset/char/android",
+			},
+		},
+		{
+			find: forge.RelationGraphFinder{Path: "/test/asset/char/android", Direction: "downstream", Types: forge.DependencyRelationTypes()},
+			want: []string{
+				"/test/asset/char/human1 derives_from /test/asset/char/android",
+				"/test/shot/cg/0010 uses /test/asset/char/human1",
+				"/test/shot/cg/0020 uses /test/asset/char/human1",
+				"/test/shot/cg/0010 depends_on /test/shot/cg/0020",
+			},
+		},
+		{
+			find: forge.RelationGraphFinder{Path: "/test/asset/char/yb", Direction: "downstream"},
+			want: []string{},
+		},
+		{
+			find:    forge.RelationGraphFinder{Path: "/test/asset/char/yb", Direction: "sideways"},
+			wantErr: errors.New("relation direction should be upstream or downstream, got: sideways"),
+		},
+	}
+	for _, c := range testGraphs {
+		g, err := server.RelationGraph(adminCtx, c.find)
+		if !equalError(c.wantErr, err) {
+			t.Fatalf("relation graph %v: want err %q, got %q", c.find, errorString(c.wantErr), errorString(err))
+		}
+		if err != nil {
+			continue
+		}
+		got := relStrings(g.Relations)
+		if !reflect.DeepEqual(got, c.want) {
+			t.Fatalf("relation graph %v: want %v, got %v", c.find, c.want, got)
+		}
+	}
+	err = server.DeleteRelation(adminCtx, "/test/shot/cg/0010", "/test/asset/char/yb", "uses")
+	want = errors.New("relation not found: /test/shot/cg/0010 uses /test/asset/char/yb")
+	if !equalError(want, err) {
+		t.Fatalf("delete relation: want err %q, got %q", errorString(want), errorString(err))
+	}
+	for _, c := range testAddRelations {
+		if c.wantErr != nil {
+			continue
+		}
+		err := server.DeleteRelation(adminCtx, c.from, c.to, c.typ)
+		if err != nil {
+			t.Fatalf("delete relation %v %v %v: %v", c.from, c.typ, c.to, err)
+		}
+	}
+	rels, err = server.EntryRelations(adminCtx, "/test/asset/char/human1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rels) != 0 {
+		t.Fatalf("entry relations: want none after delete, got %v", relStrings(rels))
+	}
+
+	// test renames and revert it back.
+	for _, rename := range testRenames {
+		dir := path.Dir(rename.path)
+		oldName := path.Base(rename.path)
+		err := server.RenameEntry(adminCtx, rename.path, rename.newName)
+		if !equalError(rename.wantErr, err) {
+			t.Fatalf("rename %q to %q: want err %q, got %q", rename.path, rename.newName, errorString(rename.wantErr), errorString(err))
+		}
+		if err != nil {
+			// The rename wasn't done, no need to revert.
+			continue
+		}
+		// revert
+		newPath := path.Join(dir, rename.newName)
+		err = server.RenameEntry(adminCtx, newPath, oldName)
+		if err != nil {
+			t.Fatalf("rename %q to %q: revert got unwanted err: %v", rename.path, rename.newName, err)
+		}
+	}
+
+	// search
+	whoCanRead := []string{"admin@imagvfx.com", "readwriter@imagvfx.com", "reader@imagvfx.com"}
+	for _, user := range whoCanRead {
+		ctx := forge.ContextWithUserName(bgCtx, user)
+		for _, s := range testSearches {
+			ents, err := server.SearchEntries(ctx, s.path, s.query)
+			if !equalError(s.wantErr, err) {
+				t.Fatalf("searched %q from %q: got error %q, want %q", s.query, s.path, errorString(err), errorString(s.wantErr))
+			}
+			got := make([]string, 0)
+			for _, e := range ents {
+				got = append(got, e.Path)
+			}
+			sort.Strings(got)
+			sort.Strings(s.wantRes)
+			if !reflect.DeepEqual(got, s.wantRes) {
+				t.Fatalf("searched %q from %q: got %q, want %q", s.query, s.path, got, s.wantRes)
+			}
+		}
+	}
+	whoCannotRead := []string{"uninvited@imagvfx.com"}
+	for _, user := range whoCannotRead {
+		ctx := forge.ContextWithUserName(bgCtx, user)
+		for _, s := range testSearches {
+			ents, _ := server.SearchEntries(ctx, s.path, s.query)
+			got := make([]string, 0)
+			for _, e := range ents {
+				got = append(got, e.Path)
+			}
+			if len(got) != 0 {
+				if len(got) == 1 && got[0] == "/" {
+					// anyone can search root, even to an uninvited user
+					continue
+				}
+				t.Fatalf("searched %q from %q: uninvited user shouldn't be able to search child entries, got: %v", s.query, s.path, got)
+			}
+		}
+	}
+
+	// test find
+	for _, f := range testFinds {
+		ents, err := server.FindEntries(adminCtx, f.finder)
+		if err != nil {
+			t.Fatalf("find: %v", err)
+		}
+		got := make([]string, 0)
+		for _, e := range ents {
+			got = append(got, e.Path)
+		}
+		sort.Strings(got)
+		sort.Strings(f.wantRes)
+		if !reflect.DeepEqual(got, f.wantRes) {
+			t.Fatalf("find: %v: got %q, want %q", f.label, got, f.wantRes)
+		}
+	}
+
+	// test delete
+	for _, delete := range testDeletes {
+		err := server.DeleteEntry(adminCtx, delete.path)
+		if !equalError(delete.wantErr, err) {
+			t.Fatalf("delete %q: want err %q, got %q", delete.path, errorString(delete.wantErr), errorString(err))
+		}
+	}
+
+	// test user data
+	for _, c := range userDataCases {
+		err := server.SetUserData(adminCtx, c.user, c.section, c.key, c.value)
+		if !equalError(c.wantErr, err) {
+			t.Fatalf("add %q: want err %q, got %q", c.label, errorString(c.wantErr), errorString(err))
+		}
+		if c.wantErr != nil {
+			continue
+		}
+		value, err := server.GetUserData(adminCtx, c.user, c.section, c.key)
+		if err != nil {
+			t.Fatalf("get %q: %v", c.label, err)
+		}
+		if value != c.value {
+			t.Fatalf("get %q: want %q, got %q", c.label, c.value, value)
+		}
+		err = server.SetUserData(adminCtx, c.user, c.section, c.key, "")
+		if err != nil {
+			t.Fatalf("update %q: %v", c.label, err)
+		}
+		value, err = server.GetUserData(adminCtx, c.user, c.section, c.key)
+		if err != nil {
+			t.Fatalf("get after update %q: %v", c.label, err)
+		}
+		if value != "" {
+			t.Fatalf("get after update %q: want empty string, got %q", c.label, value)
+		}
+	}
+	for _, c := range userDataCases {
+		if c.wantErr != nil {
+			continue
+		}
+		err = server.DeleteUserData(adminCtx, c.user, c.section, c.key)
+		if err != nil {
+			t.Fatalf("delete %q: %v", c.label, err)
+		}
+	}
+	data, err := server.FindUserData(adminCtx, forge.UserDataFinder{User: "admin@imagvfx.com"})
+	if err != nil {
+		t.Fatalf("find: %v", err)
+	}
+	if len(data) != 0 {
+		t.Fatalf("find: want section length 0, got %v", len(data))
+	}
+
+	// test environ after user data as environ overrided by user data.
+	for _, c := range testEntryEnviron {
+		envs, err := server.EntryEnvirons(adminCtx, c.path)
+		if !equalError(c.wantErr, err) {
+			t.Fatalf("environ: %q: %v", c.label, err)
+		}
+		var got string
+		for _, e := range envs {
+			if e.Name == c.env {
+				got = e.Eval
+				break
+			}
+		}
+		if got != c.want {
+			t.Fatalf("environ: %q: want %q, got %q", c.label, c.want, got)
+		}
+	}
+}
+
+func TestFormulaRefresh(t *testing.T) {
+	db, server, err := testDB(t)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	bgCtx := context.Background()
+	adminCtx := forge.ContextWithUserName(bgCtx, "admin@imagvfx.com")
+	err = server.AddUser(bgCtx, &forge.User{Name: "admin@imagvfx.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = server.AddEntryType(adminCtx, "shot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, d := range []struct {
+		k string
+		t string
+		v string
+	}{
+		{k: "duration", t: "text", v: ""},
+		// total refers frames that is defined later, it should be evaluated after frames.
+		{k: "total", t: "formula", v: "frames + 1"},
+		{k: "frames", t: "formula", v: "duration * 2"},
+		{k: "siblings", t: "formula", v: `sum(duration, "../")`},
+	} {
+		err = server.AddDefault(adminCtx, &forge.Default{EntryType: "shot", Category: "property", Name: d.k, Type: d.t, Value: d.v})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, pth := range []string{"/sh0010", "/sh0020", "/sh0030"} {
+		err = server.AddEntry(adminCtx, pth, "shot")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = server.UpdateProperty(adminCtx, "/sh0010", "duration", "5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = server.UpdateProperty(adminCtx, "/sh0020", "duration", "3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		path string
+		k    string
+		want string
+	}{
+		{path: "/sh0010", k: "frames", want: "10"},
+		{path: "/sh0010", k: "total", want: "11"},
+		{path: "/sh0010", k: "siblings", want: "3"},
+		{path: "/sh0020", k: "siblings", want: "5"},
+		{path: "/sh0030", k: "siblings", want: "8"},
+	}
+	for _, c := range cases {
+		p, err := server.GetProperty(adminCtx, c.path, c.k)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.Eval != c.want {
+			t.Fatalf("%v.%v: want %v, got %v", c.path, c.k, c.want, p.Eval)
+		}
+	}
+	// siblings are refreshed when an entry is deleted.
+	err = server.DeleteEntry(adminCtx, "/sh0010")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := server.GetProperty(adminCtx, "/sh0030", "siblings")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Eval != "3" {
+		t.Fatalf("/sh0030.siblings after deletion of /sh0010: want 3, got %v", p.Eval)
+	}
+}
+
+func TestFormulaHiddenEntries(t *testing.T) {
+	db, server, err := testDB(t)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	bgCtx := context.Background()
+	adminCtx := forge.ContextWithUserName(bgCtx, "admin@imagvfx.com")
+	for _, u := range []string{"admin@imagvfx.com", "artist@imagvfx.com"} {
+		err = server.AddUser(bgCtx, &forge.User{Name: u})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, typ := range []string{"show", "shot"} {
+		err = server.AddEntryType(adminCtx, typ)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, d := range []struct {
+		typ string
+		k   string
+		t   string
+		v   string
+	}{
+		{typ: "show", k: "shots", t: "formula", v: `count("type=shot")`},
+		{typ: "shot", k: "duration", t: "text", v: "1"},
+		{typ: "shot", k: "siblings", t: "formula", v: `sum(duration, "../")`},
+	} {
+		err = server.AddDefault(adminCtx, &forge.Default{EntryType: d.typ, Category: "property", Name: d.k, Type: d.t, Value: d.v})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = server.AddEntry(adminCtx, "/show", "show")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, pth := range []string{"/show/sh0010", "/show/sh0020", "/show/sh0030"} {
+		err = server.AddEntry(adminCtx, pth, "shot")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = server.AddAccess(adminCtx, "/show", "artist@imagvfx.com", "r")
+	if err != nil {
+		t.Fatal(err)
+	}
+	type propValue struct {
+		path string
+		k    string
+		want string
+	}
+	check := func(label string, cases []propValue) {
+		t.Helper()
+		for _, c := range cases {
+			p, err := server.GetProperty(adminCtx, c.path, c.k)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if p.Eval != c.want {
+				t.Fatalf("%v: %v.%v: want %v, got %v", label, c.path, c.k, c.want, p.Eval)
+			}
+		}
+	}
+	check("public", []propValue{{"/show", "shots", "3"}, {"/show/sh0010", "siblings", "2"}})
+	// formula results are shown to every reader, so they shouldn't count entries hidden from some of them.
+	err = server.AddAccess(adminCtx, "/show/sh0020", "artist@imagvfx.com", "none")
+	if err != nil {
+		t.Fatal(err)
+	}
+	check("hidden", []propValue{{"/show", "shots", "2"}, {"/show/sh0010", "siblings", "1"}})
+	// siblings cannot be searched from an entry visible to users those cannot see the parent.
+	err = server.AddAccess(adminCtx, "/show/sh0030", "everyone", "r")
+	if err != nil {
+		t.Fatal(err)
+	}
+	check("granted", []propValue{{"/show", "shots", "2"}, {"/show/sh0030", "siblings", "0"}})
+	err = server.DeleteAccess(adminCtx, "/show/sh0020", "artist@imagvfx.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = server.DeleteAccess(adminCtx, "/show/sh0030", "everyone")
+	if err != nil {
+		t.Fatal(err)
+	}
+	check("revealed", []propValue{{"/show", "shots", "3"}, {"/show/sh0030", "siblings", "2"}})
+}
+
+func TestForceDeleteEntry(t *testing.T) {
+	db, server, err := testDB(t)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	bgCtx := context.Background()
+	adminCtx := forge.ContextWithUserName(bgCtx, "admin@imagvfx.com")
+	writerCtx := forge.ContextWithUserName(bgCtx, "writer@imagvfx.com")
+	// first user who was added to the db becomes an admin
+	for _, user := range []string{"admin@imagvfx.com", "writer@imagvfx.com"} {
+		err = server.AddUser(bgCtx, &forge.User{Name: user})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, typ := range []string{"asset", "shot"} {
+		err = server.AddEntryType(adminCtx, typ)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = server.AddDefault(adminCtx, &forge.Default{EntryType: "shot", Category: "property", Name: "asset", Type: "entry_path"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range []struct{ path, typ string }{{"/asset", "asset"}, {"/asset/human", "asset"}, {"/sh0010", "shot"}} {
+		err = server.AddEntry(adminCtx, e.path, e.typ)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = server.AddAccess(adminCtx, "/", "writer@imagvfx.com", "rw")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = server.UpdateProperty(adminCtx, "/sh0010", "asset", "/asset/human")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = server.DeleteEntryRecursive(adminCtx, "/asset")
+	want := errors.New("entry is referenced by other entries, remove the references first: /asset: /sh0010.asset")
+	if !equalError(want, err) {
+		t.Fatalf("delete referenced entry: want err %q, got %q", errorString(want), errorString(err))
+	}
+	// only admins can delete referenced entries anyway.
+	err = server.ForceDeleteEntryRecursive(writerCtx, "/asset")
+	want = errors.New("only admins can delete entries referenced by other entries: /asset")
+	if !equalError(want, err) {
+		t.Fatalf("force delete referenced entry: want err %q, got %q", errorString(want), errorString(err))
+	}
+	err = server.ForceDeleteEntryRecursive(adminCtx, "/asset")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = server.GetEntry(adminCtx, "/asset/human")
+	want = errors.New("entry not found: /asset/human")
+	if !equalError(want, err) {
+		t.Fatalf("force delete referenced entry: want err %q, got %q", errorString(want), errorString(err))
+	}
 }
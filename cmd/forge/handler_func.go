@@ -35,6 +35,7 @@ var pageHandlerFuncs = template.FuncMap{
 	"hasPrefix":  strings.HasPrefix,
 	"trim":       strings.TrimSpace,
 	"trimPrefix": strings.TrimPrefix,
+	"join":       strings.Join,
 	"remapFrom": func(s string) string {
 		return strings.Split(s, ";")[0]
 	},
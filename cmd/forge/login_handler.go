@@ -1,23 +1,18 @@
 package main
 
 import (
-	"bytes"
 	"crypto/rand"
 	"crypto/sha256"
-	"encoding/base64"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
-	"net/url"
-	"strings"
 
 	"github.com/imagvfx/forge"
 )
 
 type loginHandler struct {
 	server *forge.Server
-	oidc   *forge.OIDC
+	oidc   *oidcProvider
 	apps   *AppSessionManager
 }
 
@@ -51,22 +46,27 @@ func (h *loginHandler) Handle(w http.ResponseWriter, r *http.Request) {
 			// Need to store the authentication info so the app could retrive it with api.
 			session["app_session_key"] = appKey
 		}
+		// nonce prevents replay attack, it will be checked with the id token.
+		// It is kept for the same reason with the state.
+		if session["nonce"] == "" {
+			seed := make([]byte, 1024)
+			rand.Read(seed)
+			hs := sha256.New()
+			hs.Write(seed)
+			session["nonce"] = fmt.Sprintf("%x", hs.Sum(nil))
+		}
 		setSession(w, session)
 
-		// nonce prevents replay attack
-		seed := make([]byte, 1024)
-		rand.Read(seed)
-		hs := sha256.New()
-		hs.Write(seed)
-		nonce := fmt.Sprintf("%x", hs.Sum(nil))
+		authURL, err := h.oidc.AuthURL(r.Context(), session["state"], session["nonce"])
+		if err != nil {
+			return err
+		}
 		recipe := struct {
-			OIDC      *forge.OIDC
-			OIDCState string
-			OIDCNonce string
+			AuthURL string
+			Google  bool
 		}{
-			OIDC:      h.oidc,
-			OIDCState: session["state"],
-			OIDCNonce: nonce,
+			AuthURL: authURL,
+			Google:  h.oidc.isGoogle(),
 		}
 		err = Tmpl.ExecuteTemplate(w, "login.bml", recipe)
 		if err != nil {
@@ -84,66 +84,77 @@ func (h *loginHandler) HandleCallback(w http.ResponseWriter, r *http.Request) {
 			clearSession(w)
 			return err
 		}
-		if r.FormValue("state") != session["state"] {
+		if session["state"] == "" || r.FormValue("state") != session["state"] {
 			return fmt.Errorf("send and recieved states are different")
 		}
+		// the provider sends an error instead of code, when the user denied the login for example.
+		oidcErr := r.FormValue("error")
+		if oidcErr != "" {
+			return fmt.Errorf("oidc login failed: %v: %v", oidcErr, r.FormValue("error_description"))
+		}
 		// code is needed for backend communication
 		code := r.FormValue("code")
 		if code == "" {
 			return fmt.Errorf("no code in oauth response")
 		}
-		resp, err := http.PostForm("https://oauth2.googleapis.com/token", url.Values{
-			"code":          {code},
-			"client_id":     {h.oidc.ClientID},
-			"client_secret": {h.oidc.ClientSecret},
-			"redirect_uri":  {h.oidc.RedirectURI},
-			"grant_type":    {"authorization_code"},
-		})
+		idToken, err := h.oidc.Exchange(r.Context(), code)
 		if err != nil {
 			return err
 		}
-		oa := OIDCResponse{}
-		dec := json.NewDecoder(resp.Body)
-		err = dec.Decode(&oa)
+		op, err := h.oidc.Verify(r.Context(), idToken, session["nonce"])
 		if err != nil {
 			return err
 		}
-		part := strings.Split(oa.IDToken, ".")
-		if len(part) != 3 {
-			return fmt.Errorf("oauth id token should consist of 3 parts")
-		}
-		// usually we need to verify jwt token, but will skip this time as we just got from authorization server.
-		payload, err := base64.RawURLEncoding.DecodeString(part[1])
-		if err != nil {
-			return err
-		}
-		op := OIDCPayload{}
-		dec = json.NewDecoder(bytes.NewReader(payload))
-		err = dec.Decode(&op)
+		user := op.Email
+		called := op.Name
+		// the provider may not restrict the domain, hd parameter of google is only a hint for example.
+		err = h.server.CheckUserDomain(user)
 		if err != nil {
 			return err
 		}
-		user := op.Email
-		called := op.Name
 		ctx := forge.ContextWithUserName(r.Context(), user)
-		_, err = h.server.GetUser(ctx, user)
+		u, err := h.server.GetUser(ctx, user)
 		if err != nil {
 			var e *forge.NotFoundError
 			if !errors.As(err, &e) {
 				return err
 			}
-			u := &forge.User{
+			u = &forge.User{
 				Name:   user,
 				Called: called,
 			}
-			err := h.server.AddUser(ctx, u)
+			err := h.server.SignUpUser(ctx, u)
+			if err != nil {
+				return err
+			}
+		}
+		if u.Pending {
+			return forge.Unauthorized("user is waiting for approval of an admin: %v", user)
+		}
+		// sync only when the token has the claims, otherwise the user would lose
+		// all the managed groups whenever the provider omits them.
+		hasClaims := false
+		groups := make([]string, 0)
+		for _, claim := range h.server.GroupSyncClaims() {
+			if _, ok := op.Claims[claim]; !ok {
+				continue
+			}
+			hasClaims = true
+			groups = append(groups, op.ClaimValues(claim)...)
+		}
+		if hasClaims {
+			err = h.server.SyncGroupMembers(ctx, user, groups)
 			if err != nil {
 				return err
 			}
 		}
 		session["user"] = user
+		// a new login shouldn't continue the impersonation of the last login.
+		session["impersonate"] = ""
+		session["impersonate_writable"] = ""
 		// clear session info that was created for the login process.
 		session["state"] = ""
+		session["nonce"] = ""
 		appKey := session["app_session_key"]
 		session["app_session_key"] = ""
 		entryURL := session["entry_url"]
@@ -190,7 +201,37 @@ type OIDCResponse struct {
 	IDToken string `json:"id_token"`
 }
 
+// OIDCPayload is claims of an id token.
 type OIDCPayload struct {
-	Email string `json:"email"`
-	Name  string `json:"name"`
+	Issuer            string   `json:"iss"`
+	Audience          audience `json:"aud"`
+	AuthorizedParty   string   `json:"azp"`
+	Expiry            int64    `json:"exp"`
+	IssuedAt          int64    `json:"iat"`
+	Nonce             string   `json:"nonce"`
+	Email             string   `json:"email"`
+	EmailVerified     *bool    `json:"email_verified"`
+	PreferredUsername string   `json:"preferred_username"`
+	Name              string   `json:"name"`
+	// Claims has all the claims of the id token, including the ones above.
+	Claims map[string]any `json:"-"`
+}
+
+// ClaimValues returns values of a claim that is either a string or an array of strings.
+// Values of other types are ignored.
+func (op *OIDCPayload) ClaimValues(name string) []string {
+	vals := make([]string, 0)
+	switch v := op.Claims[name].(type) {
+	case string:
+		vals = append(vals, v)
+	case []any:
+		for _, x := range v {
+			s, ok := x.(string)
+			if !ok {
+				continue
+			}
+			vals = append(vals, s)
+		}
+	}
+	return vals
 }
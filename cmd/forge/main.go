@@ -154,19 +154,19 @@ func main() {
 		}
 		for _, t := range cfg.EntryType.Types {
 			for _, p := range t.SubEntries {
-				err := server.AddDefault(ctx, t.Name, "sub_entry", p.Key, p.Type, p.Value)
+				err := server.AddDefault(ctx, &forge.Default{EntryType: t.Name, Category: "sub_entry", Name: p.Key, Type: p.Type, Value: p.Value})
 				if err != nil {
 					log.Fatal(err)
 				}
 			}
 			for _, p := range t.Properties {
-				err := server.AddDefault(ctx, t.Name, "property", p.Key, p.Type, p.Value)
+				err := server.AddDefault(ctx, &forge.Default{EntryType: t.Name, Category: "property", Name: p.Key, Type: p.Type, Value: p.Value})
 				if err != nil {
 					log.Fatal(err)
 				}
 			}
 			for _, p := range t.Environs {
-				err := server.AddDefault(ctx, t.Name, "environ", p.Key, p.Type, p.Value)
+				err := server.AddDefault(ctx, &forge.Default{EntryType: t.Name, Category: "environ", Name: p.Key, Type: p.Type, Value: p.Value})
 				if err != nil {
 					log.Fatal(err)
 				}
@@ -175,14 +175,25 @@ func main() {
 	}
 	appSessionMan := NewAppSessionManager()
 	// appSessionMan.DebugStatus()
+	// google is the default provider.
+	oidcIssuer := os.Getenv("OIDC_ISSUER")
+	oidcCallback := "/login/callback"
+	if oidcIssuer == "" {
+		oidcIssuer = "https://accounts.google.com"
+		// keep the redirect uri that is registered to google already.
+		oidcCallback = "/login/callback/google"
+	}
 	login := &loginHandler{
 		server: server,
-		oidc: &forge.OIDC{
+		oidc: newOIDCProvider(&forge.OIDC{
+			Issuer:       oidcIssuer,
 			ClientID:     os.Getenv("OIDC_CLIENT_ID"),
 			ClientSecret: os.Getenv("OIDC_CLIENT_SECRET"),
-			RedirectURI:  "https://" + host + "/login/callback/google",
+			RedirectURI:  "https://" + host + oidcCallback,
 			HostDomain:   domain,
-		},
+			// ex) "upn" for azure ad, when the users don't have verified emails.
+			IdentityClaim: os.Getenv("OIDC_IDENTITY_CLAIM"),
+		}),
 		apps: appSessionMan,
 	}
 	page := &pageHandler{
@@ -198,10 +209,12 @@ func main() {
 	Tmpl = template.Must(bml.ToHTMLTemplate(Tmpl, "tmpl/*"))
 	mux := http.NewServeMux()
 	mux.HandleFunc("/login", login.Handle)
+	mux.HandleFunc("/login/callback", login.HandleCallback)
 	mux.HandleFunc("/login/callback/google", login.HandleCallback)
 	mux.HandleFunc("/app-login-completed", login.HandleAppLoginCompleted)
 	mux.HandleFunc("/logout", login.HandleLogout)
 	mux.HandleFunc("/", page.Handler(page.handleEntry))
+	mux.HandleFunc("/id/", page.Handler(page.handleEntryByID))
 	mux.HandleFunc("/logs", page.Handler(page.handleEntryLogs))
 	mux.HandleFunc("/thumbnail/", page.Handler(page.handleThumbnail))
 	mux.HandleFunc("/users", page.Handler(page.handleUsers))
@@ -210,6 +223,8 @@ func main() {
 	mux.HandleFunc("/types/", page.Handler(page.handleEachEntryType))
 	mux.HandleFunc("/setting", page.Handler(page.handleSetting))
 	mux.HandleFunc("/download-as-excel", page.Handler(page.handleDownloadAsExcel))
+	mux.HandleFunc("/download-rename-map", page.Handler(page.handleDownloadRenameMap))
+	mux.HandleFunc("/download-relation-graph", page.Handler(page.handleDownloadRelationGraph))
 	mux.HandleFunc("/backup-as-excel", page.Handler(page.handleBackupAsExcel))
 	mux.HandleFunc("/user-data/", page.Handler(page.handleUserData))
 	mux.HandleFunc("/api/", api.Handler(api.handleNotFound))
@@ -218,6 +233,7 @@ func main() {
 	mux.HandleFunc("/api/add-entry-type", api.Handler(api.handleAddEntryType))
 	mux.HandleFunc("/api/rename-entry-type", api.Handler(api.handleRenameEntryType))
 	mux.HandleFunc("/api/delete-entry-type", api.Handler(api.handleDeleteEntryType))
+	mux.HandleFunc("/api/get-defaults", api.Handler(api.handleGetDefaults))
 	mux.HandleFunc("/api/add-default", api.Handler(api.handleAddDefault))
 	mux.HandleFunc("/api/update-default", api.Handler(api.handleUpdateDefault))
 	mux.HandleFunc("/api/delete-default", api.Handler(api.handleDeleteDefault))
@@ -229,13 +245,20 @@ func main() {
 	mux.HandleFunc("/api/parent-entries", api.Handler(api.handleParentEntries))
 	mux.HandleFunc("/api/search-entries", api.Handler(api.handleSearchEntries))
 	mux.HandleFunc("/api/add-entry", api.Handler(api.handleAddEntry))
+	mux.HandleFunc("/api/add-entries", api.Handler(api.handleAddEntries))
 	mux.HandleFunc("/api/get-entry", api.Handler(api.handleGetEntry))
 	mux.HandleFunc("/api/get-entries", api.Handler(api.handleGetEntries))
 	mux.HandleFunc("/api/rename-entry", api.Handler(api.handleRenameEntry))
+	mux.HandleFunc("/api/rename-entries", api.Handler(api.handleRenameEntries))
 	mux.HandleFunc("/api/archive-entry", api.Handler(api.handleArchiveEntry))
 	mux.HandleFunc("/api/unarchive-entry", api.Handler(api.handleUnarchiveEntry))
 	mux.HandleFunc("/api/delete-entry", api.Handler(api.handleDeleteEntry))
 	mux.HandleFunc("/api/count-all-sub-entries", api.Handler(api.handleCountAllSubEntries))
+	mux.HandleFunc("/api/find-references", api.Handler(api.handleFindReferences))
+	mux.HandleFunc("/api/entry-relations", api.Handler(api.handleEntryRelations))
+	mux.HandleFunc("/api/add-relation", api.Handler(api.handleAddRelation))
+	mux.HandleFunc("/api/delete-relation", api.Handler(api.handleDeleteRelation))
+	mux.HandleFunc("/api/relation-graph", api.Handler(api.handleRelationGraph))
 	mux.HandleFunc("/api/update-property", api.Handler(api.handleUpdateProperty))
 	mux.HandleFunc("/api/get-property", api.Handler(api.handleGetProperty))
 	mux.HandleFunc("/api/get-properties", api.Handler(api.handleGetProperties))
@@ -254,6 +277,7 @@ func main() {
 	mux.HandleFunc("/api/add-or-update-access", api.Handler(api.handleAddOrUpdateAccess))
 	mux.HandleFunc("/api/get-access", api.Handler(api.handleGetAccess))
 	mux.HandleFunc("/api/entry-access-list", api.Handler(api.handleEntryAccessList))
+	mux.HandleFunc("/api/explain-access", api.Handler(api.handleExplainAccess))
 	mux.HandleFunc("/api/delete-access", api.Handler(api.handleDeleteAccess))
 	mux.HandleFunc("/api/get-property-history", api.Handler(api.handleGetPropertyHistory))
 	mux.HandleFunc("/api/get-environ-history", api.Handler(api.handleGetEnvironHistory))
@@ -261,6 +285,7 @@ func main() {
 	mux.HandleFunc("/api/get-all-groups", api.Handler(api.handleGetAllGroups))
 	mux.HandleFunc("/api/add-group", api.Handler(api.handleAddGroup))
 	mux.HandleFunc("/api/rename-group", api.Handler(api.handleRenameGroup))
+	mux.HandleFunc("/api/delete-group", api.Handler(api.handleDeleteGroup))
 	mux.HandleFunc("/api/get-group-members", api.Handler(api.handleGetGroupMembers))
 	mux.HandleFunc("/api/add-group-member", api.Handler(api.handleAddGroupMember))
 	mux.HandleFunc("/api/delete-group-member", api.Handler(api.handleDeleteGroupMember))
@@ -270,8 +295,15 @@ func main() {
 	mux.HandleFunc("/api/get-all-users", api.Handler(api.handleGetAllUsers))
 	mux.HandleFunc("/api/get-active-users", api.Handler(api.handleGetActiveUsers))
 	mux.HandleFunc("/api/get-disabled-users", api.Handler(api.handleGetDisabledUsers))
+	mux.HandleFunc("/api/get-pending-users", api.Handler(api.handleGetPendingUsers))
 	mux.HandleFunc("/api/update-user-called", api.Handler(api.handleUpdateUserCalled))
 	mux.HandleFunc("/api/update-user-disabled", api.Handler(api.handleUpdateUserDisabled))
+	mux.HandleFunc("/api/rename-user", api.Handler(api.handleRenameUser))
+	mux.HandleFunc("/api/merge-users", api.Handler(api.handleMergeUsers))
+	mux.HandleFunc("/api/approve-user", api.Handler(api.handleApproveUser))
+	mux.HandleFunc("/api/reject-user", api.Handler(api.handleRejectUser))
+	mux.HandleFunc("/api/start-impersonation", api.Handler(api.handleStartImpersonation))
+	mux.HandleFunc("/api/stop-impersonation", api.Handler(api.handleStopImpersonation))
 	mux.HandleFunc("/api/get-user-setting", api.Handler(api.handleGetUserSetting))
 	mux.HandleFunc("/api/update-user-setting", api.Handler(api.handleUpdateUserSetting))
 	mux.HandleFunc("/api/ensure-user-data-section", api.Handler(api.handleEnsureUserDataSection))
@@ -0,0 +1,430 @@
+package main
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/imagvfx/forge"
+)
+
+const (
+	// jwksCacheTime is how long the provider keys are cached.
+	jwksCacheTime = time.Hour
+	// jwksRefreshInterval limits refreshing the keys when a token has an unknown key id,
+	// so bogus tokens cannot make us flood the provider.
+	jwksRefreshInterval = time.Minute
+	// tokenLeeway allows small clock differences between us and the provider.
+	tokenLeeway = time.Minute
+)
+
+// oidcProvider talks to an OpenID Connect provider, and verifies id tokens from it.
+// The provider endpoints are discovered at the first use, and the signing keys are cached.
+type oidcProvider struct {
+	cfg    *forge.OIDC
+	client *http.Client
+	// now returns the current time. It could be replaced for tests.
+	now func() time.Time
+
+	mu          sync.Mutex
+	discovery   *oidcDiscovery
+	keys        map[string]crypto.PublicKey
+	keysFetched time.Time
+}
+
+// oidcDiscovery is the provider metadata in {issuer}/.well-known/openid-configuration.
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+func newOIDCProvider(cfg *forge.OIDC) *oidcProvider {
+	return &oidcProvider{
+		cfg:    cfg,
+		client: &http.Client{Timeout: 10 * time.Second},
+		now:    time.Now,
+	}
+}
+
+// isGoogle reports whether the provider is google, which has its own sign-in button.
+func (p *oidcProvider) isGoogle() bool {
+	return p.cfg.Issuer == "https://accounts.google.com"
+}
+
+// getJSON gets a json document from the url and decodes it to v.
+func (p *oidcProvider) getJSON(ctx context.Context, u string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", u, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("get %v: %v", u, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// discover finds the provider endpoints. It is done only once.
+func (p *oidcProvider) discover(ctx context.Context) (*oidcDiscovery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+	issuer := strings.TrimSuffix(p.cfg.Issuer, "/")
+	d := &oidcDiscovery{}
+	err := p.getJSON(ctx, issuer+"/.well-known/openid-configuration", d)
+	if err != nil {
+		return nil, fmt.Errorf("oidc discovery: %w", err)
+	}
+	// the issuer in the document should be identical to the configured one.
+	// see https://openid.net/specs/openid-connect-discovery-1_0.html#ProviderConfigurationValidation
+	if strings.TrimSuffix(d.Issuer, "/") != issuer {
+		return nil, fmt.Errorf("oidc discovery: issuer mismatch: got %v, want %v", d.Issuer, p.cfg.Issuer)
+	}
+	if d.AuthorizationEndpoint == "" || d.TokenEndpoint == "" || d.JWKSURI == "" {
+		return nil, fmt.Errorf("oidc discovery: endpoints not specified")
+	}
+	p.discovery = d
+	return d, nil
+}
+
+// AuthURL returns the url of the provider where a user should be sent to login.
+func (p *oidcProvider) AuthURL(ctx context.Context, state, nonce string) (string, error) {
+	d, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+	q := url.Values{
+		"response_type": {"code"},
+		"client_id":     {p.cfg.ClientID},
+		"scope":         {"openid email profile"},
+		"redirect_uri":  {p.cfg.RedirectURI},
+		"state":         {state},
+		"nonce":         {nonce},
+	}
+	if p.cfg.HostDomain != "" {
+		q.Set("hd", p.cfg.HostDomain)
+	}
+	sep := "?"
+	if strings.Contains(d.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+	return d.AuthorizationEndpoint + sep + q.Encode(), nil
+}
+
+// Exchange exchanges the code from the provider with an id token.
+func (p *oidcProvider) Exchange(ctx context.Context, code string) (string, error) {
+	d, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+	form := url.Values{
+		"code":          {code},
+		"client_id":     {p.cfg.ClientID},
+		"client_secret": {p.cfg.ClientSecret},
+		"redirect_uri":  {p.cfg.RedirectURI},
+		"grant_type":    {"authorization_code"},
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", d.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("oidc token exchange: %v", resp.Status)
+	}
+	oa := OIDCResponse{}
+	err = json.NewDecoder(resp.Body).Decode(&oa)
+	if err != nil {
+		return "", err
+	}
+	if oa.IDToken == "" {
+		return "", fmt.Errorf("oidc token exchange: no id token in response")
+	}
+	return oa.IDToken, nil
+}
+
+// jwk is a public key in a JSON Web Key Set.
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// publicKey converts the jwk to a public key. It returns nil for an unsupported key.
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		default:
+			return nil, nil
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{
+			Curve: curve,
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}, nil
+	}
+	return nil, nil
+}
+
+// key returns the provider key of the key id.
+// The keys are fetched again when they are old, or the key id is unknown as the provider might rotate the keys.
+func (p *oidcProvider) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	d, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	age := p.now().Sub(p.keysFetched)
+	key, ok := p.keys[kid]
+	if ok && age < jwksCacheTime {
+		return key, nil
+	}
+	if !ok && p.keys != nil && age < jwksRefreshInterval {
+		return nil, fmt.Errorf("unknown key id in id token: %v", kid)
+	}
+	set := struct {
+		Keys []jwk `json:"keys"`
+	}{}
+	err = p.getJSON(ctx, d.JWKSURI, &set)
+	if err != nil {
+		return nil, fmt.Errorf("oidc keys: %w", err)
+	}
+	keys := make(map[string]crypto.PublicKey)
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		pub, err := k.publicKey()
+		if err != nil {
+			return nil, fmt.Errorf("oidc keys: %v: %w", k.Kid, err)
+		}
+		if pub == nil {
+			continue
+		}
+		keys[k.Kid] = pub
+	}
+	p.keys = keys
+	p.keysFetched = p.now()
+	key, ok = p.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown key id in id token: %v", kid)
+	}
+	return key, nil
+}
+
+// verifySignature verifies the signature of a jwt with the key.
+func verifySignature(alg string, key crypto.PublicKey, signed, sig []byte) error {
+	var hash crypto.Hash
+	switch alg {
+	case "RS256", "ES256":
+		hash = crypto.SHA256
+	case "RS384", "ES384":
+		hash = crypto.SHA384
+	case "RS512":
+		hash = crypto.SHA512
+	default:
+		return fmt.Errorf("unsupported id token algorithm: %v", alg)
+	}
+	h := hash.New()
+	h.Write(signed)
+	digest := h.Sum(nil)
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		if !strings.HasPrefix(alg, "RS") {
+			return fmt.Errorf("id token algorithm doesn't match with the key: %v", alg)
+		}
+		err := rsa.VerifyPKCS1v15(k, hash, digest, sig)
+		if err != nil {
+			return fmt.Errorf("invalid id token signature")
+		}
+	case *ecdsa.PublicKey:
+		if !strings.HasPrefix(alg, "ES") {
+			return fmt.Errorf("id token algorithm doesn't match with the key: %v", alg)
+		}
+		// signature of ecdsa is r and s those have the same length.
+		size := (k.Curve.Params().BitSize + 7) / 8
+		if len(sig) != 2*size {
+			return fmt.Errorf("invalid id token signature")
+		}
+		r := new(big.Int).SetBytes(sig[:size])
+		s := new(big.Int).SetBytes(sig[size:])
+		if !ecdsa.Verify(k, digest, r, s) {
+			return fmt.Errorf("invalid id token signature")
+		}
+	default:
+		return fmt.Errorf("unsupported key type")
+	}
+	return nil
+}
+
+// audience is the "aud" claim of an id token, which could be either a string or an array of strings.
+type audience []string
+
+func (a *audience) UnmarshalJSON(b []byte) error {
+	var s string
+	err := json.Unmarshal(b, &s)
+	if err == nil {
+		*a = audience{s}
+		return nil
+	}
+	var ss []string
+	err = json.Unmarshal(b, &ss)
+	if err != nil {
+		return err
+	}
+	*a = ss
+	return nil
+}
+
+// Verify verifies the id token with its signature and claims, then returns the payload.
+func (p *oidcProvider) Verify(ctx context.Context, idToken, nonce string) (*OIDCPayload, error) {
+	part := strings.Split(idToken, ".")
+	if len(part) != 3 {
+		return nil, fmt.Errorf("oidc id token should consist of 3 parts")
+	}
+	header, err := base64.RawURLEncoding.DecodeString(part[0])
+	if err != nil {
+		return nil, err
+	}
+	head := struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}{}
+	err = json.Unmarshal(header, &head)
+	if err != nil {
+		return nil, err
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(part[2])
+	if err != nil {
+		return nil, err
+	}
+	key, err := p.key(ctx, head.Kid)
+	if err != nil {
+		return nil, err
+	}
+	err = verifySignature(head.Alg, key, []byte(part[0]+"."+part[1]), sig)
+	if err != nil {
+		return nil, err
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(part[1])
+	if err != nil {
+		return nil, err
+	}
+	op := &OIDCPayload{}
+	err = json.Unmarshal(payload, op)
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(payload, &op.Claims)
+	if err != nil {
+		return nil, err
+	}
+	d, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if op.Issuer != d.Issuer {
+		return nil, fmt.Errorf("invalid id token issuer: %v", op.Issuer)
+	}
+	validAud := false
+	for _, aud := range op.Audience {
+		if aud == p.cfg.ClientID {
+			validAud = true
+			break
+		}
+	}
+	if !validAud {
+		return nil, fmt.Errorf("id token is not issued for this client")
+	}
+	if len(op.Audience) > 1 && op.AuthorizedParty != p.cfg.ClientID {
+		return nil, fmt.Errorf("id token is not issued for this client")
+	}
+	now := p.now()
+	if op.Expiry == 0 || now.After(time.Unix(op.Expiry, 0).Add(tokenLeeway)) {
+		return nil, fmt.Errorf("id token expired")
+	}
+	if now.Add(tokenLeeway).Before(time.Unix(op.IssuedAt, 0)) {
+		return nil, fmt.Errorf("id token issued in the future")
+	}
+	if nonce == "" || op.Nonce != nonce {
+		return nil, fmt.Errorf("invalid id token nonce")
+	}
+	claim := p.cfg.IdentityClaim
+	if claim != "" && claim != "email" {
+		// the claim is configured explicitly, it is the provider's job to verify it.
+		id, _ := op.Claims[claim].(string)
+		if id == "" {
+			return nil, fmt.Errorf("id token doesn't have %v claim", claim)
+		}
+		op.Email = id
+		return op, nil
+	}
+	// never fall back to other claims like preferred_username,
+	// they could be modified by the users.
+	if op.Email == "" {
+		return nil, fmt.Errorf("id token doesn't have email")
+	}
+	if op.EmailVerified == nil || !*op.EmailVerified {
+		return nil, fmt.Errorf("email is not verified: %v", op.Email)
+	}
+	return op, nil
+}
@@ -0,0 +1,440 @@
+package main
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"html/template"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/securecookie"
+	"github.com/imagvfx/forge"
+	"github.com/kybin/bml"
+)
+
+// fakeOIDC is a local OpenID Connect provider for tests.
+type fakeOIDC struct {
+	*httptest.Server
+
+	mu sync.Mutex
+	// keys are published keys by key id.
+	keys map[string]crypto.Signer
+	// tokens are id tokens those will be sent for the codes.
+	tokens map[string]string
+	// jwksHits is the number of requests to the jwks endpoint.
+	jwksHits int
+}
+
+func newFakeOIDC(t *testing.T) *fakeOIDC {
+	f := &fakeOIDC{
+		keys:   make(map[string]crypto.Signer),
+		tokens: make(map[string]string),
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 f.URL,
+			"authorization_endpoint": f.URL + "/auth",
+			"token_endpoint":         f.URL + "/token",
+			"jwks_uri":               f.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		f.jwksHits++
+		keys := make([]map[string]string, 0)
+		for kid, k := range f.keys {
+			switch pub := k.Public().(type) {
+			case *rsa.PublicKey:
+				keys = append(keys, map[string]string{
+					"kty": "RSA",
+					"kid": kid,
+					"use": "sig",
+					"n":   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+					"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+				})
+			case *ecdsa.PublicKey:
+				keys = append(keys, map[string]string{
+					"kty": "EC",
+					"kid": kid,
+					"use": "sig",
+					"crv": "P-256",
+					"x":   base64.RawURLEncoding.EncodeToString(pub.X.FillBytes(make([]byte, 32))),
+					"y":   base64.RawURLEncoding.EncodeToString(pub.Y.FillBytes(make([]byte, 32))),
+				})
+			}
+		}
+		json.NewEncoder(w).Encode(map[string]any{"keys": keys})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		token, ok := f.tokens[r.FormValue("code")]
+		if !ok || r.FormValue("client_id") != "forge" || r.FormValue("client_secret") != "secret" {
+			http.Error(w, "invalid_grant", http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"id_token": token})
+	})
+	f.Server = httptest.NewServer(mux)
+	t.Cleanup(f.Close)
+	return f
+}
+
+// addKey publishes a new key with the key id.
+func (f *fakeOIDC) addKey(t *testing.T, kid, kty string) crypto.Signer {
+	var key crypto.Signer
+	var err error
+	if kty == "EC" {
+		key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	} else {
+		key, err = rsa.GenerateKey(rand.Reader, 2048)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.keys[kid] = key
+	return key
+}
+
+// sign creates a jwt of the claims, signed with the key.
+func sign(t *testing.T, alg, kid string, key crypto.Signer, claims map[string]any) string {
+	header, err := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	if alg == "none" {
+		return signed + "."
+	}
+	digest := sha256.Sum256([]byte(signed))
+	var sig []byte
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		sig, err = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, k, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		sig = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func TestOIDCVerify(t *testing.T) {
+	f := newFakeOIDC(t)
+	rsaKey := f.addKey(t, "rsa", "RSA")
+	ecKey := f.addKey(t, "ec", "EC")
+	unpublished, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	p := newOIDCProvider(&forge.OIDC{Issuer: f.URL, ClientID: "forge", ClientSecret: "secret"})
+	p.now = func() time.Time { return now }
+	ctx := context.Background()
+	// claims returns valid claims, those will be modified by the test cases.
+	claims := func() map[string]any {
+		return map[string]any{
+			"iss":            f.URL,
+			"aud":            "forge",
+			"exp":            now.Add(time.Hour).Unix(),
+			"iat":            now.Unix(),
+			"nonce":          "nonce",
+			"email":          "artist@imagvfx.com",
+			"email_verified": true,
+			"name":           "Artist",
+		}
+	}
+	cases := []struct {
+		label     string
+		alg       string
+		kid       string
+		key       crypto.Signer
+		modify    func(c map[string]any)
+		wantEmail string
+		wantErr   error
+	}{
+		{label: "rs256", alg: "RS256", kid: "rsa", key: rsaKey, wantEmail: "artist@imagvfx.com"},
+		{label: "es256", alg: "ES256", kid: "ec", key: ecKey, wantEmail: "artist@imagvfx.com"},
+		{
+			label: "multiple audiences", alg: "RS256", kid: "rsa", key: rsaKey,
+			modify:    func(c map[string]any) { c["aud"] = []string{"other", "forge"}; c["azp"] = "forge" },
+			wantEmail: "artist@imagvfx.com",
+		},
+		{
+			// preferred_username could be modified by the user, it shouldn't be an identity.
+			label: "preferred username", alg: "RS256", kid: "rsa", key: rsaKey,
+			modify:  func(c map[string]any) { delete(c, "email"); c["preferred_username"] = "lead@imagvfx.com" },
+			wantErr: errors.New("id token doesn't have email"),
+		},
+		{
+			label: "email verification unknown", alg: "RS256", kid: "rsa", key: rsaKey,
+			modify:  func(c map[string]any) { delete(c, "email_verified") },
+			wantErr: errors.New("email is not verified: artist@imagvfx.com"),
+		},
+		{
+			label: "other audience", alg: "RS256", kid: "rsa", key: rsaKey,
+			modify:  func(c map[string]any) { c["aud"] = "other" },
+			wantErr: errors.New("id token is not issued for this client"),
+		},
+		{
+			label: "multiple audiences without azp", alg: "RS256", kid: "rsa", key: rsaKey,
+			modify:  func(c map[string]any) { c["aud"] = []string{"other", "forge"} },
+			wantErr: errors.New("id token is not issued for this client"),
+		},
+		{
+			label: "expired", alg: "RS256", kid: "rsa", key: rsaKey,
+			modify:  func(c map[string]any) { c["exp"] = now.Add(-2 * time.Minute).Unix() },
+			wantErr: errors.New("id token expired"),
+		},
+		{
+			label: "expired within leeway", alg: "RS256", kid: "rsa", key: rsaKey,
+			modify:    func(c map[string]any) { c["exp"] = now.Add(-30 * time.Second).Unix() },
+			wantEmail: "artist@imagvfx.com",
+		},
+		{
+			label: "issued in future", alg: "RS256", kid: "rsa", key: rsaKey,
+			modify:  func(c map[string]any) { c["iat"] = now.Add(time.Hour).Unix() },
+			wantErr: errors.New("id token issued in the future"),
+		},
+		{
+			label: "other nonce", alg: "RS256", kid: "rsa", key: rsaKey,
+			modify:  func(c map[string]any) { c["nonce"] = "replayed" },
+			wantErr: errors.New("invalid id token nonce"),
+		},
+		{
+			label: "other issuer", alg: "RS256", kid: "rsa", key: rsaKey,
+			modify:  func(c map[string]any) { c["iss"] = "https://evil.com" },
+			wantErr: errors.New("invalid id token issuer: https://evil.com"),
+		},
+		{
+			label: "email not verified", alg: "RS256", kid: "rsa", key: rsaKey,
+			modify:  func(c map[string]any) { c["email_verified"] = false },
+			wantErr: errors.New("email is not verified: artist@imagvfx.com"),
+		},
+		{label: "unpublished key", alg: "RS256", kid: "rsa", key: unpublished, wantErr: errors.New("invalid id token signature")},
+		{label: "key mismatch", alg: "ES256", kid: "rsa", key: ecKey, wantErr: errors.New("id token algorithm doesn't match with the key: ES256")},
+		{label: "alg none", alg: "none", kid: "rsa", wantErr: errors.New("unsupported id token algorithm: none")},
+	}
+	for _, c := range cases {
+		cl := claims()
+		if c.modify != nil {
+			c.modify(cl)
+		}
+		token := sign(t, c.alg, c.kid, c.key, cl)
+		op, err := p.Verify(ctx, token, "nonce")
+		if !equalError(c.wantErr, err) {
+			t.Fatalf("%v: want err %q, got %q", c.label, errorString(c.wantErr), errorString(err))
+		}
+		if err != nil {
+			continue
+		}
+		if op.Email != c.wantEmail {
+			t.Fatalf("%v: want email %v, got %v", c.label, c.wantEmail, op.Email)
+		}
+	}
+	// keys are cached.
+	if f.jwksHits != 1 {
+		t.Fatalf("want 1 jwks request, got %v", f.jwksHits)
+	}
+	// the provider rotates the keys.
+	now = now.Add(jwksRefreshInterval)
+	rotated := f.addKey(t, "rotated", "RSA")
+	token := sign(t, "RS256", "rotated", rotated, claims())
+	_, err = p.Verify(ctx, token, "nonce")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.jwksHits != 2 {
+		t.Fatalf("want 2 jwks requests after key rotation, got %v", f.jwksHits)
+	}
+	// unknown key ids don't make requests to the provider every time.
+	token = sign(t, "RS256", "unknown", unpublished, claims())
+	for range 3 {
+		_, err = p.Verify(ctx, token, "nonce")
+		want := errors.New("unknown key id in id token: unknown")
+		if !equalError(want, err) {
+			t.Fatalf("unknown key id: want err %q, got %q", errorString(want), errorString(err))
+		}
+	}
+	if f.jwksHits != 2 {
+		t.Fatalf("want 2 jwks requests for unknown key ids, got %v", f.jwksHits)
+	}
+	now = now.Add(jwksRefreshInterval)
+	_, err = p.Verify(ctx, token, "nonce")
+	if err == nil {
+		t.Fatalf("unknown key id should not be verified")
+	}
+	if f.jwksHits != 3 {
+		t.Fatalf("want 3 jwks requests after refresh interval, got %v", f.jwksHits)
+	}
+	// the identity claim can be configured explicitly.
+	upn := newOIDCProvider(&forge.OIDC{Issuer: f.URL, ClientID: "forge", ClientSecret: "secret", IdentityClaim: "upn"})
+	upn.now = p.now
+	upnCases := []struct {
+		label     string
+		modify    func(c map[string]any)
+		wantEmail string
+		wantErr   error
+	}{
+		{
+			label:     "upn",
+			modify:    func(c map[string]any) { delete(c, "email"); delete(c, "email_verified"); c["upn"] = "lead@imagvfx.com" },
+			wantEmail: "lead@imagvfx.com",
+		},
+		{
+			label:   "no upn",
+			modify:  func(c map[string]any) { c["preferred_username"] = "lead@imagvfx.com" },
+			wantErr: errors.New("id token doesn't have upn claim"),
+		},
+	}
+	for _, c := range upnCases {
+		cl := claims()
+		c.modify(cl)
+		token := sign(t, "RS256", "rsa", rsaKey, cl)
+		op, err := upn.Verify(ctx, token, "nonce")
+		if !equalError(c.wantErr, err) {
+			t.Fatalf("%v: want err %q, got %q", c.label, errorString(c.wantErr), errorString(err))
+		}
+		if err != nil {
+			continue
+		}
+		if op.Email != c.wantEmail {
+			t.Fatalf("%v: want email %v, got %v", c.label, c.wantEmail, op.Email)
+		}
+	}
+}
+
+func TestOIDCLogin(t *testing.T) {
+	db, server, err := testDB(t)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	secureCookie = securecookie.New(securecookie.GenerateRandomKey(64), securecookie.GenerateRandomKey(32))
+	Tmpl = template.Must(bml.ToHTMLTemplate(template.New("").Funcs(pageHandlerFuncs), "tmpl/*"))
+	f := newFakeOIDC(t)
+	key := f.addKey(t, "rsa", "RSA")
+	login := &loginHandler{
+		server: server,
+		oidc: newOIDCProvider(&forge.OIDC{
+			Issuer:       f.URL,
+			ClientID:     "forge",
+			ClientSecret: "secret",
+			RedirectURI:  "https://forge.imagvfx.com/login/callback",
+		}),
+		apps: NewAppSessionManager(),
+	}
+	// the login page sends the user to the provider with the state and nonce.
+	w := httptest.NewRecorder()
+	login.Handle(w, httptest.NewRequest("GET", "/login", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("login page: want status 200, got %v: %v", w.Code, w.Body.String())
+	}
+	cookies := w.Result().Cookies()
+	r := httptest.NewRequest("GET", "/login", nil)
+	for _, c := range cookies {
+		r.AddCookie(c)
+	}
+	session, err := getSession(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if session["state"] == "" || session["nonce"] == "" {
+		t.Fatalf("login page should save state and nonce to the session")
+	}
+	authURL := template.HTMLEscapeString(f.URL + "/auth?")
+	if !strings.Contains(w.Body.String(), authURL) {
+		t.Fatalf("login page should have the auth url of the provider: %v", w.Body.String())
+	}
+	now := time.Now()
+	f.tokens["code"] = sign(t, "RS256", "rsa", key, map[string]any{
+		"iss":            f.URL,
+		"aud":            "forge",
+		"exp":            now.Add(time.Hour).Unix(),
+		"iat":            now.Unix(),
+		"nonce":          session["nonce"],
+		"email":          "artist@imagvfx.com",
+		"email_verified": true,
+		"name":           "Artist",
+	})
+	// a user of the provider sets the address of another user to preferred_username.
+	f.tokens["spoofed"] = sign(t, "RS256", "rsa", key, map[string]any{
+		"iss":                f.URL,
+		"aud":                "forge",
+		"exp":                now.Add(time.Hour).Unix(),
+		"iat":                now.Unix(),
+		"nonce":              session["nonce"],
+		"preferred_username": "lead@imagvfx.com",
+	})
+	f.tokens["replayed"] = sign(t, "RS256", "rsa", key, map[string]any{
+		"iss":   f.URL,
+		"aud":   "forge",
+		"exp":   now.Add(time.Hour).Unix(),
+		"iat":   now.Unix(),
+		"nonce": "nonce-of-another-login",
+		"email": "lead@imagvfx.com",
+	})
+	cases := []struct {
+		label      string
+		state      string
+		code       string
+		wantStatus int
+	}{
+		{label: "other state", state: "other", code: "code", wantStatus: http.StatusBadRequest},
+		{label: "replayed token", state: session["state"], code: "replayed", wantStatus: http.StatusBadRequest},
+		{label: "invalid code", state: session["state"], code: "invalid", wantStatus: http.StatusBadRequest},
+		{label: "spoofed username", state: session["state"], code: "spoofed", wantStatus: http.StatusBadRequest},
+		{label: "login", state: session["state"], code: "code", wantStatus: http.StatusSeeOther},
+	}
+	for _, c := range cases {
+		q := url.Values{"state": {c.state}, "code": {c.code}}
+		r := httptest.NewRequest("GET", "/login/callback?"+q.Encode(), nil)
+		for _, c := range cookies {
+			r.AddCookie(c)
+		}
+		w := httptest.NewRecorder()
+		login.HandleCallback(w, r)
+		if w.Code != c.wantStatus {
+			t.Fatalf("%v: want status %v, got %v: %v", c.label, c.wantStatus, w.Code, w.Body.String())
+		}
+	}
+	ctx := forge.ContextWithUserName(context.Background(), "artist@imagvfx.com")
+	u, err := server.GetUser(ctx, "artist@imagvfx.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Called != "Artist" {
+		t.Fatalf("logged in user: want called Artist, got %v", u.Called)
+	}
+	_, err = server.GetUser(ctx, "lead@imagvfx.com")
+	if err == nil {
+		t.Fatalf("user of a replayed or spoofed token shouldn't be added")
+	}
+}
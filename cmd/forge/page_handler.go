@@ -5,6 +5,8 @@ import (
 	"context"
 	"crypto/md5"
 	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -12,6 +14,7 @@ import (
 	"net/http"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/imagvfx/forge"
@@ -66,7 +69,15 @@ func (h *pageHandler) Handler(handleFunc func(ctx context.Context, w http.Respon
 				h.login.Handle(w, r)
 				return nil
 			}
-			ctx := forge.ContextWithUserName(r.Context(), user)
+			ctx, err := sessionContext(r.Context(), h.server, session)
+			if err != nil {
+				revokeImpersonation(w, r, session)
+				return err
+			}
+			imp := forge.ImpersonationFromContext(ctx)
+			if imp != nil {
+				log.Printf("%v as %v: %v", imp.Admin, forge.UserNameFromContext(ctx), r.URL.Path)
+			}
 			return handleFunc(ctx, w, r)
 		}()
 		handleError(w, err)
@@ -110,6 +121,15 @@ func (h *pageHandler) handleEntry(ctx context.Context, w http.ResponseWriter, r
 	}
 	ent, err := h.server.GetEntry(ctx, path)
 	if err != nil {
+		var moved *forge.MovedError
+		if errors.As(err, &moved) {
+			to := moved.NewPath
+			if r.URL.RawQuery != "" {
+				to += "?" + r.URL.RawQuery
+			}
+			http.Redirect(w, r, to, http.StatusSeeOther)
+			return nil
+		}
 		return err
 	}
 	props, err := h.server.EntryProperties(ctx, path)
@@ -134,7 +154,9 @@ func (h *pageHandler) handleEntry(ctx context.Context, w http.ResponseWriter, r
 	}
 	searchEntryType := r.FormValue("search_entry_type")
 	if _, ok := r.Form["search_entry_type"]; ok {
-		if searchEntryType != setting.EntryPageSearchEntryType {
+		imp := forge.ImpersonationFromContext(ctx)
+		// read-only impersonation cannot remember the search for the user.
+		if searchEntryType != setting.EntryPageSearchEntryType && (imp == nil || imp.Writable) {
 			err := h.server.UpdateUserSetting(ctx, user, "entry_page_search_entry_type", searchEntryType)
 			if err != nil {
 				return err
@@ -519,6 +541,13 @@ func (h *pageHandler) handleEntry(ctx context.Context, w http.ResponseWriter, r
 				if bProp == nil {
 					return 1
 				}
+				if aProp.Lookup != nil {
+					// sort lookup properties with the referenced properties.
+					aProp = aProp.Lookup
+				}
+				if bProp.Lookup != nil {
+					bProp = bProp.Lookup
+				}
 				// Even they are properties with same name, their types can be different.
 				cmp := k * strings.Compare(aProp.Type, bProp.Type)
 				if cmp != 0 {
@@ -576,6 +605,34 @@ func (h *pageHandler) handleEntry(ctx context.Context, w http.ResponseWriter, r
 			return cmp <= 0
 		})
 	}
+	// sortPropsByMeta sorts properties by their sections and orders.
+	// A section comes earlier when it has a property of lower order.
+	// Properties without a section come first.
+	sortPropsByMeta := func(props []string, meta map[string]forge.PropertyMeta) {
+		sortProps(props)
+		sectionOrder := make(map[string]int)
+		for _, p := range props {
+			m := meta[p]
+			o, ok := sectionOrder[m.Section]
+			if !ok || m.Order < o {
+				sectionOrder[m.Section] = m.Order
+			}
+		}
+		sort.SliceStable(props, func(i, j int) bool {
+			a := meta[props[i]]
+			b := meta[props[j]]
+			if a.Section != b.Section {
+				if a.Section == "" || b.Section == "" {
+					return a.Section == ""
+				}
+				if sectionOrder[a.Section] != sectionOrder[b.Section] {
+					return sectionOrder[a.Section] < sectionOrder[b.Section]
+				}
+				return a.Section < b.Section
+			}
+			return a.Order < b.Order
+		})
+	}
 	baseTypes, err := h.server.FindBaseEntryTypes(ctx)
 	if err != nil {
 		return err
@@ -585,12 +642,14 @@ func (h *pageHandler) handleEntry(ctx context.Context, w http.ResponseWriter, r
 		if err != nil {
 			return err
 		}
+		propMeta := make(map[string]forge.PropertyMeta)
 		for _, d := range defaults {
 			if d.Category == "property" && !strings.HasPrefix(d.Name, ".") {
 				if defaultProp[typ] == nil {
 					defaultProp[typ] = make(map[string]bool)
 				}
 				defaultProp[typ][d.Name] = true
+				propMeta[d.Name] = d.PropertyMeta
 			}
 		}
 		// user property filter
@@ -613,7 +672,7 @@ func (h *pageHandler) handleEntry(ctx context.Context, w http.ResponseWriter, r
 					props = append(props, p)
 				}
 			}
-			sortProps(props)
+			sortPropsByMeta(props, propMeta)
 			propFilters[typ] = props
 			continue
 		}
@@ -898,6 +957,7 @@ func (h *pageHandler) handleEntry(ctx context.Context, w http.ResponseWriter, r
 	recipe := struct {
 		User                     *forge.User
 		UserIsAdmin              bool
+		Impersonation            *forge.Impersonation
 		UserWritable             bool
 		UserSetting              *forge.UserSetting
 		PageSetting              *forge.UserDataSection
@@ -928,6 +988,7 @@ func (h *pageHandler) handleEntry(ctx context.Context, w http.ResponseWriter, r
 		Properties               []*forge.Property
 		Environs                 []*forge.Property
 		AccessorTypes            []string
+		AccessModes              []string
 		AccessList               []*forge.Access
 		ThumbnailPath            map[string]string
 		BaseEntryTypes           []string
@@ -936,6 +997,7 @@ func (h *pageHandler) handleEntry(ctx context.Context, w http.ResponseWriter, r
 	}{
 		User:                     u,
 		UserIsAdmin:              isAdmin,
+		Impersonation:            forge.ImpersonationFromContext(ctx),
 		UserWritable:             userWritable,
 		UserSetting:              setting,
 		PageSetting:              pageSetting,
@@ -966,6 +1028,7 @@ func (h *pageHandler) handleEntry(ctx context.Context, w http.ResponseWriter, r
 		Properties:               props,
 		Environs:                 envs,
 		AccessorTypes:            forge.AccessorTypes(),
+		AccessModes:              forge.AccessModes(),
 		AccessList:               acs,
 		ThumbnailPath:            thumbnailPath,
 		BaseEntryTypes:           baseTypes,
@@ -979,6 +1042,25 @@ func (h *pageHandler) handleEntry(ctx context.Context, w http.ResponseWriter, r
 	return nil
 }
 
+// handleEntryByID redirects /id/{id} to the entry page of current path of the entry.
+// Use it for a link to an entry that should survive renames of the entry.
+func (h *pageHandler) handleEntryByID(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	id, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/id/"))
+	if err != nil {
+		return forge.NotFound("invalid entry id: %v", strings.TrimPrefix(r.URL.Path, "/id/"))
+	}
+	ent, err := h.server.GetEntryByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	to := ent.Path
+	if r.URL.RawQuery != "" {
+		to += "?" + r.URL.RawQuery
+	}
+	http.Redirect(w, r, to, http.StatusSeeOther)
+	return nil
+}
+
 func (h *pageHandler) handleEntryLogs(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
 	user := forge.UserNameFromContext(ctx)
 	u, err := h.server.GetUser(ctx, user)
@@ -1033,21 +1115,23 @@ func (h *pageHandler) handleEntryLogs(ctx context.Context, w http.ResponseWriter
 			history = append(history, l)
 		}
 		recipe := struct {
-			User        *forge.User
-			UserIsAdmin bool
-			Entry       *forge.Entry
-			Category    string
-			Name        string
-			History     []*forge.Log
-			Users       []*forge.User
+			User          *forge.User
+			UserIsAdmin   bool
+			Impersonation *forge.Impersonation
+			Entry         *forge.Entry
+			Category      string
+			Name          string
+			History       []*forge.Log
+			Users         []*forge.User
 		}{
-			User:        u,
-			UserIsAdmin: isAdmin,
-			Entry:       ent,
-			Category:    ctg,
-			Name:        name,
-			History:     history,
-			Users:       users,
+			User:          u,
+			UserIsAdmin:   isAdmin,
+			Impersonation: forge.ImpersonationFromContext(ctx),
+			Entry:         ent,
+			Category:      ctg,
+			Name:          name,
+			History:       history,
+			Users:         users,
 		}
 		err = Tmpl.ExecuteTemplate(w, "entry-item-history.bml", recipe)
 		if err != nil {
@@ -1063,15 +1147,17 @@ func (h *pageHandler) handleEntryLogs(ctx context.Context, w http.ResponseWriter
 			l.When = l.When.Local()
 		}
 		recipe := struct {
-			User        *forge.User
-			UserIsAdmin bool
-			Entry       *forge.Entry
-			Logs        []*forge.Log
+			User          *forge.User
+			UserIsAdmin   bool
+			Impersonation *forge.Impersonation
+			Entry         *forge.Entry
+			Logs          []*forge.Log
 		}{
-			User:        u,
-			UserIsAdmin: isAdmin,
-			Entry:       ent,
-			Logs:        logs,
+			User:          u,
+			UserIsAdmin:   isAdmin,
+			Impersonation: forge.ImpersonationFromContext(ctx),
+			Entry:         ent,
+			Logs:          logs,
 		}
 		err = Tmpl.ExecuteTemplate(w, "entry-logs.bml", recipe)
 		if err != nil {
@@ -1081,6 +1167,70 @@ func (h *pageHandler) handleEntryLogs(ctx context.Context, w http.ResponseWriter
 	}
 }
 
+// handleDownloadRenameMap downloads the map of a batch rename of sub entries as a csv file.
+// It downloads the last one, when log id is not specified.
+func (h *pageHandler) handleDownloadRenameMap(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	path := r.FormValue("path")
+	logs, err := h.server.GetLogs(ctx, path, "sub_entry", "renames")
+	if err != nil {
+		return err
+	}
+	var renameLog *forge.Log
+	id := r.FormValue("id")
+	for _, l := range logs {
+		if id == "" {
+			if renameLog == nil || l.ID > renameLog.ID {
+				renameLog = l
+			}
+			continue
+		}
+		if strconv.Itoa(l.ID) == id {
+			renameLog = l
+			break
+		}
+	}
+	if renameLog == nil {
+		return forge.NotFound("rename log not found: %v", path)
+	}
+	parent := strings.TrimSuffix(path, "/")
+	wr := csv.NewWriter(w)
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=rename-%v.csv", renameLog.ID))
+	wr.Write([]string{"old", "new"})
+	for _, ln := range strings.Split(renameLog.Value, "\n") {
+		old, newName, _ := strings.Cut(ln, ",")
+		wr.Write([]string{parent + "/" + old, parent + "/" + newName})
+	}
+	wr.Flush()
+	return wr.Error()
+}
+
+// handleDownloadRelationGraph exports a relation graph as DOT or JSON, for visualization.
+func (h *pageHandler) handleDownloadRelationGraph(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	find, err := relationGraphFinder(r, r.FormValue("path"))
+	if err != nil {
+		return err
+	}
+	g, err := h.server.RelationGraph(ctx, find)
+	if err != nil {
+		return err
+	}
+	format := r.FormValue("format")
+	switch format {
+	case "", "dot":
+		w.Header().Set("Content-Type", "text/vnd.graphviz")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=relation-%v.dot", g.Direction))
+		_, err = io.WriteString(w, g.DOT())
+		return err
+	case "json":
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=relation-%v.json", g.Direction))
+		return json.NewEncoder(w).Encode(g)
+	default:
+		return fmt.Errorf("unknown relation graph format: %v", format)
+	}
+}
+
 func (h *pageHandler) handleThumbnail(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
 	if !strings.HasPrefix(r.URL.Path, "/thumbnail/") {
 		return fmt.Errorf("invalid thumbnail path")
@@ -1129,19 +1279,27 @@ func (h *pageHandler) handleUsers(ctx context.Context, w http.ResponseWriter, r
 	if err != nil {
 		return err
 	}
+	pendingUsers, err := h.server.PendingUsers(ctx)
+	if err != nil {
+		return err
+	}
 	recipe := struct {
 		User          *forge.User
 		UserIsAdmin   bool
+		Impersonation *forge.Impersonation
 		EditMode      bool
 		Users         []*forge.User
 		DisabledUsers []*forge.User
+		PendingUsers  []*forge.User
 		Members       map[string][]*forge.Member
 	}{
 		User:          u,
 		UserIsAdmin:   isAdmin,
+		Impersonation: forge.ImpersonationFromContext(ctx),
 		EditMode:      editMode,
 		Users:         users,
 		DisabledUsers: disabledUsers,
+		PendingUsers:  pendingUsers,
 	}
 	err = Tmpl.ExecuteTemplate(w, "users.bml", recipe)
 	if err != nil {
@@ -1165,23 +1323,43 @@ func (h *pageHandler) handleGroups(ctx context.Context, w http.ResponseWriter, r
 		return err
 	}
 	members := make(map[string][]*forge.Member)
+	expandedMembers := make(map[string][]*forge.Member)
 	for _, g := range groups {
 		mems, err := h.server.GroupMembers(ctx, g.Name)
 		if err != nil {
 			return err
 		}
 		members[g.Name] = mems
+		hasSubGroup := false
+		for _, m := range mems {
+			if m.IsGroup {
+				hasSubGroup = true
+				break
+			}
+		}
+		if !hasSubGroup {
+			continue
+		}
+		expanded, err := h.server.ExpandedGroupMembers(ctx, g.Name)
+		if err != nil {
+			return err
+		}
+		expandedMembers[g.Name] = expanded
 	}
 	recipe := struct {
-		User        *forge.User
-		UserIsAdmin bool
-		Groups      []*forge.Group
-		Members     map[string][]*forge.Member
+		User            *forge.User
+		UserIsAdmin     bool
+		Impersonation   *forge.Impersonation
+		Groups          []*forge.Group
+		Members         map[string][]*forge.Member
+		ExpandedMembers map[string][]*forge.Member
 	}{
-		User:        u,
-		UserIsAdmin: isAdmin,
-		Groups:      groups,
-		Members:     members,
+		User:            u,
+		UserIsAdmin:     isAdmin,
+		Impersonation:   forge.ImpersonationFromContext(ctx),
+		Groups:          groups,
+		Members:         members,
+		ExpandedMembers: expandedMembers,
 	}
 	err = Tmpl.ExecuteTemplate(w, "groups.bml", recipe)
 	if err != nil {
@@ -1207,10 +1385,12 @@ func (h *pageHandler) handleEntryTypes(ctx context.Context, w http.ResponseWrite
 	recipe := struct {
 		User           *forge.User
 		UserIsAdmin    bool
+		Impersonation  *forge.Impersonation
 		EntryTypeNames []string
 	}{
 		User:           u,
 		UserIsAdmin:    isAdmin,
+		Impersonation:  forge.ImpersonationFromContext(ctx),
 		EntryTypeNames: typeNames,
 	}
 	err = Tmpl.ExecuteTemplate(w, "types.bml", recipe)
@@ -1278,13 +1458,15 @@ func (h *pageHandler) handleEachEntryType(ctx context.Context, w http.ResponseWr
 		types = append(types, t)
 	}
 	recipe := struct {
-		User        *forge.User
-		UserIsAdmin bool
-		EntryTypes  []*EntryType
+		User          *forge.User
+		UserIsAdmin   bool
+		Impersonation *forge.Impersonation
+		EntryTypes    []*EntryType
 	}{
-		User:        u,
-		UserIsAdmin: isAdmin,
-		EntryTypes:  types,
+		User:          u,
+		UserIsAdmin:   isAdmin,
+		Impersonation: forge.ImpersonationFromContext(ctx),
+		EntryTypes:    types,
 	}
 	err = Tmpl.ExecuteTemplate(w, "type.bml", recipe)
 	if err != nil {
@@ -1312,15 +1494,17 @@ func (h *pageHandler) handleSetting(ctx context.Context, w http.ResponseWriter,
 		return err
 	}
 	recipe := struct {
-		User        *forge.User
-		UserIsAdmin bool
-		Setting     *forge.UserSetting
-		UserData    []*forge.UserDataSection
+		User          *forge.User
+		UserIsAdmin   bool
+		Impersonation *forge.Impersonation
+		Setting       *forge.UserSetting
+		UserData      []*forge.UserDataSection
 	}{
-		User:        u,
-		UserIsAdmin: isAdmin,
-		Setting:     setting,
-		UserData:    data,
+		User:          u,
+		UserIsAdmin:   isAdmin,
+		Impersonation: forge.ImpersonationFromContext(ctx),
+		Setting:       setting,
+		UserData:      data,
 	}
 	err = Tmpl.ExecuteTemplate(w, "setting.bml", recipe)
 	if err != nil {
@@ -1349,13 +1533,15 @@ func (h *pageHandler) handleUserData(ctx context.Context, w http.ResponseWriter,
 	}
 	// TODO: change User as *forge.User in every templates
 	recipe := struct {
-		User        *forge.User
-		UserIsAdmin bool
-		Section     *forge.UserDataSection
+		User          *forge.User
+		UserIsAdmin   bool
+		Impersonation *forge.Impersonation
+		Section       *forge.UserDataSection
 	}{
-		User:        u,
-		UserIsAdmin: isAdmin,
-		Section:     data[0],
+		User:          u,
+		UserIsAdmin:   isAdmin,
+		Impersonation: forge.ImpersonationFromContext(ctx),
+		Section:       data[0],
 	}
 	err = Tmpl.ExecuteTemplate(w, "user-data.bml", recipe)
 	if err != nil {
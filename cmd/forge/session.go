@@ -1,10 +1,14 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"log"
 	"net/http"
 	"sync"
 	"time"
+
+	"github.com/imagvfx/forge"
 )
 
 func setSession(w http.ResponseWriter, session map[string]string) error {
@@ -44,6 +48,52 @@ func clearSession(w http.ResponseWriter) {
 	http.SetCookie(w, c)
 }
 
+// sessionContext returns a context having the session user.
+// When an admin impersonates another user, the context user is the impersonated user,
+// and the admin is kept in the context as an impersonation.
+//
+// The admin is checked on every request, so an admin who is disabled or removed from
+// the admin group cannot keep acting as another user. See revokeImpersonation for the case.
+func sessionContext(ctx context.Context, server *forge.Server, session map[string]string) (context.Context, error) {
+	user := session["user"]
+	if session["impersonate"] == "" {
+		return forge.ContextWithUserName(ctx, user), nil
+	}
+	adminCtx := forge.ContextWithUserName(ctx, user)
+	u, err := server.GetUser(adminCtx, user)
+	if err != nil {
+		return nil, err
+	}
+	isAdmin, err := server.IsAdmin(adminCtx, user)
+	if err != nil {
+		return nil, err
+	}
+	if u.Disabled || !isAdmin {
+		return nil, forge.Unauthorized("impersonation is not allowed to %v anymore", user)
+	}
+	ctx = forge.ContextWithUserName(ctx, session["impersonate"])
+	return forge.ContextWithImpersonation(ctx, &forge.Impersonation{
+		Admin:    user,
+		Writable: session["impersonate_writable"] != "",
+	}), nil
+}
+
+// revokeImpersonation clears the impersonation of the browser session,
+// when sessionContext refused it. The next request will be made by the real user.
+func revokeImpersonation(w http.ResponseWriter, r *http.Request, session map[string]string) {
+	if session["impersonate"] == "" || r.FormValue("session") != "" {
+		// app sessions cannot be updated from here, they are refused until re-login.
+		return
+	}
+	log.Printf("revoked impersonation of %v by %v", session["impersonate"], session["user"])
+	session["impersonate"] = ""
+	session["impersonate_writable"] = ""
+	err := setSession(w, session)
+	if err != nil {
+		log.Print(err)
+	}
+}
+
 type AppSession struct {
 	User    string
 	Session string
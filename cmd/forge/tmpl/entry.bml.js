@@ -1951,6 +1951,10 @@ window.onload = function() {
 			showCategoryInfos(tgl.dataset.category);
 		}
 	}
+	let dirEntryBottom = document.querySelector(".dirEntryBottom");
+	if (dirEntryBottom && dirEntryBottom.dataset.selectedCategory == "reference") {
+		loadReferences();
+	}
 	let infoAdders = document.getElementsByClassName("infoAdder");
 	for (let a of infoAdders) {
 		let ent = a.closest(".entry");
@@ -2040,6 +2044,21 @@ window.onload = function() {
 				parent = "";
 			}
 			let type = form.dataset.type;
+			if (value.includes("[") || value.includes(",")) {
+				// batch creation with a pattern like 'sh[0010-0600:10]'.
+				let data = new FormData();
+				data.append("parent", form.dataset.parent);
+				data.append("pattern", value);
+				data.append("type", type);
+				postForge("/api/add-entries", data, function(_, err) {
+					if (err) {
+						printErrorStatus(err);
+						return;
+					}
+					location.reload(true);
+				});
+				return false;
+			}
 			let data = new FormData();
 			for (let name of form.name.value.split(" ")) {
 				let path = parent + "/" + name;
@@ -2754,6 +2773,9 @@ function showCategoryInfos(ctg) {
 		ctg = ""
 	}
 	cont.dataset.selectedCategory = ctg;
+	if (ctg == "reference") {
+		loadReferences();
+	}
 	let data = new FormData();
 	data.append("update_entry_page_selected_category", "1")
 	data.append("category", ctg)
@@ -2765,8 +2787,56 @@ function showCategoryInfos(ctg) {
 	});
 }
 
+// loadReferences shows the entries referencing this entry.
+// Finding them is expensive, so it is done only once when the category is selected.
+function loadReferences() {
+	let cont = document.querySelector(".dirEntryBottom");
+	if (cont.dataset.referencesLoaded) {
+		return;
+	}
+	cont.dataset.referencesLoaded = "1";
+	let data = new FormData();
+	data.append("path", cont.closest(".dirEntry").dataset.entryPath);
+	postForge("/api/find-references", data, function(refs, err) {
+		if (err) {
+			delete cont.dataset.referencesLoaded;
+			printErrorStatus(err);
+			return;
+		}
+		let selector = cont.querySelector(".infoCategoryToggle[data-category='reference'] > .infoSelector");
+		selector.innerText = "Referenced By (" + String(refs.length) + ")";
+		let infos = cont.querySelector(".dirEntryInfos");
+		for (let r of refs) {
+			let info = document.createElement("div");
+			info.classList.add("dirEntryInfo");
+			info.dataset.category = "reference";
+			info.dataset.entryPath = r.EntryPath;
+			info.dataset.name = r.Name;
+			info.dataset.type = r.Type;
+			let top = document.createElement("div");
+			top.classList.add("infoTop");
+			let title = document.createElement("div");
+			title.classList.add("infoTitle");
+			title.title = r.Type;
+			title.innerText = r.Name;
+			top.appendChild(title);
+			info.appendChild(top);
+			let value = document.createElement("div");
+			value.classList.add("infoValue");
+			let a = document.createElement("a");
+			a.classList.add("entryLink");
+			a.href = r.EntryPath;
+			a.innerText = r.EntryPath;
+			value.appendChild(a);
+			info.appendChild(value);
+			infos.appendChild(info);
+		}
+	});
+}
+
 let PropertyTypes = {{marshalJS $.PropertyTypes}}
 let AccessorTypes = {{marshalJS $.AccessorTypes}}
+let AccessModes = {{marshalJS $.AccessModes}}
 
 function showInfoAdder(entry, ctg, name, type, value) {
 	// TODO: Add the item inplace?
@@ -2804,6 +2874,11 @@ function showInfoAdder(entry, ctg, name, type, value) {
 
 	let valueInput = adder.getElementsByClassName("valueInput")[0];
 	valueInput.value = value;
+	valueInput.placeholder = "";
+	if (ctg == "access") {
+		// none denies the access inherited from parents.
+		valueInput.placeholder = AccessModes.join(" / ");
+	}
 	resizeTextArea(valueInput);
 	if (!name) {
 		nameInput.focus();
@@ -2886,7 +2961,17 @@ function openDeleteEntryDialog(paths) {
 					reject(err);
 					return;
 				}
-				resolve(path + " (+" + String(num) + ")");
+				postForge("/api/find-references", data, function(refs, err) {
+					if (err) {
+						reject(err);
+						return;
+					}
+					let line = path + " (+" + String(num) + ")";
+					if (refs.length != 0) {
+						line += " - referenced by " + refs.map(r => r.EntryPath + "." + r.Name).join(", ");
+					}
+					resolve(line);
+				});
 			});
 		});
 		proms.push(p);
@@ -2918,13 +3003,24 @@ function openDeleteEntryDialog(paths) {
 			data.append("path", path);
 		}
 		data.append("recursive", "1");
-		postForge("/api/delete-entry", data, function(_, err) {
-			if (err) {
-				printErrorStatus(err);
-				return;
-			}
-			location.reload();
-		});
+		let deleteEntries = function() {
+			postForge("/api/delete-entry", data, function(_, err) {
+				if (err) {
+					if ({{$.UserIsAdmin}} && !data.has("force") && err.includes("entry is referenced by other entries")) {
+						// admins can delete them anyway, the references will point nothing.
+						if (window.confirm(err + "\n\nDelete anyway? The references will be broken.")) {
+							data.append("force", "1");
+							deleteEntries();
+						}
+						return;
+					}
+					printErrorStatus(err);
+					return;
+				}
+				location.reload();
+			});
+		}
+		deleteEntries();
 	}
 }
 
@@ -3400,7 +3496,7 @@ function reloadPropertyPicker(popup, ctg, prop, forceProp) {
 				return;
 			}
 			updateInputs(p.Type, p.Eval);
-			if (nameInput.dataset.type == "user") {
+			if (nameInput.dataset.type == "user" || nameInput.dataset.type == "users") {
 				let menuAt = getOffset(valueInput);
 				menuAt.top += valueInput.getBoundingClientRect().height + 4;
 				cleanAutoComplete = autoComplete(valueInput, AllUserLabels, AllUserNames, menuAt, function(value) {
@@ -3432,7 +3528,12 @@ function reloadPropertyPicker(popup, ctg, prop, forceProp) {
 						data.append("path", path);
 					}
 					data.append("name", prop);
-					data.append("value", value);
+					if (nameInput.dataset.type == "users") {
+						// users property adds a user to the list.
+						data.append("value", "+" + value);
+					} else {
+						data.append("value", value);
+					}
 					postForge("/api/update-property", data, function(_, err) {
 						if (err) {
 							printErrorStatus(err);
@@ -3,6 +3,7 @@ package forge
 import (
 	"fmt"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/BurntSushi/toml"
@@ -14,7 +15,107 @@ type Config struct {
 }
 
 type ForgeConfig struct {
-	UserdataRoot string `toml:"userdata_root"`
+	UserdataRoot string          `toml:"userdata_root"`
+	GroupSync    GroupSyncConfig `toml:"group_sync"`
+	Signup       SignupConfig    `toml:"signup"`
+}
+
+// SignupConfig decides who can sign up with the login, and how the new users are set up.
+type SignupConfig struct {
+	// AllowedDomains are email domains those are allowed to login.
+	// Any domain is allowed when it is empty.
+	AllowedDomains []string `toml:"allowed_domains"`
+	// RequireApproval makes new users disabled and pending, until an admin approves them.
+	RequireApproval bool `toml:"require_approval"`
+	// DefaultGroups are groups those a user is added to when the user is approved,
+	// or when the user signs up if approval isn't required.
+	DefaultGroups []DefaultGroupRule `toml:"default_groups"`
+}
+
+// DefaultGroupRule adds users of the domain to the groups.
+// A rule without domain applies to every user.
+type DefaultGroupRule struct {
+	Domain string
+	Groups []string
+}
+
+// AllowUser checks the user's email domain is allowed to login.
+func (c SignupConfig) AllowUser(user string) bool {
+	if len(c.AllowedDomains) == 0 {
+		return true
+	}
+	_, domain, _ := strings.Cut(user, "@")
+	for _, d := range c.AllowedDomains {
+		if strings.EqualFold(d, domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// UserGroups returns the default groups of the user.
+func (c SignupConfig) UserGroups(user string) []string {
+	_, domain, _ := strings.Cut(user, "@")
+	groups := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, r := range c.DefaultGroups {
+		if r.Domain != "" && !strings.EqualFold(r.Domain, domain) {
+			continue
+		}
+		for _, g := range r.Groups {
+			if seen[g] {
+				continue
+			}
+			seen[g] = true
+			groups = append(groups, g)
+		}
+	}
+	return groups
+}
+
+// GroupSyncConfig maps claims of an id token to forge groups.
+// Group memberships of a user are synced with the claims on each login.
+//
+// A group that is a target of Groups is managed. A user is added to or removed from
+// a managed group automatically, according to the claims.
+// Other groups are manual, sync never touches them.
+type GroupSyncConfig struct {
+	// Claims are the names of claims that have the user's groups in the identity provider,
+	// like "groups" or "roles".
+	Claims []string
+	// Groups maps a value of the claims to a forge group.
+	Groups map[string]string
+}
+
+// Managed returns the groups those are managed by the sync.
+func (c GroupSyncConfig) Managed() []string {
+	managed := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, g := range c.Groups {
+		if seen[g] {
+			continue
+		}
+		seen[g] = true
+		managed = append(managed, g)
+	}
+	sort.Strings(managed)
+	return managed
+}
+
+// Map maps the claim values to forge groups. Values those are not mapped are ignored.
+func (c GroupSyncConfig) Map(values []string) []string {
+	groups := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, v := range values {
+		g, ok := c.Groups[v]
+		if !ok || seen[g] {
+			continue
+		}
+		seen[g] = true
+		groups = append(groups, g)
+	}
+	sort.Strings(groups)
+	return groups
 }
 
 type EntryTypeConfig struct {
@@ -0,0 +1,188 @@
+package forge
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Constraint restricts values of a property. It is defined with a default property.
+//
+// It is written as lines of 'key' or 'key: value' form.
+//
+//	required             value cannot be empty
+//	required: status=wip value cannot be empty when status property of the entry is wip
+//	pattern: \d+x\d+     value should match the regular expression entirely
+//	min: 1               value should be greater than or equal to it (int, formula, date)
+//	max: 5               value should be less than or equal to it (int, formula, date)
+//	max_length: 20       value cannot be longer than the number of characters
+//	unique               value cannot be same with the property of a sibling entry
+//
+// Constraints except required are not applied to an empty value.
+type Constraint struct {
+	Required     bool
+	RequiredWhen string
+	Pattern      string
+	Min          string
+	Max          string
+	MaxLength    int
+	Unique       bool
+
+	// re is the compiled Pattern, set by ParseConstraint.
+	re *regexp.Regexp
+}
+
+// ParseConstraint parses a constraint of a property type.
+// Empty string is a valid constraint, that doesn't restrict anything.
+func ParseConstraint(typ, s string) (*Constraint, error) {
+	c := &Constraint{}
+	for _, ln := range strings.Split(s, "\n") {
+		ln = strings.TrimSpace(ln)
+		if ln == "" {
+			continue
+		}
+		k, v, _ := strings.Cut(ln, ":")
+		k = strings.TrimSpace(k)
+		v = strings.TrimSpace(v)
+		switch k {
+		case "required":
+			if v == "" {
+				c.Required = true
+				continue
+			}
+			name, _, ok := strings.Cut(v, "=")
+			if !ok || strings.TrimSpace(name) == "" {
+				return nil, fmt.Errorf("invalid constraint: required condition should be 'property=value' form: %v", v)
+			}
+			c.RequiredWhen = v
+		case "pattern":
+			if v == "" {
+				return nil, fmt.Errorf("invalid constraint: pattern not specified")
+			}
+			re, err := compileWholeMatch(v)
+			if err != nil {
+				return nil, fmt.Errorf("invalid constraint: %v", err)
+			}
+			c.Pattern = v
+			c.re = re
+		case "min", "max":
+			err := checkConstraintBound(typ, v)
+			if err != nil {
+				return nil, fmt.Errorf("invalid constraint: %v %v", k, err)
+			}
+			if k == "min" {
+				c.Min = v
+			} else {
+				c.Max = v
+			}
+		case "max_length":
+			n, err := strconv.Atoi(v)
+			if err != nil || n <= 0 {
+				return nil, fmt.Errorf("invalid constraint: max_length should be a positive integer: %v", v)
+			}
+			c.MaxLength = n
+		case "unique":
+			c.Unique = true
+		default:
+			return nil, fmt.Errorf("invalid constraint: unknown key: %v", k)
+		}
+	}
+	return c, nil
+}
+
+func checkConstraintBound(typ, v string) error {
+	switch typ {
+	case "int":
+		_, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("should be an integer: %v", v)
+		}
+	case "formula":
+		_, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return fmt.Errorf("should be a number: %v", v)
+		}
+	case "date":
+		_, err := time.Parse("2006/01/02", v)
+		if err != nil {
+			return fmt.Errorf("should be a date of yyyy/mm/dd form: %v", v)
+		}
+	default:
+		return fmt.Errorf("not supported for %v type", typ)
+	}
+	return nil
+}
+
+// String returns the constraint as it's text form.
+func (c *Constraint) String() string {
+	lines := make([]string, 0)
+	if c.Required {
+		lines = append(lines, "required")
+	}
+	if c.RequiredWhen != "" {
+		lines = append(lines, "required: "+c.RequiredWhen)
+	}
+	if c.Pattern != "" {
+		lines = append(lines, "pattern: "+c.Pattern)
+	}
+	if c.Min != "" {
+		lines = append(lines, "min: "+c.Min)
+	}
+	if c.Max != "" {
+		lines = append(lines, "max: "+c.Max)
+	}
+	if c.MaxLength != 0 {
+		lines = append(lines, "max_length: "+strconv.Itoa(c.MaxLength))
+	}
+	if c.Unique {
+		lines = append(lines, "unique")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Check checks a value of a property with the constraint.
+// Constraints those need other values (conditional required and unique) should be checked by the caller.
+func (c *Constraint) Check(typ, value string) error {
+	if value == "" {
+		if c.Required {
+			return fmt.Errorf("value is required")
+		}
+		return nil
+	}
+	if c.Pattern != "" {
+		re := c.re
+		if re == nil {
+			// the constraint is not made with ParseConstraint.
+			var err error
+			re, err = compileWholeMatch(c.Pattern)
+			if err != nil {
+				return fmt.Errorf("invalid constraint: %v", err)
+			}
+		}
+		if !re.MatchString(value) {
+			return fmt.Errorf("value doesn't match the pattern %v: %v", c.Pattern, value)
+		}
+	}
+	if c.Min != "" && CompareProperty(typ, value, c.Min) < 0 {
+		return fmt.Errorf("value should be greater than or equal to %v: %v", c.Min, value)
+	}
+	if c.Max != "" && CompareProperty(typ, value, c.Max) > 0 {
+		return fmt.Errorf("value should be less than or equal to %v: %v", c.Max, value)
+	}
+	if c.MaxLength != 0 && len([]rune(value)) > c.MaxLength {
+		return fmt.Errorf("value is longer than %v characters: %v", c.MaxLength, value)
+	}
+	return nil
+}
+
+// RequiredCondition returns property name and value of the condition that makes the value required.
+// It returns empty strings when the constraint doesn't have the condition.
+func (c *Constraint) RequiredCondition() (string, string) {
+	if c.RequiredWhen == "" {
+		return "", ""
+	}
+	name, value, _ := strings.Cut(c.RequiredWhen, "=")
+	return strings.TrimSpace(name), strings.TrimSpace(value)
+}
@@ -0,0 +1,81 @@
+package forge
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseConstraint(t *testing.T) {
+	cases := []struct {
+		typ     string
+		s       string
+		want    string
+		wantErr error
+	}{
+		{typ: "text", s: "", want: ""},
+		{typ: "text", s: "required", want: "required"},
+		{typ: "date", s: " required : status=wip ", want: "required: status=wip"},
+		{typ: "text", s: "unique\npattern: \\d+x\\d+\n\nmax_length: 9", want: "pattern: \\d+x\\d+\nmax_length: 9\nunique"},
+		{typ: "int", s: "max: 5\nmin: 1", want: "min: 1\nmax: 5"},
+		{typ: "date", s: "min: 2024/01/01", want: "min: 2024/01/01"},
+		{typ: "text", s: "required: status", wantErr: errors.New("invalid constraint: required condition should be 'property=value' form: status")},
+		{typ: "text", s: "pattern:", wantErr: errors.New("invalid constraint: pattern not specified")},
+		{typ: "text", s: "pattern: (", wantErr: errors.New("invalid constraint: error parsing regexp: missing closing ): `(`")},
+		{typ: "text", s: "min: 1", wantErr: errors.New("invalid constraint: min not supported for text type")},
+		{typ: "int", s: "max: five", wantErr: errors.New("invalid constraint: max should be an integer: five")},
+		{typ: "date", s: "min: 20240101", wantErr: errors.New("invalid constraint: min should be a date of yyyy/mm/dd form: 20240101")},
+		{typ: "text", s: "max_length: 0", wantErr: errors.New("invalid constraint: max_length should be a positive integer: 0")},
+		{typ: "text", s: "optional", wantErr: errors.New("invalid constraint: unknown key: optional")},
+	}
+	for _, c := range cases {
+		got, err := ParseConstraint(c.typ, c.s)
+		if !equalError(c.wantErr, err) {
+			t.Fatalf("%q: want err %v, got %v", c.s, c.wantErr, err)
+		}
+		if err != nil {
+			continue
+		}
+		if got.String() != c.want {
+			t.Fatalf("%q: want %q, got %q", c.s, c.want, got.String())
+		}
+	}
+}
+
+func TestConstraintCheck(t *testing.T) {
+	cases := []struct {
+		typ        string
+		constraint string
+		value      string
+		wantErr    error
+	}{
+		{typ: "text", constraint: "", value: "anything"},
+		{typ: "text", constraint: "required", value: "", wantErr: errors.New("value is required")},
+		{typ: "text", constraint: "pattern: \\d+x\\d+", value: ""},
+		{typ: "text", constraint: "pattern: \\d+x\\d+", value: "1920x1080"},
+		{typ: "text", constraint: "pattern: \\d+x\\d+", value: "1920x1080p", wantErr: errors.New("value doesn't match the pattern \\d+x\\d+: 1920x1080p")},
+		{typ: "int", constraint: "min: 1\nmax: 5", value: "1"},
+		{typ: "int", constraint: "min: 1\nmax: 5", value: "10", wantErr: errors.New("value should be less than or equal to 5: 10")},
+		{typ: "int", constraint: "min: 1\nmax: 5", value: "-1", wantErr: errors.New("value should be greater than or equal to 1: -1")},
+		{typ: "date", constraint: "max: 2024/12/31", value: "2025/01/01", wantErr: errors.New("value should be less than or equal to 2024/12/31: 2025/01/01")},
+		{typ: "text", constraint: "max_length: 3", value: "한글자", wantErr: nil},
+		{typ: "text", constraint: "max_length: 3", value: "four", wantErr: errors.New("value is longer than 3 characters: four")},
+		// conditional required should be checked by the caller.
+		{typ: "text", constraint: "required: status=wip", value: ""},
+	}
+	for _, c := range cases {
+		con, err := ParseConstraint(c.typ, c.constraint)
+		if err != nil {
+			t.Fatalf("%q: %v", c.constraint, err)
+		}
+		err = con.Check(c.typ, c.value)
+		if !equalError(c.wantErr, err) {
+			t.Fatalf("%q with %q: want err %v, got %v", c.constraint, c.value, c.wantErr, err)
+		}
+	}
+	// a constraint not made with ParseConstraint reports an invalid pattern, instead of panicking.
+	err := (&Constraint{Pattern: "("}).Check("text", "(")
+	want := errors.New("invalid constraint: error parsing regexp: missing closing ): `(`")
+	if !equalError(want, err) {
+		t.Fatalf("invalid pattern: want err %v, got %v", want, err)
+	}
+}
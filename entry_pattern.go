@@ -0,0 +1,155 @@
+package forge
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// maxEntryPatternNames is the maximum number of names an entry pattern can have.
+const maxEntryPatternNames = 1000
+
+// ExpandEntryPattern expands a pattern for batch creation of entries into names.
+//
+// A pattern is a comma separated list of names. A name can have bracketed parts
+// those are expanded to multiple names. A bracketed part is also a comma separated list of
+// values or ranges of 'start-end' or 'start-end:step' form.
+// Numbers of a range are zero padded to the width of the start.
+//
+//	sh0010,sh0020          sh0010, sh0020
+//	sh[0010-0040:10]       sh0010, sh0020, sh0030, sh0040
+//	sh[0010-0020:10,0100]  sh0010, sh0020, sh0100
+//	ep[1-2]_[a,b]          ep1_a, ep1_b, ep2_a, ep2_b
+func ExpandEntryPattern(pattern string) ([]string, error) {
+	items, err := splitEntryPattern(pattern)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, item := range items {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			return nil, fmt.Errorf("invalid entry pattern: empty name: %v", pattern)
+		}
+		expanded, err := expandEntryPatternItem(item)
+		if err != nil {
+			return nil, fmt.Errorf("invalid entry pattern: %v: %v", err, pattern)
+		}
+		for _, name := range expanded {
+			if seen[name] {
+				return nil, fmt.Errorf("invalid entry pattern: duplicated name %v: %v", name, pattern)
+			}
+			seen[name] = true
+			names = append(names, name)
+		}
+		if len(names) > maxEntryPatternNames {
+			return nil, fmt.Errorf("invalid entry pattern: more than %v names: %v", maxEntryPatternNames, pattern)
+		}
+	}
+	return names, nil
+}
+
+// splitEntryPattern splits a pattern with commas those are not in brackets.
+func splitEntryPattern(pattern string) ([]string, error) {
+	items := make([]string, 0)
+	inBracket := false
+	start := 0
+	for i, r := range pattern {
+		switch r {
+		case '[':
+			if inBracket {
+				return nil, fmt.Errorf("invalid entry pattern: nested '[': %v", pattern)
+			}
+			inBracket = true
+		case ']':
+			if !inBracket {
+				return nil, fmt.Errorf("invalid entry pattern: unopened ']': %v", pattern)
+			}
+			inBracket = false
+		case ',':
+			if !inBracket {
+				items = append(items, pattern[start:i])
+				start = i + 1
+			}
+		}
+	}
+	if inBracket {
+		return nil, fmt.Errorf("invalid entry pattern: unclosed '[': %v", pattern)
+	}
+	items = append(items, pattern[start:])
+	return items, nil
+}
+
+// expandEntryPatternItem expands a name that could have bracketed parts.
+// The brackets should be balanced already.
+func expandEntryPatternItem(item string) ([]string, error) {
+	open := strings.IndexByte(item, '[')
+	if open == -1 {
+		return []string{item}, nil
+	}
+	end := open + strings.IndexByte(item[open:], ']')
+	values, err := expandEntryPatternValues(item[open+1 : end])
+	if err != nil {
+		return nil, err
+	}
+	rest, err := expandEntryPatternItem(item[end+1:])
+	if err != nil {
+		return nil, err
+	}
+	if len(values)*len(rest) > maxEntryPatternNames {
+		return nil, fmt.Errorf("more than %v names", maxEntryPatternNames)
+	}
+	names := make([]string, 0, len(values)*len(rest))
+	for _, v := range values {
+		for _, r := range rest {
+			names = append(names, item[:open]+v+r)
+		}
+	}
+	return names, nil
+}
+
+// expandEntryPatternValues expands the inside of brackets.
+func expandEntryPatternValues(s string) ([]string, error) {
+	values := make([]string, 0)
+	for _, v := range strings.Split(s, ",") {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			return nil, fmt.Errorf("empty value in brackets")
+		}
+		rng, step, hasStep := strings.Cut(v, ":")
+		start, end, isRange := strings.Cut(rng, "-")
+		if !isRange || !isDigits(start) || !isDigits(end) {
+			if hasStep {
+				return nil, fmt.Errorf("step without range: %v", v)
+			}
+			values = append(values, v)
+			continue
+		}
+		from, err := strconv.Atoi(start)
+		if err != nil {
+			return nil, fmt.Errorf("invalid range: %v", v)
+		}
+		to, err := strconv.Atoi(end)
+		if err != nil {
+			return nil, fmt.Errorf("invalid range: %v", v)
+		}
+		if from > to {
+			return nil, fmt.Errorf("range start is bigger than end: %v", v)
+		}
+		n := 1
+		if hasStep {
+			n, err = strconv.Atoi(step)
+			if err != nil || n <= 0 {
+				return nil, fmt.Errorf("step should be a positive integer: %v", v)
+			}
+		}
+		if (to-from)/n+1 > maxEntryPatternNames {
+			return nil, fmt.Errorf("more than %v names", maxEntryPatternNames)
+		}
+		for i := from; i <= to; i += n {
+			values = append(values, fmt.Sprintf("%0*d", len(start), i))
+		}
+	}
+	return values, nil
+}
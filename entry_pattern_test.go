@@ -0,0 +1,45 @@
+package forge
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestExpandEntryPattern(t *testing.T) {
+	cases := []struct {
+		pattern string
+		want    []string
+		wantErr error
+	}{
+		{pattern: "sh0010", want: []string{"sh0010"}},
+		{pattern: "sh0010, sh0020", want: []string{"sh0010", "sh0020"}},
+		{pattern: "sh[0010-0040:10]", want: []string{"sh0010", "sh0020", "sh0030", "sh0040"}},
+		{pattern: "sh[0010-0020:10, 0100],sh0200", want: []string{"sh0010", "sh0020", "sh0100", "sh0200"}},
+		{pattern: "sh[0010-0035:10]", want: []string{"sh0010", "sh0020", "sh0030"}},
+		{pattern: "ep[1-2]_[a,b]", want: []string{"ep1_a", "ep1_b", "ep2_a", "ep2_b"}},
+		{pattern: "[8-10]", want: []string{"8", "9", "10"}},
+		{pattern: "fx-[a,b]", want: []string{"fx-a", "fx-b"}},
+		{pattern: "", wantErr: errors.New("invalid entry pattern: empty name: ")},
+		{pattern: "sh0010,,sh0020", wantErr: errors.New("invalid entry pattern: empty name: sh0010,,sh0020")},
+		{pattern: "sh[0010", wantErr: errors.New("invalid entry pattern: unclosed '[': sh[0010")},
+		{pattern: "sh0010]", wantErr: errors.New("invalid entry pattern: unopened ']': sh0010]")},
+		{pattern: "sh[[0010]]", wantErr: errors.New("invalid entry pattern: nested '[': sh[[0010]]")},
+		{pattern: "sh[]", wantErr: errors.New("invalid entry pattern: empty value in brackets: sh[]")},
+		{pattern: "sh[0020-0010]", wantErr: errors.New("invalid entry pattern: range start is bigger than end: 0020-0010: sh[0020-0010]")},
+		{pattern: "sh[0010-0020:0]", wantErr: errors.New("invalid entry pattern: step should be a positive integer: 0010-0020:0: sh[0010-0020:0]")},
+		{pattern: "sh[a:10]", wantErr: errors.New("invalid entry pattern: step without range: a:10: sh[a:10]")},
+		{pattern: "sh[0010-0020:10],sh0020", wantErr: errors.New("invalid entry pattern: duplicated name sh0020: sh[0010-0020:10],sh0020")},
+		{pattern: "sh[0-9999]", wantErr: errors.New("invalid entry pattern: more than 1000 names: sh[0-9999]")},
+		{pattern: "[0-99][0-99]", wantErr: errors.New("invalid entry pattern: more than 1000 names: [0-99][0-99]")},
+	}
+	for _, c := range cases {
+		got, err := ExpandEntryPattern(c.pattern)
+		if !equalError(c.wantErr, err) {
+			t.Fatalf("%q: want err %v, got %v", c.pattern, c.wantErr, err)
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Fatalf("%q: want %v, got %v", c.pattern, c.want, got)
+		}
+	}
+}
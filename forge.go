@@ -26,6 +26,7 @@ func (e *Entry) Name() string {
 
 func (e *Entry) MarshalJSON() ([]byte, error) {
 	m := struct {
+		ID           int
 		Path         string
 		Name         string
 		Type         string
@@ -35,6 +36,7 @@ func (e *Entry) MarshalJSON() ([]byte, error) {
 		HasThumbnail bool
 		Property     map[string]*Property
 	}{
+		ID:           e.ID,
 		Path:         e.Path,
 		Name:         e.Name(),
 		Type:         e.Type,
@@ -83,6 +85,37 @@ type Default struct {
 	Name      string
 	Type      string
 	Value     string
+	// Constraint restricts values of the property. Only valid for property defaults.
+	// See Constraint type for the form.
+	Constraint string
+	// PropertyMeta is only valid for property defaults.
+	PropertyMeta
+	// Inheritable makes an empty property take the value of the nearest ancestor
+	// having the same property. Only valid for property defaults.
+	Inheritable bool
+}
+
+// PropertyMeta is information for presenting a property,
+// so tools can show the property consistently.
+type PropertyMeta struct {
+	// Label is the name shown to users. Name of the property will be shown when it is empty.
+	Label string
+	// Description is a help text of the property.
+	Description string
+	// Section groups related properties.
+	Section string
+	// Order decides position of the property in the section. Lower comes first.
+	Order int
+	// ReadOnly prevents the property from being modified by non-admin users.
+	ReadOnly bool
+	// ReadGroups limits users those can see the property to members of the groups.
+	// Members of WriteGroups can see the property as well.
+	// Empty means everyone who can see the entry.
+	ReadGroups []string
+	// WriteGroups limits users those can modify the property to members of the groups.
+	// The members still need write access to the entry, unless they are property owners.
+	// Empty means everyone who can modify the entry.
+	WriteGroups []string
 }
 
 type DefaultFinder struct {
@@ -92,12 +125,15 @@ type DefaultFinder struct {
 }
 
 type DefaultUpdater struct {
-	EntryType string
-	Category  string
-	Name      string
-	NewName   *string
-	Type      *string
-	Value     *string
+	EntryType   string
+	Category    string
+	Name        string
+	NewName     *string
+	Type        *string
+	Value       *string
+	Constraint  *string
+	Meta        *PropertyMeta
+	Inheritable *bool
 }
 
 // Global is similar with Default in a sense that it is tied to an EntryType.
@@ -148,25 +184,48 @@ type Property struct {
 	ValueError error
 	RawValue   string
 	UpdatedAt  time.Time
+	// Lookup is the property referenced by a lookup property.
+	// It is nil for other types, or when the reference cannot be resolved.
+	Lookup *Property
+	// PropertyMeta is brought from the default of the property.
+	// It is empty for environs.
+	PropertyMeta
+	// Inheritable is brought from the default of the property.
+	Inheritable bool
+	// InheritedFrom is the path of the ancestor where the value is inherited from.
+	// It is empty when the property has it's own value.
+	InheritedFrom string
 }
 
 func (p *Property) MarshalJSON() ([]byte, error) {
 	m := struct {
-		Path      string
-		Name      string
-		Type      string
-		Eval      string
-		Value     string
-		RawValue  string
-		UpdatedAt string
+		Path          string
+		Name          string
+		Type          string
+		Eval          string
+		Value         string
+		RawValue      string
+		UpdatedAt     string
+		Label         string
+		Description   string
+		Section       string
+		Order         int
+		ReadOnly      bool
+		InheritedFrom string
 	}{
-		Path:      p.EntryPath, // TODO: change to EntryPath as like Property itself
-		Name:      p.Name,
-		Type:      p.Type,
-		Eval:      p.Eval,
-		Value:     p.Value,
-		RawValue:  p.RawValue,
-		UpdatedAt: p.UpdatedAt.Format(time.RFC3339),
+		Path:          p.EntryPath, // TODO: change to EntryPath as like Property itself
+		Name:          p.Name,
+		Type:          p.Type,
+		Eval:          p.Eval,
+		Value:         p.Value,
+		RawValue:      p.RawValue,
+		UpdatedAt:     p.UpdatedAt.Format(time.RFC3339),
+		Label:         p.Label,
+		Description:   p.Description,
+		Section:       p.Section,
+		Order:         p.Order,
+		ReadOnly:      p.ReadOnly,
+		InheritedFrom: p.InheritedFrom,
 	}
 	return json.Marshal(m)
 }
@@ -192,6 +251,24 @@ func CompareProperty(t string, a, b string) int {
 		} else if ia > ib {
 			cmp = 1
 		}
+	case "formula":
+		fa, erra := strconv.ParseFloat(a, 64)
+		fb, errb := strconv.ParseFloat(b, 64)
+		// show the error value first
+		if erra != nil {
+			cmp--
+		}
+		if errb != nil {
+			cmp++
+		}
+		if cmp != 0 {
+			return cmp
+		}
+		if fa < fb {
+			cmp = -1
+		} else if fa > fb {
+			cmp = 1
+		}
 	default:
 		cmp = strings.Compare(a, b)
 	}
@@ -210,6 +287,16 @@ type PropertyUpdater struct {
 	Value     *string
 }
 
+// Reference is a property that points to another entry.
+type Reference struct {
+	// EntryPath is path of the entry that has the property.
+	EntryPath string
+	Name      string
+	Type      string
+	// Target is path of the entry the property points to.
+	Target string
+}
+
 func PropertyTypes() []string {
 	return []string{
 		"text",
@@ -217,9 +304,12 @@ func PropertyTypes() []string {
 		"date",
 		"entry_path",
 		"entry_name",
+		"formula",
 		"int",
+		"lookup",
 		"timecode",
 		"user",
+		"users",
 	}
 }
 
@@ -230,6 +320,29 @@ func AccessorTypes() []string {
 	}
 }
 
+// AccessModes returns modes of an access control.
+//
+//	r     the accessor can read the entry
+//	rw    the accessor can read and write the entry
+//	none  the accessor cannot see the entry, even if a parent entry allows it
+func AccessModes() []string {
+	return []string{
+		"r",
+		"rw",
+		"none",
+	}
+}
+
+// IsAccessMode checks the mode is one of the access modes.
+func IsAccessMode(mode string) bool {
+	for _, m := range AccessModes() {
+		if m == mode {
+			return true
+		}
+	}
+	return false
+}
+
 // Accessor is either a user or a group, that can be specified in entry access control list.
 type Accessor struct {
 	ID       int
@@ -282,20 +395,61 @@ type AccessUpdater struct {
 	Value     *string
 }
 
-type Log struct {
-	ID        int
+// AccessExplanation explains how the access mode of a user to an entry is decided.
+type AccessExplanation struct {
+	User string
+	Path string
+	// Mode is the final access mode, "r" or "rw".
+	// Empty mode means the user cannot see the entry.
+	Mode     string
+	Admin    bool
+	Disabled bool
+	// Reason describes the decision in short.
+	Reason string
+	// Steps are the entry and it's parents checked in order, until the mode is decided.
+	Steps []*AccessStep
+}
+
+// AccessStep is an entry checked to decide an access mode.
+type AccessStep struct {
 	EntryPath string
-	User      string
-	Action    string
-	Category  string
-	Name      string
-	Type      string
-	Value     string
-	When      time.Time
+	// Matches are access controls of the entry those are applied to the user,
+	// either for the user or for groups having the user as a member.
+	Matches []*AccessMatch
+}
+
+// AccessMatch is an access control applied to a user.
+type AccessMatch struct {
+	Name string
+	Type string
+	Mode string
+	// Decisive indicates the access control decided the mode.
+	Decisive bool
+	// Via is the chain of sub groups, through which the user is a member of the group.
+	// It starts from the group having the user directly, and ends right below the group.
+	// It is empty when the access control is for the user, or the user is a direct member of the group.
+	Via []string
+}
+
+type Log struct {
+	ID           int
+	EntryPath    string
+	User         string
+	Impersonator string
+	Action       string
+	Category     string
+	Name         string
+	Type         string
+	Value        string
+	When         time.Time
 }
 
 func (l *Log) String() string {
-	s := fmt.Sprintf("%v: %v %v %v: %v", l.When, l.User, l.Action, l.Category, l.Name)
+	user := l.User
+	if l.Impersonator != "" {
+		user += fmt.Sprintf(" (by %v)", l.Impersonator)
+	}
+	s := fmt.Sprintf("%v: %v %v %v: %v", l.When, user, l.Action, l.Category, l.Name)
 	if l.Value != "" {
 		s += fmt.Sprintf(" = %v", l.Value)
 	}
@@ -313,6 +467,9 @@ type User struct {
 	Name     string
 	Called   string
 	Disabled bool
+	// Pending indicates the user signed up and is waiting for approval of an admin.
+	// A pending user is disabled as well.
+	Pending bool
 }
 
 type UserFinder struct {
@@ -320,6 +477,7 @@ type UserFinder struct {
 	Name     *string
 	Called   *string
 	Disabled *bool
+	Pending  *bool
 }
 
 type UserUpdater struct {
@@ -397,14 +555,52 @@ type GroupUpdater struct {
 	Called  *string
 }
 
+// GroupReferences reports where a group is referenced.
+// A referenced group will not be deleted unless it is deleted with cascade.
+type GroupReferences struct {
+	Group string
+	// Accesses are paths of entries those have access control for the group.
+	Accesses []string
+	// DefaultAccesses are entry types those have default access for the group.
+	DefaultAccesses []string
+	// Parents are groups those have the group as a member.
+	Parents []string
+	// Properties are properties those have the group in their value, written as 'path.name'.
+	Properties []string
+	// DefaultProperties are default properties those have the group in their read or write groups,
+	// written as 'entry_type.name'.
+	DefaultProperties []string
+}
+
+// Empty reports whether the group isn't referenced at all.
+func (r *GroupReferences) Empty() bool {
+	return len(r.Accesses) == 0 && len(r.DefaultAccesses) == 0 && len(r.Parents) == 0 &&
+		len(r.Properties) == 0 && len(r.DefaultProperties) == 0
+}
+
+func (r *GroupReferences) String() string {
+	return fmt.Sprintf("%d accesses, %d default accesses, %d parent groups, %d properties, %d default properties",
+		len(r.Accesses), len(r.DefaultAccesses), len(r.Parents), len(r.Properties), len(r.DefaultProperties))
+}
+
+// Member is a member of a group. It could be either a user or a group.
 type Member struct {
 	Group  string
 	Member string
+	// IsGroup indicates the member is a group.
+	// Members of the group are members of the parent group as well.
+	IsGroup bool
+	// Via is the sub group the member is a member of.
+	// It is only set for an indirect member found with MemberFinder.Expand.
+	Via string
 }
 
 type MemberFinder struct {
 	Group  string
 	Member *string
+	// Expand finds users those are members of the group directly or through sub groups.
+	// Sub groups themselves are not included.
+	Expand bool
 }
 
 type QuickSearchArranger struct {
@@ -0,0 +1,437 @@
+package forge
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Formula is a parsed expression of a formula property.
+//
+// A formula is an arithmetic expression (+, -, *, /, parentheses) of numbers and following terms.
+//
+//	name                  value of a property of the entry
+//	env.NAME              value of an environ of the entry
+//	count("query")        number of sub entries matching the search query
+//	sum(name, "query")    sum of a property of sub entries matching the search query
+//	min(name, "query")    minimum of a property of sub entries matching the search query
+//	max(name, "query")    maximum of a property of sub entries matching the search query
+//
+// The query is the same as the one used for entry search, and searches sub entries of the entry.
+// When the query starts with "../", it searches siblings of the entry instead.
+//
+// ex) count("type=shot status=done") / count("type=shot") * 100
+type Formula struct {
+	src  string
+	root formulaNode
+}
+
+// FormulaEnv provides values those a formula refers.
+type FormulaEnv interface {
+	// Property returns value of a property of the entry.
+	Property(name string) (string, error)
+	// Environ returns value of an environ of the entry.
+	Environ(name string) (string, error)
+	// Search returns values of a property for each entry matching the query.
+	// When name is empty, it should return an empty string for each entry.
+	Search(query, name string) ([]string, error)
+}
+
+// ParseFormula parses a formula expression.
+func ParseFormula(s string) (*Formula, error) {
+	toks, err := formulaTokens(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid formula: %v", err)
+	}
+	p := &formulaParser{toks: toks}
+	root, err := p.expr()
+	if err != nil {
+		return nil, fmt.Errorf("invalid formula: %v", err)
+	}
+	if p.peek().kind != tokenEOF {
+		return nil, fmt.Errorf("invalid formula: unexpected %v", p.peek())
+	}
+	return &Formula{src: s, root: root}, nil
+}
+
+// String returns the expression of the formula.
+func (f *Formula) String() string {
+	return f.src
+}
+
+// Eval evaluates the formula with values from env.
+func (f *Formula) Eval(env FormulaEnv) (float64, error) {
+	return f.root.eval(env)
+}
+
+// Properties returns names of the properties those the formula refers by name,
+// including the ones aggregated from other entries.
+// Properties used in search queries aren't included.
+func (f *Formula) Properties() []string {
+	names := make([]string, 0)
+	seen := make(map[string]bool)
+	var walk func(n formulaNode)
+	walk = func(n formulaNode) {
+		name := ""
+		switch n := n.(type) {
+		case propertyNode:
+			name = string(n)
+		case callNode:
+			name = n.name
+		case binaryNode:
+			walk(n.l)
+			walk(n.r)
+		}
+		if name == "" || seen[name] {
+			return
+		}
+		seen[name] = true
+		names = append(names, name)
+	}
+	walk(f.root)
+	return names
+}
+
+// FormatFormulaResult formats a result of formula evaluation.
+// It doesn't have a decimal point for integers.
+func FormatFormulaResult(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// parseFormulaNumber parses a value of a property used in a formula.
+// Empty value is treated as 0.
+func parseFormulaNumber(name, v string) (float64, error) {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return 0, nil
+	}
+	n, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return 0, fmt.Errorf("not a number value of %v: %v", name, v)
+	}
+	return n, nil
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenNumber
+	tokenString
+	tokenIdent
+	tokenOp
+)
+
+type formulaToken struct {
+	kind tokenKind
+	val  string
+}
+
+func (t formulaToken) String() string {
+	switch t.kind {
+	case tokenEOF:
+		return "end of formula"
+	case tokenString:
+		return strconv.Quote(t.val)
+	}
+	return "'" + t.val + "'"
+}
+
+func formulaTokens(s string) ([]formulaToken, error) {
+	toks := make([]formulaToken, 0)
+	rs := []rune(s)
+	isIdent := func(r rune) bool {
+		return r == '_' || r == '.' || unicode.IsLetter(r) || unicode.IsDigit(r)
+	}
+	for i := 0; i < len(rs); {
+		r := rs[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case strings.ContainsRune("+-*/(),", r):
+			toks = append(toks, formulaToken{kind: tokenOp, val: string(r)})
+			i++
+		case r == '"':
+			j := i + 1
+			for j < len(rs) && rs[j] != '"' {
+				j++
+			}
+			if j == len(rs) {
+				return nil, fmt.Errorf("unclosed string")
+			}
+			toks = append(toks, formulaToken{kind: tokenString, val: string(rs[i+1 : j])})
+			i = j + 1
+		case unicode.IsDigit(r):
+			j := i
+			for j < len(rs) && (unicode.IsDigit(rs[j]) || rs[j] == '.') {
+				j++
+			}
+			toks = append(toks, formulaToken{kind: tokenNumber, val: string(rs[i:j])})
+			i = j
+		case isIdent(r):
+			j := i
+			for j < len(rs) && isIdent(rs[j]) {
+				j++
+			}
+			toks = append(toks, formulaToken{kind: tokenIdent, val: string(rs[i:j])})
+			i = j
+		default:
+			return nil, fmt.Errorf("unexpected character '%v'", string(r))
+		}
+	}
+	toks = append(toks, formulaToken{kind: tokenEOF})
+	return toks, nil
+}
+
+type formulaParser struct {
+	toks []formulaToken
+	pos  int
+}
+
+func (p *formulaParser) peek() formulaToken {
+	return p.toks[p.pos]
+}
+
+func (p *formulaParser) next() formulaToken {
+	t := p.toks[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *formulaParser) expect(op string) error {
+	t := p.next()
+	if t.kind != tokenOp || t.val != op {
+		return fmt.Errorf("want '%v', got %v", op, t)
+	}
+	return nil
+}
+
+// expr := term (('+' | '-') term)*
+func (p *formulaParser) expr() (formulaNode, error) {
+	n, err := p.term()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := p.peek()
+		if t.kind != tokenOp || (t.val != "+" && t.val != "-") {
+			return n, nil
+		}
+		p.next()
+		r, err := p.term()
+		if err != nil {
+			return nil, err
+		}
+		n = binaryNode{op: t.val, l: n, r: r}
+	}
+}
+
+// term := factor (('*' | '/') factor)*
+func (p *formulaParser) term() (formulaNode, error) {
+	n, err := p.factor()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := p.peek()
+		if t.kind != tokenOp || (t.val != "*" && t.val != "/") {
+			return n, nil
+		}
+		p.next()
+		r, err := p.factor()
+		if err != nil {
+			return nil, err
+		}
+		n = binaryNode{op: t.val, l: n, r: r}
+	}
+}
+
+// factor := number | '-' factor | '(' expr ')' | call | reference
+func (p *formulaParser) factor() (formulaNode, error) {
+	t := p.next()
+	switch t.kind {
+	case tokenNumber:
+		v, err := strconv.ParseFloat(t.val, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number: %v", t.val)
+		}
+		return numberNode(v), nil
+	case tokenOp:
+		if t.val == "-" {
+			n, err := p.factor()
+			if err != nil {
+				return nil, err
+			}
+			return binaryNode{op: "-", l: numberNode(0), r: n}, nil
+		}
+		if t.val == "(" {
+			n, err := p.expr()
+			if err != nil {
+				return nil, err
+			}
+			err = p.expect(")")
+			if err != nil {
+				return nil, err
+			}
+			return n, nil
+		}
+	case tokenIdent:
+		nt := p.peek()
+		if nt.kind == tokenOp && nt.val == "(" {
+			p.next()
+			return p.call(t.val)
+		}
+		if env, ok := strings.CutPrefix(t.val, "env."); ok {
+			return environNode(env), nil
+		}
+		return propertyNode(t.val), nil
+	}
+	return nil, fmt.Errorf("unexpected %v", t)
+}
+
+// call := fn '(' [name ','] query ')'
+func (p *formulaParser) call(fn string) (formulaNode, error) {
+	n := callNode{fn: fn}
+	switch fn {
+	case "count":
+	case "sum", "min", "max":
+		t := p.next()
+		if t.kind != tokenIdent {
+			return nil, fmt.Errorf("%v needs a property name as the first argument, got %v", fn, t)
+		}
+		n.name = t.val
+		err := p.expect(",")
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown function: %v", fn)
+	}
+	t := p.next()
+	if t.kind != tokenString {
+		return nil, fmt.Errorf("%v needs a search query string, got %v", fn, t)
+	}
+	n.query = t.val
+	err := p.expect(")")
+	if err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+type formulaNode interface {
+	eval(env FormulaEnv) (float64, error)
+}
+
+type numberNode float64
+
+func (n numberNode) eval(env FormulaEnv) (float64, error) {
+	return float64(n), nil
+}
+
+type propertyNode string
+
+func (n propertyNode) eval(env FormulaEnv) (float64, error) {
+	v, err := env.Property(string(n))
+	if err != nil {
+		return 0, err
+	}
+	return parseFormulaNumber(string(n), v)
+}
+
+type environNode string
+
+func (n environNode) eval(env FormulaEnv) (float64, error) {
+	v, err := env.Environ(string(n))
+	if err != nil {
+		return 0, err
+	}
+	return parseFormulaNumber(string(n), v)
+}
+
+type binaryNode struct {
+	op   string
+	l, r formulaNode
+}
+
+func (n binaryNode) eval(env FormulaEnv) (float64, error) {
+	l, err := n.l.eval(env)
+	if err != nil {
+		return 0, err
+	}
+	r, err := n.r.eval(env)
+	if err != nil {
+		return 0, err
+	}
+	switch n.op {
+	case "+":
+		return l + r, nil
+	case "-":
+		return l - r, nil
+	case "*":
+		return l * r, nil
+	case "/":
+		if r == 0 {
+			return 0, fmt.Errorf("division by zero")
+		}
+		return l / r, nil
+	}
+	return 0, fmt.Errorf("unknown operator: %v", n.op)
+}
+
+// callNode is a call to an aggregate function.
+// Empty values are ignored, and it returns 0 when there is no value to aggregate.
+type callNode struct {
+	fn    string
+	name  string
+	query string
+}
+
+func (n callNode) eval(env FormulaEnv) (float64, error) {
+	vals, err := env.Search(n.query, n.name)
+	if err != nil {
+		return 0, err
+	}
+	if n.fn == "count" {
+		return float64(len(vals)), nil
+	}
+	nums := make([]float64, 0, len(vals))
+	for _, v := range vals {
+		if strings.TrimSpace(v) == "" {
+			continue
+		}
+		f, err := parseFormulaNumber(n.name, v)
+		if err != nil {
+			return 0, err
+		}
+		nums = append(nums, f)
+	}
+	if len(nums) == 0 {
+		return 0, nil
+	}
+	switch n.fn {
+	case "sum":
+		sum := 0.0
+		for _, f := range nums {
+			sum += f
+		}
+		return sum, nil
+	case "min":
+		m := math.Inf(1)
+		for _, f := range nums {
+			m = math.Min(m, f)
+		}
+		return m, nil
+	case "max":
+		m := math.Inf(-1)
+		for _, f := range nums {
+			m = math.Max(m, f)
+		}
+		return m, nil
+	}
+	return 0, fmt.Errorf("unknown function: %v", n.fn)
+}
@@ -0,0 +1,151 @@
+package forge
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testFormulaEnv is a FormulaEnv for tests.
+// Search treats the query as the name of a sub entry group.
+type testFormulaEnv struct {
+	prop map[string]string
+	env  map[string]string
+	sub  map[string][]map[string]string
+}
+
+func (e testFormulaEnv) Property(name string) (string, error) {
+	v, ok := e.prop[name]
+	if !ok {
+		return "", fmt.Errorf("property not found: %v", name)
+	}
+	return v, nil
+}
+
+func (e testFormulaEnv) Environ(name string) (string, error) {
+	v, ok := e.env[name]
+	if !ok {
+		return "", fmt.Errorf("environ not found: %v", name)
+	}
+	return v, nil
+}
+
+func (e testFormulaEnv) Search(query, name string) ([]string, error) {
+	vals := make([]string, 0)
+	for _, s := range e.sub[query] {
+		vals = append(vals, s[name])
+	}
+	return vals, nil
+}
+
+func TestFormula(t *testing.T) {
+	env := testFormulaEnv{
+		prop: map[string]string{
+			"duration": "48",
+			"handle":   "8",
+			"empty":    "",
+			"status":   "done",
+		},
+		env: map[string]string{
+			"FPS": "24",
+		},
+		sub: map[string][]map[string]string{
+			"type=shot": {
+				{"duration": "10", "status": "done"},
+				{"duration": "", "status": "inprogress"},
+				{"duration": "25", "status": "done"},
+				{"duration": "5", "status": ""},
+			},
+			"type=shot status=done": {
+				{"duration": "10", "status": "done"},
+				{"duration": "25", "status": "done"},
+			},
+			"type=asset": {},
+			"bad": {
+				{"duration": "long"},
+			},
+		},
+	}
+	cases := []struct {
+		formula  string
+		want     string
+		parseErr error
+		evalErr  error
+	}{
+		{formula: "1", want: "1"},
+		{formula: "1 + 2 * 3", want: "7"},
+		{formula: "(1 + 2) * 3", want: "9"},
+		{formula: "-3 + 1", want: "-2"},
+		{formula: "7 / 2", want: "3.5"},
+		{formula: "duration + handle * 2", want: "64"},
+		{formula: "duration / env.FPS", want: "2"},
+		{formula: "empty + 1", want: "1"},
+		{formula: `count("type=shot")`, want: "4"},
+		{formula: `count("type=asset")`, want: "0"},
+		{formula: `count("type=shot status=done") / count("type=shot") * 100`, want: "50"},
+		{formula: `sum(duration, "type=shot")`, want: "40"},
+		{formula: `min(duration, "type=shot")`, want: "5"},
+		{formula: `max(duration, "type=shot")`, want: "25"},
+		{formula: `max(duration, "type=asset")`, want: "0"},
+		{formula: "", parseErr: errors.New("invalid formula: unexpected end of formula")},
+		{formula: "1 +", parseErr: errors.New("invalid formula: unexpected end of formula")},
+		{formula: "(1 + 2", parseErr: errors.New("invalid formula: want ')', got end of formula")},
+		{formula: "1 2", parseErr: errors.New("invalid formula: unexpected '2'")},
+		{formula: "1 % 2", parseErr: errors.New("invalid formula: unexpected character '%'")},
+		{formula: `count("type=shot)`, parseErr: errors.New("invalid formula: unclosed string")},
+		{formula: `count(type)`, parseErr: errors.New("invalid formula: count needs a search query string, got 'type'")},
+		{formula: `sum("type=shot")`, parseErr: errors.New(`invalid formula: sum needs a property name as the first argument, got "type=shot"`)},
+		{formula: `avg(duration, "type=shot")`, parseErr: errors.New("invalid formula: unknown function: avg")},
+		{formula: "1 / 0", evalErr: errors.New("division by zero")},
+		{formula: "status + 1", evalErr: errors.New("not a number value of status: done")},
+		{formula: "missing", evalErr: errors.New("property not found: missing")},
+		{formula: "env.MISSING", evalErr: errors.New("environ not found: MISSING")},
+		{formula: `sum(duration, "bad")`, evalErr: errors.New("not a number value of duration: long")},
+	}
+	for _, c := range cases {
+		f, err := ParseFormula(c.formula)
+		if !equalError(c.parseErr, err) {
+			t.Fatalf("%q: want parse err %v, got %v", c.formula, c.parseErr, err)
+		}
+		if err != nil {
+			continue
+		}
+		if strings.TrimSpace(f.String()) != strings.TrimSpace(c.formula) {
+			t.Fatalf("%q: unexpected string of formula: %q", c.formula, f.String())
+		}
+		v, err := f.Eval(env)
+		if !equalError(c.evalErr, err) {
+			t.Fatalf("%q: want eval err %v, got %v", c.formula, c.evalErr, err)
+		}
+		if err != nil {
+			continue
+		}
+		got := FormatFormulaResult(v)
+		if got != c.want {
+			t.Fatalf("%q: want %v, got %v", c.formula, c.want, got)
+		}
+	}
+}
+
+func TestFormulaProperties(t *testing.T) {
+	cases := []struct {
+		formula string
+		want    []string
+	}{
+		{formula: "1 + 2", want: []string{}},
+		{formula: "bid * (days + bid)", want: []string{"bid", "days"}},
+		{formula: `sum(duration, "status=done") / count("status=done") + env.FPS`, want: []string{"duration"}},
+	}
+	for _, c := range cases {
+		f, err := ParseFormula(c.formula)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := f.Properties()
+		if !reflect.DeepEqual(got, c.want) {
+			t.Fatalf("%q: want %v, got %v", c.formula, c.want, got)
+		}
+	}
+}
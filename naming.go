@@ -0,0 +1,193 @@
+package forge
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// NamingRule restricts names of new entries of a type.
+// It is defined with 'naming_rule' global of the type.
+//
+// It is written as lines of 'key: value' form.
+//
+//	pattern: sh\d+    name should match the regular expression entirely
+//	prefix: sh        name should start with the prefix
+//	padding: 4        name should have zero padded number of the width after the prefix
+//	step: 10          enables auto numbering, the next name will be the next multiple of it
+//
+// Auto numbering is used when a new entry is named as '+'.
+// An empty name doesn't trigger it, as a path ended with '/' is cleaned to the parent path
+// and should fail as the parent already exists, instead of adding an unexpected entry.
+// Use '+' or Server.AddNumberedEntry instead.
+type NamingRule struct {
+	Pattern string
+	Prefix  string
+	Padding int
+	Step    int
+
+	// re is the compiled Pattern, set by ParseNamingRule.
+	re *regexp.Regexp
+}
+
+// ParseNamingRule parses a naming rule.
+// Empty string is a valid naming rule, that doesn't restrict anything.
+func ParseNamingRule(s string) (*NamingRule, error) {
+	r := &NamingRule{}
+	for _, ln := range strings.Split(s, "\n") {
+		ln = strings.TrimSpace(ln)
+		if ln == "" {
+			continue
+		}
+		k, v, _ := strings.Cut(ln, ":")
+		k = strings.TrimSpace(k)
+		v = strings.TrimSpace(v)
+		switch k {
+		case "pattern":
+			if v == "" {
+				return nil, fmt.Errorf("invalid naming rule: pattern not specified")
+			}
+			re, err := compileWholeMatch(v)
+			if err != nil {
+				return nil, fmt.Errorf("invalid naming rule: %v", err)
+			}
+			r.Pattern = v
+			r.re = re
+		case "prefix":
+			if v == "" {
+				return nil, fmt.Errorf("invalid naming rule: prefix not specified")
+			}
+			r.Prefix = v
+		case "padding", "step":
+			n, err := strconv.Atoi(v)
+			if err != nil || n <= 0 {
+				return nil, fmt.Errorf("invalid naming rule: %v should be a positive integer: %v", k, v)
+			}
+			if k == "padding" {
+				r.Padding = n
+			} else {
+				r.Step = n
+			}
+		default:
+			return nil, fmt.Errorf("invalid naming rule: unknown key: %v", k)
+		}
+	}
+	return r, nil
+}
+
+// String returns the naming rule as it's text form.
+func (r *NamingRule) String() string {
+	lines := make([]string, 0)
+	if r.Pattern != "" {
+		lines = append(lines, "pattern: "+r.Pattern)
+	}
+	if r.Prefix != "" {
+		lines = append(lines, "prefix: "+r.Prefix)
+	}
+	if r.Padding != 0 {
+		lines = append(lines, "padding: "+strconv.Itoa(r.Padding))
+	}
+	if r.Step != 0 {
+		lines = append(lines, "step: "+strconv.Itoa(r.Step))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Check checks a name with the naming rule.
+// The error lists all the rules the name violates.
+func (r *NamingRule) Check(name string) error {
+	violated := make([]string, 0)
+	if r.Pattern != "" {
+		re := r.re
+		if re == nil {
+			// the rule is not made with ParseNamingRule.
+			var err error
+			re, err = compileWholeMatch(r.Pattern)
+			if err != nil {
+				return fmt.Errorf("invalid naming rule: %v", err)
+			}
+		}
+		if !re.MatchString(name) {
+			violated = append(violated, "pattern: "+r.Pattern)
+		}
+	}
+	rest := name
+	if r.Prefix != "" {
+		var ok bool
+		rest, ok = strings.CutPrefix(name, r.Prefix)
+		if !ok {
+			violated = append(violated, "prefix: "+r.Prefix)
+		}
+	}
+	if r.Padding != 0 && !isPaddedNumber(rest, r.Padding) {
+		violated = append(violated, "padding: "+strconv.Itoa(r.Padding))
+	}
+	if len(violated) != 0 {
+		return fmt.Errorf("name violates the naming rule (%v): %v", strings.Join(violated, ", "), name)
+	}
+	return nil
+}
+
+// isPaddedNumber checks s is a number zero padded to the width.
+// A number wider than the width is fine, when it doesn't have leading zeros.
+func isPaddedNumber(s string, width int) bool {
+	if !isDigits(s) {
+		return false
+	}
+	if len(s) == width {
+		return true
+	}
+	return len(s) > width && s[0] != '0'
+}
+
+// Next returns the next name of auto numbering that follows names.
+// Names not in prefix + number form are ignored.
+func (r *NamingRule) Next(names []string) (string, error) {
+	if r.Step == 0 {
+		return "", fmt.Errorf("auto numbering is not enabled, naming rule should have step")
+	}
+	last := 0
+	for _, name := range names {
+		num, ok := strings.CutPrefix(name, r.Prefix)
+		if !ok || !isDigits(num) {
+			continue
+		}
+		n, err := strconv.Atoi(num)
+		if err != nil {
+			// too big
+			continue
+		}
+		last = max(last, n)
+	}
+	n := (last/r.Step + 1) * r.Step
+	name := r.Prefix + fmt.Sprintf("%0*d", r.Padding, n)
+	err := r.Check(name)
+	if err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+// isDigits checks s is not empty and consists of digits only.
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// compileWholeMatch compiles the pattern to a regular expression that should match an entire string.
+func compileWholeMatch(pattern string) (*regexp.Regexp, error) {
+	// compile the pattern as is at first, so an error shows the pattern written by the user.
+	_, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	return regexp.Compile("^(?:" + pattern + ")$")
+}
@@ -0,0 +1,101 @@
+package forge
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseNamingRule(t *testing.T) {
+	cases := []struct {
+		s       string
+		want    string
+		wantErr error
+	}{
+		{s: "", want: ""},
+		{s: "step: 10\n padding : 4\n\nprefix: sh", want: "prefix: sh\npadding: 4\nstep: 10"},
+		{s: "pattern: [a-z]+\\d*", want: "pattern: [a-z]+\\d*"},
+		{s: "pattern:", wantErr: errors.New("invalid naming rule: pattern not specified")},
+		{s: "pattern: (", wantErr: errors.New("invalid naming rule: error parsing regexp: missing closing ): `(`")},
+		{s: "prefix:", wantErr: errors.New("invalid naming rule: prefix not specified")},
+		{s: "padding: four", wantErr: errors.New("invalid naming rule: padding should be a positive integer: four")},
+		{s: "step: 0", wantErr: errors.New("invalid naming rule: step should be a positive integer: 0")},
+		{s: "suffix: _v", wantErr: errors.New("invalid naming rule: unknown key: suffix")},
+	}
+	for _, c := range cases {
+		got, err := ParseNamingRule(c.s)
+		if !equalError(c.wantErr, err) {
+			t.Fatalf("%q: want err %v, got %v", c.s, c.wantErr, err)
+		}
+		if err != nil {
+			continue
+		}
+		if got.String() != c.want {
+			t.Fatalf("%q: want %q, got %q", c.s, c.want, got.String())
+		}
+	}
+}
+
+func TestNamingRuleCheck(t *testing.T) {
+	cases := []struct {
+		rule    string
+		name    string
+		wantErr error
+	}{
+		{rule: "", name: "anything"},
+		{rule: "prefix: sh\npadding: 4", name: "sh0010"},
+		{rule: "prefix: sh\npadding: 4", name: "sh12345"},
+		{rule: "prefix: sh\npadding: 4", name: "sh010", wantErr: errors.New("name violates the naming rule (padding: 4): sh010")},
+		{rule: "prefix: sh\npadding: 4", name: "sh00010", wantErr: errors.New("name violates the naming rule (padding: 4): sh00010")},
+		{rule: "prefix: sh\npadding: 4", name: "0010", wantErr: errors.New("name violates the naming rule (prefix: sh): 0010")},
+		{rule: "prefix: sh\npadding: 4", name: "x1", wantErr: errors.New("name violates the naming rule (prefix: sh, padding: 4): x1")},
+		{rule: "padding: 3", name: "010"},
+		{rule: "pattern: [a-z]+", name: "char"},
+		{rule: "pattern: [a-z]+\nprefix: c", name: "Char1", wantErr: errors.New("name violates the naming rule (pattern: [a-z]+, prefix: c): Char1")},
+	}
+	for _, c := range cases {
+		r, err := ParseNamingRule(c.rule)
+		if err != nil {
+			t.Fatalf("%q: %v", c.rule, err)
+		}
+		err = r.Check(c.name)
+		if !equalError(c.wantErr, err) {
+			t.Fatalf("%q with %q: want err %v, got %v", c.rule, c.name, c.wantErr, err)
+		}
+	}
+	// a rule not made with ParseNamingRule reports an invalid pattern, instead of panicking.
+	err := (&NamingRule{Pattern: "("}).Check("(")
+	want := errors.New("invalid naming rule: error parsing regexp: missing closing ): `(`")
+	if !equalError(want, err) {
+		t.Fatalf("invalid pattern: want err %v, got %v", want, err)
+	}
+}
+
+func TestNamingRuleNext(t *testing.T) {
+	cases := []struct {
+		rule    string
+		names   []string
+		want    string
+		wantErr error
+	}{
+		{rule: "prefix: sh\npadding: 4\nstep: 10", names: nil, want: "sh0010"},
+		{rule: "prefix: sh\npadding: 4\nstep: 10", names: []string{"sh0010", "sh0020"}, want: "sh0030"},
+		{rule: "prefix: sh\npadding: 4\nstep: 10", names: []string{"sh0010", "sh0015", "etc"}, want: "sh0020"},
+		{rule: "prefix: sh\npadding: 4\nstep: 10", names: []string{"sh9990"}, want: "sh10000"},
+		{rule: "step: 1", names: []string{"3", "1", "a7"}, want: "4"},
+		{rule: "prefix: sh\npadding: 4", wantErr: errors.New("auto numbering is not enabled, naming rule should have step")},
+		{rule: "pattern: sh00\\d\\d\nprefix: sh\npadding: 4\nstep: 50", names: []string{"sh0050"}, wantErr: errors.New("name violates the naming rule (pattern: sh00\\d\\d): sh0100")},
+	}
+	for _, c := range cases {
+		r, err := ParseNamingRule(c.rule)
+		if err != nil {
+			t.Fatalf("%q: %v", c.rule, err)
+		}
+		got, err := r.Next(c.names)
+		if !equalError(c.wantErr, err) {
+			t.Fatalf("%q with %v: want err %v, got %v", c.rule, c.names, c.wantErr, err)
+		}
+		if got != c.want {
+			t.Fatalf("%q with %v: want %q, got %q", c.rule, c.names, c.want, got)
+		}
+	}
+}
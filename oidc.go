@@ -2,8 +2,17 @@ package forge
 
 // OIDC stands for open id connect.
 type OIDC struct {
+	// Issuer is the issuer url of the provider, like https://accounts.google.com.
+	// Endpoints of the provider are discovered from {Issuer}/.well-known/openid-configuration.
+	Issuer       string
 	ClientID     string
 	ClientSecret string
 	RedirectURI  string
-	HostDomain   string
+	// HostDomain limits google accounts to the domain. Other providers ignore it.
+	HostDomain string
+	// IdentityClaim is the claim of an id token that is used as the forge user name.
+	// Empty means "email", which should be verified by the provider (email_verified=true).
+	// Other claims are trusted as is, so set it only to a claim that the provider
+	// guarantees to be verified and stable for a user, like "upn" of azure ad.
+	IdentityClaim string
 }
@@ -0,0 +1,92 @@
+package forge
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Relation is a typed link from an entry to another entry.
+// Unlike an entry_path property, an entry can have many relations of a type.
+type Relation struct {
+	ID   int
+	From string
+	To   string
+	Type string
+}
+
+type RelationFinder struct {
+	From *string
+	To   *string
+	Type *string
+}
+
+// RelationTypes returns types of relations.
+//
+//	uses          ex) a shot uses an asset
+//	derives_from  ex) an asset derives from another asset
+//	depends_on    ex) a shot depends on another shot
+//	relates_to    entries are related, without dependency
+func RelationTypes() []string {
+	return []string{
+		"uses",
+		"derives_from",
+		"depends_on",
+		"relates_to",
+	}
+}
+
+// IsRelationType checks the type is one of the relation types.
+func IsRelationType(typ string) bool {
+	for _, t := range RelationTypes() {
+		if t == typ {
+			return true
+		}
+	}
+	return false
+}
+
+// DependencyRelationTypes returns relation types those make dependency between entries.
+// Relations of these types cannot make a cycle together.
+func DependencyRelationTypes() []string {
+	return []string{
+		"uses",
+		"derives_from",
+		"depends_on",
+	}
+}
+
+// RelationGraphFinder finds relations by following them from an entry.
+//
+// Upstream direction follows relations from an entry to the entries it depends on,
+// while downstream direction follows them backward to the entries depending on it.
+type RelationGraphFinder struct {
+	Path      string
+	Direction string
+	// Depth limits the number of relations followed from the entry.
+	// Zero means there is no limit.
+	Depth int
+	// Types limits types of relations to follow.
+	// Empty means all types.
+	Types []string
+}
+
+// RelationGraph is relations found by following them from the root entry.
+type RelationGraph struct {
+	Root      string
+	Direction string
+	Relations []*Relation
+}
+
+// DOT returns the graph in DOT language of Graphviz.
+func (g *RelationGraph) DOT() string {
+	lines := []string{
+		"digraph relations {",
+		fmt.Sprintf("\t%v [style=bold];", strconv.Quote(g.Root)),
+	}
+	for _, r := range g.Relations {
+		lines = append(lines, fmt.Sprintf("\t%v -> %v [label=%v];", strconv.Quote(r.From), strconv.Quote(r.To), strconv.Quote(r.Type)))
+	}
+	lines = append(lines, "}")
+	return strings.Join(lines, "\n") + "\n"
+}
@@ -0,0 +1,38 @@
+package forge
+
+import (
+	"testing"
+)
+
+func TestRelationGraphDOT(t *testing.T) {
+	cases := []struct {
+		g    *RelationGraph
+		want string
+	}{
+		{
+			g:    &RelationGraph{Root: "/show/shot/sh0010", Direction: "upstream"},
+			want: "digraph relations {\n\t\"/show/shot/sh0010\" [style=bold];\n}\n",
+		},
+		{
+			g: &RelationGraph{
+				Root:      "/show/shot/sh0010",
+				Direction: "upstream",
+				Relations: []*Relation{
+					{From: "/show/shot/sh0010", To: "/show/asset/char/hero", Type: "uses"},
+					{From: "/show/asset/char/hero", To: "/show/asset/char/base", Type: "derives_from"},
+				},
+			},
+			want: "digraph relations {\n" +
+				"\t\"/show/shot/sh0010\" [style=bold];\n" +
+				"\t\"/show/shot/sh0010\" -> \"/show/asset/char/hero\" [label=\"uses\"];\n" +
+				"\t\"/show/asset/char/hero\" -> \"/show/asset/char/base\" [label=\"derives_from\"];\n" +
+				"}\n",
+		},
+	}
+	for _, c := range cases {
+		got := c.g.DOT()
+		if got != c.want {
+			t.Fatalf("%v: want %q, got %q", c.g.Root, c.want, got)
+		}
+	}
+}
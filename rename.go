@@ -0,0 +1,86 @@
+package forge
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// RenameMap returns new names of entries for a batch rename with a rule.
+// Names those are not changed by the rule are not in the map.
+//
+//	s/{regexp}/{replacement}/  replaces parts of a name matched with the regexp, $1 is the first submatch
+//	renumber {start} {step}    renumbers the last numbers of names in their order, keeping the width
+func RenameMap(rule string, names []string) (map[string]string, error) {
+	rule = strings.TrimSpace(rule)
+	renames := make(map[string]string)
+	if strings.HasPrefix(rule, "s/") {
+		toks := strings.Split(rule, "/")
+		if len(toks) != 4 || toks[3] != "" {
+			return nil, fmt.Errorf("invalid rename rule: should be 's/{regexp}/{replacement}/' form: %v", rule)
+		}
+		re, err := regexp.Compile(toks[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid rename rule: %v", err)
+		}
+		for _, name := range names {
+			newName := re.ReplaceAllString(name, toks[2])
+			if newName != name {
+				renames[name] = newName
+			}
+		}
+		return renames, nil
+	}
+	if args, ok := strings.CutPrefix(rule, "renumber "); ok {
+		toks := strings.Fields(args)
+		if len(toks) != 2 {
+			return nil, fmt.Errorf("invalid rename rule: should be 'renumber {start} {step}' form: %v", rule)
+		}
+		start, err := strconv.Atoi(toks[0])
+		if err != nil || start < 0 {
+			return nil, fmt.Errorf("invalid rename rule: start should be a non-negative integer: %v", toks[0])
+		}
+		step, err := strconv.Atoi(toks[1])
+		if err != nil || step <= 0 {
+			return nil, fmt.Errorf("invalid rename rule: step should be a positive integer: %v", toks[1])
+		}
+		type numbered struct {
+			name   string
+			n      int
+			at     int
+			digits string
+		}
+		re := regexp.MustCompile(`\d+`)
+		nums := make([]numbered, 0, len(names))
+		for _, name := range names {
+			locs := re.FindAllStringIndex(name, -1)
+			if len(locs) == 0 {
+				continue
+			}
+			loc := locs[len(locs)-1]
+			digits := name[loc[0]:loc[1]]
+			n, err := strconv.Atoi(digits)
+			if err != nil {
+				return nil, fmt.Errorf("invalid number in name: %v", name)
+			}
+			nums = append(nums, numbered{name: name, n: n, at: loc[0], digits: digits})
+		}
+		sort.SliceStable(nums, func(i, j int) bool {
+			if nums[i].n != nums[j].n {
+				return nums[i].n < nums[j].n
+			}
+			return nums[i].name < nums[j].name
+		})
+		for i, num := range nums {
+			n := fmt.Sprintf("%0*d", len(num.digits), start+i*step)
+			newName := num.name[:num.at] + n + num.name[num.at+len(num.digits):]
+			if newName != num.name {
+				renames[num.name] = newName
+			}
+		}
+		return renames, nil
+	}
+	return nil, fmt.Errorf("invalid rename rule: unknown rule: %v", rule)
+}
@@ -0,0 +1,39 @@
+package forge
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestRenameMap(t *testing.T) {
+	cases := []struct {
+		rule    string
+		names   []string
+		want    map[string]string
+		wantErr error
+	}{
+		{rule: "s/^sh/shot/", names: []string{"sh0010", "sh0020", "etc"}, want: map[string]string{"sh0010": "shot0010", "sh0020": "shot0020"}},
+		{rule: "s/(\\d+)_v(\\d+)/${2}_v$1/", names: []string{"1_v2"}, want: map[string]string{"1_v2": "2_v1"}},
+		{rule: "renumber 15 10", names: []string{"sh0020", "sh0010", "sh0030", "etc"}, want: map[string]string{"sh0010": "sh0015", "sh0020": "sh0025", "sh0030": "sh0035"}},
+		{rule: "renumber 10 10", names: []string{"sh0010", "sh0015", "sh0020"}, want: map[string]string{"sh0015": "sh0020", "sh0020": "sh0030"}},
+		{rule: "renumber 10 10", names: []string{"ep1_sh9", "ep1_sh1"}, want: map[string]string{"ep1_sh1": "ep1_sh10", "ep1_sh9": "ep1_sh20"}},
+		{rule: "s/sh/", wantErr: errors.New("invalid rename rule: should be 's/{regexp}/{replacement}/' form: s/sh/")},
+		{rule: "s/(/x/", wantErr: errors.New("invalid rename rule: error parsing regexp: missing closing ): `(`")},
+		{rule: "renumber 10", wantErr: errors.New("invalid rename rule: should be 'renumber {start} {step}' form: renumber 10")},
+		{rule: "renumber 10 0", wantErr: errors.New("invalid rename rule: step should be a positive integer: 0")},
+		{rule: "reverse", wantErr: errors.New("invalid rename rule: unknown rule: reverse")},
+	}
+	for _, c := range cases {
+		got, err := RenameMap(c.rule, c.names)
+		if !equalError(c.wantErr, err) {
+			t.Fatalf("%q: want err %v, got %v", c.rule, c.wantErr, err)
+		}
+		if err != nil {
+			continue
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Fatalf("%q with %v: want %v, got %v", c.rule, c.names, c.want, got)
+		}
+	}
+}
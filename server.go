@@ -3,6 +3,7 @@ package forge
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"image"
 	"image/png"
@@ -25,17 +26,145 @@ func NewServer(svc Service, cfg *Config) *Server {
 	return s
 }
 
+// checkImpersonationWrite checks the context user can modify things.
+// An admin who is impersonating a user cannot, unless the impersonation is writable.
+func (s *Server) checkImpersonationWrite(ctx context.Context) error {
+	imp := ImpersonationFromContext(ctx)
+	if imp == nil || imp.Writable {
+		return nil
+	}
+	return Unauthorized("read-only impersonation of %v by %v", UserNameFromContext(ctx), imp.Admin)
+}
+
+// GetEntry gets an entry with the path.
+// When the entry isn't at the path but another entry had the path before it's moved,
+// it returns MovedError that has current path of the entry.
 func (s *Server) GetEntry(ctx context.Context, path string) (*Entry, error) {
 	if path == "" {
 		return nil, fmt.Errorf("entry path not specified")
 	}
 	ent, err := s.svc.GetEntry(ctx, path)
+	if err != nil {
+		var e *NotFoundError
+		if !errors.As(err, &e) {
+			return nil, err
+		}
+		// The entry could be moved.
+		newPath, merr := s.svc.GetMovedEntryPath(ctx, path)
+		if merr != nil {
+			return nil, err
+		}
+		return nil, Moved(path, newPath)
+	}
+	return ent, nil
+}
+
+// GetEntryByID gets an entry with it's id, that doesn't change even if the entry is renamed.
+func (s *Server) GetEntryByID(ctx context.Context, id int) (*Entry, error) {
+	ent, err := s.svc.GetEntryByID(ctx, id)
 	if err != nil {
 		return nil, err
 	}
 	return ent, nil
 }
 
+// FindReferences finds properties of other entries those point to the entry.
+// An entry that is referenced cannot be deleted.
+func (s *Server) FindReferences(ctx context.Context, path string) ([]*Reference, error) {
+	if path == "" {
+		return nil, fmt.Errorf("entry path not specified")
+	}
+	refs, err := s.svc.FindReferences(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	return refs, nil
+}
+
+// EntryRelations returns relations from and to the entry.
+func (s *Server) EntryRelations(ctx context.Context, path string) ([]*Relation, error) {
+	if path == "" {
+		return nil, fmt.Errorf("entry path not specified")
+	}
+	from, err := s.svc.FindRelations(ctx, RelationFinder{From: &path})
+	if err != nil {
+		return nil, err
+	}
+	to, err := s.svc.FindRelations(ctx, RelationFinder{To: &path})
+	if err != nil {
+		return nil, err
+	}
+	return append(from, to...), nil
+}
+
+// AddRelation adds a typed relation from an entry to another entry.
+// Dependency relations cannot make a cycle.
+func (s *Server) AddRelation(ctx context.Context, from, to, typ string) error {
+	err := s.checkImpersonationWrite(ctx)
+	if err != nil {
+		return err
+	}
+	if from == "" {
+		return fmt.Errorf("relation from entry not specified")
+	}
+	if to == "" {
+		return fmt.Errorf("relation to entry not specified")
+	}
+	if typ == "" {
+		return fmt.Errorf("relation type not specified")
+	}
+	err = s.svc.AddRelation(ctx, &Relation{From: from, To: to, Type: typ})
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *Server) DeleteRelation(ctx context.Context, from, to, typ string) error {
+	err := s.checkImpersonationWrite(ctx)
+	if err != nil {
+		return err
+	}
+	if from == "" {
+		return fmt.Errorf("relation from entry not specified")
+	}
+	if to == "" {
+		return fmt.Errorf("relation to entry not specified")
+	}
+	if typ == "" {
+		return fmt.Errorf("relation type not specified")
+	}
+	err = s.svc.DeleteRelation(ctx, &Relation{From: from, To: to, Type: typ})
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// RelationGraph follows relations from an entry, to find all the entries it depends on (upstream),
+// or all the entries depending on it (downstream).
+func (s *Server) RelationGraph(ctx context.Context, find RelationGraphFinder) (*RelationGraph, error) {
+	if find.Path == "" {
+		return nil, fmt.Errorf("entry path not specified")
+	}
+	if find.Direction != "upstream" && find.Direction != "downstream" {
+		return nil, fmt.Errorf("relation direction should be upstream or downstream, got: %v", find.Direction)
+	}
+	if find.Depth < 0 {
+		return nil, fmt.Errorf("relation depth should not be negative, got: %v", find.Depth)
+	}
+	for _, typ := range find.Types {
+		if !IsRelationType(typ) {
+			return nil, fmt.Errorf("invalid relation type: %v", typ)
+		}
+	}
+	g, err := s.svc.RelationGraph(ctx, find)
+	if err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
 func (s *Server) FindEntries(ctx context.Context, find EntryFinder) ([]*Entry, error) {
 	return s.svc.FindEntries(ctx, find)
 }
@@ -92,6 +221,10 @@ func (s *Server) CountAllSubEntries(ctx context.Context, path string) (int, erro
 }
 
 func (s *Server) AddEntry(ctx context.Context, path, typ string) error {
+	err := s.checkImpersonationWrite(ctx)
+	if err != nil {
+		return err
+	}
 	if path == "" {
 		return fmt.Errorf("entry path not specified")
 	}
@@ -99,32 +232,186 @@ func (s *Server) AddEntry(ctx context.Context, path, typ string) error {
 		Path: path,
 		Type: typ,
 	}
-	err := s.svc.AddEntry(ctx, e)
+	err = s.svc.AddEntry(ctx, e)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
+// AddNumberedEntry adds an entry under the parent, named with auto numbering of the type.
+// It returns path of the new entry.
+//
+// It is same as calling AddEntry with the path having '+' as it's name.
+func (s *Server) AddNumberedEntry(ctx context.Context, parent, typ string) (string, error) {
+	err := s.checkImpersonationWrite(ctx)
+	if err != nil {
+		return "", err
+	}
+	if parent == "" {
+		return "", fmt.Errorf("parent entry path not specified")
+	}
+	e := &Entry{
+		Path: strings.TrimSuffix(parent, "/") + "/+",
+		Type: typ,
+	}
+	err = s.svc.AddEntry(ctx, e)
+	if err != nil {
+		return "", err
+	}
+	return e.Path, nil
+}
+
+// AddEntries adds entries under the parent, named with the pattern, in a transaction.
+// It returns paths of the new entries. See ExpandEntryPattern for the pattern.
+func (s *Server) AddEntries(ctx context.Context, parent, pattern, typ string) ([]string, error) {
+	err := s.checkImpersonationWrite(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ents, err := patternEntries(parent, pattern, typ)
+	if err != nil {
+		return nil, err
+	}
+	err = s.svc.AddEntries(ctx, ents)
+	if err != nil {
+		return nil, err
+	}
+	paths := make([]string, 0, len(ents))
+	for _, e := range ents {
+		paths = append(paths, e.Path)
+	}
+	return paths, nil
+}
+
+// DryRunAddEntries checks AddEntries will succeed with the arguments, without actually add the entries.
+// It returns paths of the entries to be added, and paths of the entries already exist.
+func (s *Server) DryRunAddEntries(ctx context.Context, parent, pattern, typ string) ([]string, []string, error) {
+	ents, err := patternEntries(parent, pattern, typ)
+	if err != nil {
+		return nil, nil, err
+	}
+	exists, err := s.svc.DryRunAddEntries(ctx, ents)
+	if err != nil {
+		return nil, nil, err
+	}
+	isExist := make(map[string]bool)
+	for _, pth := range exists {
+		isExist[pth] = true
+	}
+	adds := make([]string, 0, len(ents))
+	for _, e := range ents {
+		if !isExist[e.Path] {
+			adds = append(adds, e.Path)
+		}
+	}
+	return adds, exists, nil
+}
+
+// patternEntries returns entries under the parent, named with the pattern.
+func patternEntries(parent, pattern, typ string) ([]*Entry, error) {
+	if parent == "" {
+		return nil, fmt.Errorf("parent entry path not specified")
+	}
+	if pattern == "" {
+		return nil, fmt.Errorf("entry pattern not specified")
+	}
+	names, err := ExpandEntryPattern(pattern)
+	if err != nil {
+		return nil, err
+	}
+	parent = strings.TrimSuffix(parent, "/")
+	ents := make([]*Entry, 0, len(names))
+	for _, name := range names {
+		ents = append(ents, &Entry{
+			Path: parent + "/" + name,
+			Type: typ,
+		})
+	}
+	return ents, nil
+}
+
 func (s *Server) RenameEntry(ctx context.Context, path, newName string) error {
+	err := s.checkImpersonationWrite(ctx)
+	if err != nil {
+		return err
+	}
 	if path == "" {
 		return fmt.Errorf("entry path not specified")
 	}
 	if newName == "" {
 		return fmt.Errorf("new entry name not specified")
 	}
-	err := s.svc.RenameEntry(ctx, path, newName)
+	err = s.svc.RenameEntry(ctx, path, newName)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// RenameEntries renames sub entries of the parent at once.
+// Renames maps old names to new names, and entries can swap their names.
+func (s *Server) RenameEntries(ctx context.Context, parent string, renames map[string]string) error {
+	err := s.checkImpersonationWrite(ctx)
+	if err != nil {
+		return err
+	}
+	if parent == "" {
+		return fmt.Errorf("parent entry path not specified")
+	}
+	if len(renames) == 0 {
+		return fmt.Errorf("renames not specified")
+	}
+	err = s.svc.RenameEntries(ctx, parent, renames)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
+// RenameEntriesWithRule renames sub entries of the parent with a rule at once.
+// Only the named entries are renamed, or all of the sub entries when names are empty.
+// It returns the map of old names to new names. See RenameMap for the rule.
+func (s *Server) RenameEntriesWithRule(ctx context.Context, parent string, names []string, rule string) (map[string]string, error) {
+	err := s.checkImpersonationWrite(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if parent == "" {
+		return nil, fmt.Errorf("parent entry path not specified")
+	}
+	if len(names) == 0 {
+		subEnts, err := s.SubEntries(ctx, parent)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range subEnts {
+			names = append(names, e.Name())
+		}
+	}
+	renames, err := RenameMap(rule, names)
+	if err != nil {
+		return nil, err
+	}
+	if len(renames) == 0 {
+		return renames, nil
+	}
+	err = s.svc.RenameEntries(ctx, parent, renames)
+	if err != nil {
+		return nil, err
+	}
+	return renames, nil
+}
+
 func (s *Server) ArchiveEntry(ctx context.Context, path string) error {
+	err := s.checkImpersonationWrite(ctx)
+	if err != nil {
+		return err
+	}
 	if path == "" {
 		return fmt.Errorf("entry path not specified")
 	}
-	err := s.svc.ArchiveEntry(ctx, path)
+	err = s.svc.ArchiveEntry(ctx, path)
 	if err != nil {
 		return err
 	}
@@ -132,10 +419,14 @@ func (s *Server) ArchiveEntry(ctx context.Context, path string) error {
 }
 
 func (s *Server) UnarchiveEntry(ctx context.Context, path string) error {
+	err := s.checkImpersonationWrite(ctx)
+	if err != nil {
+		return err
+	}
 	if path == "" {
 		return fmt.Errorf("entry path not specified")
 	}
-	err := s.svc.UnarchiveEntry(ctx, path)
+	err = s.svc.UnarchiveEntry(ctx, path)
 	if err != nil {
 		return err
 	}
@@ -143,10 +434,14 @@ func (s *Server) UnarchiveEntry(ctx context.Context, path string) error {
 }
 
 func (s *Server) DeleteEntry(ctx context.Context, path string) error {
+	err := s.checkImpersonationWrite(ctx)
+	if err != nil {
+		return err
+	}
 	if path == "" {
 		return fmt.Errorf("entry path not specified")
 	}
-	err := s.svc.DeleteEntry(ctx, path)
+	err = s.svc.DeleteEntry(ctx, path)
 	if err != nil {
 		return err
 	}
@@ -154,10 +449,31 @@ func (s *Server) DeleteEntry(ctx context.Context, path string) error {
 }
 
 func (s *Server) DeleteEntryRecursive(ctx context.Context, path string) error {
+	err := s.checkImpersonationWrite(ctx)
+	if err != nil {
+		return err
+	}
 	if path == "" {
 		return fmt.Errorf("entry path not specified")
 	}
-	err := s.svc.DeleteEntryRecursive(ctx, path)
+	err = s.svc.DeleteEntryRecursive(ctx, path)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// ForceDeleteEntryRecursive deletes the entry and it's sub entries, even when other entries refer to them.
+// Only admins can do it, see DeleteEntryRecursive for others.
+func (s *Server) ForceDeleteEntryRecursive(ctx context.Context, path string) error {
+	err := s.checkImpersonationWrite(ctx)
+	if err != nil {
+		return err
+	}
+	if path == "" {
+		return fmt.Errorf("entry path not specified")
+	}
+	err = s.svc.ForceDeleteEntryRecursive(ctx, path)
 	if err != nil {
 		return err
 	}
@@ -189,10 +505,14 @@ func (s *Server) FindOverrideEntryTypes(ctx context.Context) ([]string, error) {
 }
 
 func (s *Server) AddEntryType(ctx context.Context, name string) error {
+	err := s.checkImpersonationWrite(ctx)
+	if err != nil {
+		return err
+	}
 	if name == "" {
 		return fmt.Errorf("entry type name not specified")
 	}
-	err := s.svc.AddEntryType(ctx, name)
+	err = s.svc.AddEntryType(ctx, name)
 	if err != nil {
 		return err
 	}
@@ -200,13 +520,17 @@ func (s *Server) AddEntryType(ctx context.Context, name string) error {
 }
 
 func (s *Server) RenameEntryType(ctx context.Context, name, newName string) error {
+	err := s.checkImpersonationWrite(ctx)
+	if err != nil {
+		return err
+	}
 	if name == "" {
 		return fmt.Errorf("current entry type name not specified")
 	}
 	if newName == "" {
 		return fmt.Errorf("new entry type name not specified")
 	}
-	err := s.svc.RenameEntryType(ctx, name, newName)
+	err = s.svc.RenameEntryType(ctx, name, newName)
 	if err != nil {
 		return err
 	}
@@ -214,10 +538,14 @@ func (s *Server) RenameEntryType(ctx context.Context, name, newName string) erro
 }
 
 func (s *Server) DeleteEntryType(ctx context.Context, name string) error {
+	err := s.checkImpersonationWrite(ctx)
+	if err != nil {
+		return err
+	}
 	if name == "" {
 		return fmt.Errorf("entry type name not specified")
 	}
-	err := s.svc.DeleteEntryType(ctx, name)
+	err = s.svc.DeleteEntryType(ctx, name)
 	if err != nil {
 		return err
 	}
@@ -235,52 +563,79 @@ func (s *Server) Defaults(ctx context.Context, entType string) ([]*Default, erro
 	return defaults, nil
 }
 
-func (s *Server) AddDefault(ctx context.Context, entType, ctg, name, typ, value string) error {
-	if entType == "" {
+// AddDefault adds a default to the entry type.
+// Constraint, PropertyMeta and Inheritable of the default are only valid for property defaults.
+func (s *Server) AddDefault(ctx context.Context, d *Default) error {
+	err := s.checkImpersonationWrite(ctx)
+	if err != nil {
+		return err
+	}
+	if d == nil {
+		return fmt.Errorf("nil default")
+	}
+	if d.EntryType == "" {
 		return fmt.Errorf("default entry type not specified")
 	}
-	if ctg == "" {
+	if d.Category == "" {
 		return fmt.Errorf("default category not specified")
 	}
-	if name == "" {
+	if d.Name == "" {
 		return fmt.Errorf("default name not specified")
 	}
-	if typ == "" {
+	if d.Type == "" {
 		return fmt.Errorf("default type not specified")
 	}
-	d := &Default{
-		EntryType: entType,
-		Category:  ctg,
-		Name:      name,
-		Type:      typ,
-		Value:     value,
+	if d.Category != "property" {
+		if d.Constraint != "" {
+			return fmt.Errorf("constraint is only available for property defaults")
+		}
+		if !isEmptyPropertyMeta(d.PropertyMeta) {
+			return fmt.Errorf("meta is only available for property defaults")
+		}
+		if d.Inheritable {
+			return fmt.Errorf("inheritable is only available for property defaults")
+		}
 	}
-	err := s.svc.AddDefault(ctx, d)
+	err = s.svc.AddDefault(ctx, d)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func (s *Server) UpdateDefault(ctx context.Context, entType, ctg, name string, newName, typ, value *string) error {
-	if entType == "" {
+// isEmptyPropertyMeta checks the meta doesn't have any information.
+func isEmptyPropertyMeta(m PropertyMeta) bool {
+	return m.Label == "" && m.Description == "" && m.Section == "" && m.Order == 0 && !m.ReadOnly &&
+		len(m.ReadGroups) == 0 && len(m.WriteGroups) == 0
+}
+
+// UpdateDefault updates fields of a default those are not nil.
+// When Inheritable is true, an empty property of the default takes the value
+// of the nearest ancestor having the same property.
+func (s *Server) UpdateDefault(ctx context.Context, upd DefaultUpdater) error {
+	err := s.checkImpersonationWrite(ctx)
+	if err != nil {
+		return err
+	}
+	if upd.EntryType == "" {
 		return fmt.Errorf("default entry type not specified")
 	}
-	if ctg == "" {
+	if upd.Category == "" {
 		return fmt.Errorf("default category not specified")
 	}
-	if name == "" {
+	if upd.Name == "" {
 		return fmt.Errorf("default name not specified")
 	}
-	upd := DefaultUpdater{
-		EntryType: entType,
-		Category:  ctg,
-		Name:      name,
-		NewName:   newName,
-		Type:      typ,
-		Value:     value,
+	if upd.Constraint != nil && upd.Category != "property" {
+		return fmt.Errorf("constraint is only available for property defaults")
 	}
-	err := s.svc.UpdateDefault(ctx, upd)
+	if upd.Meta != nil && upd.Category != "property" {
+		return fmt.Errorf("meta is only available for property defaults")
+	}
+	if upd.Inheritable != nil && upd.Category != "property" {
+		return fmt.Errorf("inheritable is only available for property defaults")
+	}
+	err = s.svc.UpdateDefault(ctx, upd)
 	if err != nil {
 		return err
 	}
@@ -288,6 +643,10 @@ func (s *Server) UpdateDefault(ctx context.Context, entType, ctg, name string, n
 }
 
 func (s *Server) DeleteDefault(ctx context.Context, entType, ctg, name string) error {
+	err := s.checkImpersonationWrite(ctx)
+	if err != nil {
+		return err
+	}
 	if entType == "" {
 		return fmt.Errorf("default entry type not specified")
 	}
@@ -297,7 +656,7 @@ func (s *Server) DeleteDefault(ctx context.Context, entType, ctg, name string) e
 	if name == "" {
 		return fmt.Errorf("default name not specified")
 	}
-	err := s.svc.DeleteDefault(ctx, entType, ctg, name)
+	err = s.svc.DeleteDefault(ctx, entType, ctg, name)
 	if err != nil {
 		return err
 	}
@@ -327,6 +686,10 @@ func (s *Server) GetGlobal(ctx context.Context, entType, name string) (*Global,
 }
 
 func (s *Server) AddGlobal(ctx context.Context, entType, name, typ, value string) error {
+	err := s.checkImpersonationWrite(ctx)
+	if err != nil {
+		return err
+	}
 	if entType == "" {
 		return fmt.Errorf("global entry type not specified")
 	}
@@ -342,7 +705,7 @@ func (s *Server) AddGlobal(ctx context.Context, entType, name, typ, value string
 		Type:      typ,
 		Value:     value,
 	}
-	err := s.svc.AddGlobal(ctx, sg)
+	err = s.svc.AddGlobal(ctx, sg)
 	if err != nil {
 		return err
 	}
@@ -350,6 +713,10 @@ func (s *Server) AddGlobal(ctx context.Context, entType, name, typ, value string
 }
 
 func (s *Server) UpdateGlobal(ctx context.Context, entType, name, typ, value string) error {
+	err := s.checkImpersonationWrite(ctx)
+	if err != nil {
+		return err
+	}
 	if entType == "" {
 		return fmt.Errorf("global entry type not specified")
 	}
@@ -365,7 +732,7 @@ func (s *Server) UpdateGlobal(ctx context.Context, entType, name, typ, value str
 		Type:      &typ,
 		Value:     &value,
 	}
-	err := s.svc.UpdateGlobal(ctx, upd)
+	err = s.svc.UpdateGlobal(ctx, upd)
 	if err != nil {
 		return err
 	}
@@ -373,13 +740,17 @@ func (s *Server) UpdateGlobal(ctx context.Context, entType, name, typ, value str
 }
 
 func (s *Server) DeleteGlobal(ctx context.Context, entType, name string) error {
+	err := s.checkImpersonationWrite(ctx)
+	if err != nil {
+		return err
+	}
 	if entType == "" {
 		return fmt.Errorf("global entry type not specified")
 	}
 	if name == "" {
 		return fmt.Errorf("global name not specified")
 	}
-	err := s.svc.DeleteGlobal(ctx, entType, name)
+	err = s.svc.DeleteGlobal(ctx, entType, name)
 	if err != nil {
 		return err
 	}
@@ -415,13 +786,17 @@ func (s *Server) GetProperty(ctx context.Context, path string, name string) (*Pr
 }
 
 func (s *Server) UpdateProperty(ctx context.Context, path string, name, value string) error {
+	err := s.checkImpersonationWrite(ctx)
+	if err != nil {
+		return err
+	}
 	if path == "" {
 		return fmt.Errorf("property path not specified")
 	}
 	if name == "" {
 		return fmt.Errorf("property name not specified")
 	}
-	err := s.svc.UpdateProperty(ctx, PropertyUpdater{
+	err = s.svc.UpdateProperty(ctx, PropertyUpdater{
 		EntryPath: path,
 		Name:      name,
 		Value:     &value,
@@ -433,11 +808,20 @@ func (s *Server) UpdateProperty(ctx context.Context, path string, name, value st
 }
 
 func (s *Server) UpdateProperties(ctx context.Context, upds []PropertyUpdater) error {
+	err := s.checkImpersonationWrite(ctx)
+	if err != nil {
+		return err
+	}
 	// Note it directly uses UpdateProperty unlike others methods here.
 	// I will change to use service instead of Server in the future.
 	return s.svc.UpdateProperties(ctx, upds)
 }
 
+// DryRunUpdateProperties checks UpdateProperties will succeed with the updaters, without actually update them.
+func (s *Server) DryRunUpdateProperties(ctx context.Context, upds []PropertyUpdater) error {
+	return s.svc.DryRunUpdateProperties(ctx, upds)
+}
+
 func (s *Server) EntryEnvirons(ctx context.Context, path string) ([]*Property, error) {
 	if path == "" {
 		return nil, fmt.Errorf("environ path not specified")
@@ -481,6 +865,10 @@ func (s *Server) GetEnviron(ctx context.Context, path, name string) (*Property,
 }
 
 func (s *Server) AddEnviron(ctx context.Context, path string, name, typ, value string) error {
+	err := s.checkImpersonationWrite(ctx)
+	if err != nil {
+		return err
+	}
 	if path == "" {
 		return fmt.Errorf("environ path not specified")
 	}
@@ -496,7 +884,7 @@ func (s *Server) AddEnviron(ctx context.Context, path string, name, typ, value s
 		Type:      typ,
 		Value:     value,
 	}
-	err := s.svc.AddEnviron(ctx, env)
+	err = s.svc.AddEnviron(ctx, env)
 	if err != nil {
 		return err
 	}
@@ -504,13 +892,17 @@ func (s *Server) AddEnviron(ctx context.Context, path string, name, typ, value s
 }
 
 func (s *Server) UpdateEnviron(ctx context.Context, path string, name, value string) error {
+	err := s.checkImpersonationWrite(ctx)
+	if err != nil {
+		return err
+	}
 	if path == "" {
 		return fmt.Errorf("environ path not specified")
 	}
 	if name == "" {
 		return fmt.Errorf("environ name not specified")
 	}
-	err := s.svc.UpdateEnviron(ctx, PropertyUpdater{
+	err = s.svc.UpdateEnviron(ctx, PropertyUpdater{
 		EntryPath: path,
 		Name:      name,
 		Value:     &value,
@@ -522,13 +914,17 @@ func (s *Server) UpdateEnviron(ctx context.Context, path string, name, value str
 }
 
 func (s *Server) DeleteEnviron(ctx context.Context, path string, name string) error {
+	err := s.checkImpersonationWrite(ctx)
+	if err != nil {
+		return err
+	}
 	if path == "" {
 		return fmt.Errorf("environ path not specified")
 	}
 	if name == "" {
 		return fmt.Errorf("environ name not specified")
 	}
-	err := s.svc.DeleteEnviron(ctx, path, name)
+	err = s.svc.DeleteEnviron(ctx, path, name)
 	if err != nil {
 		return err
 	}
@@ -578,6 +974,10 @@ func (s *Server) GetAccess(ctx context.Context, path string, accessor string) (*
 }
 
 func (s *Server) AddAccess(ctx context.Context, path string, accessor, mode string) error {
+	err := s.checkImpersonationWrite(ctx)
+	if err != nil {
+		return err
+	}
 	if path == "" {
 		return fmt.Errorf("access control path not specified")
 	}
@@ -587,10 +987,7 @@ func (s *Server) AddAccess(ctx context.Context, path string, accessor, mode stri
 	if mode == "" {
 		return fmt.Errorf("access mode not specified")
 	}
-	switch mode {
-	case "r":
-	case "rw":
-	default:
+	if !IsAccessMode(mode) {
 		return fmt.Errorf("unknown access type")
 	}
 	ac := &Access{
@@ -598,7 +995,7 @@ func (s *Server) AddAccess(ctx context.Context, path string, accessor, mode stri
 		Name:      accessor,
 		Value:     mode,
 	}
-	err := s.svc.AddAccess(ctx, ac)
+	err = s.svc.AddAccess(ctx, ac)
 	if err != nil {
 		return err
 	}
@@ -606,6 +1003,10 @@ func (s *Server) AddAccess(ctx context.Context, path string, accessor, mode stri
 }
 
 func (s *Server) UpdateAccess(ctx context.Context, path, accessor, mode string) error {
+	err := s.checkImpersonationWrite(ctx)
+	if err != nil {
+		return err
+	}
 	if path == "" {
 		return fmt.Errorf("access control path not specified")
 	}
@@ -615,10 +1016,7 @@ func (s *Server) UpdateAccess(ctx context.Context, path, accessor, mode string)
 	if mode == "" {
 		return fmt.Errorf("access mode not specified")
 	}
-	switch mode {
-	case "r":
-	case "rw":
-	default:
+	if !IsAccessMode(mode) {
 		return fmt.Errorf("unknown access type")
 	}
 	ac := AccessUpdater{
@@ -626,7 +1024,7 @@ func (s *Server) UpdateAccess(ctx context.Context, path, accessor, mode string)
 		Name:      accessor,
 		Value:     &mode,
 	}
-	err := s.svc.UpdateAccess(ctx, ac)
+	err = s.svc.UpdateAccess(ctx, ac)
 	if err != nil {
 		return err
 	}
@@ -634,19 +1032,40 @@ func (s *Server) UpdateAccess(ctx context.Context, path, accessor, mode string)
 }
 
 func (s *Server) DeleteAccess(ctx context.Context, path string, accessor string) error {
+	err := s.checkImpersonationWrite(ctx)
+	if err != nil {
+		return err
+	}
 	if path == "" {
 		return fmt.Errorf("access control path not specified")
 	}
 	if accessor == "" {
 		return fmt.Errorf("accessor not specified")
 	}
-	err := s.svc.DeleteAccess(ctx, path, accessor)
+	err = s.svc.DeleteAccess(ctx, path, accessor)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
+// ExplainAccess explains how the access mode of a user to an entry is decided,
+// with the parents and access controls checked in order.
+// Only admins can explain access of other users.
+func (s *Server) ExplainAccess(ctx context.Context, user, path string) (*AccessExplanation, error) {
+	if user == "" {
+		return nil, fmt.Errorf("user not specified")
+	}
+	if path == "" {
+		return nil, fmt.Errorf("entry path not specified")
+	}
+	exp, err := s.svc.ExplainAccess(ctx, user, path)
+	if err != nil {
+		return nil, err
+	}
+	return exp, nil
+}
+
 func (s *Server) IsAdmin(ctx context.Context, user string) (bool, error) {
 	if user == "" {
 		return false, fmt.Errorf("user not specified")
@@ -705,9 +1124,21 @@ func (s *Server) ActiveUsers(ctx context.Context) ([]*User, error) {
 	return users, nil
 }
 
+// DisabledUsers returns disabled users, except the pending users.
 func (s *Server) DisabledUsers(ctx context.Context) ([]*User, error) {
 	disabled := true
-	users, err := s.svc.FindUsers(ctx, UserFinder{Disabled: &disabled})
+	pending := false
+	users, err := s.svc.FindUsers(ctx, UserFinder{Disabled: &disabled, Pending: &pending})
+	if err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// PendingUsers returns users those are waiting for approval.
+func (s *Server) PendingUsers(ctx context.Context) ([]*User, error) {
+	pending := true
+	users, err := s.svc.FindUsers(ctx, UserFinder{Pending: &pending})
 	if err != nil {
 		return nil, err
 	}
@@ -726,13 +1157,91 @@ func (s *Server) GetUser(ctx context.Context, user string) (*User, error) {
 }
 
 func (s *Server) AddUser(ctx context.Context, u *User) error {
+	err := s.checkImpersonationWrite(ctx)
+	if err != nil {
+		return err
+	}
+	if u == nil {
+		return fmt.Errorf("nil user")
+	}
+	if u.Name == "" {
+		return fmt.Errorf("user not specified")
+	}
+	err = s.svc.AddUser(ctx, u)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *Server) signupConfig() SignupConfig {
+	if s.cfg.Forge == nil {
+		return SignupConfig{}
+	}
+	return s.cfg.Forge.Signup
+}
+
+// CheckUserDomain checks the user's email domain is allowed to login.
+func (s *Server) CheckUserDomain(user string) error {
+	if !s.signupConfig().AllowUser(user) {
+		return Unauthorized("email domain is not allowed to login: %v", user)
+	}
+	return nil
+}
+
+// SignUpUser adds a user who logged in at the first time.
+// The user will be pending when the signup requires approval,
+// otherwise the user is added to the default groups right away.
+func (s *Server) SignUpUser(ctx context.Context, u *User) error {
+	err := s.checkImpersonationWrite(ctx)
+	if err != nil {
+		return err
+	}
 	if u == nil {
 		return fmt.Errorf("nil user")
 	}
 	if u.Name == "" {
 		return fmt.Errorf("user not specified")
 	}
-	err := s.svc.AddUser(ctx, u)
+	err = s.CheckUserDomain(u.Name)
+	if err != nil {
+		return err
+	}
+	cfg := s.signupConfig()
+	u.Pending = cfg.RequireApproval
+	err = s.svc.SignUpUser(ctx, u, cfg.UserGroups(u.Name))
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// ApproveUser enables a pending user and adds the user to the default groups.
+func (s *Server) ApproveUser(ctx context.Context, user string) error {
+	err := s.checkImpersonationWrite(ctx)
+	if err != nil {
+		return err
+	}
+	if user == "" {
+		return fmt.Errorf("user not specified")
+	}
+	err = s.svc.ApproveUser(ctx, user, s.signupConfig().UserGroups(user))
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// RejectUser takes a user out of the pending users, the user remains disabled.
+func (s *Server) RejectUser(ctx context.Context, user string) error {
+	err := s.checkImpersonationWrite(ctx)
+	if err != nil {
+		return err
+	}
+	if user == "" {
+		return fmt.Errorf("user not specified")
+	}
+	err = s.svc.RejectUser(ctx, user)
 	if err != nil {
 		return err
 	}
@@ -740,10 +1249,14 @@ func (s *Server) AddUser(ctx context.Context, u *User) error {
 }
 
 func (s *Server) UpdateUserCalled(ctx context.Context, user, called string) error {
+	err := s.checkImpersonationWrite(ctx)
+	if err != nil {
+		return err
+	}
 	if user == "" {
 		return fmt.Errorf("user not specified")
 	}
-	err := s.svc.UpdateUser(ctx, UserUpdater{
+	err = s.svc.UpdateUser(ctx, UserUpdater{
 		Name:   user,
 		Called: &called,
 	})
@@ -754,10 +1267,14 @@ func (s *Server) UpdateUserCalled(ctx context.Context, user, called string) erro
 }
 
 func (s *Server) UpdateUserDisabled(ctx context.Context, user string, disabled bool) error {
+	err := s.checkImpersonationWrite(ctx)
+	if err != nil {
+		return err
+	}
 	if user == "" {
 		return fmt.Errorf("user not specified")
 	}
-	err := s.svc.UpdateUser(ctx, UserUpdater{
+	err = s.svc.UpdateUser(ctx, UserUpdater{
 		Name:     user,
 		Disabled: &disabled,
 	})
@@ -767,6 +1284,44 @@ func (s *Server) UpdateUserDisabled(ctx context.Context, user string, disabled b
 	return nil
 }
 
+// RenameUser renames a user, keeping everything of the user attached.
+func (s *Server) RenameUser(ctx context.Context, user, newName string) error {
+	err := s.checkImpersonationWrite(ctx)
+	if err != nil {
+		return err
+	}
+	if user == "" {
+		return fmt.Errorf("user not specified")
+	}
+	if newName == "" {
+		return fmt.Errorf("new name of user not specified")
+	}
+	err = s.svc.RenameUser(ctx, user, newName)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// MergeUsers merges a user into another user, and removes the user.
+func (s *Server) MergeUsers(ctx context.Context, from, to string) error {
+	err := s.checkImpersonationWrite(ctx)
+	if err != nil {
+		return err
+	}
+	if from == "" {
+		return fmt.Errorf("user to merge not specified")
+	}
+	if to == "" {
+		return fmt.Errorf("user to merge into not specified")
+	}
+	err = s.svc.MergeUsers(ctx, from, to)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
 func (s *Server) GetUserSetting(ctx context.Context, user string) (*UserSetting, error) {
 	if user == "" {
 		return nil, fmt.Errorf("user not specified")
@@ -779,12 +1334,16 @@ func (s *Server) GetUserSetting(ctx context.Context, user string) (*UserSetting,
 }
 
 func (s *Server) UpdateUserSetting(ctx context.Context, user, key string, value any) error {
+	err := s.checkImpersonationWrite(ctx)
+	if err != nil {
+		return err
+	}
 	upd := UserSettingUpdater{
 		User:  user,
 		Key:   key,
 		Value: value,
 	}
-	err := s.svc.UpdateUserSetting(ctx, upd)
+	err = s.svc.UpdateUserSetting(ctx, upd)
 	if err != nil {
 		return err
 	}
@@ -792,7 +1351,11 @@ func (s *Server) UpdateUserSetting(ctx context.Context, user, key string, value
 }
 
 func (s *Server) AddUserDataSection(ctx context.Context, user, section string) error {
-	err := s.svc.AddUserDataSection(ctx, user, section)
+	err := s.checkImpersonationWrite(ctx)
+	if err != nil {
+		return err
+	}
+	err = s.svc.AddUserDataSection(ctx, user, section)
 	if err != nil {
 		return err
 	}
@@ -808,7 +1371,11 @@ func (s *Server) GetUserDataSection(ctx context.Context, user, section string) (
 }
 
 func (s *Server) DeleteUserDataSection(ctx context.Context, user, section string) error {
-	err := s.svc.DeleteUserDataSection(ctx, user, section)
+	err := s.checkImpersonationWrite(ctx)
+	if err != nil {
+		return err
+	}
+	err = s.svc.DeleteUserDataSection(ctx, user, section)
 	if err != nil {
 		return err
 	}
@@ -832,7 +1399,11 @@ func (s *Server) GetUserData(ctx context.Context, user, section, key string) (st
 }
 
 func (s *Server) SetUserData(ctx context.Context, user, section, key, value string) error {
-	err := s.svc.SetUserData(ctx, user, section, key, value)
+	err := s.checkImpersonationWrite(ctx)
+	if err != nil {
+		return err
+	}
+	err = s.svc.SetUserData(ctx, user, section, key, value)
 	if err != nil {
 		return err
 	}
@@ -840,7 +1411,11 @@ func (s *Server) SetUserData(ctx context.Context, user, section, key, value stri
 }
 
 func (s *Server) DeleteUserData(ctx context.Context, user, section, key string) error {
-	err := s.svc.DeleteUserData(ctx, user, section, key)
+	err := s.checkImpersonationWrite(ctx)
+	if err != nil {
+		return err
+	}
+	err = s.svc.DeleteUserData(ctx, user, section, key)
 	if err != nil {
 		return err
 	}
@@ -852,6 +1427,10 @@ func (s *Server) UserRead(ctx context.Context, path string) error {
 }
 
 func (s *Server) UserWrite(ctx context.Context, path string) error {
+	err := s.checkImpersonationWrite(ctx)
+	if err != nil {
+		return err
+	}
 	return s.svc.UserWrite(ctx, path)
 }
 
@@ -879,6 +1458,10 @@ func (s *Server) GetGroup(ctx context.Context, group string) (*Group, error) {
 }
 
 func (s *Server) AddGroup(ctx context.Context, g *Group) error {
+	err := s.checkImpersonationWrite(ctx)
+	if err != nil {
+		return err
+	}
 	if g == nil {
 		return fmt.Errorf("nil group")
 	}
@@ -889,7 +1472,7 @@ func (s *Server) AddGroup(ctx context.Context, g *Group) error {
 		Name:   g.Name,
 		Called: g.Called,
 	}
-	err := s.svc.AddGroup(ctx, sg)
+	err = s.svc.AddGroup(ctx, sg)
 	if err != nil {
 		return err
 	}
@@ -897,6 +1480,10 @@ func (s *Server) AddGroup(ctx context.Context, g *Group) error {
 }
 
 func (s *Server) RenameGroup(ctx context.Context, name string, newName string) error {
+	err := s.checkImpersonationWrite(ctx)
+	if err != nil {
+		return err
+	}
 	if name == "" {
 		return fmt.Errorf("name of group not specified")
 	}
@@ -904,13 +1491,30 @@ func (s *Server) RenameGroup(ctx context.Context, name string, newName string) e
 		return fmt.Errorf("new name of group not specified")
 	}
 	g := GroupUpdater{Name: name, NewName: &newName}
-	err := s.svc.UpdateGroup(ctx, g)
+	err = s.svc.UpdateGroup(ctx, g)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
+// DeleteGroup deletes a group. It refuses to delete a group that is referenced,
+// unless cascade is true. Then it removes the references and reports them.
+func (s *Server) DeleteGroup(ctx context.Context, group string, cascade bool) (*GroupReferences, error) {
+	err := s.checkImpersonationWrite(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if group == "" {
+		return nil, fmt.Errorf("group not specified")
+	}
+	refs, err := s.svc.DeleteGroup(ctx, group, cascade)
+	if err != nil {
+		return nil, err
+	}
+	return refs, nil
+}
+
 func (s *Server) GroupMembers(ctx context.Context, group string) ([]*Member, error) {
 	if group == "" {
 		return nil, fmt.Errorf("group not specified")
@@ -922,7 +1526,25 @@ func (s *Server) GroupMembers(ctx context.Context, group string) ([]*Member, err
 	return members, nil
 }
 
+// ExpandedGroupMembers returns users those are members of the group,
+// including the ones who are members through sub groups.
+func (s *Server) ExpandedGroupMembers(ctx context.Context, group string) ([]*Member, error) {
+	if group == "" {
+		return nil, fmt.Errorf("group not specified")
+	}
+	members, err := s.svc.FindGroupMembers(ctx, MemberFinder{Group: group, Expand: true})
+	if err != nil {
+		return nil, err
+	}
+	return members, nil
+}
+
+// AddGroupMember adds a user or a group as a member of the group.
 func (s *Server) AddGroupMember(ctx context.Context, group, member string) error {
+	err := s.checkImpersonationWrite(ctx)
+	if err != nil {
+		return err
+	}
 	if group == "" {
 		return fmt.Errorf("group not specified")
 	}
@@ -930,7 +1552,7 @@ func (s *Server) AddGroupMember(ctx context.Context, group, member string) error
 		return fmt.Errorf("member not specified")
 	}
 	m := &Member{Group: group, Member: member}
-	err := s.svc.AddGroupMember(ctx, m)
+	err = s.svc.AddGroupMember(ctx, m)
 	if err != nil {
 		return err
 	}
@@ -938,19 +1560,101 @@ func (s *Server) AddGroupMember(ctx context.Context, group, member string) error
 }
 
 func (s *Server) DeleteGroupMember(ctx context.Context, group, member string) error {
+	err := s.checkImpersonationWrite(ctx)
+	if err != nil {
+		return err
+	}
 	if group == "" {
 		return fmt.Errorf("group not specified")
 	}
 	if member == "" {
 		return fmt.Errorf("member not specified")
 	}
-	err := s.svc.DeleteGroupMember(ctx, group, member)
+	err = s.svc.DeleteGroupMember(ctx, group, member)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// SyncGroupMembers syncs the user's memberships of the managed groups with the claim values
+// of the identity provider, according to the group sync config.
+// It does nothing when the group sync isn't configured.
+func (s *Server) SyncGroupMembers(ctx context.Context, user string, values []string) error {
+	err := s.checkImpersonationWrite(ctx)
+	if err != nil {
+		return err
+	}
+	if user == "" {
+		return fmt.Errorf("user not specified")
+	}
+	if s.cfg.Forge == nil || len(s.cfg.Forge.GroupSync.Groups) == 0 {
+		return nil
+	}
+	sync := s.cfg.Forge.GroupSync
+	err = s.svc.SyncGroupMembers(ctx, user, sync.Managed(), sync.Map(values))
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
+// GroupMemberLogs returns the logs of membership changes of the group.
+func (s *Server) GroupMemberLogs(ctx context.Context, group string) ([]*Log, error) {
+	if group == "" {
+		return nil, fmt.Errorf("group not specified")
+	}
+	user := UserNameFromContext(ctx)
+	admin, err := s.svc.IsAdmin(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	if !admin {
+		return nil, Unauthorized("only admins can see logs of group members")
+	}
+	ctg := "group_member"
+	logs, err := s.svc.FindLogs(ctx, LogFinder{
+		Category: &ctg,
+		Name:     &group,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return logs, nil
+}
+
+// UserMergeLogs returns the logs of the users merged into the user.
+func (s *Server) UserMergeLogs(ctx context.Context, user string) ([]*Log, error) {
+	if user == "" {
+		return nil, fmt.Errorf("user not specified")
+	}
+	ctxUser := UserNameFromContext(ctx)
+	admin, err := s.svc.IsAdmin(ctx, ctxUser)
+	if err != nil {
+		return nil, err
+	}
+	if !admin {
+		return nil, Unauthorized("only admins can see logs of user merges")
+	}
+	ctg := "user"
+	logs, err := s.svc.FindLogs(ctx, LogFinder{
+		Category: &ctg,
+		Name:     &user,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return logs, nil
+}
+
+// GroupSyncClaims returns the names of the id token claims those have the user's groups.
+func (s *Server) GroupSyncClaims() []string {
+	if s.cfg.Forge == nil {
+		return nil
+	}
+	return s.cfg.Forge.GroupSync.Claims
+}
+
 // GetThumbnail gets a thumbnail image of a entry.
 func (s *Server) GetThumbnail(ctx context.Context, path string) (*Thumbnail, error) {
 	if path == "" {
@@ -987,6 +1691,10 @@ func thumbnail(img image.Image, width, height int) image.Image {
 
 // AddThumbnail adds a thumbnail image to a entry.
 func (s *Server) AddThumbnail(ctx context.Context, path string, img image.Image) error {
+	err := s.checkImpersonationWrite(ctx)
+	if err != nil {
+		return err
+	}
 	if path == "" {
 		return fmt.Errorf("thumbnail path not specified")
 	}
@@ -995,7 +1703,7 @@ func (s *Server) AddThumbnail(ctx context.Context, path string, img image.Image)
 	}
 	thumb := thumbnail(img, 192, 108)
 	buf := new(bytes.Buffer)
-	err := png.Encode(buf, thumb)
+	err = png.Encode(buf, thumb)
 	if err != nil {
 		return err
 	}
@@ -1010,6 +1718,10 @@ func (s *Server) AddThumbnail(ctx context.Context, path string, img image.Image)
 }
 
 func (s *Server) UpdateThumbnail(ctx context.Context, path string, img image.Image) error {
+	err := s.checkImpersonationWrite(ctx)
+	if err != nil {
+		return err
+	}
 	if path == "" {
 		return fmt.Errorf("thumbnail path not specified")
 	}
@@ -1018,7 +1730,7 @@ func (s *Server) UpdateThumbnail(ctx context.Context, path string, img image.Ima
 	}
 	thumb := thumbnail(img, 192, 108)
 	buf := new(bytes.Buffer)
-	err := png.Encode(buf, thumb)
+	err = png.Encode(buf, thumb)
 	if err != nil {
 		return err
 	}
@@ -1033,10 +1745,14 @@ func (s *Server) UpdateThumbnail(ctx context.Context, path string, img image.Ima
 }
 
 func (s *Server) DeleteThumbnail(ctx context.Context, path string) error {
+	err := s.checkImpersonationWrite(ctx)
+	if err != nil {
+		return err
+	}
 	if path == "" {
 		return fmt.Errorf("thumbnail path not specified")
 	}
-	err := s.svc.DeleteThumbnail(ctx, path)
+	err = s.svc.DeleteThumbnail(ctx, path)
 	if err != nil {
 		return err
 	}
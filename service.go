@@ -25,12 +25,23 @@ type Service interface {
 	SearchEntries(ctx context.Context, search EntrySearcher) ([]*Entry, error)
 	CountAllSubEntries(ctx context.Context, path string) (int, error)
 	GetEntry(ctx context.Context, path string) (*Entry, error)
+	GetEntryByID(ctx context.Context, id int) (*Entry, error)
+	GetMovedEntryPath(ctx context.Context, path string) (string, error)
+	FindReferences(ctx context.Context, path string) ([]*Reference, error)
+	FindRelations(ctx context.Context, find RelationFinder) ([]*Relation, error)
+	AddRelation(ctx context.Context, r *Relation) error
+	DeleteRelation(ctx context.Context, r *Relation) error
+	RelationGraph(ctx context.Context, find RelationGraphFinder) (*RelationGraph, error)
 	AddEntry(ctx context.Context, ent *Entry) error
+	AddEntries(ctx context.Context, ents []*Entry) error
+	DryRunAddEntries(ctx context.Context, ents []*Entry) ([]string, error)
 	RenameEntry(ctx context.Context, path string, newName string) error
+	RenameEntries(ctx context.Context, parent string, renames map[string]string) error
 	ArchiveEntry(ctx context.Context, path string) error
 	UnarchiveEntry(ctx context.Context, path string) error
 	DeleteEntry(ctx context.Context, path string) error
 	DeleteEntryRecursive(ctx context.Context, path string) error
+	ForceDeleteEntryRecursive(ctx context.Context, path string) error
 	AddThumbnail(ctx context.Context, thumb *Thumbnail) error
 	UpdateThumbnail(ctx context.Context, upd ThumbnailUpdater) error
 	GetThumbnail(ctx context.Context, path string) (*Thumbnail, error)
@@ -39,6 +50,7 @@ type Service interface {
 	GetProperty(ctx context.Context, path, name string) (*Property, error)
 	UpdateProperty(ctx context.Context, upd PropertyUpdater) error
 	UpdateProperties(ctx context.Context, upds []PropertyUpdater) error
+	DryRunUpdateProperties(ctx context.Context, upds []PropertyUpdater) error
 	EntryEnvirons(ctx context.Context, path string) ([]*Property, error)
 	GetEnvirons(ctx context.Context, path string) ([]*Property, error)
 	GetEnviron(ctx context.Context, path, name string) (*Property, error)
@@ -51,12 +63,18 @@ type Service interface {
 	AddAccess(ctx context.Context, ac *Access) error
 	UpdateAccess(ctx context.Context, upd AccessUpdater) error
 	DeleteAccess(ctx context.Context, path string, name string) error
+	ExplainAccess(ctx context.Context, user, path string) (*AccessExplanation, error)
 	IsAdmin(ctx context.Context, user string) (bool, error)
 	FindLogs(ctx context.Context, find LogFinder) ([]*Log, error)
 	GetLogs(ctx context.Context, path, ctg, name string) ([]*Log, error)
 	FindUsers(ctx context.Context, find UserFinder) ([]*User, error)
 	AddUser(ctx context.Context, u *User) error
+	SignUpUser(ctx context.Context, u *User, groups []string) error
+	ApproveUser(ctx context.Context, user string, groups []string) error
+	RejectUser(ctx context.Context, user string) error
 	UpdateUser(ctx context.Context, upd UserUpdater) error
+	RenameUser(ctx context.Context, name, newName string) error
+	MergeUsers(ctx context.Context, from, to string) error
 	GetUser(ctx context.Context, user string) (*User, error)
 	GetUserSetting(ctx context.Context, user string) (*UserSetting, error)
 	UpdateUserSetting(ctx context.Context, upd UserSettingUpdater) error
@@ -72,9 +90,11 @@ type Service interface {
 	FindGroups(ctx context.Context, find GroupFinder) ([]*Group, error)
 	AddGroup(ctx context.Context, g *Group) error
 	UpdateGroup(ctx context.Context, upd GroupUpdater) error
+	DeleteGroup(ctx context.Context, name string, cascade bool) (*GroupReferences, error)
 	FindGroupMembers(ctx context.Context, find MemberFinder) ([]*Member, error)
 	AddGroupMember(ctx context.Context, m *Member) error
 	DeleteGroupMember(ctx context.Context, group, member string) error
+	SyncGroupMembers(ctx context.Context, member string, managed, groups []string) error
 }
 
 type NotFoundError struct {
@@ -92,6 +112,24 @@ func NotFound(s string, is ...any) *NotFoundError {
 	return &NotFoundError{fmt.Errorf(s, is...)}
 }
 
+// MovedError is a NotFoundError for a path, that the entry had before it's moved to NewPath.
+type MovedError struct {
+	Path    string
+	NewPath string
+}
+
+func (e *MovedError) Error() string {
+	return fmt.Sprintf("entry moved to %v: %v", e.NewPath, e.Path)
+}
+
+func (e *MovedError) Unwrap() error {
+	return NotFound("entry not found: %v", e.Path)
+}
+
+func Moved(path, newPath string) *MovedError {
+	return &MovedError{Path: path, NewPath: newPath}
+}
+
 type UnauthorizedError struct {
 	err error
 }
@@ -111,6 +149,7 @@ type contextKey int
 
 const (
 	userNameContextKey = contextKey(iota + 1)
+	impersonationContextKey
 )
 
 func ContextWithUserName(ctx context.Context, email string) context.Context {
@@ -124,3 +163,27 @@ func UserNameFromContext(ctx context.Context) string {
 	}
 	return email.(string)
 }
+
+// Impersonation is a session of an admin who views forge as another user.
+// The user of the context is the impersonated user while it is active.
+type Impersonation struct {
+	// Admin is the real user who started the impersonation.
+	Admin string
+	// Writable indicates the admin can modify things as the impersonated user.
+	// An impersonation is read-only by default.
+	Writable bool
+}
+
+func ContextWithImpersonation(ctx context.Context, imp *Impersonation) context.Context {
+	return context.WithValue(ctx, impersonationContextKey, imp)
+}
+
+// ImpersonationFromContext returns the impersonation of the context.
+// It returns nil when the context user isn't impersonated.
+func ImpersonationFromContext(ctx context.Context) *Impersonation {
+	imp := ctx.Value(impersonationContextKey)
+	if imp == nil {
+		return nil
+	}
+	return imp.(*Impersonation)
+}
@@ -50,23 +50,29 @@ func EntryAccessList(db *sql.DB, ctx context.Context, path string) ([]*forge.Acc
 	return accs, nil
 }
 
+// entryAccessList returns access controls those are applied to the entry,
+// including the ones inherited from the ancestors.
 func entryAccessList(tx *sql.Tx, ctx context.Context, path string) ([]*forge.Access, error) {
+	_, err := getEntry(tx, ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	r, err := newAccessResolver(tx, ctx, forge.UserNameFromContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	// load the access lists of the entry and the ancestors at once.
+	err = r.load(path)
+	if err != nil {
+		return nil, err
+	}
 	acm := make(map[string]*forge.Access)
 	for {
-		ent, err := getEntry(tx, ctx, path)
-		if err != nil {
-			return nil, err
-		}
-		as, err := findAccessList(tx, ctx, forge.AccessFinder{EntryPath: &path})
-		if err != nil {
-			return nil, err
-		}
-		for _, a := range as {
+		for _, a := range r.acl[path] {
 			if acm[a.Name] != nil {
 				// Already found the accessor permission on a child entry.
 				continue
 			}
-			a.EntryPath = ent.Path
 			acm[a.Name] = a
 		}
 		if path == "/" {
@@ -114,6 +120,11 @@ func findAccessList(tx *sql.Tx, ctx context.Context, find forge.AccessFinder) ([
 	if len(keys) != 0 {
 		where = "WHERE " + strings.Join(keys, " AND ")
 	}
+	return queryAccessList(tx, ctx, where, vals)
+}
+
+// queryAccessList finds access controls those match the where clause.
+func queryAccessList(tx *sql.Tx, ctx context.Context, where string, vals []any) ([]*forge.Access, error) {
 	rows, err := tx.QueryContext(ctx, `
 		SELECT
 			access_controls.id,
@@ -151,17 +162,40 @@ func findAccessList(tx *sql.Tx, ctx context.Context, find forge.AccessFinder) ([
 		if isGroup {
 			a.Type = "group"
 		}
-		a.Value = "r"
-		if a.RawValue == 1 {
-			a.Value = "rw"
-		}
+		a.Value = accessModeString(a.RawValue)
 		a.Eval = a.Value
 		acss = append(acss, a)
 	}
 	return acss, nil
 }
 
+// accessModeRaw returns the value of an access mode saved in db.
+func accessModeRaw(mode string) int {
+	switch mode {
+	case "rw":
+		return 1
+	case "none":
+		return -1
+	}
+	return 0
+}
+
+// accessModeString returns the access mode of a value saved in db.
+func accessModeString(raw int) string {
+	switch raw {
+	case 1:
+		return "rw"
+	case -1:
+		return "none"
+	}
+	return "r"
+}
+
 func userEnabled(tx *sql.Tx, ctx context.Context, user string) (bool, error) {
+	if user == "system" {
+		// system isn't a real user, but always enabled.
+		return true, nil
+	}
 	u, err := getAccessor(tx, ctx, user)
 	if err != nil {
 		return false, err
@@ -186,6 +220,8 @@ func UserRead(db *sql.DB, ctx context.Context, path string) error {
 	return userRead(tx, ctx, path)
 }
 
+// userRead checks the context user can read the entry.
+// Use an accessResolver instead, when it needs to check many entries.
 func userRead(tx *sql.Tx, ctx context.Context, path string) error {
 	user := forge.UserNameFromContext(ctx)
 	enabled, err := userEnabled(tx, ctx, user)
@@ -199,15 +235,11 @@ func userRead(tx *sql.Tx, ctx context.Context, path string) error {
 		// Everyone should be able to access root.
 		return nil
 	}
-	mode, err := userAccessMode(tx, ctx, path)
+	r, err := newAccessResolver(tx, ctx, user)
 	if err != nil {
 		return err
 	}
-	if mode == nil {
-		// The entry should invisible to the user.
-		return forge.NotFound("cannot access to entry: %s", path)
-	}
-	return nil
+	return r.read(path)
 }
 
 // UserWrite simulates the context user write to the path.
@@ -224,90 +256,105 @@ func UserWrite(db *sql.DB, ctx context.Context, path string) error {
 	return userWrite(tx, ctx, path)
 }
 
+// userWrite checks the context user can write to the entry.
+// Use an accessResolver instead, when it needs to check many entries.
 func userWrite(tx *sql.Tx, ctx context.Context, path string) error {
-	user := forge.UserNameFromContext(ctx)
-	enabled, err := userEnabled(tx, ctx, user)
+	r, err := newAccessResolver(tx, ctx, forge.UserNameFromContext(ctx))
 	if err != nil {
 		return err
 	}
-	if !enabled {
-		return fmt.Errorf("user disabled: %v", user)
+	return r.write(path)
+}
+
+// userAccessMode returns the user's access control for an entry.
+// It checks the parents recursively as access control inherits.
+// It returns (nil, nil) when there is no access_control exists for the user,
+// or the user is denied with 'none' mode.
+//
+// See accessResolver.mode for the rules.
+func userAccessMode(tx *sql.Tx, ctx context.Context, path string) (*string, error) {
+	r, err := newAccessResolver(tx, ctx, forge.UserNameFromContext(ctx))
+	if err != nil {
+		return nil, err
 	}
-	mode, err := userAccessMode(tx, ctx, path)
+	mode, err := r.mode(path)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	if mode == nil {
-		// The entry should invisible to the user.
-		return forge.NotFound("cannot access to entry: %s", path)
+	if mode == "" {
+		return nil, nil
 	}
-	if *mode == "r" {
-		return forge.Unauthorized("entry modification not allowed: %s", path)
+	return &mode, nil
+}
+
+// explainAccessMode decides the user's access mode for an entry, and explains how it is decided.
+// It doesn't consider whether the user is disabled, see userRead and userWrite for that.
+func explainAccessMode(tx *sql.Tx, ctx context.Context, user, path string) (*forge.AccessExplanation, error) {
+	r, err := newAccessResolver(tx, ctx, user)
+	if err != nil {
+		return nil, err
 	}
-	return nil
+	return r.explain(path)
 }
 
-// userAccessMode returns the user's access control for an entry.
-// It checks the parents recursively as access control inherits.
-// It returns (nil, nil) when there is no access_control exists for the user.
-func userAccessMode(tx *sql.Tx, ctx context.Context, path string) (*string, error) {
-	if path == "" {
-		return nil, fmt.Errorf("path should be specified for access check")
+// ExplainAccess explains how the access mode of a user to an entry is decided.
+// Only admins can explain access of other users.
+func ExplainAccess(db *sql.DB, ctx context.Context, user, path string) (*forge.AccessExplanation, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
 	}
-	user := forge.UserNameFromContext(ctx)
-	if user == "" {
-		return nil, forge.Unauthorized("context user unspecified")
+	defer tx.Rollback()
+	exp, err := explainAccess(tx, ctx, user, path)
+	if err != nil {
+		return nil, err
 	}
-	yes, err := isAdmin(tx, ctx, user)
+	err = tx.Commit()
 	if err != nil {
 		return nil, err
 	}
-	if yes {
-		// admins can read any entry.
-		rwMode := "rw"
-		return &rwMode, nil
+	return exp, nil
+}
+
+// explainAccess explains access of a user to an entry, as it is checked by userRead and userWrite.
+func explainAccess(tx *sql.Tx, ctx context.Context, user, path string) (*forge.AccessExplanation, error) {
+	ctxUser := forge.UserNameFromContext(ctx)
+	if ctxUser == "" {
+		return nil, forge.Unauthorized("context user unspecified")
 	}
-	for {
-		as, err := findAccessList(tx, ctx, forge.AccessFinder{EntryPath: &path})
+	if ctxUser != user {
+		yes, err := isAdmin(tx, ctx, ctxUser)
 		if err != nil {
 			return nil, err
 		}
-		// Lower entry has precedence to higher entry.
-		// In a same entry, user accessor has precedence to group accessor.
-		for _, a := range as {
-			if a.Type == "user" && a.Name == user {
-				return &a.Value, nil
-			}
-		}
-		// a user can be a member of multiple groups,
-		// let's find most permissive
-		value := ""
-		for _, a := range as {
-			if a.Type == "user" {
-				continue
-			}
-			// groups
-			yes, err := isGroupMember(tx, ctx, a.Name, user)
-			if err != nil {
-				return nil, err
-			}
-			if yes {
-				if a.Value == "r" {
-					value = a.Value
-					continue
-				}
-				return &a.Value, nil
-			}
-		}
-		if value == "r" {
-			return &value, nil
-		}
-		if path == "/" {
-			break
+		if !yes {
+			return nil, forge.Unauthorized("only admins can explain access of other users")
 		}
-		path = filepath.Dir(path)
 	}
-	return nil, nil
+	_, err := getEntryID(tx, ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	enabled, err := userEnabled(tx, ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	exp, err := explainAccessMode(tx, ctx, user, path)
+	if err != nil {
+		return nil, err
+	}
+	if !enabled {
+		exp.Disabled = true
+		exp.Mode = ""
+		exp.Reason = "user disabled"
+		return exp, nil
+	}
+	if path == "/" && exp.Mode == "" {
+		// see userRead.
+		exp.Mode = "r"
+		exp.Reason = "everyone can read root"
+	}
+	return exp, nil
 }
 
 func IsAdmin(db *sql.DB, ctx context.Context, user string) (bool, error) {
@@ -393,10 +440,7 @@ func addAccess(tx *sql.Tx, ctx context.Context, a *forge.Access) error {
 	if err != nil {
 		return err
 	}
-	a.RawValue = 0
-	if a.Value == "rw" {
-		a.RawValue = 1
-	}
+	a.RawValue = accessModeRaw(a.Value)
 	entryID, err := getEntryID(tx, ctx, a.EntryPath)
 	if err != nil {
 		return err
@@ -439,6 +483,11 @@ func addAccess(tx *sql.Tx, ctx context.Context, a *forge.Access) error {
 	if err != nil {
 		return err
 	}
+	// formulas of the ancestors and siblings could search the entry.
+	err = refreshFormulas(tx, ctx, a.EntryPath, false)
+	if err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -471,10 +520,7 @@ func updateAccess(tx *sql.Tx, ctx context.Context, upd forge.AccessUpdater) erro
 	keys := make([]string, 0)
 	vals := make([]any, 0)
 	if upd.Value != nil {
-		rawMode := 0
-		if *upd.Value == "rw" {
-			rawMode = 1
-		}
+		rawMode := accessModeRaw(*upd.Value)
 		if rawMode != a.RawValue {
 			keys = append(keys, "mode=?")
 			vals = append(vals, rawMode)
@@ -520,6 +566,11 @@ func updateAccess(tx *sql.Tx, ctx context.Context, upd forge.AccessUpdater) erro
 	if err != nil {
 		return err
 	}
+	// formulas of the ancestors and siblings could search the entry.
+	err = refreshFormulas(tx, ctx, a.EntryPath, false)
+	if err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -595,5 +646,10 @@ func deleteAccess(tx *sql.Tx, ctx context.Context, path, name string) error {
 	if err != nil {
 		return nil
 	}
+	// formulas of the ancestors and siblings could search the entry.
+	err = refreshFormulas(tx, ctx, path, false)
+	if err != nil {
+		return err
+	}
 	return nil
 }
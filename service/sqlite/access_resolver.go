@@ -0,0 +1,317 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/imagvfx/forge"
+)
+
+// accessResolver decides access modes of a user for entries in a transaction.
+//
+// It loads access lists of entries and the user's groups in batches, and memoizes
+// the decisions of entries and their ancestors, so checking many entries,
+// like in a search, doesn't need to query the db for each entry and each ancestor.
+//
+// It doesn't notice changes of access lists or group members after they are loaded,
+// create a new resolver after such changes.
+type accessResolver struct {
+	tx      *sql.Tx
+	ctx     context.Context
+	user    string
+	enabled bool
+	admin   bool
+	// groups are the groups the user is a member of.
+	// It is loaded when it's needed at the first time.
+	groups map[string]bool
+	// acl holds the access list of entries by path.
+	// Entries without any access control have an empty list once they are loaded.
+	acl map[string][]*forge.Access
+	// modes memoizes the decided modes of entries by path.
+	// "" means the user cannot see the entry.
+	modes map[string]string
+}
+
+func newAccessResolver(tx *sql.Tx, ctx context.Context, user string) (*accessResolver, error) {
+	if user == "" {
+		return nil, forge.Unauthorized("context user unspecified")
+	}
+	enabled, err := userEnabled(tx, ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	admin, err := isAdmin(tx, ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	r := &accessResolver{
+		tx:      tx,
+		ctx:     ctx,
+		user:    user,
+		enabled: enabled,
+		admin:   admin,
+		acl:     make(map[string][]*forge.Access),
+		modes:   make(map[string]string),
+	}
+	return r, nil
+}
+
+// load loads access lists of the entries and their ancestors, those aren't loaded yet.
+func (r *accessResolver) load(paths ...string) error {
+	need := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, pth := range paths {
+		for {
+			if _, ok := r.acl[pth]; ok || seen[pth] {
+				break
+			}
+			seen[pth] = true
+			need = append(need, pth)
+			if pth == "/" {
+				break
+			}
+			pth = filepath.Dir(pth)
+		}
+	}
+	// sqlite limits number of variables in a query.
+	const batch = 500
+	for len(need) != 0 {
+		n := min(len(need), batch)
+		as, err := findAccessListOfPaths(r.tx, r.ctx, need[:n])
+		if err != nil {
+			return err
+		}
+		for _, pth := range need[:n] {
+			r.acl[pth] = make([]*forge.Access, 0)
+		}
+		for _, a := range as {
+			r.acl[a.EntryPath] = append(r.acl[a.EntryPath], a)
+		}
+		need = need[n:]
+	}
+	return nil
+}
+
+// match finds the access controls of an entry those match with the user,
+// and the decisive one among them. It returns nil decisive when nothing matches.
+//
+// User accessor has precedence to group accessor.
+// When the user is a member of multiple groups, the most permissive mode wins (rw > r > none).
+func (r *accessResolver) match(path string) ([]*forge.AccessMatch, *forge.AccessMatch, error) {
+	err := r.load(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	as := r.acl[path]
+	matches := make([]*forge.AccessMatch, 0)
+	for _, a := range as {
+		if a.Type == "user" && a.Name == r.user {
+			m := &forge.AccessMatch{Name: a.Name, Type: a.Type, Mode: a.Value, Decisive: true}
+			return append(matches, m), m, nil
+		}
+	}
+	// a user can be a member of multiple groups,
+	// let's find most permissive
+	var most *forge.AccessMatch
+	for _, a := range as {
+		if a.Type == "user" {
+			continue
+		}
+		if r.groups == nil {
+			r.groups, err = userGroups(r.tx, r.ctx, r.user)
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+		if !r.groups[a.Name] {
+			continue
+		}
+		m := &forge.AccessMatch{Name: a.Name, Type: a.Type, Mode: a.Value}
+		matches = append(matches, m)
+		if most == nil || accessModeRaw(m.Mode) > accessModeRaw(most.Mode) {
+			most = m
+		}
+	}
+	if most != nil {
+		most.Decisive = true
+	}
+	return matches, most, nil
+}
+
+// mode returns the user's access mode of an entry, it is "r", "rw" or "".
+// "" means the user cannot see the entry.
+//
+// Lower entry has precedence to higher entry, so 'none' blocks inheritance from the parents.
+// It doesn't consider whether the user is disabled, see read and write for that.
+func (r *accessResolver) mode(path string) (string, error) {
+	if path == "" {
+		return "", fmt.Errorf("path should be specified for access check")
+	}
+	if r.admin {
+		return "rw", nil
+	}
+	if mode, ok := r.modes[path]; ok {
+		return mode, nil
+	}
+	_, decisive, err := r.match(path)
+	if err != nil {
+		return "", err
+	}
+	mode := ""
+	if decisive != nil {
+		mode = decisive.Mode
+		if mode == "none" {
+			mode = ""
+		}
+	} else if path != "/" {
+		mode, err = r.mode(filepath.Dir(path))
+		if err != nil {
+			return "", err
+		}
+	}
+	r.modes[path] = mode
+	return mode, nil
+}
+
+// explain explains how the user's access mode of an entry is decided.
+// It follows the same rules with mode.
+func (r *accessResolver) explain(path string) (*forge.AccessExplanation, error) {
+	if path == "" {
+		return nil, fmt.Errorf("path should be specified for access check")
+	}
+	exp := &forge.AccessExplanation{
+		User:  r.user,
+		Path:  path,
+		Steps: make([]*forge.AccessStep, 0),
+	}
+	if r.admin {
+		// admins can read any entry.
+		exp.Admin = true
+		exp.Mode = "rw"
+		exp.Reason = "admin can read and write any entry"
+		return exp, nil
+	}
+	// groupPaths is loaded when a group matches at the first time.
+	var groupPaths map[string][]string
+	for {
+		matches, decisive, err := r.match(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range matches {
+			if m.Type != "group" {
+				continue
+			}
+			if groupPaths == nil {
+				groupPaths, err = userGroupPaths(r.tx, r.ctx, r.user)
+				if err != nil {
+					return nil, err
+				}
+			}
+			m.Via = groupPaths[m.Name]
+		}
+		exp.Steps = append(exp.Steps, &forge.AccessStep{EntryPath: path, Matches: matches})
+		if decisive != nil {
+			exp.Mode = decisive.Mode
+			if decisive.Mode == "none" {
+				exp.Mode = ""
+			}
+			exp.Reason = fmt.Sprintf("%v %v has %v access at %v", decisive.Type, decisive.Name, decisive.Mode, path)
+			return exp, nil
+		}
+		if path == "/" {
+			break
+		}
+		path = filepath.Dir(path)
+	}
+	exp.Reason = "no access control for the user"
+	return exp, nil
+}
+
+// read checks the user can read the entry, as userRead does.
+func (r *accessResolver) read(path string) error {
+	if !r.enabled {
+		return fmt.Errorf("user disabled: %v", r.user)
+	}
+	if path == "/" {
+		// Everyone should be able to access root.
+		return nil
+	}
+	mode, err := r.mode(path)
+	if err != nil {
+		return err
+	}
+	if mode == "" {
+		// The entry should invisible to the user.
+		return forge.NotFound("cannot access to entry: %s", path)
+	}
+	return nil
+}
+
+// write checks the user can write to the entry, as userWrite does.
+func (r *accessResolver) write(path string) error {
+	if !r.enabled {
+		return fmt.Errorf("user disabled: %v", r.user)
+	}
+	mode, err := r.mode(path)
+	if err != nil {
+		return err
+	}
+	if mode == "" {
+		// The entry should invisible to the user.
+		return forge.NotFound("cannot access to entry: %s", path)
+	}
+	if mode == "r" {
+		return forge.Unauthorized("entry modification not allowed: %s", path)
+	}
+	return nil
+}
+
+// findAccessListOfPaths finds access controls of the entries at once.
+func findAccessListOfPaths(tx *sql.Tx, ctx context.Context, paths []string) ([]*forge.Access, error) {
+	if len(paths) == 0 {
+		return []*forge.Access{}, nil
+	}
+	vals := make([]any, 0, len(paths))
+	for _, pth := range paths {
+		vals = append(vals, pth)
+	}
+	where := "WHERE entries.path IN (?" + strings.Repeat(", ?", len(paths)-1) + ")"
+	return queryAccessList(tx, ctx, where, vals)
+}
+
+// readableEntries filters the entries those the context user cannot read.
+// It checks all the entries with a resolver, instead of calling userRead for each of them.
+func readableEntries(tx *sql.Tx, ctx context.Context, ents []*forge.Entry) ([]*forge.Entry, error) {
+	r, err := newAccessResolver(tx, ctx, forge.UserNameFromContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	paths := make([]string, 0, len(ents))
+	for _, e := range ents {
+		paths = append(paths, e.Path)
+	}
+	err = r.load(paths...)
+	if err != nil {
+		return nil, err
+	}
+	readable := make([]*forge.Entry, 0, len(ents))
+	for _, e := range ents {
+		err := r.read(e.Path)
+		if err != nil {
+			var e *forge.NotFoundError
+			if !errors.As(err, &e) {
+				return nil, err
+			}
+			// read returns forge.NotFoundError
+			// because of the user doesn't have permission to see the entry.
+			continue
+		}
+		readable = append(readable, e)
+	}
+	return readable, nil
+}
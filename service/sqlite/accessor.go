@@ -3,6 +3,7 @@ package sqlite
 import (
 	"context"
 	"database/sql"
+	"strconv"
 	"strings"
 
 	"github.com/imagvfx/forge"
@@ -21,6 +22,12 @@ func createAccessorsTable(tx *sql.Tx) error {
 	if err != nil {
 		return err
 	}
+	_, err = tx.Exec(`ALTER TABLE accessors ADD COLUMN pending BOOL NOT NULL DEFAULT 0`)
+	if err != nil {
+		if !strings.Contains(err.Error(), "duplicate column name") {
+			return err
+		}
+	}
 	_, err = tx.Exec(`CREATE UNIQUE INDEX IF NOT EXISTS index_accessors_name ON accessors (name)`)
 	return err
 }
@@ -108,3 +115,84 @@ func getAccessorByID(tx *sql.Tx, ctx context.Context, id int) (*forge.Accessor,
 	}
 	return a, nil
 }
+
+// accessorProperties finds properties those have the accessor in their value.
+// They are 'user' or 'users' properties, as they save accessor ids as their raw values.
+func accessorProperties(tx *sql.Tx, ctx context.Context, id int) ([]*forge.Property, error) {
+	rows, err := tx.QueryContext(ctx, `
+		SELECT
+			properties.id,
+			entries.path,
+			default_properties.name,
+			default_properties.type,
+			properties.val
+		FROM properties
+		LEFT JOIN entries ON properties.entry_id = entries.id
+		LEFT JOIN default_properties ON properties.default_id = default_properties.id
+		WHERE (default_properties.type='user' AND properties.val=?)
+			OR (default_properties.type='users' AND properties.val LIKE ?)
+		ORDER BY properties.id ASC
+	`,
+		strconv.Itoa(id),
+		"%\n"+strconv.Itoa(id)+"\n%",
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	props := make([]*forge.Property, 0)
+	for rows.Next() {
+		p := &forge.Property{}
+		err := rows.Scan(
+			&p.ID,
+			&p.EntryPath,
+			&p.Name,
+			&p.Type,
+			&p.RawValue,
+		)
+		if err != nil {
+			return nil, err
+		}
+		props = append(props, p)
+	}
+	return props, nil
+}
+
+// replaceAccessorInProperty replaces an accessor id in the raw value of a 'user' or 'users' property.
+// A users property keeps the order of the accessors, and drops the duplicated one.
+// It doesn't change the time the property is updated, as it only re-points the value.
+func replaceAccessorInProperty(tx *sql.Tx, ctx context.Context, p *forge.Property, from, to int) error {
+	rawVal := strconv.Itoa(to)
+	if p.Type == "users" {
+		ids := make([]string, 0)
+		seen := make(map[string]bool)
+		for _, v := range strings.Split(p.RawValue, "\n") {
+			v = strings.TrimSpace(v)
+			if v == "" || v == "[" || v == "]" {
+				continue
+			}
+			if v == strconv.Itoa(from) {
+				v = strconv.Itoa(to)
+			}
+			if seen[v] {
+				continue
+			}
+			seen[v] = true
+			ids = append(ids, v)
+		}
+		// see validateUsers for the format.
+		rawVal = "[\n" + strings.Join(ids, "\n") + "\n]"
+	}
+	_, err := tx.ExecContext(ctx, `
+		UPDATE properties
+		SET val=?
+		WHERE id=?
+	`,
+		rawVal,
+		p.ID,
+	)
+	if err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,143 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/imagvfx/forge"
+)
+
+// checkConstraint checks a validated property with the constraint defined in it's default.
+// A new property (old == nil) isn't required to have a value, as it is created with the default value.
+func checkConstraint(tx *sql.Tx, ctx context.Context, p, old *forge.Property) error {
+	entType, err := getEntryType(tx, ctx, p.EntryPath)
+	if err != nil {
+		return err
+	}
+	d, err := getDefaultProperty(tx, ctx, entType, p.Name)
+	if err != nil {
+		return err
+	}
+	if d.Constraint == "" {
+		return nil
+	}
+	c, err := forge.ParseConstraint(d.Type, d.Constraint)
+	if err != nil {
+		return err
+	}
+	if old == nil {
+		c.Required = false
+		c.RequiredWhen = ""
+	}
+	// validated value could be different from the evaluated one. (ex. tag)
+	np := &forge.Property{EntryPath: p.EntryPath, Name: p.Name, Type: p.Type, RawValue: p.RawValue}
+	evalProperty(tx, ctx, np)
+	if np.ValueError != nil {
+		return np.ValueError
+	}
+	value := np.Value
+	err = c.Check(p.Type, value)
+	if err != nil {
+		return fmt.Errorf("invalid value for %v: %v", p.Name, err)
+	}
+	if value == "" {
+		cond, want := c.RequiredCondition()
+		if cond == "" {
+			return nil
+		}
+		cp, err := getProperty(tx, ctx, p.EntryPath, cond)
+		if err != nil {
+			var e *forge.NotFoundError
+			if !errors.As(err, &e) {
+				return err
+			}
+			return nil
+		}
+		if cp.Value == want {
+			return fmt.Errorf("invalid value for %v: value is required when %v is %v", p.Name, cond, want)
+		}
+		return nil
+	}
+	if c.Unique {
+		rows, err := tx.QueryContext(ctx, `
+			SELECT entries.path FROM properties
+			LEFT JOIN entries ON properties.entry_id=entries.id
+			LEFT JOIN default_properties ON properties.default_id=default_properties.id
+			WHERE
+				entries.parent_id=(SELECT parent_id FROM entries WHERE path=?) AND
+				entries.path!=? AND
+				default_properties.name=? AND
+				properties.val=?
+			LIMIT 1
+		`,
+			p.EntryPath,
+			p.EntryPath,
+			p.Name,
+			p.RawValue,
+		)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		if rows.Next() {
+			var pth string
+			err := rows.Scan(&pth)
+			if err != nil {
+				return err
+			}
+			return fmt.Errorf("invalid value for %v: value should be unique among siblings, but %v has it: %v", p.Name, pth, value)
+		}
+	}
+	return nil
+}
+
+// checkRequiredConditions checks properties of an entry those are required when
+// one of the updated properties has a specific value.
+func checkRequiredConditions(tx *sql.Tx, ctx context.Context, path string, updated []string) error {
+	entType, err := getEntryType(tx, ctx, path)
+	if err != nil {
+		return err
+	}
+	defs, err := findDefaultProperties(tx, ctx, forge.DefaultFinder{EntryType: &entType})
+	if err != nil {
+		return err
+	}
+	isUpdated := make(map[string]bool)
+	for _, name := range updated {
+		isUpdated[name] = true
+	}
+	for _, d := range defs {
+		if d.Constraint == "" {
+			continue
+		}
+		c, err := forge.ParseConstraint(d.Type, d.Constraint)
+		if err != nil {
+			return err
+		}
+		cond, want := c.RequiredCondition()
+		if !isUpdated[cond] {
+			continue
+		}
+		cp, err := getProperty(tx, ctx, path, cond)
+		if err != nil {
+			var e *forge.NotFoundError
+			if !errors.As(err, &e) {
+				return err
+			}
+			continue
+		}
+		if cp.Value != want {
+			continue
+		}
+		p, err := getProperty(tx, ctx, path, d.Name)
+		if err != nil {
+			return err
+		}
+		if p.Value == "" {
+			return fmt.Errorf("invalid value for %v: value is required when %v is %v", d.Name, cond, want)
+		}
+	}
+	return nil
+}
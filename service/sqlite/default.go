@@ -18,6 +18,15 @@ func createDefaultPropertiesTable(tx *sql.Tx) error {
 			name TEXT NOT NULL,
 			type TEXT NOT NULL,
 			value TEXT NOT NULL,
+			constraints TEXT NOT NULL,
+			label TEXT NOT NULL,
+			description TEXT NOT NULL,
+			section TEXT NOT NULL,
+			sort_order INTEGER NOT NULL,
+			read_only BOOL NOT NULL,
+			inheritable BOOL NOT NULL,
+			read_groups TEXT NOT NULL,
+			write_groups TEXT NOT NULL,
 			FOREIGN KEY (entry_type_id) REFERENCES entry_types (id),
 			UNIQUE (entry_type_id, name)
 		)
@@ -25,6 +34,24 @@ func createDefaultPropertiesTable(tx *sql.Tx) error {
 	if err != nil {
 		return err
 	}
+	for _, col := range []string{
+		"constraints TEXT NOT NULL DEFAULT ''",
+		"label TEXT NOT NULL DEFAULT ''",
+		"description TEXT NOT NULL DEFAULT ''",
+		"section TEXT NOT NULL DEFAULT ''",
+		"sort_order INTEGER NOT NULL DEFAULT 0",
+		"read_only BOOL NOT NULL DEFAULT 0",
+		"inheritable BOOL NOT NULL DEFAULT 0",
+		"read_groups TEXT NOT NULL DEFAULT ''",
+		"write_groups TEXT NOT NULL DEFAULT ''",
+	} {
+		_, err = tx.Exec(`ALTER TABLE default_properties ADD COLUMN ` + col)
+		if err != nil {
+			if !strings.Contains(err.Error(), "duplicate column name") {
+				return err
+			}
+		}
+	}
 	_, err = tx.Exec(`CREATE INDEX IF NOT EXISTS index_default_properties_entry_type_id ON default_properties (entry_type_id)`)
 	return err
 }
@@ -143,7 +170,16 @@ func findDefaultProperties(tx *sql.Tx, ctx context.Context, find forge.DefaultFi
 			entry_types.name,
 			default_properties.name,
 			default_properties.type,
-			default_properties.value
+			default_properties.value,
+			default_properties.constraints,
+			default_properties.label,
+			default_properties.description,
+			default_properties.section,
+			default_properties.sort_order,
+			default_properties.read_only,
+			default_properties.inheritable,
+			default_properties.read_groups,
+			default_properties.write_groups
 		FROM default_properties
 		LEFT JOIN entry_types ON default_properties.entry_type_id = entry_types.id
 		`+where,
@@ -158,16 +194,28 @@ func findDefaultProperties(tx *sql.Tx, ctx context.Context, find forge.DefaultFi
 		d := &forge.Default{
 			Category: "property",
 		}
+		var readGroups, writeGroups string
 		err := rows.Scan(
 			&d.ID,
 			&d.EntryType,
 			&d.Name,
 			&d.Type,
 			&d.Value,
+			&d.Constraint,
+			&d.Label,
+			&d.Description,
+			&d.Section,
+			&d.Order,
+			&d.ReadOnly,
+			&d.Inheritable,
+			&readGroups,
+			&writeGroups,
 		)
 		if err != nil {
 			return nil, err
 		}
+		d.ReadGroups = groupList(readGroups)
+		d.WriteGroups = groupList(writeGroups)
 		defaults = append(defaults, d)
 	}
 	return defaults, nil
@@ -299,10 +347,7 @@ func findDefaultAccessList(tx *sql.Tx, ctx context.Context, find forge.DefaultFi
 		if ac.IsGroup {
 			d.Type = "group"
 		}
-		d.Value = "r"
-		if mode == 1 {
-			d.Value = "rw"
-		}
+		d.Value = accessModeString(mode)
 		defaults = append(defaults, d)
 	}
 	return defaults, nil
@@ -418,10 +463,82 @@ func AddDefault(db *sql.DB, ctx context.Context, d *forge.Default) error {
 	return nil
 }
 
+// validateDefaultPropertyValue checks a default value of the types
+// those cannot be validated when an entry is created with it.
+// Other types will be validated when the value is applied to entries.
+func validateDefaultPropertyValue(tx *sql.Tx, ctx context.Context, typ, value string) error {
+	if typ != "formula" {
+		// value template will be expanded at creation of an entry.
+		err := forge.CheckValueTemplate(value)
+		if err != nil {
+			return err
+		}
+	}
+	switch typ {
+	case "formula":
+		f, err := forge.ParseFormula(value)
+		if err != nil {
+			return err
+		}
+		err = checkFormulaProperties(tx, ctx, f)
+		if err != nil {
+			return err
+		}
+	case "lookup":
+		if value == "" {
+			return nil
+		}
+		ref, _, err := splitLookup(value)
+		if err != nil {
+			return err
+		}
+		if strings.HasPrefix(ref, "^") {
+			_, err := getEntryTypeID(tx, ctx, ref[1:])
+			if err != nil {
+				return fmt.Errorf("invalid lookup: %v", err)
+			}
+		}
+	}
+	return nil
+}
+
+// checkPropertyMetaGroups checks the groups of the meta exist,
+// and no formula refers to the property when the meta limits it to read groups.
+func checkPropertyMetaGroups(tx *sql.Tx, ctx context.Context, name string, meta forge.PropertyMeta) error {
+	for _, groups := range [][]string{meta.ReadGroups, meta.WriteGroups} {
+		for _, g := range groups {
+			_, err := getGroup(tx, ctx, g)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	if len(meta.ReadGroups) != 0 {
+		err := checkFormulaReferences(tx, ctx, name)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func addDefaultProperty(tx *sql.Tx, ctx context.Context, d *forge.Default) error {
 	if d.Name == "" {
 		return fmt.Errorf("default property name not specified")
 	}
+	err := validateDefaultPropertyValue(tx, ctx, d.Type, d.Value)
+	if err != nil {
+		return err
+	}
+	c, err := forge.ParseConstraint(d.Type, d.Constraint)
+	if err != nil {
+		return err
+	}
+	d.Constraint = c.String()
+	err = checkPropertyMetaGroups(tx, ctx, d.Name, d.PropertyMeta)
+	if err != nil {
+		return err
+	}
 	typeID, err := getEntryTypeID(tx, ctx, d.EntryType)
 	if err != nil {
 		return err
@@ -431,14 +548,32 @@ func addDefaultProperty(tx *sql.Tx, ctx context.Context, d *forge.Default) error
 			entry_type_id,
 			name,
 			type,
-			value
+			value,
+			constraints,
+			label,
+			description,
+			section,
+			sort_order,
+			read_only,
+			inheritable,
+			read_groups,
+			write_groups
 		)
-		VALUES (?, ?, ?, ?)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`,
 		typeID,
 		d.Name,
 		d.Type,
 		d.Value,
+		d.Constraint,
+		d.Label,
+		d.Description,
+		d.Section,
+		d.Order,
+		d.ReadOnly,
+		d.Inheritable,
+		groupString(d.ReadGroups),
+		groupString(d.WriteGroups),
 	)
 	if err != nil {
 		return err
@@ -459,17 +594,27 @@ func addDefaultProperty(tx *sql.Tx, ctx context.Context, d *forge.Default) error
 			if !errors.As(err, &e) {
 				return err
 			}
+			val, err := expandDefaultValue(tx, ctx, ent, d)
+			if err != nil {
+				return err
+			}
 			err = addProperty(tx, ctx, &forge.Property{
 				EntryPath: ent.Path,
 				Name:      d.Name,
 				Type:      d.Type,
-				Value:     d.Value,
+				Value:     val,
 			})
 			if err != nil {
 				return err
 			}
 		}
 	}
+	if d.Type == "formula" {
+		err = refreshFormulasOfType(tx, ctx, d.EntryType)
+		if err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -539,13 +684,10 @@ func addDefaultAccess(tx *sql.Tx, ctx context.Context, d *forge.Default) error {
 	if d.Type != "user" && d.Type != "group" {
 		return fmt.Errorf("invalid default access type (want 'user' or 'group'): %v", d.Type)
 	}
-	if d.Value != "r" && d.Value != "rw" {
-		return fmt.Errorf("invalid default access value (want 'r' or 'rw'): %v", d.Value)
-	}
-	mode := 0
-	if d.Value == "rw" {
-		mode = 1
+	if !forge.IsAccessMode(d.Value) {
+		return fmt.Errorf("invalid default access value (want 'r', 'rw' or 'none'): %v", d.Value)
 	}
+	mode := accessModeRaw(d.Value)
 	ac, err := getAccessor(tx, ctx, d.Name)
 	if err != nil {
 		return fmt.Errorf("invalid accessor name: %v", d.Name)
@@ -710,6 +852,43 @@ func updateDefaultProperty(tx *sql.Tx, ctx context.Context, upd forge.DefaultUpd
 		keys = append(keys, "value=?")
 		vals = append(vals, *upd.Value)
 	}
+	typ := d.Type
+	if upd.Type != nil {
+		typ = *upd.Type
+	}
+	val := d.Value
+	if upd.Value != nil {
+		val = *upd.Value
+	}
+	err = validateDefaultPropertyValue(tx, ctx, typ, val)
+	if err != nil {
+		return err
+	}
+	// constraint should be valid for the type, even when only the type is changed.
+	constraint := d.Constraint
+	if upd.Constraint != nil {
+		constraint = *upd.Constraint
+	}
+	c, err := forge.ParseConstraint(typ, constraint)
+	if err != nil {
+		return err
+	}
+	if upd.Constraint != nil {
+		keys = append(keys, "constraints=?")
+		vals = append(vals, c.String())
+	}
+	if upd.Meta != nil {
+		err := checkPropertyMetaGroups(tx, ctx, upd.Name, *upd.Meta)
+		if err != nil {
+			return err
+		}
+		keys = append(keys, "label=?", "description=?", "section=?", "sort_order=?", "read_only=?", "read_groups=?", "write_groups=?")
+		vals = append(vals, upd.Meta.Label, upd.Meta.Description, upd.Meta.Section, upd.Meta.Order, upd.Meta.ReadOnly, groupString(upd.Meta.ReadGroups), groupString(upd.Meta.WriteGroups))
+	}
+	if upd.Inheritable != nil {
+		keys = append(keys, "inheritable=?")
+		vals = append(vals, *upd.Inheritable)
+	}
 	if len(keys) == 0 {
 		return fmt.Errorf("need at least one field to update default: %v %v %v", upd.EntryType, "property", upd.Name)
 	}
@@ -728,8 +907,20 @@ func updateDefaultProperty(tx *sql.Tx, ctx context.Context, upd forge.DefaultUpd
 	if err != nil {
 		return err
 	}
+	if typ == "formula" {
+		// value of a formula property is the result of the formula, not the default.
+		return refreshFormulasOfType(tx, ctx, upd.EntryType)
+	}
+	if upd.Inheritable != nil && d.Inheritable != *upd.Inheritable {
+		// values of the properties could be changed.
+		err := refreshFormulasOfType(tx, ctx, upd.EntryType)
+		if err != nil {
+			return err
+		}
+	}
 	// For value, we will only update properties having old default value with the new one.
-	if d.Value != *upd.Value {
+	// Values from a template are already expanded for each entry, so they cannot be compared.
+	if upd.Value != nil && d.Value != *upd.Value && !forge.IsValueTemplate(d.Value) && !forge.IsValueTemplate(*upd.Value) {
 		_, err := tx.ExecContext(ctx, `
 		UPDATE properties
 		SET val = ?
@@ -845,13 +1036,10 @@ func updateDefaultAccess(tx *sql.Tx, ctx context.Context, upd forge.DefaultUpdat
 		return fmt.Errorf("cannot change default accessor type")
 	}
 	if upd.Value != nil {
-		if *upd.Value != "r" && *upd.Value != "rw" {
-			return fmt.Errorf("invalid default access value (want 'r' or 'rw'): %v", upd.Type)
-		}
-		mode := 0
-		if *upd.Value == "rw" {
-			mode = 1
+		if !forge.IsAccessMode(*upd.Value) {
+			return fmt.Errorf("invalid default access value (want 'r', 'rw' or 'none'): %v", *upd.Value)
 		}
+		mode := accessModeRaw(*upd.Value)
 		keys = append(keys, "value=?")
 		vals = append(vals, mode)
 	}
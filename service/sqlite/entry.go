@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"path"
 	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 
@@ -180,18 +181,16 @@ func findEntries(tx *sql.Tx, ctx context.Context, find forge.EntryFinder) ([]*fo
 		if thumbID != nil {
 			e.HasThumbnail = true
 		}
-		err = userRead(tx, ctx, e.Path)
-		if err != nil {
-			var e *forge.NotFoundError
-			if !errors.As(err, &e) {
-				return nil, err
-			}
-			// userRead returns forge.NotFoundError
-			// because of the user doesn't have permission to see the entry.
-			continue
-		}
 		ents = append(ents, e)
 	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+	ents, err = readableEntries(tx, ctx, ents)
+	if err != nil {
+		return nil, err
+	}
 	for _, e := range ents {
 		e.Property = make(map[string]*forge.Property)
 		props, err := entryProperties(tx, ctx, e.Path)
@@ -275,6 +274,25 @@ func getEntry(tx *sql.Tx, ctx context.Context, path string) (*forge.Entry, error
 	return ents[0], nil
 }
 
+// GetEntryByID gets an entry with it's id.
+// Unlike the path, the id of an entry doesn't change even if the entry is renamed.
+func GetEntryByID(db *sql.DB, ctx context.Context, id int) (*forge.Entry, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	ent, err := getEntryByID(tx, ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+	return ent, nil
+}
+
 func getEntryByID(tx *sql.Tx, ctx context.Context, id int) (*forge.Entry, error) {
 	ents, err := findEntries(tx, ctx, forge.EntryFinder{ID: &id, Archived: true})
 	if err != nil {
@@ -327,6 +345,10 @@ func getEntryType(tx *sql.Tx, ctx context.Context, path string) (string, error)
 }
 
 func AddEntry(db *sql.DB, ctx context.Context, e *forge.Entry) error {
+	if path.Base(e.Path) == "+" {
+		unlock := autoNameLocks.lock(path.Dir(path.Clean(e.Path)))
+		defer unlock()
+	}
 	tx, err := db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
@@ -343,6 +365,122 @@ func AddEntry(db *sql.DB, ctx context.Context, e *forge.Entry) error {
 	return nil
 }
 
+// AddEntries adds multiple entries in a transaction.
+// It fails without adding any entry, when one of the entries cannot be added.
+func AddEntries(db *sql.DB, ctx context.Context, ents []*forge.Entry) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	exists, err := existingEntries(tx, ctx, ents)
+	if err != nil {
+		return err
+	}
+	if len(exists) != 0 {
+		return fmt.Errorf("entries exist: %v", strings.Join(exists, ", "))
+	}
+	err = addEntries(tx, ctx, ents)
+	if err != nil {
+		return err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// DryRunAddEntries performs AddEntries without saving the changes.
+// Unlike AddEntries, it returns paths of the entries already exist instead of failing with them.
+// Other entries are added to check the error AddEntries will return.
+func DryRunAddEntries(db *sql.DB, ctx context.Context, ents []*forge.Entry) ([]string, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	exists, err := existingEntries(tx, ctx, ents)
+	if err != nil {
+		return nil, err
+	}
+	isExist := make(map[string]bool)
+	for _, pth := range exists {
+		isExist[pth] = true
+	}
+	adds := make([]*forge.Entry, 0, len(ents))
+	for _, e := range ents {
+		if isExist[e.Path] {
+			continue
+		}
+		adds = append(adds, e)
+	}
+	err = addEntries(tx, ctx, adds)
+	if err != nil {
+		return nil, err
+	}
+	return exists, nil
+}
+
+// existingEntries returns paths of the entries those already exist.
+//
+// Entries hidden from the context user aren't reported by their paths, or the user could find them
+// by trying to add entries. It returns a generic conflict error for those instead.
+func existingEntries(tx *sql.Tx, ctx context.Context, ents []*forge.Entry) ([]string, error) {
+	r, err := newAccessResolver(tx, ctx, forge.UserNameFromContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	exists := make([]string, 0)
+	hidden := false
+	for _, ent := range ents {
+		pth := path.Clean(ent.Path)
+		_, err := getEntryID(tx, ctx, pth)
+		if err != nil {
+			var e *forge.NotFoundError
+			if !errors.As(err, &e) {
+				return nil, err
+			}
+			continue
+		}
+		err = r.read(pth)
+		if err != nil {
+			var e *forge.NotFoundError
+			if !errors.As(err, &e) {
+				return nil, err
+			}
+			hidden = true
+			continue
+		}
+		exists = append(exists, pth)
+	}
+	if hidden {
+		return nil, fmt.Errorf("cannot add entries: conflict with existing entries")
+	}
+	return exists, nil
+}
+
+func addEntries(tx *sql.Tx, ctx context.Context, ents []*forge.Entry) error {
+	for _, e := range ents {
+		if path.Base(e.Path) == "+" {
+			return fmt.Errorf("auto numbering is not supported when adding multiple entries: %v", e.Path)
+		}
+		err := addEntryR(tx, ctx, e)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// entryNameChars are characters an entry name can have.
+var entryNameChars = strings.Join([]string{
+	"abcdefghijklmnopqrstuvwxyz",
+	"ABCDEFGHIJKLMNOPQRSTUVWXYZ",
+	"0123456789",
+	"_-/",
+}, "")
+
 func addEntryR(tx *sql.Tx, ctx context.Context, e *forge.Entry) error {
 	e.Path = path.Clean(e.Path)
 	if e.Path == "/" {
@@ -352,14 +490,14 @@ func addEntryR(tx *sql.Tx, ctx context.Context, e *forge.Entry) error {
 	// Check and apply the type if it is predefined sub entry of the parent.
 	parentPath := filepath.Dir(e.Path)
 	entName := filepath.Base(e.Path)
-	validChars := strings.Join([]string{
-		"abcdefghijklmnopqrstuvwxyz",
-		"ABCDEFGHIJKLMNOPQRSTUVWXYZ",
-		"0123456789",
-		"_-/",
-	}, "")
+	// Name of '+' will be replaced with the next name of auto numbering.
+	// Empty name isn't, the path was cleaned to the parent path already. See forge.NamingRule.
+	autoName := entName == "+"
 	for _, r := range entName {
-		if !strings.ContainsRune(validChars, r) {
+		if autoName {
+			break
+		}
+		if !strings.ContainsRune(entryNameChars, r) {
 			return fmt.Errorf("entry name has invalid character '%v': %v", string(r), e.Path)
 		}
 	}
@@ -392,6 +530,18 @@ func addEntryR(tx *sql.Tx, ctx context.Context, e *forge.Entry) error {
 		}
 		e.Type = firstType
 	}
+	if autoName {
+		entName, err = nextEntryName(tx, ctx, parentPath, e.Type)
+		if err != nil {
+			return err
+		}
+		e.Path = path.Join(parentPath, entName)
+	} else {
+		err = checkEntryName(tx, ctx, e.Type, entName)
+		if err != nil {
+			return err
+		}
+	}
 	predefinedValue := ""
 	predefined, err := getProperty(tx, ctx, parent.Path, ".predefined_sub_entries")
 	if err != nil {
@@ -457,12 +607,20 @@ func addEntryR(tx *sql.Tx, ctx context.Context, e *forge.Entry) error {
 			return err
 		}
 		for _, d := range defProps {
+			if d.Type == "formula" && seenProp[d.Name] {
+				// formula is defined by the base type, and cannot be overrided.
+				continue
+			}
+			val, err := expandDefaultValue(tx, ctx, e, d)
+			if err != nil {
+				return err
+			}
 			if !seenProp[d.Name] {
 				dp := &forge.Property{
 					EntryPath: e.Path,
 					Name:      d.Name,
 					Type:      d.Type,
-					Value:     d.Value,
+					Value:     val,
 				}
 				err := addProperty(tx, ctx, dp)
 				if err != nil {
@@ -473,7 +631,7 @@ func addEntryR(tx *sql.Tx, ctx context.Context, e *forge.Entry) error {
 				upd := forge.PropertyUpdater{
 					EntryPath: e.Path,
 					Name:      d.Name,
-					Value:     &d.Value,
+					Value:     &val,
 				}
 				err := updateProperty(tx, ctx, upd)
 				if err != nil {
@@ -554,6 +712,10 @@ func addEntryR(tx *sql.Tx, ctx context.Context, e *forge.Entry) error {
 			return err
 		}
 	}
+	err = refreshFormulas(tx, ctx, e.Path, false)
+	if err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -674,6 +836,14 @@ func renameEntry(tx *sql.Tx, ctx context.Context, path, newName string) error {
 	if err != nil {
 		return err
 	}
+	entType, err := getEntryType(tx, ctx, path)
+	if err != nil {
+		return err
+	}
+	err = checkEntryName(tx, ctx, entType, newName)
+	if err != nil {
+		return err
+	}
 	newPath := filepath.Join(parent, newName)
 	_, err = getEntry(tx, ctx, newPath)
 	if err != nil {
@@ -684,7 +854,11 @@ func renameEntry(tx *sql.Tx, ctx context.Context, path, newName string) error {
 	} else {
 		return fmt.Errorf("rename target path already exists: %v", newPath)
 	}
-	err = updateEntryPath(tx, ctx, path, newPath)
+	err = addPathHistory(tx, ctx, path)
+	if err != nil {
+		return err
+	}
+	err = moveEntry(tx, ctx, path, newPath)
 	if err != nil {
 		return err
 	}
@@ -704,6 +878,172 @@ func renameEntry(tx *sql.Tx, ctx context.Context, path, newName string) error {
 	if err != nil {
 		return err
 	}
+	// formulas of the entry could search its siblings and sub entries with their paths.
+	err = refreshFormulas(tx, ctx, newPath, true)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// RenameEntries renames sub entries of the parent in a transaction.
+// Renames maps old names to new names. A new name can be an old name of another entry
+// that is also renamed, so entries can swap their names.
+func RenameEntries(db *sql.DB, ctx context.Context, parent string, renames map[string]string) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	err = renameEntries(tx, ctx, parent, renames)
+	if err != nil {
+		return err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func renameEntries(tx *sql.Tx, ctx context.Context, parent string, renames map[string]string) error {
+	if parent == "" {
+		return fmt.Errorf("need a parent path for rename")
+	}
+	if !strings.HasPrefix(parent, "/") {
+		return fmt.Errorf("entry path should be started with /")
+	}
+	if parent != "/" && strings.HasSuffix(parent, "/") {
+		return fmt.Errorf("entry path shouldn't be ended with /")
+	}
+	user := forge.UserNameFromContext(ctx)
+	if user == "" {
+		return forge.Unauthorized("context user unspecified")
+	}
+	err := userWrite(tx, ctx, parent)
+	if err != nil {
+		return err
+	}
+	// An entry keeping its name is not renamed, so it shouldn't be treated as the one
+	// giving the name to another entry either. Don't modify the caller's map.
+	moves := make(map[string]string, len(renames))
+	for old, newName := range renames {
+		if old == newName {
+			continue
+		}
+		moves[old] = newName
+	}
+	olds := make([]string, 0, len(moves))
+	for old := range moves {
+		olds = append(olds, old)
+	}
+	if len(olds) == 0 {
+		return nil
+	}
+	sort.Strings(olds)
+	renamedFrom := make(map[string]string)
+	for _, old := range olds {
+		newName := moves[old]
+		if newName == "" {
+			return fmt.Errorf("need a new name for rename: %v", old)
+		}
+		if strings.Contains(newName, "/") {
+			return fmt.Errorf("entry name cannot have '/' in it: %v", newName)
+		}
+		for _, r := range newName {
+			if !strings.ContainsRune(entryNameChars, r) {
+				return fmt.Errorf("entry name has invalid character '%v': %v", string(r), newName)
+			}
+		}
+		if renamedFrom[newName] != "" {
+			return fmt.Errorf("cannot rename both %v and %v to %v", renamedFrom[newName], old, newName)
+		}
+		renamedFrom[newName] = old
+		entType, err := getEntryType(tx, ctx, path.Join(parent, old))
+		if err != nil {
+			return err
+		}
+		err = checkEntryName(tx, ctx, entType, newName)
+		if err != nil {
+			return err
+		}
+	}
+	for _, old := range olds {
+		newName := moves[old]
+		if _, ok := moves[newName]; ok {
+			// the entry having the name will be renamed as well.
+			continue
+		}
+		newPath := path.Join(parent, newName)
+		_, err := getEntryID(tx, ctx, newPath)
+		if err != nil {
+			var e *forge.NotFoundError
+			if !errors.As(err, &e) {
+				return err
+			}
+			continue
+		}
+		return fmt.Errorf("rename target path already exists: %v", newPath)
+	}
+	// Move the entries to temporary names at first, so they can swap their names.
+	// An entry name cannot have '~', the temporary names will not conflict with others.
+	for _, old := range olds {
+		err := addPathHistory(tx, ctx, path.Join(parent, old))
+		if err != nil {
+			return err
+		}
+		err = moveEntry(tx, ctx, path.Join(parent, old), path.Join(parent, "~"+old))
+		if err != nil {
+			return err
+		}
+	}
+	renameMap := make([]string, 0, len(olds))
+	for _, old := range olds {
+		newName := moves[old]
+		newPath := path.Join(parent, newName)
+		err := moveEntry(tx, ctx, path.Join(parent, "~"+old), newPath)
+		if err != nil {
+			return err
+		}
+		err = addLog(tx, ctx, &forge.Log{
+			EntryPath: newPath,
+			User:      user,
+			Action:    "rename",
+			Category:  "entry",
+			Name:      newName,
+		})
+		if err != nil {
+			return err
+		}
+		renameMap = append(renameMap, old+","+newName)
+	}
+	// Keep the whole map in the parent, so it can be checked (or downloaded) later.
+	err = addLog(tx, ctx, &forge.Log{
+		EntryPath: parent,
+		User:      user,
+		Action:    "rename",
+		Category:  "sub_entry",
+		Name:      "renames",
+		Type:      "text",
+		Value:     strings.Join(renameMap, "\n"),
+	})
+	if err != nil {
+		return err
+	}
+	// formulas could search the renamed entries with their paths.
+	err = refreshFormulas(tx, ctx, parent, true)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// moveEntry changes path of an entry and it's sub entries without any check.
+func moveEntry(tx *sql.Tx, ctx context.Context, path, newPath string) error {
+	err := updateEntryPath(tx, ctx, path, newPath)
+	if err != nil {
+		return err
+	}
 	// root entry successfully renamed,
 	// let's do it for all sub entries.
 	like := path + `/*`
@@ -810,6 +1150,11 @@ func archiveEntry(tx *sql.Tx, ctx context.Context, path string) error {
 	if err != nil {
 		return err
 	}
+	// formulas don't count archived entries.
+	err = refreshFormulas(tx, ctx, path, false)
+	if err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -861,6 +1206,11 @@ func unarchiveEntry(tx *sql.Tx, ctx context.Context, path string) error {
 	if err != nil {
 		return err
 	}
+	// formulas don't count archived entries.
+	err = refreshFormulas(tx, ctx, path, false)
+	if err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -882,6 +1232,15 @@ func DeleteEntry(db *sql.DB, ctx context.Context, path string) error {
 }
 
 func deleteEntry(tx *sql.Tx, ctx context.Context, path string) error {
+	err := checkNoReferences(tx, ctx, path, false)
+	if err != nil {
+		return err
+	}
+	return removeEntry(tx, ctx, path)
+}
+
+// removeEntry deletes an entry without checking references to the entry.
+func removeEntry(tx *sql.Tx, ctx context.Context, path string) error {
 	// Delete an entry actually affects many sub entries,
 	// should be picky.
 	if path == "" {
@@ -918,7 +1277,7 @@ func deleteEntry(tx *sql.Tx, ctx context.Context, path string) error {
 	if err != nil {
 		return err
 	}
-	relatedTables := []string{"thumbnails", "properties", "environs", "access_controls", "logs"}
+	relatedTables := []string{"thumbnails", "properties", "environs", "access_controls", "logs", "entry_path_history"}
 	for _, table := range relatedTables {
 		stmt := fmt.Sprintf(`
 			DELETE FROM %v
@@ -931,6 +1290,10 @@ func deleteEntry(tx *sql.Tx, ctx context.Context, path string) error {
 			return err
 		}
 	}
+	err = deleteEntryRelations(tx, ctx, e.ID)
+	if err != nil {
+		return err
+	}
 	result, err := tx.Exec(`
 		DELETE FROM entries
 		WHERE id=?
@@ -947,6 +1310,11 @@ func deleteEntry(tx *sql.Tx, ctx context.Context, path string) error {
 	if n != 1 {
 		return fmt.Errorf("want 1 property affected, got %v", n)
 	}
+	// the entry is deleted already, but it's ancestors and siblings could have counted it.
+	err = refreshFormulas(tx, ctx, path, false)
+	if err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -967,18 +1335,54 @@ func DeleteEntryRecursive(db *sql.DB, ctx context.Context, path string) error {
 	return nil
 }
 
+// ForceDeleteEntryRecursive deletes the entry and it's sub entries, even when other entries refer to them.
+// The references won't point any entry after that, so only admins can do it.
+func ForceDeleteEntryRecursive(db *sql.DB, ctx context.Context, path string) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	user := forge.UserNameFromContext(ctx)
+	admin, err := isAdmin(tx, ctx, user)
+	if err != nil {
+		return err
+	}
+	if !admin {
+		return forge.Unauthorized("only admins can delete entries referenced by other entries: %v", path)
+	}
+	err = removeEntryR(tx, ctx, path)
+	if err != nil {
+		return err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
 func deleteEntryR(tx *sql.Tx, ctx context.Context, path string) error {
+	// References between the entries those will be deleted together are fine.
+	err := checkNoReferences(tx, ctx, path, true)
+	if err != nil {
+		return err
+	}
+	return removeEntryR(tx, ctx, path)
+}
+
+func removeEntryR(tx *sql.Tx, ctx context.Context, path string) error {
 	subEnts, err := findEntries(tx, ctx, forge.EntryFinder{ParentPath: &path, Archived: true})
 	if err != nil {
 		return err
 	}
 	for _, ent := range subEnts {
-		err := deleteEntryR(tx, ctx, ent.Path)
+		err := removeEntryR(tx, ctx, ent.Path)
 		if err != nil {
 			return err
 		}
 	}
-	err = deleteEntry(tx, ctx, path)
+	err = removeEntry(tx, ctx, path)
 	if err != nil {
 		return err
 	}
@@ -247,6 +247,11 @@ func addEnviron(tx *sql.Tx, ctx context.Context, e *forge.Property) error {
 	if err != nil {
 		return err
 	}
+	// environs are inherited to sub entries.
+	err = refreshFormulas(tx, ctx, e.EntryPath, true)
+	if err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -329,6 +334,11 @@ func updateEnviron(tx *sql.Tx, ctx context.Context, upd forge.PropertyUpdater) e
 	if err != nil {
 		return err
 	}
+	// environs are inherited to sub entries.
+	err = refreshFormulas(tx, ctx, e.EntryPath, true)
+	if err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -393,5 +403,9 @@ func deleteEnviron(tx *sql.Tx, ctx context.Context, path, name string) error {
 	if err != nil {
 		return err
 	}
+	err = refreshFormulas(tx, ctx, path, true)
+	if err != nil {
+		return err
+	}
 	return nil
 }
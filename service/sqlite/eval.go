@@ -26,13 +26,16 @@ func evalProperty(tx *sql.Tx, ctx context.Context, p *forge.Property) {
 		"timecode":   evalTimecode,
 		"text":       evalText,
 		"user":       evalUser,
+		"users":      evalUsers,
 		"entry_path": evalEntryPath,
 		"entry_name": evalEntryName,
 		"entry_link": evalEntryLink,
+		"lookup":     evalLookup,
 		"date":       evalDate,
 		"int":        evalInt,
 		"tag":        evalTag,
 		"search":     evalSearch,
+		"formula":    evalFormula,
 	}
 	eval := evalFn[p.Type]
 	if eval == nil {
@@ -46,6 +49,108 @@ func evalProperty(tx *sql.Tx, ctx context.Context, p *forge.Property) {
 	eval(tx, ctx, p)
 }
 
+// inheritProperty takes the value of the nearest ancestor for an empty inheritable property.
+// The ancestor should have a non-empty property with the same name and type.
+// Ancestors those the context user cannot read, or their properties, are skipped.
+// It doesn't change the raw value, so the property is still empty in db.
+func inheritProperty(tx *sql.Tx, ctx context.Context, p *forge.Property) {
+	if p.EntryPath == "/" {
+		return
+	}
+	ancestors := make([]any, 0)
+	pth := p.EntryPath
+	for pth != "/" {
+		pth = filepath.Dir(pth)
+		ancestors = append(ancestors, pth)
+	}
+	vals := []any{p.Name, p.Type}
+	vals = append(vals, ancestors...)
+	rows, err := tx.QueryContext(ctx, `
+		SELECT
+			entries.path,
+			properties.val,
+			default_properties.read_groups,
+			default_properties.write_groups
+		FROM properties
+		LEFT JOIN entries ON properties.entry_id = entries.id
+		LEFT JOIN default_properties ON properties.default_id = default_properties.id
+		WHERE
+			default_properties.name=? AND
+			default_properties.type=? AND
+			properties.val!='' AND
+			entries.path IN (?`+strings.Repeat(", ?", len(ancestors)-1)+`)
+		ORDER BY length(entries.path) DESC
+	`,
+		vals...,
+	)
+	if err != nil {
+		p.ValueError = err
+		return
+	}
+	defer rows.Close()
+	candidates := make([]*forge.Property, 0)
+	for rows.Next() {
+		ap := &forge.Property{Name: p.Name, Type: p.Type}
+		var readGroups, writeGroups string
+		err = rows.Scan(&ap.EntryPath, &ap.RawValue, &readGroups, &writeGroups)
+		if err != nil {
+			p.ValueError = err
+			return
+		}
+		ap.ReadGroups = groupList(readGroups)
+		ap.WriteGroups = groupList(writeGroups)
+		candidates = append(candidates, ap)
+	}
+	err = rows.Err()
+	if err != nil {
+		p.ValueError = err
+		return
+	}
+	rows.Close()
+	for _, ap := range candidates {
+		// the user could be denied to read the ancestor, even though the user can read the entry.
+		err := userRead(tx, ctx, ap.EntryPath)
+		if err != nil {
+			var e *forge.NotFoundError
+			if !errors.As(err, &e) {
+				p.ValueError = err
+				return
+			}
+			continue
+		}
+		// the ancestor could be another entry type, that limits the property to read groups.
+		readable, err := propertyReadable(tx, ctx, ap.PropertyMeta)
+		if err != nil {
+			p.ValueError = err
+			return
+		}
+		if !readable {
+			continue
+		}
+		evalProperty(tx, ctx, ap)
+		p.Eval = ap.Eval
+		p.Value = ap.Value
+		p.ValueError = ap.ValueError
+		p.InheritedFrom = ap.EntryPath
+		return
+	}
+}
+
+// isInheritableProperty checks whether a property of any entry type is inheritable with the name.
+func isInheritableProperty(tx *sql.Tx, ctx context.Context, name string) (bool, error) {
+	var n int
+	err := tx.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM default_properties
+		WHERE name=? AND inheritable
+	`,
+		name,
+	).Scan(&n)
+	if err != nil {
+		return false, err
+	}
+	return n != 0, nil
+}
+
 func evalText(tx *sql.Tx, ctx context.Context, p *forge.Property) {
 	val := p.RawValue
 	p.Eval = val
@@ -71,14 +176,43 @@ func evalUser(tx *sql.Tx, ctx context.Context, p *forge.Property) {
 	p.Value = u.Name
 }
 
+func evalUsers(tx *sql.Tx, ctx context.Context, p *forge.Property) {
+	val := p.RawValue
+	if strings.HasPrefix(val, "[") && strings.HasSuffix(val, "]") {
+		val = val[1 : len(val)-1]
+	}
+	names := make([]string, 0)
+	calls := make([]string, 0)
+	for _, v := range strings.Split(strings.TrimSpace(val), "\n") {
+		id, err := strconv.Atoi(strings.TrimSpace(v))
+		if err != nil {
+			p.ValueError = fmt.Errorf("invalid value for users: %v", p.RawValue)
+			return
+		}
+		a, err := getAccessorByID(tx, ctx, id)
+		if err != nil {
+			p.ValueError = err
+			return
+		}
+		called := a.Called
+		if called == "" {
+			called = a.Name
+		}
+		names = append(names, a.Name)
+		calls = append(calls, called)
+	}
+	p.Eval = strings.Join(calls, "\n")
+	p.Value = strings.Join(names, "\n")
+}
+
 func evalTimecode(tx *sql.Tx, ctx context.Context, p *forge.Property) {
-	// 00:00:00:00
+	// 00:00:00:00, or 00:00:00;00 for drop frame
 	val := p.RawValue
 	if len(val) != 11 {
 		p.ValueError = fmt.Errorf("invalid value for timecode: %v", val)
 		return
 	}
-	if val[2] != ':' && val[5] != ':' && val[8] != ':' {
+	if val[2] != ':' || val[5] != ':' || (val[8] != ':' && val[8] != ';') {
 		p.ValueError = fmt.Errorf("invalid value for timecode: %v", val)
 		return
 	}
@@ -139,6 +273,106 @@ func evalEntryLink(tx *sql.Tx, ctx context.Context, p *forge.Property) {
 	p.Value = eval
 }
 
+// evalLookup finds the referenced property at read time.
+// Value will be the lookup expression, Eval will be the referenced value.
+func evalLookup(tx *sql.Tx, ctx context.Context, p *forge.Property) {
+	ref, name, err := splitLookup(p.RawValue)
+	if err != nil {
+		p.ValueError = err
+		return
+	}
+	pth := ""
+	expr := p.RawValue
+	switch {
+	case strings.HasPrefix(ref, "^"):
+		typ := ref[1:]
+		parent := p.EntryPath
+		for parent != "/" {
+			parent = filepath.Dir(parent)
+			ent, err := getEntry(tx, ctx, parent)
+			if err != nil {
+				p.ValueError = err
+				return
+			}
+			if ent.Type == typ {
+				pth = ent.Path
+				break
+			}
+		}
+		if pth == "" {
+			p.ValueError = fmt.Errorf("no ancestor of type %v: %v", typ, p.EntryPath)
+			return
+		}
+	case ref[0] >= '0' && ref[0] <= '9':
+		ep := &forge.Property{EntryPath: p.EntryPath, RawValue: ref}
+		evalEntryPath(tx, ctx, ep)
+		if ep.ValueError != nil {
+			p.ValueError = ep.ValueError
+			return
+		}
+		pth = ep.Eval
+		expr = ep.Value + "." + name
+	default:
+		ep, err := getProperty(tx, ctx, p.EntryPath, ref)
+		if err != nil {
+			p.ValueError = err
+			return
+		}
+		if ep.Type != "entry_path" && ep.Type != "entry_name" {
+			p.ValueError = fmt.Errorf("lookup needs entry_path property, got %v type: %v", ep.Type, ref)
+			return
+		}
+		if ep.ValueError != nil {
+			p.ValueError = ep.ValueError
+			return
+		}
+		if ep.RawValue == "" {
+			// nothing to lookup yet
+			p.Value = expr
+			return
+		}
+		// entry_name has the same raw value with entry_path.
+		rp := &forge.Property{EntryPath: ep.EntryPath, RawValue: ep.RawValue}
+		evalEntryPath(tx, ctx, rp)
+		if rp.ValueError != nil {
+			p.ValueError = rp.ValueError
+			return
+		}
+		pth = rp.Eval
+	}
+	err = userRead(tx, ctx, pth)
+	if err != nil {
+		p.ValueError = err
+		return
+	}
+	target, err := getProperty(tx, ctx, pth, name)
+	if err != nil {
+		p.ValueError = err
+		return
+	}
+	readable, err := propertyReadable(tx, ctx, target.PropertyMeta)
+	if err != nil {
+		p.ValueError = err
+		return
+	}
+	if !readable {
+		p.ValueError = forge.NotFound("property not found: %v.%v", pth, name)
+		return
+	}
+	if target.Type == "lookup" {
+		// prevent a reference cycle.
+		p.ValueError = fmt.Errorf("cannot lookup another lookup property: %v.%v", pth, name)
+		return
+	}
+	if target.ValueError != nil {
+		p.ValueError = target.ValueError
+		return
+	}
+	p.Eval = target.Eval
+	p.Value = expr
+	p.Lookup = target
+}
+
 func evalDate(tx *sql.Tx, ctx context.Context, p *forge.Property) {
 	// 2006/01/02
 	val := p.RawValue
@@ -195,6 +429,18 @@ func evalSearch(tx *sql.Tx, ctx context.Context, p *forge.Property) {
 	p.Value = p.RawValue
 }
 
+// evalFormula returns the formula result cached in db.
+// The cache will be refreshed by refreshFormulas when related values are changed.
+func evalFormula(tx *sql.Tx, ctx context.Context, p *forge.Property) {
+	err := formulaValueError(p.RawValue)
+	if err != nil {
+		p.ValueError = err
+		return
+	}
+	p.Eval = p.RawValue
+	p.Value = p.RawValue
+}
+
 // evalSpecialProperty evaluates special properties that defined in forge.
 // It will return true when given property was special property.
 func evalSpecialProperty(tx *sql.Tx, ctx context.Context, p *forge.Property) bool {
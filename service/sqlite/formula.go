@@ -0,0 +1,361 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/imagvfx/forge"
+)
+
+// formulaErrorPrefix is the prefix of a formula property value in db, when the evaluation has failed.
+const formulaErrorPrefix = "!error: "
+
+// formulaContextKey marks a context that evaluates formulas.
+type formulaContextKey struct{}
+
+// formulaContext returns a context to evaluate formulas.
+//
+// The results are saved to db and shown to every reader, so formulas can only see
+// properties those everyone can see. Properties limited to read groups are hidden to them,
+// and so are entries hidden from some readers of the entry. See formulaEnv.Search.
+func formulaContext(ctx context.Context) context.Context {
+	ctx = forge.ContextWithUserName(ctx, "system")
+	return context.WithValue(ctx, formulaContextKey{}, true)
+}
+
+// isFormulaContext checks the context is one for evaluating formulas.
+func isFormulaContext(ctx context.Context) bool {
+	yes, _ := ctx.Value(formulaContextKey{}).(bool)
+	return yes
+}
+
+// formulaEnv provides values of an entry to evaluate a formula.
+type formulaEnv struct {
+	tx   *sql.Tx
+	ctx  context.Context
+	path string
+}
+
+func (e formulaEnv) Property(name string) (string, error) {
+	p, err := getProperty(e.tx, e.ctx, e.path, name)
+	if err != nil {
+		return "", err
+	}
+	readable, err := propertyReadable(e.tx, e.ctx, p.PropertyMeta)
+	if err != nil {
+		return "", err
+	}
+	if !readable {
+		return "", forge.NotFound("property not found: %v.%v", e.path, name)
+	}
+	if p.ValueError != nil {
+		return "", p.ValueError
+	}
+	return p.Value, nil
+}
+
+func (e formulaEnv) Environ(name string) (string, error) {
+	envs, err := entryEnvirons(e.tx, e.ctx, e.path)
+	if err != nil {
+		return "", err
+	}
+	for _, env := range envs {
+		if env.Name == name {
+			return env.Value, nil
+		}
+	}
+	return "", forge.NotFound("environ not found: %v", name)
+}
+
+func (e formulaEnv) Search(query, name string) ([]string, error) {
+	root := e.path
+	self := ""
+	if q, ok := strings.CutPrefix(query, "../"); ok {
+		// search siblings
+		root = path.Dir(e.path)
+		self = e.path
+		query = q
+	}
+	kwds := strings.Fields(query)
+	if len(kwds) == 0 {
+		// search every sub entry
+		kwds = []string{"path:/"}
+	}
+	ents, err := searchEntries(e.tx, e.ctx, forge.EntrySearcher{SearchRoot: root, Keywords: kwds})
+	if err != nil {
+		return nil, err
+	}
+	// The result is shown to every reader of the entry,
+	// so entries hidden from any of them shouldn't be searched.
+	r, err := newAccessResolver(e.tx, e.ctx, forge.UserNameFromContext(e.ctx))
+	if err != nil {
+		return nil, err
+	}
+	paths := []string{e.path}
+	for _, ent := range ents {
+		paths = append(paths, ent.Path)
+	}
+	err = r.load(paths...)
+	if err != nil {
+		return nil, err
+	}
+	if self != "" && accessBetween(r.acl, root, e.path, "r", "rw") {
+		// some readers of the entry might not be able to read the parent and the siblings.
+		return []string{}, nil
+	}
+	vals := make([]string, 0, len(ents))
+	for _, ent := range ents {
+		if ent.Path == self {
+			continue
+		}
+		if accessBetween(r.acl, root, ent.Path, "none") {
+			continue
+		}
+		v := ""
+		if name != "" {
+			p := ent.Property[name]
+			if p != nil {
+				v = p.Value
+			}
+		}
+		vals = append(vals, v)
+	}
+	return vals, nil
+}
+
+// accessBetween checks any entry below the ancestor down to the entry has an access control of the modes.
+//
+// Lower access control takes precedence, so only 'none' can hide an entry from readers of the ancestor,
+// and only 'r' or 'rw' can let users who cannot read the ancestor read the entry.
+func accessBetween(acl map[string][]*forge.Access, ancestor, pth string, modes ...string) bool {
+	for pth != ancestor && pth != "/" {
+		for _, a := range acl[pth] {
+			for _, m := range modes {
+				if a.Value == m {
+					return true
+				}
+			}
+		}
+		pth = path.Dir(pth)
+	}
+	return false
+}
+
+// evalFormulaExpr evaluates a formula expression for an entry, and returns the value to be saved in db.
+func evalFormulaExpr(tx *sql.Tx, ctx context.Context, path, expr string) string {
+	f, err := forge.ParseFormula(expr)
+	if err != nil {
+		return formulaErrorPrefix + err.Error()
+	}
+	v, err := f.Eval(formulaEnv{tx: tx, ctx: ctx, path: path})
+	if err != nil {
+		return formulaErrorPrefix + err.Error()
+	}
+	return forge.FormatFormulaResult(v)
+}
+
+// refreshFormulas re-evaluates formula properties those could be affected by a change of an entry,
+// and saves the results to db. So they can be searched and don't need evaluation at every read.
+//
+// Formulas of the entry and it's ancestors are affected by a change of the entry,
+// and so are formulas of the siblings those search siblings with "../".
+// The entry doesn't have to exist, when it is deleted for example.
+// When subtree is true, formulas of the sub entries are re-evaluated as well. (ex. environ change)
+func refreshFormulas(tx *sql.Tx, ctx context.Context, pth string, subtree bool) error {
+	// formulas should be evaluated in the same way, regardless of the user.
+	ctx = formulaContext(ctx)
+	keys := make([]string, 0)
+	vals := make([]any, 0)
+	p := pth
+	for {
+		keys = append(keys, "entries.path=?")
+		vals = append(vals, p)
+		if p == "/" {
+			break
+		}
+		p = path.Dir(p)
+	}
+	if pth != "/" {
+		parent := strings.TrimSuffix(path.Dir(pth), "/")
+		keys = append(keys, "(entries.path GLOB ? AND entries.path NOT GLOB ? AND INSTR(default_properties.value, '\"../') > 0)")
+		vals = append(vals, parent+"/*", parent+"/*/*")
+	}
+	if subtree {
+		keys = append(keys, "entries.path GLOB ?")
+		vals = append(vals, strings.TrimSuffix(pth, "/")+"/*")
+	}
+	return updateFormulas(tx, ctx, strings.Join(keys, " OR "), vals)
+}
+
+// updateFormulas re-evaluates formula properties of entries found with the where clause.
+func updateFormulas(tx *sql.Tx, ctx context.Context, where string, vals []any) error {
+	rows, err := tx.QueryContext(ctx, `
+		SELECT
+			properties.id,
+			properties.val,
+			entries.path,
+			default_properties.value
+		FROM properties
+		LEFT JOIN entries ON properties.entry_id = entries.id
+		LEFT JOIN default_properties ON properties.default_id = default_properties.id
+		WHERE default_properties.type='formula' AND (`+where+`)
+		ORDER BY entries.path, properties.id
+	`,
+		vals...,
+	)
+	if err != nil {
+		return err
+	}
+	type formula struct {
+		id   int
+		val  string
+		path string
+		expr string
+	}
+	formulas := make([]*formula, 0)
+	for rows.Next() {
+		f := &formula{}
+		err := rows.Scan(
+			&f.id,
+			&f.val,
+			&f.path,
+			&f.expr,
+		)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		formulas = append(formulas, f)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	// evaluate deeper entries first, as parents could aggregate values of the sub entries.
+	depth := func(p string) int {
+		if p == "/" {
+			return 0
+		}
+		return strings.Count(p, "/")
+	}
+	sort.SliceStable(formulas, func(i, j int) bool {
+		return depth(formulas[i].path) > depth(formulas[j].path)
+	})
+	for len(formulas) != 0 {
+		// formulas of an entry could refer each other, evaluate them until nothing changes.
+		// n formulas settle in n passes, unless they make a cycle.
+		n := 1
+		for n < len(formulas) && formulas[n].path == formulas[0].path {
+			n++
+		}
+		for range n {
+			changed := false
+			for _, f := range formulas[:n] {
+				val := evalFormulaExpr(tx, ctx, f.path, f.expr)
+				if val == f.val {
+					continue
+				}
+				_, err := tx.ExecContext(ctx, `
+					UPDATE properties
+					SET val=?, updated_at=?
+					WHERE id=?
+				`,
+					val,
+					time.Now().UTC(),
+					f.id,
+				)
+				if err != nil {
+					return err
+				}
+				f.val = val
+				changed = true
+			}
+			if !changed {
+				break
+			}
+		}
+		formulas = formulas[n:]
+	}
+	return nil
+}
+
+// refreshFormulasOfType re-evaluates formula properties of all entries of the entry type,
+// and their ancestors.
+func refreshFormulasOfType(tx *sql.Tx, ctx context.Context, entryType string) error {
+	ctx = formulaContext(ctx)
+	err := updateFormulas(tx, ctx, "entries.type_id=(SELECT id FROM entry_types WHERE name=?)", []any{entryType})
+	if err != nil {
+		return err
+	}
+	ents, err := findEntries(tx, ctx, forge.EntryFinder{Types: []string{entryType}, Archived: true})
+	if err != nil {
+		return err
+	}
+	parents := make(map[string]bool)
+	for _, ent := range ents {
+		parents[path.Dir(ent.Path)] = true
+	}
+	for p := range parents {
+		err := refreshFormulas(tx, ctx, p, false)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkFormulaProperties checks the formula doesn't refer to properties limited to read groups,
+// as the result will be shown to users those cannot see them.
+func checkFormulaProperties(tx *sql.Tx, ctx context.Context, f *forge.Formula) error {
+	for _, name := range f.Properties() {
+		defs, err := findDefaultProperties(tx, ctx, forge.DefaultFinder{Name: &name})
+		if err != nil {
+			return err
+		}
+		for _, d := range defs {
+			if len(d.ReadGroups) != 0 {
+				return fmt.Errorf("formula cannot refer to a property limited to read groups: %v", name)
+			}
+		}
+	}
+	return nil
+}
+
+// checkFormulaReferences checks no formula refers to the property, before it is limited to read groups.
+func checkFormulaReferences(tx *sql.Tx, ctx context.Context, name string) error {
+	defs, err := findDefaultProperties(tx, ctx, forge.DefaultFinder{})
+	if err != nil {
+		return err
+	}
+	for _, d := range defs {
+		if d.Type != "formula" {
+			continue
+		}
+		f, err := forge.ParseFormula(d.Value)
+		if err != nil {
+			// invalid formulas don't refer anything.
+			continue
+		}
+		for _, n := range f.Properties() {
+			if n == name {
+				return fmt.Errorf("property is referred by formula %v.%v, cannot limit it to read groups", d.EntryType, d.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// formulaValueError returns the error saved as the value of a formula property.
+func formulaValueError(raw string) error {
+	msg, ok := strings.CutPrefix(raw, formulaErrorPrefix)
+	if !ok {
+		return nil
+	}
+	return errors.New(msg)
+}
@@ -142,6 +142,10 @@ func AddGlobal(db *sql.DB, ctx context.Context, g *forge.Global) error {
 }
 
 func addGlobal(tx *sql.Tx, ctx context.Context, g *forge.Global) error {
+	err := validateGlobal(g.Name, g.Value)
+	if err != nil {
+		return err
+	}
 	typeID, err := getEntryTypeID(tx, ctx, g.EntryType)
 	if err != nil {
 		return err
@@ -171,6 +175,16 @@ func addGlobal(tx *sql.Tx, ctx context.Context, g *forge.Global) error {
 	return nil
 }
 
+// validateGlobal validates value of a global those are used by forge itself.
+func validateGlobal(name, value string) error {
+	switch name {
+	case "naming_rule":
+		_, err := forge.ParseNamingRule(value)
+		return err
+	}
+	return nil
+}
+
 func UpdateGlobal(db *sql.DB, ctx context.Context, upd forge.GlobalUpdater) error {
 	tx, err := db.BeginTx(ctx, nil)
 	if err != nil {
@@ -188,7 +202,10 @@ func UpdateGlobal(db *sql.DB, ctx context.Context, upd forge.GlobalUpdater) erro
 	if !yes {
 		return forge.Unauthorized("user doesn't have permission to update global: %v", user)
 	}
-	updateGlobal(tx, ctx, upd)
+	err = updateGlobal(tx, ctx, upd)
+	if err != nil {
+		return err
+	}
 	err = tx.Commit()
 	if err != nil {
 		return err
@@ -204,6 +221,10 @@ func updateGlobal(tx *sql.Tx, ctx context.Context, upd forge.GlobalUpdater) erro
 		vals = append(vals, *upd.Type)
 	}
 	if upd.Value != nil {
+		err := validateGlobal(upd.Name, *upd.Value)
+		if err != nil {
+			return err
+		}
 		keys = append(keys, "value=?")
 		vals = append(vals, *upd.Value)
 	}
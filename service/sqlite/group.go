@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"sort"
 	"strings"
 
 	"github.com/imagvfx/forge"
@@ -274,3 +275,237 @@ func updateGroup(tx *sql.Tx, ctx context.Context, upd forge.GroupUpdater) error
 	}
 	return nil
 }
+
+func DeleteGroup(db *sql.DB, ctx context.Context, name string, cascade bool) (*forge.GroupReferences, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	user := forge.UserNameFromContext(ctx)
+	if user == "" {
+		return nil, forge.Unauthorized("context user unspecified")
+	}
+	yes, err := isAdmin(tx, ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	if !yes {
+		return nil, forge.Unauthorized("user doesn't have permission to delete group: %v", user)
+	}
+	refs, err := deleteGroup(tx, ctx, name, cascade)
+	if err != nil {
+		return nil, err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+	return refs, nil
+}
+
+// groupReferences finds where the group is referenced.
+func groupReferences(tx *sql.Tx, ctx context.Context, g *forge.Group) (*forge.GroupReferences, error) {
+	refs := &forge.GroupReferences{
+		Group:             g.Name,
+		Accesses:          make([]string, 0),
+		DefaultAccesses:   make([]string, 0),
+		Parents:           make([]string, 0),
+		Properties:        make([]string, 0),
+		DefaultProperties: make([]string, 0),
+	}
+	rows, err := tx.QueryContext(ctx, `
+		SELECT
+			entries.path
+		FROM access_controls
+		LEFT JOIN entries ON access_controls.entry_id = entries.id
+		WHERE access_controls.accessor_id=?
+		ORDER BY entries.path ASC
+	`,
+		g.ID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var pth string
+		err := rows.Scan(&pth)
+		if err != nil {
+			return nil, err
+		}
+		refs.Accesses = append(refs.Accesses, pth)
+	}
+	rows, err = tx.QueryContext(ctx, `
+		SELECT
+			entry_types.name
+		FROM default_accesses
+		LEFT JOIN entry_types ON default_accesses.entry_type_id = entry_types.id
+		WHERE default_accesses.accessor_id=?
+		ORDER BY entry_types.name ASC
+	`,
+		g.ID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var typ string
+		err := rows.Scan(&typ)
+		if err != nil {
+			return nil, err
+		}
+		refs.DefaultAccesses = append(refs.DefaultAccesses, typ)
+	}
+	rows, err = tx.QueryContext(ctx, `
+		SELECT
+			groups.name
+		FROM group_members
+		LEFT JOIN accessors AS groups ON group_members.group_id = groups.id
+		WHERE group_members.member_id=?
+		ORDER BY groups.name ASC
+	`,
+		g.ID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var parent string
+		err := rows.Scan(&parent)
+		if err != nil {
+			return nil, err
+		}
+		refs.Parents = append(refs.Parents, parent)
+	}
+	props, err := accessorProperties(tx, ctx, g.ID)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range props {
+		refs.Properties = append(refs.Properties, p.EntryPath+"."+p.Name)
+	}
+	defs, err := findDefaultProperties(tx, ctx, forge.DefaultFinder{})
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range defs {
+		readGroups := removeGroup(d.ReadGroups, g.Name)
+		writeGroups := removeGroup(d.WriteGroups, g.Name)
+		if len(readGroups) != len(d.ReadGroups) || len(writeGroups) != len(d.WriteGroups) {
+			refs.DefaultProperties = append(refs.DefaultProperties, d.EntryType+"."+d.Name)
+		}
+	}
+	sort.Strings(refs.DefaultProperties)
+	return refs, nil
+}
+
+// deleteGroup deletes a group and it's memberships.
+// When the group is referenced, it refuses to delete the group unless cascade is true.
+// Then the references will be removed with the group, and reported.
+// A default property limited only to the group will be limited to admin group.
+func deleteGroup(tx *sql.Tx, ctx context.Context, name string, cascade bool) (*forge.GroupReferences, error) {
+	if name == "admin" || strings.Split(name, "@")[0] == "everyone" {
+		return nil, fmt.Errorf("delete 'admin' or 'everyone[@host]' group is not supported: %v", name)
+	}
+	g, err := getGroup(tx, ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	refs, err := groupReferences(tx, ctx, g)
+	if err != nil {
+		return nil, err
+	}
+	if !refs.Empty() && !cascade {
+		return nil, fmt.Errorf("group is referenced by %v, delete it with cascade to remove them: %v", refs, name)
+	}
+	// default accesses should be deleted first, as they prevent deleting the entry accesses.
+	for _, typ := range refs.DefaultAccesses {
+		err := deleteDefaultAccess(tx, ctx, typ, name)
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, pth := range refs.Accesses {
+		err := deleteAccess(tx, ctx, pth, name)
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, parent := range refs.Parents {
+		err := deleteGroupMember(tx, ctx, parent, name)
+		if err != nil {
+			return nil, err
+		}
+	}
+	props, err := accessorProperties(tx, ctx, g.ID)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range props {
+		// only users properties can have a group.
+		remove := "-" + name
+		err := updateProperty(tx, ctx, forge.PropertyUpdater{EntryPath: p.EntryPath, Name: p.Name, Value: &remove})
+		if err != nil {
+			return nil, err
+		}
+	}
+	defs, err := findDefaultProperties(tx, ctx, forge.DefaultFinder{})
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range defs {
+		readGroups := removeGroup(d.ReadGroups, name)
+		writeGroups := removeGroup(d.WriteGroups, name)
+		if len(readGroups) == len(d.ReadGroups) && len(writeGroups) == len(d.WriteGroups) {
+			continue
+		}
+		// empty groups means no limit, keep the property limited to admins instead.
+		if len(readGroups) == 0 && len(d.ReadGroups) != 0 {
+			readGroups = []string{"admin"}
+		}
+		if len(writeGroups) == 0 && len(d.WriteGroups) != 0 {
+			writeGroups = []string{"admin"}
+		}
+		_, err := tx.ExecContext(ctx, `
+			UPDATE default_properties
+			SET read_groups=?, write_groups=?
+			WHERE id=?
+		`,
+			groupString(readGroups),
+			groupString(writeGroups),
+			d.ID,
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
+	_, err = tx.ExecContext(ctx, `
+		DELETE FROM group_members
+		WHERE group_id=?
+	`,
+		g.ID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	result, err := tx.ExecContext(ctx, `
+		DELETE FROM accessors
+		WHERE is_group=1 AND id=?
+	`,
+		g.ID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if n != 1 {
+		return nil, fmt.Errorf("want 1 group affected, got %v", n)
+	}
+	return refs, nil
+}
@@ -14,6 +14,7 @@ func createLogsTable(tx *sql.Tx) error {
 			id INTEGER PRIMARY KEY,
 			entry_id INTEGER,
 			user TEXT NOT NULL,
+			impersonator TEXT NOT NULL DEFAULT '',
 			action TEXT NOT NULL,
 			ctg TEXT NOT NULL,
 			name TEXT NOT NULL,
@@ -26,6 +27,12 @@ func createLogsTable(tx *sql.Tx) error {
 	if err != nil {
 		return err
 	}
+	_, err = tx.Exec(`ALTER TABLE logs ADD COLUMN impersonator TEXT NOT NULL DEFAULT ''`)
+	if err != nil {
+		if !strings.Contains(err.Error(), "duplicate column name") {
+			return err
+		}
+	}
 	_, err = tx.Exec(`CREATE INDEX IF NOT EXISTS index_logs_entry_id ON logs (entry_id)`)
 	if err != nil {
 		return err
@@ -74,8 +81,9 @@ func findLogs(tx *sql.Tx, ctx context.Context, find forge.LogFinder) ([]*forge.L
 	rows, err := tx.QueryContext(ctx, `
 		SELECT
 			logs.id,
-			entries.path,
+			COALESCE(entries.path, ''),
 			logs.user,
+			logs.impersonator,
 			logs.action,
 			logs.ctg,
 			logs.name,
@@ -98,6 +106,7 @@ func findLogs(tx *sql.Tx, ctx context.Context, find forge.LogFinder) ([]*forge.L
 			&l.ID,
 			&l.EntryPath,
 			&l.User,
+			&l.Impersonator,
 			&l.Action,
 			&l.Category,
 			&l.Name,
@@ -110,7 +119,13 @@ func findLogs(tx *sql.Tx, ctx context.Context, find forge.LogFinder) ([]*forge.L
 		}
 		logs = append(logs, l)
 	}
-	return logs, nil
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+	// rows should be closed before querying the properties.
+	rows.Close()
+	return visibleLogs(tx, ctx, logs)
 }
 
 func GetLogs(db *sql.DB, ctx context.Context, path, ctg, name string) ([]*forge.Log, error) {
@@ -145,25 +160,38 @@ func getLogs(tx *sql.Tx, ctx context.Context, path, ctg, name string) ([]*forge.
 	return logs, nil
 }
 
+// addLog adds a log.
+// When the context user is impersonated, the admin who impersonated is recorded as well.
+// A log with empty EntryPath isn't bound to an entry, like logs of group members.
 func addLog(tx *sql.Tx, ctx context.Context, l *forge.Log) error {
-	entryID, err := getEntryID(tx, ctx, l.EntryPath)
-	if err != nil {
-		return err
+	imp := forge.ImpersonationFromContext(ctx)
+	if imp != nil {
+		l.Impersonator = imp.Admin
+	}
+	var entryID any
+	if l.EntryPath != "" {
+		id, err := getEntryID(tx, ctx, l.EntryPath)
+		if err != nil {
+			return err
+		}
+		entryID = id
 	}
 	result, err := tx.ExecContext(ctx, `
 		INSERT INTO logs (
 			entry_id,
 			user,
+			impersonator,
 			action,
 			ctg,
 			name,
 			typ,
 			val
 		)
-		VALUES (?, ?, ?, ?, ?, ?, ?)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
 	`,
 		entryID,
 		l.User,
+		l.Impersonator,
 		l.Action,
 		l.Category,
 		l.Name,
@@ -3,6 +3,7 @@ package sqlite
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"strings"
 
@@ -45,6 +46,9 @@ func FindGroupMembers(db *sql.DB, ctx context.Context, find forge.MemberFinder)
 }
 
 func findGroupMembers(tx *sql.Tx, ctx context.Context, find forge.MemberFinder) ([]*forge.Member, error) {
+	if find.Expand {
+		return expandGroupMembers(tx, ctx, find)
+	}
 	keys := make([]string, 0)
 	vals := make([]any, 0)
 	keys = append(keys, "groups.name=?")
@@ -60,7 +64,8 @@ func findGroupMembers(tx *sql.Tx, ctx context.Context, find forge.MemberFinder)
 	rows, err := tx.QueryContext(ctx, `
 		SELECT
 			groups.name,
-			members.name
+			members.name,
+			members.is_group
 		FROM group_members
 		LEFT JOIN accessors AS groups ON group_members.group_id = groups.id
 		LEFT JOIN accessors AS members ON group_members.member_id = members.id
@@ -77,6 +82,7 @@ func findGroupMembers(tx *sql.Tx, ctx context.Context, find forge.MemberFinder)
 		err := rows.Scan(
 			&m.Group,
 			&m.Member,
+			&m.IsGroup,
 		)
 		if err != nil {
 			return nil, err
@@ -86,6 +92,77 @@ func findGroupMembers(tx *sql.Tx, ctx context.Context, find forge.MemberFinder)
 	return members, nil
 }
 
+// expandGroupMembers finds users those are members of the group directly or through sub groups.
+// A user who is a member through several paths is found only once, with the nearest path.
+func expandGroupMembers(tx *sql.Tx, ctx context.Context, find forge.MemberFinder) ([]*forge.Member, error) {
+	_, err := getGroup(tx, ctx, find.Group)
+	if err != nil {
+		return nil, err
+	}
+	type sub struct {
+		group string
+		via   string
+	}
+	members := make([]*forge.Member, 0)
+	found := make(map[string]bool)
+	visited := map[string]bool{find.Group: true}
+	queue := []sub{{group: find.Group}}
+	for len(queue) != 0 {
+		s := queue[0]
+		queue = queue[1:]
+		mems, err := findGroupMembers(tx, ctx, forge.MemberFinder{Group: s.group})
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range mems {
+			if m.IsGroup {
+				if visited[m.Member] {
+					continue
+				}
+				visited[m.Member] = true
+				via := s.via
+				if via == "" {
+					via = m.Member
+				}
+				queue = append(queue, sub{group: m.Member, via: via})
+				continue
+			}
+			if found[m.Member] {
+				continue
+			}
+			if find.Member != nil && m.Member != *find.Member {
+				continue
+			}
+			found[m.Member] = true
+			members = append(members, &forge.Member{Group: find.Group, Member: m.Member, Via: s.via})
+		}
+	}
+	return members, nil
+}
+
+// subGroups returns the groups those are members of the group directly or through other sub groups.
+func subGroups(tx *sql.Tx, ctx context.Context, group string) (map[string]bool, error) {
+	subs := make(map[string]bool)
+	queue := []string{group}
+	for len(queue) != 0 {
+		g := queue[0]
+		queue = queue[1:]
+		mems, err := findGroupMembers(tx, ctx, forge.MemberFinder{Group: g})
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range mems {
+			if !m.IsGroup || subs[m.Member] {
+				continue
+			}
+			subs[m.Member] = true
+			queue = append(queue, m.Member)
+		}
+	}
+	return subs, nil
+}
+
+// isGroupMember checks the user is a member of the group, directly or through sub groups.
 func isGroupMember(tx *sql.Tx, ctx context.Context, group, member string) (bool, error) {
 	// The group and member should be exist.
 	_, err := getGroup(tx, ctx, group)
@@ -96,27 +173,112 @@ func isGroupMember(tx *sql.Tx, ctx context.Context, group, member string) (bool,
 	if err != nil {
 		return false, err
 	}
-	// everyone
-	if group == "everyone" {
-		return true, nil
-	}
-	// everyone@domain
-	_, domain, err := splitUserName(member)
+	groups, err := userGroups(tx, ctx, member)
 	if err != nil {
 		return false, err
 	}
-	if group == "everyone@"+domain {
-		return true, nil
+	return groups[group], nil
+}
+
+// userGroupPaths returns the groups the user is a member of explicitly,
+// with the sub groups through which the user is a member of each group. See forge.AccessMatch.Via.
+// A group reachable through several paths has the nearest one, as expandGroupMembers does.
+func userGroupPaths(tx *sql.Tx, ctx context.Context, user string) (map[string][]string, error) {
+	paths := make(map[string][]string)
+	queue := []string{user}
+	for len(queue) != 0 {
+		member := queue[0]
+		queue = queue[1:]
+		rows, err := tx.QueryContext(ctx, `
+			SELECT
+				groups.name
+			FROM group_members
+			LEFT JOIN accessors AS groups ON group_members.group_id = groups.id
+			LEFT JOIN accessors AS members ON group_members.member_id = members.id
+			WHERE members.name=?
+			ORDER BY groups.name
+		`,
+			member,
+		)
+		if err != nil {
+			return nil, err
+		}
+		parents := make([]string, 0)
+		for rows.Next() {
+			var g string
+			err := rows.Scan(&g)
+			if err != nil {
+				rows.Close()
+				return nil, err
+			}
+			parents = append(parents, g)
+		}
+		rows.Close()
+		err = rows.Err()
+		if err != nil {
+			return nil, err
+		}
+		for _, g := range parents {
+			if _, ok := paths[g]; ok {
+				// found with a nearer path, or it's a cycle.
+				continue
+			}
+			var via []string
+			if member != user {
+				via = append(append(via, paths[member]...), member)
+			}
+			paths[g] = via
+			queue = append(queue, g)
+		}
 	}
-	// time to check db
-	mems, err := findGroupMembers(tx, ctx, forge.MemberFinder{Group: group, Member: &member})
+	return paths, nil
+}
+
+// userGroups returns the groups the user is a member of, at once.
+// 'everyone' and 'everyone@{domain}' are always included,
+// and a group is included when one of its sub groups has the user.
+func userGroups(tx *sql.Tx, ctx context.Context, user string) (map[string]bool, error) {
+	_, domain, err := splitUserName(user)
 	if err != nil {
-		return false, err
+		return nil, err
+	}
+	groups := map[string]bool{
+		"everyone":           true,
+		"everyone@" + domain: true,
+	}
+	// UNION (not UNION ALL) stops the recursion even if there is a cycle.
+	rows, err := tx.QueryContext(ctx, `
+		WITH RECURSIVE parents(id) AS (
+			SELECT id FROM accessors WHERE name=?
+			UNION
+			SELECT group_members.group_id FROM group_members
+			JOIN parents ON group_members.member_id = parents.id
+		)
+		SELECT
+			accessors.name
+		FROM parents
+		LEFT JOIN accessors ON parents.id = accessors.id
+		WHERE accessors.is_group=1
+	`,
+		user,
+	)
+	if err != nil {
+		return nil, err
 	}
-	if len(mems) == 0 {
-		return false, nil
+	defer rows.Close()
+	for rows.Next() {
+		var g string
+		err := rows.Scan(&g)
+		if err != nil {
+			return nil, err
+		}
+		groups[g] = true
 	}
-	return true, nil
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+	return groups, nil
 }
 
 func AddGroupMember(db *sql.DB, ctx context.Context, m *forge.Member) error {
@@ -159,10 +321,25 @@ func addGroupMember(tx *sql.Tx, ctx context.Context, m *forge.Member) error {
 	if err != nil {
 		return err
 	}
-	u, err := getUser(tx, ctx, m.Member)
+	a, err := getAccessor(tx, ctx, m.Member)
 	if err != nil {
 		return err
 	}
+	if a.IsGroup {
+		if a.Name == "everyone" || strings.HasPrefix(a.Name, "everyone@") {
+			return fmt.Errorf("everyone or everyone@{domain} group cannot be a member of another group")
+		}
+		if a.Name == g.Name {
+			return fmt.Errorf("group cannot be a member of itself: %v", g.Name)
+		}
+		subs, err := subGroups(tx, ctx, a.Name)
+		if err != nil {
+			return err
+		}
+		if subs[g.Name] {
+			return fmt.Errorf("cannot add group %q to %q: %q is a sub group of %q", a.Name, g.Name, g.Name, a.Name)
+		}
+	}
 	_, err = tx.ExecContext(ctx, `
 		INSERT INTO group_members (
 			group_id,
@@ -171,7 +348,7 @@ func addGroupMember(tx *sql.Tx, ctx context.Context, m *forge.Member) error {
 		VALUES (?, ?)
 	`,
 		g.ID,
-		u.ID,
+		a.ID,
 	)
 	if err != nil {
 		return err
@@ -215,20 +392,11 @@ func deleteGroupMember(tx *sql.Tx, ctx context.Context, group, member string) er
 	if strings.HasPrefix(group, "everyone@") {
 		return fmt.Errorf("everyone@{domain} group doesn't have any explicit member")
 	}
-	if group == "admin" {
-		members, err := findGroupMembers(tx, ctx, forge.MemberFinder{Group: group})
-		if err != nil {
-			return err
-		}
-		if len(members) == 1 {
-			return fmt.Errorf("need at least 1 admin")
-		}
-	}
 	g, err := getGroup(tx, ctx, group)
 	if err != nil {
 		return err
 	}
-	u, err := getUser(tx, ctx, member)
+	a, err := getAccessor(tx, ctx, member)
 	if err != nil {
 		return err
 	}
@@ -237,7 +405,7 @@ func deleteGroupMember(tx *sql.Tx, ctx context.Context, group, member string) er
 		WHERE group_id=? AND member_id=?
 	`,
 		g.ID,
-		u.ID,
+		a.ID,
 	)
 	if err != nil {
 		return err
@@ -249,5 +417,102 @@ func deleteGroupMember(tx *sql.Tx, ctx context.Context, group, member string) er
 	if n != 1 {
 		return forge.NotFound("%q is not a member of group %q", member, group)
 	}
+	if group == "admin" {
+		// Check after the deletion, as the admin could be a member through a sub group.
+		members, err := findGroupMembers(tx, ctx, forge.MemberFinder{Group: group, Expand: true})
+		if err != nil {
+			return err
+		}
+		if len(members) == 0 {
+			return fmt.Errorf("need at least 1 admin")
+		}
+	}
+	return nil
+}
+
+func SyncGroupMembers(db *sql.DB, ctx context.Context, member string, managed, groups []string) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	user := forge.UserNameFromContext(ctx)
+	if user == "" {
+		return forge.Unauthorized("context user unspecified")
+	}
+	if user != member {
+		yes, err := isAdmin(tx, ctx, user)
+		if err != nil {
+			return err
+		}
+		if !yes {
+			return forge.Unauthorized("user doesn't have permission to sync group members of another user: %v", user)
+		}
+	}
+	err = syncGroupMembers(tx, ctx, member, managed, groups)
+	if err != nil {
+		return err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// syncGroupMembers adds the user to the managed groups those are in groups,
+// and removes the user from the other managed groups.
+// Groups those aren't managed are left untouched, and a managed group that doesn't exist is ignored.
+// Only direct memberships are considered, memberships through sub groups are left as is.
+//
+// Each change is logged in "group_member" category with "sync" type.
+func syncGroupMembers(tx *sql.Tx, ctx context.Context, member string, managed, groups []string) error {
+	_, err := getUser(tx, ctx, member)
+	if err != nil {
+		return err
+	}
+	want := make(map[string]bool)
+	for _, g := range groups {
+		want[g] = true
+	}
+	for _, g := range managed {
+		_, err := getGroup(tx, ctx, g)
+		if err != nil {
+			var e *forge.NotFoundError
+			if !errors.As(err, &e) {
+				return err
+			}
+			continue
+		}
+		mems, err := findGroupMembers(tx, ctx, forge.MemberFinder{Group: g, Member: &member})
+		if err != nil {
+			return err
+		}
+		has := len(mems) != 0
+		if has == want[g] {
+			continue
+		}
+		action := "add"
+		if want[g] {
+			err = addGroupMember(tx, ctx, &forge.Member{Group: g, Member: member})
+		} else {
+			action = "delete"
+			err = deleteGroupMember(tx, ctx, g, member)
+		}
+		if err != nil {
+			return err
+		}
+		err = addLog(tx, ctx, &forge.Log{
+			User:     forge.UserNameFromContext(ctx),
+			Action:   action,
+			Category: "group_member",
+			Name:     g,
+			Type:     "sync",
+			Value:    member,
+		})
+		if err != nil {
+			return err
+		}
+	}
 	return nil
 }
@@ -0,0 +1,119 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/imagvfx/forge"
+)
+
+// autoNameLocks serializes entry creations those use auto numbering under the same parent,
+// so concurrent creations in the process will not get the same name.
+// Transactions don't serialize them, as they read names of the siblings before they write,
+// and one of them will fail as the db is locked.
+// Unique path of an entry prevents it among processes.
+var autoNameLocks = &parentLocks{locks: make(map[string]*parentLock)}
+
+// parentLocks holds locks of parent entries, those are in use.
+type parentLocks struct {
+	mu    sync.Mutex
+	locks map[string]*parentLock
+}
+
+type parentLock struct {
+	sync.Mutex
+	// n is the number of callers those hold or wait for the lock.
+	n int
+}
+
+// lock locks the parent entry, and returns a function that unlocks it.
+func (l *parentLocks) lock(parent string) func() {
+	l.mu.Lock()
+	pl := l.locks[parent]
+	if pl == nil {
+		pl = &parentLock{}
+		l.locks[parent] = pl
+	}
+	pl.n++
+	l.mu.Unlock()
+	pl.Lock()
+	return func() {
+		pl.Unlock()
+		l.mu.Lock()
+		pl.n--
+		if pl.n == 0 {
+			delete(l.locks, parent)
+		}
+		l.mu.Unlock()
+	}
+}
+
+// getNamingRule gets the naming rule of an entry type from 'naming_rule' global of it's base type.
+// It returns an empty rule when it is not defined.
+func getNamingRule(tx *sql.Tx, ctx context.Context, entType string) (*forge.NamingRule, error) {
+	baseType := strings.Split(entType, ".")[0]
+	g, err := getGlobal(tx, ctx, baseType, "naming_rule")
+	if err != nil {
+		var e *forge.NotFoundError
+		if !errors.As(err, &e) {
+			return nil, err
+		}
+		return &forge.NamingRule{}, nil
+	}
+	r, err := forge.ParseNamingRule(g.Value)
+	if err != nil {
+		return nil, fmt.Errorf("naming_rule global of %v: %v", baseType, err)
+	}
+	return r, nil
+}
+
+// checkEntryName checks a name of a new entry with the naming rule of the type.
+func checkEntryName(tx *sql.Tx, ctx context.Context, entType, name string) error {
+	r, err := getNamingRule(tx, ctx, entType)
+	if err != nil {
+		return err
+	}
+	err = r.Check(name)
+	if err != nil {
+		return fmt.Errorf("invalid entry name for %v: %v", entType, err)
+	}
+	return nil
+}
+
+// nextEntryName returns the next name of a new entry of the type under the parent entry.
+// The naming rule of the type should enable auto numbering.
+func nextEntryName(tx *sql.Tx, ctx context.Context, parentPath, entType string) (string, error) {
+	r, err := getNamingRule(tx, ctx, entType)
+	if err != nil {
+		return "", err
+	}
+	rows, err := tx.QueryContext(ctx, `
+		SELECT path FROM entries
+		WHERE parent_id=(SELECT id FROM entries WHERE path=?)
+	`,
+		parentPath,
+	)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+	names := make([]string, 0)
+	for rows.Next() {
+		var pth string
+		err := rows.Scan(&pth)
+		if err != nil {
+			return "", err
+		}
+		names = append(names, path.Base(pth))
+	}
+	name, err := r.Next(names)
+	if err != nil {
+		return "", fmt.Errorf("cannot allocate entry name for %v: %v", entType, err)
+	}
+	return name, nil
+}
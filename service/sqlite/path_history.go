@@ -0,0 +1,100 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/imagvfx/forge"
+)
+
+// createEntryPathHistoryTable creates a table that remembers old paths of entries,
+// so an entry can be found with the path it had before renamed.
+func createEntryPathHistoryTable(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+		CREATE TABLE IF NOT EXISTS entry_path_history (
+			id INTEGER PRIMARY KEY,
+			entry_id INTEGER NOT NULL,
+			path TEXT NOT NULL,
+			time TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (entry_id) REFERENCES entries (id)
+		)
+	`)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`CREATE INDEX IF NOT EXISTS index_entry_path_history_path ON entry_path_history (path)`)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`CREATE INDEX IF NOT EXISTS index_entry_path_history_entry_id ON entry_path_history (entry_id)`)
+	return err
+}
+
+// addPathHistory remembers current paths of an entry and it's sub entries, before they are moved.
+func addPathHistory(tx *sql.Tx, ctx context.Context, path string) error {
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO entry_path_history (
+			entry_id,
+			path
+		)
+		SELECT id, path FROM entries
+		WHERE path=? OR path GLOB ?
+	`,
+		path,
+		path+"/*",
+	)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// GetMovedEntryPath returns current path of the entry that had the path before.
+// When multiple entries had the path, the entry moved most recently will be chosen.
+func GetMovedEntryPath(db *sql.DB, ctx context.Context, path string) (string, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+	newPath, err := getMovedEntryPath(tx, ctx, path)
+	if err != nil {
+		return "", err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return "", err
+	}
+	return newPath, nil
+}
+
+func getMovedEntryPath(tx *sql.Tx, ctx context.Context, path string) (string, error) {
+	rows, err := tx.QueryContext(ctx, `
+		SELECT entries.path FROM entry_path_history
+		LEFT JOIN entries ON entry_path_history.entry_id=entries.id
+		WHERE entry_path_history.path=? AND entries.path!=?
+		ORDER BY entry_path_history.id DESC
+		LIMIT 1
+	`,
+		path,
+		path,
+	)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		return "", forge.NotFound("entry not found: %v", path)
+	}
+	var newPath string
+	err = rows.Scan(&newPath)
+	if err != nil {
+		return "", err
+	}
+	// The user should be able to see the entry.
+	err = userRead(tx, ctx, newPath)
+	if err != nil {
+		return "", err
+	}
+	return newPath, nil
+}
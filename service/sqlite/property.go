@@ -49,8 +49,13 @@ func EntryProperties(db *sql.DB, ctx context.Context, path string) ([]*forge.Pro
 	return props, nil
 }
 
+// entryProperties returns properties of the entry, those the context user can see.
 func entryProperties(tx *sql.Tx, ctx context.Context, path string) ([]*forge.Property, error) {
-	return findProperties(tx, ctx, forge.PropertyFinder{EntryPath: &path})
+	props, err := findProperties(tx, ctx, forge.PropertyFinder{EntryPath: &path})
+	if err != nil {
+		return nil, err
+	}
+	return visibleProperties(tx, ctx, props)
 }
 
 // when id is empty, it will find properties of root.
@@ -80,7 +85,15 @@ func findProperties(tx *sql.Tx, ctx context.Context, find forge.PropertyFinder)
 			default_properties.type,
 			properties.val,
 			properties.updated_at,
-			entries.path
+			entries.path,
+			default_properties.label,
+			default_properties.description,
+			default_properties.section,
+			default_properties.sort_order,
+			default_properties.read_only,
+			default_properties.inheritable,
+			default_properties.read_groups,
+			default_properties.write_groups
 		FROM properties
 		LEFT JOIN entries ON properties.entry_id = entries.id
 		LEFT JOIN default_properties ON properties.default_id = default_properties.id
@@ -94,6 +107,7 @@ func findProperties(tx *sql.Tx, ctx context.Context, find forge.PropertyFinder)
 	props := make([]*forge.Property, 0)
 	for rows.Next() {
 		p := &forge.Property{}
+		var readGroups, writeGroups string
 		err := rows.Scan(
 			&p.ID,
 			&p.Name,
@@ -101,11 +115,24 @@ func findProperties(tx *sql.Tx, ctx context.Context, find forge.PropertyFinder)
 			&p.RawValue,
 			&p.UpdatedAt,
 			&p.EntryPath,
+			&p.Label,
+			&p.Description,
+			&p.Section,
+			&p.Order,
+			&p.ReadOnly,
+			&p.Inheritable,
+			&readGroups,
+			&writeGroups,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("find properties: %w", err)
 		}
+		p.ReadGroups = groupList(readGroups)
+		p.WriteGroups = groupList(writeGroups)
 		evalProperty(tx, ctx, p)
+		if p.Inheritable && p.RawValue == "" {
+			inheritProperty(tx, ctx, p)
+		}
 		props = append(props, p)
 	}
 	return props, nil
@@ -119,7 +146,12 @@ func propertiesByDefaultID(tx *sql.Tx, ctx context.Context, defaultID int) ([]*f
 			default_properties.type,
 			properties.val,
 			properties.updated_at,
-			entries.path
+			entries.path,
+			default_properties.label,
+			default_properties.description,
+			default_properties.section,
+			default_properties.sort_order,
+			default_properties.read_only
 		FROM properties
 		LEFT JOIN entries ON properties.entry_id = entries.id
 		LEFT JOIN default_properties ON properties.default_id = default_properties.id
@@ -140,6 +172,11 @@ func propertiesByDefaultID(tx *sql.Tx, ctx context.Context, defaultID int) ([]*f
 			&p.RawValue,
 			&p.UpdatedAt,
 			&p.EntryPath,
+			&p.Label,
+			&p.Description,
+			&p.Section,
+			&p.Order,
+			&p.ReadOnly,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("properties from default id: %w", err)
@@ -160,6 +197,13 @@ func GetProperty(db *sql.DB, ctx context.Context, path, name string) (*forge.Pro
 	if err != nil {
 		return nil, err
 	}
+	yes, err := propertyReadable(tx, ctx, p.PropertyMeta)
+	if err != nil {
+		return nil, err
+	}
+	if !yes {
+		return nil, forge.NotFound("property not found: %v.%v", path, name)
+	}
 	err = tx.Commit()
 	if err != nil {
 		return nil, err
@@ -196,6 +240,10 @@ func addProperty(tx *sql.Tx, ctx context.Context, p *forge.Property) error {
 	if err != nil {
 		return err
 	}
+	err = checkConstraint(tx, ctx, p, nil)
+	if err != nil {
+		return err
+	}
 	entryID, err := getEntryID(tx, ctx, p.EntryPath)
 	if err != nil {
 		return err
@@ -249,6 +297,29 @@ func UpdateProperties(db *sql.DB, ctx context.Context, upds []forge.PropertyUpda
 		return err
 	}
 	defer tx.Rollback()
+	err = updateProperties(tx, ctx, upds)
+	if err != nil {
+		return err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// DryRunUpdateProperties performs UpdateProperties without saving the changes.
+// It returns the error that UpdateProperties will return with the updaters.
+func DryRunUpdateProperties(db *sql.DB, ctx context.Context, upds []forge.PropertyUpdater) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	return updateProperties(tx, ctx, upds)
+}
+
+func updateProperties(tx *sql.Tx, ctx context.Context, upds []forge.PropertyUpdater) error {
 	if len(upds) == 0 {
 		return nil
 	}
@@ -260,13 +331,16 @@ func UpdateProperties(db *sql.DB, ctx context.Context, upds []forge.PropertyUpda
 			return fmt.Errorf("entry path should be same among property updaters in a bulk update")
 		}
 	}
+	names := make([]string, 0, len(upds))
 	for _, upd := range upds {
 		err := updateProperty(tx, ctx, upd)
 		if err != nil {
 			return err
 		}
+		names = append(names, upd.Name)
 	}
-	err = tx.Commit()
+	// check it after all updates, as other updates could fill the required values.
+	err := checkRequiredConditions(tx, ctx, path, names)
 	if err != nil {
 		return err
 	}
@@ -283,6 +357,10 @@ func UpdateProperty(db *sql.DB, ctx context.Context, upd forge.PropertyUpdater)
 	if err != nil {
 		return err
 	}
+	err = checkRequiredConditions(tx, ctx, upd.EntryPath, []string{upd.Name})
+	if err != nil {
+		return err
+	}
 	err = tx.Commit()
 	if err != nil {
 		return err
@@ -304,6 +382,36 @@ func updateProperty(tx *sql.Tx, ctx context.Context, upd forge.PropertyUpdater)
 	if err != nil {
 		return err
 	}
+	readable, err := propertyReadable(tx, ctx, old.PropertyMeta)
+	if err != nil {
+		return err
+	}
+	if !readable {
+		return forge.NotFound("property not found: %v.%v", upd.EntryPath, upd.Name)
+	}
+	if old.ReadOnly {
+		ctxUser := forge.UserNameFromContext(ctx)
+		admin, err := isAdmin(tx, ctx, ctxUser)
+		if err != nil {
+			return err
+		}
+		if !admin {
+			return forge.Unauthorized("property is read-only for non-admins: %v", upd.Name)
+		}
+	}
+	if len(old.WriteGroups) != 0 {
+		// write groups limit users those can modify the property further,
+		// the users still need write access to the entry (or be a property owner).
+		// They don't grant the modification by themselves, or a member of the groups
+		// could modify entries those the member isn't allowed to write.
+		writable, err := propertyWritable(tx, ctx, old.PropertyMeta)
+		if err != nil {
+			return err
+		}
+		if !writable {
+			return forge.Unauthorized("property modification not allowed: %v.%v", upd.EntryPath, upd.Name)
+		}
+	}
 	if deferredErr != nil {
 		// check whether the context user is property_owner.
 		// if so, allow update.
@@ -350,7 +458,11 @@ func updateProperty(tx *sql.Tx, ctx context.Context, upd forge.PropertyUpdater)
 				// subPath not exists.
 				continue
 			}
-			if p.Value != ctxUser {
+			yes, err := isPropertyUser(tx, ctx, p, ctxUser)
+			if err != nil {
+				return err
+			}
+			if !yes {
 				continue
 			}
 			// user have right to update.
@@ -371,6 +483,10 @@ func updateProperty(tx *sql.Tx, ctx context.Context, upd forge.PropertyUpdater)
 			return err
 		}
 		if p.RawValue != old.RawValue {
+			err := checkConstraint(tx, ctx, p, old)
+			if err != nil {
+				return err
+			}
 			keys = append(keys, "val=?")
 			vals = append(vals, p.RawValue)
 		}
@@ -414,32 +530,100 @@ func updateProperty(tx *sql.Tx, ctx context.Context, upd forge.PropertyUpdater)
 	if err != nil {
 		return nil
 	}
+	// sub entries might inherit the value.
+	inherited, err := isInheritableProperty(tx, ctx, p.Name)
+	if err != nil {
+		return err
+	}
+	err = refreshFormulas(tx, ctx, p.EntryPath, inherited)
+	if err != nil {
+		return err
+	}
 	if p.Name == "assignee" && p.Value != "" {
-		assignee := p.Value
-		_, err := getAccess(tx, ctx, p.EntryPath, assignee)
-		if err != nil {
-			e := &forge.NotFoundError{}
-			if !errors.As(err, &e) {
-				return err
+		assignees := []string{p.Value}
+		if p.Type == "users" {
+			// p.Value of users property only has the differences. ex) +someone@imagvfx.com
+			assignees = []string{}
+			for _, ln := range strings.Split(p.Value, "\n") {
+				name, ok := strings.CutPrefix(ln, "+")
+				if !ok {
+					continue
+				}
+				a, err := getAccessor(tx, ctx, name)
+				if err != nil {
+					return err
+				}
+				if a.IsGroup {
+					// assigning a group shouldn't let every member of the group modify the entry.
+					continue
+				}
+				assignees = append(assignees, name)
+			}
+		}
+		for _, assignee := range assignees {
+			_, err := getAccess(tx, ctx, p.EntryPath, assignee)
+			if err != nil {
+				e := &forge.NotFoundError{}
+				if !errors.As(err, &e) {
+					return err
+				}
+				a := &forge.Access{
+					EntryPath: p.EntryPath,
+					Name:      assignee,
+					Value:     "rw",
+				}
+				err = addAccess(tx, ctx, a)
+				if err != nil {
+					return err
+				}
+				continue
 			}
-			a := &forge.Access{
+			mode := "rw"
+			upd := forge.AccessUpdater{
 				EntryPath: p.EntryPath,
 				Name:      assignee,
-				Value:     "rw",
+				Value:     &mode,
+			}
+			err = updateAccess(tx, ctx, upd)
+			if err != nil {
+				return err
 			}
-			return addAccess(tx, ctx, a)
-		}
-		mode := "rw"
-		upd := forge.AccessUpdater{
-			EntryPath: p.EntryPath,
-			Name:      assignee,
-			Value:     &mode,
 		}
-		return updateAccess(tx, ctx, upd)
 	}
 	return nil
 }
 
+// isPropertyUser checks whether the user is the one that user or users property indicates.
+// For users property, the user should be one of the users, or a member of one of the groups.
+func isPropertyUser(tx *sql.Tx, ctx context.Context, p *forge.Property, user string) (bool, error) {
+	if p.Type != "users" {
+		return p.Value == user, nil
+	}
+	for _, name := range strings.Split(p.Value, "\n") {
+		if name == "" {
+			continue
+		}
+		if name == user {
+			return true, nil
+		}
+		a, err := getAccessor(tx, ctx, name)
+		if err != nil {
+			return false, err
+		}
+		if !a.IsGroup {
+			continue
+		}
+		yes, err := isGroupMember(tx, ctx, name, user)
+		if err != nil {
+			return false, err
+		}
+		if yes {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
 func deleteProperty(tx *sql.Tx, ctx context.Context, path, name string) error {
 	err := userWrite(tx, ctx, path)
 	if err != nil {
@@ -484,5 +668,9 @@ func deleteProperty(tx *sql.Tx, ctx context.Context, path, name string) error {
 	if err != nil {
 		return nil
 	}
+	err = refreshFormulas(tx, ctx, p.EntryPath, false)
+	if err != nil {
+		return err
+	}
 	return nil
 }
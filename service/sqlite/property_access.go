@@ -0,0 +1,217 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+
+	"github.com/imagvfx/forge"
+)
+
+// groupList splits groups of a default property, those are saved as a comma separated string.
+// It returns nil when there is no group.
+func groupList(s string) []string {
+	var groups []string
+	for _, g := range strings.Split(s, ",") {
+		g = strings.TrimSpace(g)
+		if g == "" {
+			continue
+		}
+		groups = append(groups, g)
+	}
+	return groups
+}
+
+// groupString joins groups of a default property to save them.
+func groupString(groups []string) string {
+	return strings.Join(groups, ",")
+}
+
+// removeGroup returns groups of a default property without the group.
+func removeGroup(groups []string, group string) []string {
+	var remain []string
+	for _, g := range groups {
+		if g == group {
+			continue
+		}
+		remain = append(remain, g)
+	}
+	return remain
+}
+
+// propertyAccess decides whether the context user can see or modify properties by their meta.
+//
+// It resolves the user's groups once when a restricted property is checked at the first time,
+// so checking many properties, like in a search, doesn't query the groups for each of them.
+type propertyAccess struct {
+	tx     *sql.Tx
+	ctx    context.Context
+	loaded bool
+	admin  bool
+	// groups are the groups the user is a member of.
+	groups map[string]bool
+}
+
+func newPropertyAccess(tx *sql.Tx, ctx context.Context) *propertyAccess {
+	return &propertyAccess{tx: tx, ctx: ctx}
+}
+
+// load resolves whether the user is an admin and the groups of the user, if not loaded yet.
+func (a *propertyAccess) load() error {
+	if a.loaded {
+		return nil
+	}
+	user := forge.UserNameFromContext(a.ctx)
+	admin, err := isAdmin(a.tx, a.ctx, user)
+	if err != nil {
+		return err
+	}
+	a.admin = admin
+	if !admin {
+		a.groups, err = userGroups(a.tx, a.ctx, user)
+		if err != nil {
+			return err
+		}
+	}
+	a.loaded = true
+	return nil
+}
+
+// memberOfAny checks the user is a member of one of the groups.
+// Groups those don't exist anymore don't have any member.
+func (a *propertyAccess) memberOfAny(groups []string) bool {
+	for _, g := range groups {
+		if a.groups[g] {
+			return true
+		}
+	}
+	return false
+}
+
+// readable checks the user can see a property with the meta.
+// It doesn't check the access to the entry, see userRead for that.
+func (a *propertyAccess) readable(meta forge.PropertyMeta) (bool, error) {
+	if len(meta.ReadGroups) == 0 {
+		return true, nil
+	}
+	if isFormulaContext(a.ctx) {
+		// formulas only see properties those everyone can see.
+		return false, nil
+	}
+	err := a.load()
+	if err != nil {
+		return false, err
+	}
+	if a.admin {
+		return true, nil
+	}
+	return a.memberOfAny(meta.ReadGroups) || a.memberOfAny(meta.WriteGroups), nil
+}
+
+// writable checks the user is allowed to modify a property with the meta,
+// when the meta limits it to the write groups.
+//
+// Write groups only restrict the users those can modify the property.
+// They don't grant the modification to users without write access to the entry,
+// see updateProperty for that.
+func (a *propertyAccess) writable(meta forge.PropertyMeta) (bool, error) {
+	err := a.load()
+	if err != nil {
+		return false, err
+	}
+	if a.admin {
+		return true, nil
+	}
+	return a.memberOfAny(meta.WriteGroups), nil
+}
+
+// propertyReadable checks the context user can see a property with the meta.
+// Use propertyAccess instead, when checking many properties.
+func propertyReadable(tx *sql.Tx, ctx context.Context, meta forge.PropertyMeta) (bool, error) {
+	return newPropertyAccess(tx, ctx).readable(meta)
+}
+
+// propertyWritable checks the context user is allowed to modify a property with the meta,
+// when the meta limits it to the write groups.
+func propertyWritable(tx *sql.Tx, ctx context.Context, meta forge.PropertyMeta) (bool, error) {
+	return newPropertyAccess(tx, ctx).writable(meta)
+}
+
+// visibleProperties filters properties those the context user cannot see.
+func visibleProperties(tx *sql.Tx, ctx context.Context, props []*forge.Property) ([]*forge.Property, error) {
+	access := newPropertyAccess(tx, ctx)
+	visible := make([]*forge.Property, 0, len(props))
+	for _, p := range props {
+		yes, err := access.readable(p.PropertyMeta)
+		if err != nil {
+			return nil, err
+		}
+		if !yes {
+			continue
+		}
+		visible = append(visible, p)
+	}
+	return visible, nil
+}
+
+// hiddenDefaultIDs returns ids of default properties those the context user cannot see.
+// Properties of the defaults shouldn't be matched by a search of the user.
+func hiddenDefaultIDs(tx *sql.Tx, ctx context.Context) ([]int, error) {
+	defs, err := findDefaultProperties(tx, ctx, forge.DefaultFinder{})
+	if err != nil {
+		return nil, err
+	}
+	access := newPropertyAccess(tx, ctx)
+	hidden := make([]int, 0)
+	for _, d := range defs {
+		yes, err := access.readable(d.PropertyMeta)
+		if err != nil {
+			return nil, err
+		}
+		if !yes {
+			hidden = append(hidden, d.ID)
+		}
+	}
+	return hidden, nil
+}
+
+// visibleLogs filters logs of properties those the context user cannot see.
+func visibleLogs(tx *sql.Tx, ctx context.Context, logs []*forge.Log) ([]*forge.Log, error) {
+	type key struct {
+		path string
+		name string
+	}
+	access := newPropertyAccess(tx, ctx)
+	readable := make(map[key]bool)
+	visible := make([]*forge.Log, 0, len(logs))
+	for _, l := range logs {
+		if l.Category != "property" {
+			visible = append(visible, l)
+			continue
+		}
+		k := key{l.EntryPath, l.Name}
+		yes, ok := readable[k]
+		if !ok {
+			p, err := getProperty(tx, ctx, l.EntryPath, l.Name)
+			if err != nil {
+				var e *forge.NotFoundError
+				if !errors.As(err, &e) {
+					return nil, err
+				}
+				// the property is deleted, there is no limit to check.
+				p = &forge.Property{}
+			}
+			yes, err = access.readable(p.PropertyMeta)
+			if err != nil {
+				return nil, err
+			}
+			readable[k] = yes
+		}
+		if !yes {
+			continue
+		}
+		visible = append(visible, l)
+	}
+	return visible, nil
+}
@@ -0,0 +1,190 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/imagvfx/forge"
+)
+
+// FindReferences finds properties of other entries those point to the entry.
+func FindReferences(db *sql.DB, ctx context.Context, path string) ([]*forge.Reference, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	refs, err := findReferences(tx, ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+	return refs, nil
+}
+
+// findReferences finds properties of other entries those point to the entry.
+// References from entries the user cannot see are not included.
+func findReferences(tx *sql.Tx, ctx context.Context, path string) ([]*forge.Reference, error) {
+	err := userRead(tx, ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	refs, err := entryReferences(tx, ctx, path, false)
+	if err != nil {
+		return nil, err
+	}
+	return visibleReferences(tx, ctx, refs)
+}
+
+// referenceQuery returns a query of references from properties to the entries, those match to the target where clause.
+// The target entries are called 'targets' in the where clause, and the values for it should be passed twice
+// as the where clause is used twice in the query. It selects these columns.
+//
+//	entry_id: id of the entry having the property
+//	default_id: id of the default of the property
+//	name: name of the property
+//	type: type of the property
+//	target_id: id of the target entry
+//	target_path: path of the target entry
+//
+// entry_path, entry_name and lookup properties point an entry with it's id, so the target is looked up with the id.
+// entry_link properties point entries with their paths, so they are compared with the matched entries only.
+// Either way, it doesn't need to compare every property with every entry.
+func referenceQuery(targetWhere string) string {
+	return `
+		SELECT
+			properties.entry_id AS entry_id,
+			default_properties.id AS default_id,
+			default_properties.name AS name,
+			default_properties.type AS type,
+			targets.id AS target_id,
+			targets.path AS target_path
+		FROM properties
+		JOIN default_properties ON properties.default_id=default_properties.id
+		JOIN entries AS targets ON targets.id=CAST(
+			CASE default_properties.type
+				WHEN 'lookup' THEN substr(properties.val, 1, instr(properties.val, '.')-1)
+				ELSE properties.val
+			END AS INTEGER
+		)
+		WHERE default_properties.type IN ('entry_path', 'entry_name', 'lookup') AND (` + targetWhere + `)
+		UNION ALL
+		SELECT
+			properties.entry_id,
+			default_properties.id,
+			default_properties.name,
+			default_properties.type,
+			targets.id,
+			targets.path
+		FROM entries AS targets
+		JOIN properties ON instr(properties.val, char(10) || targets.path || char(10)) > 0
+		JOIN default_properties ON properties.default_id=default_properties.id
+		WHERE default_properties.type='entry_link' AND (` + targetWhere + `)
+	`
+}
+
+// entryReferences finds properties of other entries those point to the entry.
+//
+// When sub is true, it also finds references to the sub entries,
+// except references from the entry and it's sub entries.
+func entryReferences(tx *sql.Tx, ctx context.Context, path string, sub bool) ([]*forge.Reference, error) {
+	targetWhere := "targets.path=?"
+	targetVals := []any{path}
+	if sub {
+		targetWhere = "targets.path=? OR targets.path GLOB ?"
+		targetVals = []any{path, path + "/*"}
+	}
+	// the target where clause is used twice in the reference query.
+	vals := append(targetVals, targetVals...)
+	where := "TRUE"
+	if sub {
+		where = "entries.path!=? AND entries.path NOT GLOB ?"
+		vals = append(vals, path, path+"/*")
+	}
+	rows, err := tx.QueryContext(ctx, `
+		SELECT
+			entries.path,
+			refs.name,
+			refs.type,
+			refs.target_path
+		FROM (`+referenceQuery(targetWhere)+`) AS refs
+		LEFT JOIN entries ON refs.entry_id=entries.id
+		WHERE refs.entry_id!=refs.target_id AND `+where+`
+		ORDER BY entries.path, refs.name, refs.target_path
+	`,
+		vals...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	refs := make([]*forge.Reference, 0)
+	for rows.Next() {
+		r := &forge.Reference{}
+		err := rows.Scan(
+			&r.EntryPath,
+			&r.Name,
+			&r.Type,
+			&r.Target,
+		)
+		if err != nil {
+			return nil, err
+		}
+		refs = append(refs, r)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+	return refs, nil
+}
+
+// visibleReferences filters references from entries those the user cannot see.
+func visibleReferences(tx *sql.Tx, ctx context.Context, refs []*forge.Reference) ([]*forge.Reference, error) {
+	visible := make([]*forge.Reference, 0, len(refs))
+	for _, r := range refs {
+		mode, err := userAccessMode(tx, ctx, r.EntryPath)
+		if err != nil {
+			return nil, err
+		}
+		if mode == nil {
+			continue
+		}
+		visible = append(visible, r)
+	}
+	return visible, nil
+}
+
+// checkNoReferences checks the entry is not referenced by other entries, before it is deleted.
+// When sub is true, references to the sub entries are checked as well.
+func checkNoReferences(tx *sql.Tx, ctx context.Context, path string, sub bool) error {
+	refs, err := entryReferences(tx, ctx, path, sub)
+	if err != nil {
+		return err
+	}
+	if len(refs) == 0 {
+		return nil
+	}
+	visible, err := visibleReferences(tx, ctx, refs)
+	if err != nil {
+		return err
+	}
+	from := make([]string, 0, len(visible)+1)
+	for _, r := range visible {
+		// a property can reference multiple entries.
+		prop := r.EntryPath + "." + r.Name
+		if len(from) != 0 && from[len(from)-1] == prop {
+			continue
+		}
+		from = append(from, prop)
+	}
+	if n := len(refs) - len(visible); n != 0 {
+		from = append(from, fmt.Sprintf("%v hidden", n))
+	}
+	return fmt.Errorf("entry is referenced by other entries, remove the references first: %v: %v", path, strings.Join(from, ", "))
+}
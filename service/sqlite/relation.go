@@ -0,0 +1,447 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/imagvfx/forge"
+)
+
+func createEntryRelationsTable(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+		CREATE TABLE IF NOT EXISTS entry_relations (
+			id INTEGER PRIMARY KEY,
+			from_id INTEGER NOT NULL,
+			to_id INTEGER NOT NULL,
+			typ TEXT NOT NULL,
+			FOREIGN KEY (from_id) REFERENCES entries (id),
+			FOREIGN KEY (to_id) REFERENCES entries (id),
+			UNIQUE (from_id, to_id, typ)
+		)
+	`)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`CREATE INDEX IF NOT EXISTS index_entry_relations_from_id ON entry_relations (from_id)`)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`CREATE INDEX IF NOT EXISTS index_entry_relations_to_id ON entry_relations (to_id)`)
+	return err
+}
+
+// FindRelations finds relations of entries.
+// Relations to or from entries those the user cannot see are not included.
+func FindRelations(db *sql.DB, ctx context.Context, find forge.RelationFinder) ([]*forge.Relation, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	if find.From != nil {
+		err := userRead(tx, ctx, *find.From)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if find.To != nil {
+		err := userRead(tx, ctx, *find.To)
+		if err != nil {
+			return nil, err
+		}
+	}
+	rels, err := findRelations(tx, ctx, find)
+	if err != nil {
+		return nil, err
+	}
+	rels, err = visibleRelations(tx, ctx, rels)
+	if err != nil {
+		return nil, err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+	return rels, nil
+}
+
+func findRelations(tx *sql.Tx, ctx context.Context, find forge.RelationFinder) ([]*forge.Relation, error) {
+	keys := make([]string, 0)
+	vals := make([]any, 0)
+	if find.From != nil {
+		keys = append(keys, "from_entries.path=?")
+		vals = append(vals, *find.From)
+	}
+	if find.To != nil {
+		keys = append(keys, "to_entries.path=?")
+		vals = append(vals, *find.To)
+	}
+	if find.Type != nil {
+		keys = append(keys, "entry_relations.typ=?")
+		vals = append(vals, *find.Type)
+	}
+	where := ""
+	if len(keys) != 0 {
+		where = "WHERE " + strings.Join(keys, " AND ")
+	}
+	rows, err := tx.QueryContext(ctx, `
+		SELECT
+			entry_relations.id,
+			from_entries.path,
+			to_entries.path,
+			entry_relations.typ
+		FROM entry_relations
+		LEFT JOIN entries AS from_entries ON entry_relations.from_id=from_entries.id
+		LEFT JOIN entries AS to_entries ON entry_relations.to_id=to_entries.id
+		`+where+`
+		ORDER BY from_entries.path, entry_relations.typ, to_entries.path
+	`,
+		vals...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	rels := make([]*forge.Relation, 0)
+	for rows.Next() {
+		r := &forge.Relation{}
+		err := rows.Scan(
+			&r.ID,
+			&r.From,
+			&r.To,
+			&r.Type,
+		)
+		if err != nil {
+			return nil, err
+		}
+		rels = append(rels, r)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+	return rels, nil
+}
+
+// visibleRelations filters relations those have an entry the user cannot see.
+func visibleRelations(tx *sql.Tx, ctx context.Context, rels []*forge.Relation) ([]*forge.Relation, error) {
+	visible := make([]*forge.Relation, 0, len(rels))
+	for _, r := range rels {
+		hidden := false
+		for _, pth := range []string{r.From, r.To} {
+			mode, err := userAccessMode(tx, ctx, pth)
+			if err != nil {
+				return nil, err
+			}
+			if mode == nil {
+				hidden = true
+				break
+			}
+		}
+		if hidden {
+			continue
+		}
+		visible = append(visible, r)
+	}
+	return visible, nil
+}
+
+func AddRelation(db *sql.DB, ctx context.Context, r *forge.Relation) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	err = addRelation(tx, ctx, r)
+	if err != nil {
+		return err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// addRelation adds a relation from an entry to another entry.
+// The user should be able to write to the 'from' entry, and read the 'to' entry.
+func addRelation(tx *sql.Tx, ctx context.Context, r *forge.Relation) error {
+	if !forge.IsRelationType(r.Type) {
+		return fmt.Errorf("invalid relation type: %v", r.Type)
+	}
+	err := userWrite(tx, ctx, r.From)
+	if err != nil {
+		return err
+	}
+	err = userRead(tx, ctx, r.To)
+	if err != nil {
+		return err
+	}
+	fromID, err := getEntryID(tx, ctx, r.From)
+	if err != nil {
+		return err
+	}
+	toID, err := getEntryID(tx, ctx, r.To)
+	if err != nil {
+		return err
+	}
+	if fromID == toID {
+		return fmt.Errorf("cannot relate an entry to itself: %v", r.From)
+	}
+	rels, err := findRelations(tx, ctx, forge.RelationFinder{From: &r.From, To: &r.To, Type: &r.Type})
+	if err != nil {
+		return err
+	}
+	if len(rels) != 0 {
+		return fmt.Errorf("relation already exists: %v %v %v", r.From, r.Type, r.To)
+	}
+	if isDependencyRelation(r.Type) {
+		cycle, err := dependsOn(tx, ctx, toID, fromID)
+		if err != nil {
+			return err
+		}
+		if cycle {
+			return fmt.Errorf("relation makes a dependency cycle: %v %v %v", r.From, r.Type, r.To)
+		}
+	}
+	result, err := tx.ExecContext(ctx, `
+		INSERT INTO entry_relations (
+			from_id,
+			to_id,
+			typ
+		)
+		VALUES (?, ?, ?)
+	`,
+		fromID,
+		toID,
+		r.Type,
+	)
+	if err != nil {
+		return err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	r.ID = int(id)
+	user := forge.UserNameFromContext(ctx)
+	if user == "" {
+		return forge.Unauthorized("context user unspecified")
+	}
+	err = addLog(tx, ctx, &forge.Log{
+		EntryPath: r.From,
+		User:      user,
+		Action:    "create",
+		Category:  "relation",
+		Name:      r.Type,
+		Type:      "entry_path",
+		Value:     r.To,
+	})
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func isDependencyRelation(typ string) bool {
+	for _, t := range forge.DependencyRelationTypes() {
+		if t == typ {
+			return true
+		}
+	}
+	return false
+}
+
+// dependsOn checks an entry depends on another entry, directly or indirectly.
+func dependsOn(tx *sql.Tx, ctx context.Context, id, otherID int) (bool, error) {
+	types := forge.DependencyRelationTypes()
+	vals := []any{id}
+	for _, t := range types {
+		vals = append(vals, t)
+	}
+	vals = append(vals, otherID)
+	var n int
+	err := tx.QueryRowContext(ctx, `
+		WITH RECURSIVE upstream(id) AS (
+			SELECT ?
+			UNION
+			SELECT entry_relations.to_id FROM entry_relations
+			JOIN upstream ON entry_relations.from_id=upstream.id
+			WHERE entry_relations.typ IN (?`+strings.Repeat(", ?", len(types)-1)+`)
+		)
+		SELECT COUNT(*) FROM upstream WHERE id=?
+	`,
+		vals...,
+	).Scan(&n)
+	if err != nil {
+		return false, err
+	}
+	return n != 0, nil
+}
+
+func DeleteRelation(db *sql.DB, ctx context.Context, r *forge.Relation) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	err = deleteRelation(tx, ctx, r)
+	if err != nil {
+		return err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func deleteRelation(tx *sql.Tx, ctx context.Context, r *forge.Relation) error {
+	err := userWrite(tx, ctx, r.From)
+	if err != nil {
+		return err
+	}
+	rels, err := findRelations(tx, ctx, forge.RelationFinder{From: &r.From, To: &r.To, Type: &r.Type})
+	if err != nil {
+		return err
+	}
+	if len(rels) == 0 {
+		return forge.NotFound("relation not found: %v %v %v", r.From, r.Type, r.To)
+	}
+	result, err := tx.ExecContext(ctx, `
+		DELETE FROM entry_relations
+		WHERE id=?
+	`,
+		rels[0].ID,
+	)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n != 1 {
+		return fmt.Errorf("want 1 relation affected, got %v", n)
+	}
+	user := forge.UserNameFromContext(ctx)
+	if user == "" {
+		return forge.Unauthorized("context user unspecified")
+	}
+	err = addLog(tx, ctx, &forge.Log{
+		EntryPath: r.From,
+		User:      user,
+		Action:    "delete",
+		Category:  "relation",
+		Name:      r.Type,
+		Type:      "entry_path",
+		Value:     r.To,
+	})
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// deleteEntryRelations deletes relations from or to an entry, when the entry is deleted.
+func deleteEntryRelations(tx *sql.Tx, ctx context.Context, id int) error {
+	_, err := tx.ExecContext(ctx, `
+		DELETE FROM entry_relations
+		WHERE from_id=? OR to_id=?
+	`,
+		id,
+		id,
+	)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func RelationGraph(db *sql.DB, ctx context.Context, find forge.RelationGraphFinder) (*forge.RelationGraph, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	g, err := relationGraph(tx, ctx, find)
+	if err != nil {
+		return nil, err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// relationGraph follows relations from an entry breadth first.
+// It doesn't follow relations to the entries those the user cannot see.
+func relationGraph(tx *sql.Tx, ctx context.Context, find forge.RelationGraphFinder) (*forge.RelationGraph, error) {
+	err := userRead(tx, ctx, find.Path)
+	if err != nil {
+		return nil, err
+	}
+	downstream := false
+	switch find.Direction {
+	case "upstream":
+	case "downstream":
+		downstream = true
+	default:
+		return nil, fmt.Errorf("invalid relation direction: %v", find.Direction)
+	}
+	followType := make(map[string]bool)
+	for _, t := range find.Types {
+		followType[t] = true
+	}
+	g := &forge.RelationGraph{
+		Root:      find.Path,
+		Direction: find.Direction,
+		Relations: make([]*forge.Relation, 0),
+	}
+	visited := map[string]bool{find.Path: true}
+	seenRel := make(map[int]bool)
+	current := []string{find.Path}
+	for depth := 1; len(current) != 0; depth++ {
+		if find.Depth != 0 && depth > find.Depth {
+			break
+		}
+		next := make([]string, 0)
+		for _, pth := range current {
+			finder := forge.RelationFinder{From: &pth}
+			if downstream {
+				finder = forge.RelationFinder{To: &pth}
+			}
+			rels, err := findRelations(tx, ctx, finder)
+			if err != nil {
+				return nil, err
+			}
+			rels, err = visibleRelations(tx, ctx, rels)
+			if err != nil {
+				return nil, err
+			}
+			for _, r := range rels {
+				if len(followType) != 0 && !followType[r.Type] {
+					continue
+				}
+				if seenRel[r.ID] {
+					continue
+				}
+				seenRel[r.ID] = true
+				g.Relations = append(g.Relations, r)
+				other := r.To
+				if downstream {
+					other = r.From
+				}
+				if !visited[other] {
+					visited[other] = true
+					next = append(next, other)
+				}
+			}
+		}
+		current = next
+	}
+	return g, nil
+}
@@ -3,7 +3,6 @@ package sqlite
 import (
 	"context"
 	"database/sql"
-	"errors"
 	"fmt"
 	"strconv"
 	"strings"
@@ -144,6 +143,21 @@ func searchEntries(tx *sql.Tx, ctx context.Context, search forge.EntrySearcher)
 	if len(wheres) == 0 {
 		return nil, nil
 	}
+	// properties those the user cannot see shouldn't be matched.
+	hiddenIDs, err := hiddenDefaultIDs(tx, ctx)
+	if err != nil {
+		return nil, err
+	}
+	visibleProp := "TRUE"
+	visibleRef := "TRUE"
+	if len(hiddenIDs) != 0 {
+		ids := make([]string, 0, len(hiddenIDs))
+		for _, id := range hiddenIDs {
+			ids = append(ids, strconv.Itoa(id))
+		}
+		visibleProp = "default_properties.id NOT IN (" + strings.Join(ids, ", ") + ")"
+		visibleRef = "refs.default_id NOT IN (" + strings.Join(ids, ", ") + ")"
+	}
 
 	// handle '(sub)', '(*)' queries separately to join them with INTERSECT.
 	// eventually merge it to innerQueries.
@@ -168,15 +182,22 @@ func searchEntries(tx *sql.Tx, ctx context.Context, search forge.EntrySearcher)
 			// Generic search. Not tied to a property.
 			queries = append(queries, `
 				(entries.path GLOB ? OR
-					(default_properties.name NOT GLOB '.*' AND
+					(default_properties.name NOT GLOB '.*' AND `+visibleProp+` AND
 						(
-							(default_properties.type!='user' AND properties.val GLOB ?) OR
+							(default_properties.type NOT IN ('user', 'users', 'lookup') AND properties.val GLOB ?) OR
 							(default_properties.type='user' AND properties.id IN
 								(SELECT properties.id FROM properties
 									LEFT JOIN accessors ON properties.val=accessors.id
 									LEFT JOIN default_properties ON properties.default_id=default_properties.id
 									WHERE default_properties.type='user' AND (accessors.called GLOB ? OR accessors.name GLOB ?)
 								)
+							) OR
+							(default_properties.type='users' AND properties.id IN
+								(SELECT properties.id FROM properties
+									JOIN accessors ON properties.val GLOB '*' || char(10) || accessors.id || char(10) || '*'
+									LEFT JOIN default_properties ON properties.default_id=default_properties.id
+									WHERE default_properties.type='users' AND (accessors.called GLOB ? OR accessors.name GLOB ?)
+								)
 							)
 						)
 					)
@@ -187,7 +208,7 @@ func searchEntries(tx *sql.Tx, ctx context.Context, search forge.EntrySearcher)
 				// relative path
 				pathl = search.SearchRoot + "*" + rawval + "*"
 			}
-			queryVals = append(queryVals, pathl, val, val, val)
+			queryVals = append(queryVals, pathl, val, val, val, val, val)
 		} else if key == "path" {
 			// special keyword "path"
 			vals := wh.Values()
@@ -267,6 +288,33 @@ func searchEntries(tx *sql.Tx, ctx context.Context, search forge.EntrySearcher)
 				q := "(entries.id " + not + " IN (SELECT entries.parent_id FROM entries WHERE entries.parent_id IS NOT NULL))"
 				queries = append(queries, q)
 			}
+		} else if key == "refs" {
+			// special keyword "refs", finds entries those reference the entry.
+			wh.Exact = true
+			vals := wh.Values()
+			if len(vals) != 0 {
+				not := ""
+				if wh.Exclude {
+					not = "NOT"
+				}
+				q := "("
+				for i, v := range vals {
+					if i != 0 {
+						q += " OR "
+					}
+					q += `entries.id ` + not + ` IN (SELECT refs.entry_id FROM (` + referenceQuery("targets.path=?") + `) AS refs
+						WHERE refs.entry_id!=refs.target_id AND ` + visibleRef + `
+					)`
+					if !strings.HasPrefix(v, "/") {
+						// relative path
+						v = search.SearchRoot + "/" + v
+					}
+					// the target where clause is used twice in the reference query.
+					queryVals = append(queryVals, v, v)
+				}
+				q += ")"
+				queries = append(queries, q)
+			}
 		} else if key == "updated" {
 			wh.Exact = true // there will be too many results if we allow in-exact search.
 			for _, v := range wh.Values() {
@@ -320,11 +368,13 @@ func searchEntries(tx *sql.Tx, ctx context.Context, search forge.EntrySearcher)
 						return q, []any{ts, te}
 					}
 				}()
-				queries = append(queries, q)
+				queries = append(queries, "("+q+" AND "+visibleProp+")")
 				queryVals = append(queryVals, vs...)
 			}
 		} else {
-			q := fmt.Sprintf("(default_properties.name=? AND ")
+			// lookup properties and empty inheritable properties are evaluated at read time,
+			// will be filtered with filterResolved.
+			q := fmt.Sprintf("(default_properties.name=? AND %s AND (default_properties.type='lookup' OR (default_properties.inheritable AND properties.val='') OR ", visibleProp)
 			queryVals = append(queryVals, key)
 			not := ""
 			if wh.Exclude {
@@ -389,9 +439,37 @@ func searchEntries(tx *sql.Tx, ctx context.Context, search forge.EntrySearcher)
 						userWhere = "TRUE"
 					}
 				}
+				// users
+				// the user also matches to groups having the user as a member, when it is exact search.
+				usersCmp := ""
+				usersVals := make([]any, 0)
+				if v != "" {
+					memberOf := ""
+					if wh.Exact {
+						memberOf = `OR accessors.id IN
+									(SELECT group_members.group_id FROM group_members
+										LEFT JOIN accessors AS members ON group_members.member_id=members.id
+										WHERE members.name=?
+									)`
+						usersVals = append(usersVals, vl, vl, v)
+					} else {
+						usersVals = append(usersVals, vl, vl)
+					}
+					usersCmp = fmt.Sprintf(`properties.id IN
+						(SELECT properties.id FROM properties
+							JOIN accessors ON properties.val GLOB '*' || char(10) || accessors.id || char(10) || '*'
+							LEFT JOIN default_properties ON properties.default_id=default_properties.id
+							WHERE default_properties.type='users' AND (accessors.called %s ? OR accessors.name %s ? %s)
+						)`, eq, eq, memberOf)
+				} else {
+					usersCmp = "properties.val=''"
+					if !wh.Exact {
+						usersCmp = "TRUE"
+					}
+				}
 				vq := fmt.Sprintf(`
 					(
-						(default_properties.type NOT IN ('tag', 'entry_link', 'user', 'date') AND properties.val %s ?) OR
+						(default_properties.type NOT IN ('tag', 'entry_link', 'user', 'users', 'date') AND properties.val %s ?) OR
 						(default_properties.type IN ('tag', 'entry_link') AND properties.val GLOB %s) OR
 						(default_properties.type='date' AND %s) OR
 						(default_properties.type='user' AND properties.id IN
@@ -400,15 +478,17 @@ func searchEntries(tx *sql.Tx, ctx context.Context, search forge.EntrySearcher)
 								LEFT JOIN default_properties ON properties.default_id=default_properties.id
 								WHERE default_properties.type='user' AND %s
 							)
-						)
+						) OR
+						(default_properties.type='users' AND %s)
 					)
-				`, eq, itemGlob, dateCmp, userWhere)
+				`, eq, itemGlob, dateCmp, userWhere, usersCmp)
 				queryVals = append(queryVals, vl)
 				queryVals = append(queryVals, dateVals...)
 				queryVals = append(queryVals, whereVals...)
+				queryVals = append(queryVals, usersVals...)
 				q += vq
 			}
-			q += "))"
+			q += ")))"
 			queries = append(queries, q)
 		}
 		if sub != "" {
@@ -597,18 +677,16 @@ func searchEntries(tx *sql.Tx, ctx context.Context, search forge.EntrySearcher)
 		if thumbID != nil {
 			e.HasThumbnail = true
 		}
-		err = userRead(tx, ctx, e.Path)
-		if err != nil {
-			var e *forge.NotFoundError
-			if !errors.As(err, &e) {
-				return nil, err
-			}
-			// userRead returns forge.NotFoundError
-			// because of the user doesn't have permission to see the entry.
-			continue
-		}
 		ents = append(ents, e)
 	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+	ents, err = readableEntries(tx, ctx, ents)
+	if err != nil {
+		return nil, err
+	}
 	for _, e := range ents {
 		e.Property = make(map[string]*forge.Property)
 		props, err := entryProperties(tx, ctx, e.Path)
@@ -619,9 +697,127 @@ func searchEntries(tx *sql.Tx, ctx context.Context, search forge.EntrySearcher)
 			e.Property[p.Name] = p
 		}
 	}
+	ents = filterResolved(tx, ctx, ents, wheres)
 	return ents, nil
 }
 
+// filterResolved filters entries with properties those are resolved at read time, as they cannot be searched in db.
+// They are lookup properties, and inheritable properties those don't have their own value.
+// The resolved value will be compared as like the resolved type.
+// Note that it only handles the properties of the entries, not of the sub entries.
+func filterResolved(tx *sql.Tx, ctx context.Context, ents []*forge.Entry, wheres []where) []*forge.Entry {
+	propWheres := make([]where, 0)
+	for _, wh := range wheres {
+		if wh.Sub != "" {
+			continue
+		}
+		switch wh.Key {
+		case "", "path", "name", "type", "has", "refs", "updated":
+			continue
+		}
+		propWheres = append(propWheres, wh)
+	}
+	if len(propWheres) == 0 {
+		return ents
+	}
+	filtered := make([]*forge.Entry, 0, len(ents))
+	for _, e := range ents {
+		match := true
+		for _, wh := range propWheres {
+			p := e.Property[wh.Key]
+			if p == nil {
+				continue
+			}
+			var resolved *forge.Property
+			if p.Type == "lookup" {
+				resolved = p.Lookup
+			} else if p.Inheritable && p.RawValue == "" {
+				// nil when there is nothing to inherit.
+				if p.InheritedFrom != "" {
+					resolved = p
+				}
+			} else {
+				continue
+			}
+			if !matchResolved(tx, ctx, resolved, wh) {
+				match = false
+				break
+			}
+		}
+		if match {
+			filtered = append(filtered, e)
+		}
+	}
+	return filtered
+}
+
+// matchResolved checks the resolved value of a property matches to the where.
+// Nil property is treated as an empty value.
+func matchResolved(tx *sql.Tx, ctx context.Context, p *forge.Property, wh where) bool {
+	typ := ""
+	value := ""
+	items := []string{""}
+	if p != nil {
+		typ = p.Type
+		value = p.Value
+		items = []string{value}
+		if strings.Contains(value, "\n") {
+			// multi item types like tag, entry_link and users.
+			items = append(items, strings.Split(value, "\n")...)
+		}
+		if p.Eval != value {
+			// user types also can be searched with the called name.
+			items = append(items, p.Eval)
+			items = append(items, strings.Split(p.Eval, "\n")...)
+		}
+	}
+	match := false
+	for _, v := range strings.Split(wh.Val, ",") {
+		v = expandSpecialValue(tx, ctx, v)
+		switch wh.Cmp {
+		case "<", "<=", ">", ">=":
+			if value == "" {
+				continue
+			}
+			if typ == "date" {
+				ds, de := expandValueForDate(tx, ctx, v, wh.Cmp)
+				if de != "" {
+					// date range not suitable for these comparison types
+					continue
+				}
+				v = ds
+			}
+			c := forge.CompareProperty(typ, value, v)
+			switch wh.Cmp {
+			case "<":
+				match = c < 0
+			case "<=":
+				match = c <= 0
+			case ">":
+				match = c > 0
+			case ">=":
+				match = c >= 0
+			}
+		default:
+			for _, item := range items {
+				if wh.Exact && item == v {
+					match = true
+				}
+				if !wh.Exact && strings.Contains(item, v) {
+					match = true
+				}
+			}
+		}
+		if match {
+			break
+		}
+	}
+	if wh.Exclude {
+		return !match
+	}
+	return match
+}
+
 func expandSpecialValue(tx *sql.Tx, ctx context.Context, v string) string {
 	if strings.HasPrefix(v, "@today") {
 		day := time.Now().Local()
@@ -94,14 +94,54 @@ func (s *Service) GetEntry(ctx context.Context, path string) (*forge.Entry, erro
 	return GetEntry(s.db, ctx, path)
 }
 
+func (s *Service) GetEntryByID(ctx context.Context, id int) (*forge.Entry, error) {
+	return GetEntryByID(s.db, ctx, id)
+}
+
+func (s *Service) GetMovedEntryPath(ctx context.Context, path string) (string, error) {
+	return GetMovedEntryPath(s.db, ctx, path)
+}
+
+func (s *Service) FindReferences(ctx context.Context, path string) ([]*forge.Reference, error) {
+	return FindReferences(s.db, ctx, path)
+}
+
+func (s *Service) FindRelations(ctx context.Context, find forge.RelationFinder) ([]*forge.Relation, error) {
+	return FindRelations(s.db, ctx, find)
+}
+
+func (s *Service) AddRelation(ctx context.Context, r *forge.Relation) error {
+	return AddRelation(s.db, ctx, r)
+}
+
+func (s *Service) DeleteRelation(ctx context.Context, r *forge.Relation) error {
+	return DeleteRelation(s.db, ctx, r)
+}
+
+func (s *Service) RelationGraph(ctx context.Context, find forge.RelationGraphFinder) (*forge.RelationGraph, error) {
+	return RelationGraph(s.db, ctx, find)
+}
+
 func (s *Service) AddEntry(ctx context.Context, ent *forge.Entry) error {
 	return AddEntry(s.db, ctx, ent)
 }
 
+func (s *Service) AddEntries(ctx context.Context, ents []*forge.Entry) error {
+	return AddEntries(s.db, ctx, ents)
+}
+
+func (s *Service) DryRunAddEntries(ctx context.Context, ents []*forge.Entry) ([]string, error) {
+	return DryRunAddEntries(s.db, ctx, ents)
+}
+
 func (s *Service) RenameEntry(ctx context.Context, path, newName string) error {
 	return RenameEntry(s.db, ctx, path, newName)
 }
 
+func (s *Service) RenameEntries(ctx context.Context, parent string, renames map[string]string) error {
+	return RenameEntries(s.db, ctx, parent, renames)
+}
+
 func (s *Service) ArchiveEntry(ctx context.Context, path string) error {
 	return ArchiveEntry(s.db, ctx, path)
 }
@@ -118,6 +158,10 @@ func (s *Service) DeleteEntryRecursive(ctx context.Context, path string) error {
 	return DeleteEntryRecursive(s.db, ctx, path)
 }
 
+func (s *Service) ForceDeleteEntryRecursive(ctx context.Context, path string) error {
+	return ForceDeleteEntryRecursive(s.db, ctx, path)
+}
+
 func (s *Service) GetThumbnail(ctx context.Context, path string) (*forge.Thumbnail, error) {
 	return GetThumbnail(s.db, ctx, path)
 }
@@ -150,6 +194,10 @@ func (s *Service) UpdateProperties(ctx context.Context, upds []forge.PropertyUpd
 	return UpdateProperties(s.db, ctx, upds)
 }
 
+func (s *Service) DryRunUpdateProperties(ctx context.Context, upds []forge.PropertyUpdater) error {
+	return DryRunUpdateProperties(s.db, ctx, upds)
+}
+
 func (s *Service) EntryEnvirons(ctx context.Context, path string) ([]*forge.Property, error) {
 	return EntryEnvirons(s.db, ctx, path)
 }
@@ -198,6 +246,10 @@ func (s *Service) DeleteAccess(ctx context.Context, path, name string) error {
 	return DeleteAccess(s.db, ctx, path, name)
 }
 
+func (s *Service) ExplainAccess(ctx context.Context, user, path string) (*forge.AccessExplanation, error) {
+	return ExplainAccess(s.db, ctx, user, path)
+}
+
 func (s *Service) IsAdmin(ctx context.Context, user string) (bool, error) {
 	return IsAdmin(s.db, ctx, user)
 }
@@ -218,10 +270,30 @@ func (s *Service) AddUser(ctx context.Context, u *forge.User) error {
 	return AddUser(s.db, ctx, u)
 }
 
+func (s *Service) SignUpUser(ctx context.Context, u *forge.User, groups []string) error {
+	return SignUpUser(s.db, ctx, u, groups)
+}
+
+func (s *Service) ApproveUser(ctx context.Context, user string, groups []string) error {
+	return ApproveUser(s.db, ctx, user, groups)
+}
+
+func (s *Service) RejectUser(ctx context.Context, user string) error {
+	return RejectUser(s.db, ctx, user)
+}
+
 func (s *Service) UpdateUser(ctx context.Context, upd forge.UserUpdater) error {
 	return UpdateUser(s.db, ctx, upd)
 }
 
+func (s *Service) RenameUser(ctx context.Context, name, newName string) error {
+	return RenameUser(s.db, ctx, name, newName)
+}
+
+func (s *Service) MergeUsers(ctx context.Context, from, to string) error {
+	return MergeUsers(s.db, ctx, from, to)
+}
+
 func (s *Service) GetUser(ctx context.Context, user string) (*forge.User, error) {
 	return GetUser(s.db, ctx, user)
 }
@@ -282,6 +354,10 @@ func (s *Service) UpdateGroup(ctx context.Context, upd forge.GroupUpdater) error
 	return UpdateGroup(s.db, ctx, upd)
 }
 
+func (s *Service) DeleteGroup(ctx context.Context, name string, cascade bool) (*forge.GroupReferences, error) {
+	return DeleteGroup(s.db, ctx, name, cascade)
+}
+
 func (s *Service) FindGroupMembers(ctx context.Context, find forge.MemberFinder) ([]*forge.Member, error) {
 	return FindGroupMembers(s.db, ctx, find)
 }
@@ -293,3 +369,7 @@ func (s *Service) AddGroupMember(ctx context.Context, m *forge.Member) error {
 func (s *Service) DeleteGroupMember(ctx context.Context, group, member string) error {
 	return DeleteGroupMember(s.db, ctx, group, member)
 }
+
+func (s *Service) SyncGroupMembers(ctx context.Context, member string, managed, groups []string) error {
+	return SyncGroupMembers(s.db, ctx, member, managed, groups)
+}
@@ -96,6 +96,14 @@ func Init(db *sql.DB) error {
 	if err != nil {
 		return err
 	}
+	err = createEntryPathHistoryTable(tx)
+	if err != nil {
+		return err
+	}
+	err = createEntryRelationsTable(tx)
+	if err != nil {
+		return err
+	}
 	err = createAccessorsTable(tx) // for user and group
 	if err != nil {
 		return err
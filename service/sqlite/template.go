@@ -0,0 +1,139 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/imagvfx/forge"
+)
+
+// expandDefaultValue expands the default value template for a new entry.
+// It returns the value as is when it is not a template. See forge.CheckTemplateKey for the keys.
+func expandDefaultValue(tx *sql.Tx, ctx context.Context, e *forge.Entry, d *forge.Default) (string, error) {
+	if d.Type == "formula" || !forge.IsValueTemplate(d.Value) {
+		return d.Value, nil
+	}
+	v := strings.TrimSpace(d.Value)
+	if v == "@user" || strings.HasPrefix(v, "@today") {
+		return expandSpecialValue(tx, ctx, v), nil
+	}
+	// seqMark marks where the sequence number will be placed.
+	// The number will be decided after the other parts are expanded.
+	const seqMark = "\x00"
+	tmpl := strings.TrimPrefix(v, "@")
+	seqWidth := 0
+	parentPath := path.Dir(e.Path)
+	val, err := forge.ExpandTemplate(tmpl, func(key string) (string, error) {
+		err := forge.CheckTemplateKey(key)
+		if err != nil {
+			return "", err
+		}
+		switch key {
+		case "name":
+			return path.Base(e.Path), nil
+		case "path":
+			return e.Path, nil
+		case "type":
+			return e.Type, nil
+		}
+		if key == "seq" || strings.HasPrefix(key, "seq:") {
+			if seqWidth != 0 {
+				return "", fmt.Errorf("invalid template: only one sequence is allowed: %v", tmpl)
+			}
+			seqWidth = 1
+			if w, ok := strings.CutPrefix(key, "seq:"); ok {
+				seqWidth, err = forge.SequenceWidth(w)
+				if err != nil {
+					return "", err
+				}
+			}
+			return seqMark, nil
+		}
+		if prop, ok := strings.CutPrefix(key, "parent."); ok {
+			switch prop {
+			case "name":
+				return path.Base(parentPath), nil
+			case "path":
+				return parentPath, nil
+			case "type":
+				return getEntryType(tx, ctx, parentPath)
+			}
+			p, err := getProperty(tx, ctx, parentPath, prop)
+			if err != nil {
+				return "", err
+			}
+			return p.Value, nil
+		}
+		name, _ := strings.CutPrefix(key, "env.")
+		// environs of the new entry are not created yet.
+		envs, err := entryEnvirons(tx, ctx, parentPath)
+		if err != nil {
+			return "", err
+		}
+		for _, env := range envs {
+			if env.Name == name {
+				return env.Value, nil
+			}
+		}
+		return "", forge.NotFound("environ not found: %v", name)
+	})
+	if err != nil {
+		return "", fmt.Errorf("expand default value of %v: %w", d.Name, err)
+	}
+	if seqWidth == 0 {
+		return val, nil
+	}
+	prefix, suffix, _ := strings.Cut(val, seqMark)
+	n, err := nextSequence(tx, ctx, e.Path, d.Name, prefix, suffix)
+	if err != nil {
+		return "", err
+	}
+	return prefix + fmt.Sprintf("%0*d", seqWidth, n) + suffix, nil
+}
+
+// nextSequence returns the next number of a sequence in the property of sibling entries.
+// Only values of prefix + number + suffix form are counted.
+func nextSequence(tx *sql.Tx, ctx context.Context, entPath, name, prefix, suffix string) (int, error) {
+	rows, err := tx.QueryContext(ctx, `
+		SELECT properties.val FROM properties
+		LEFT JOIN entries ON properties.entry_id=entries.id
+		LEFT JOIN default_properties ON properties.default_id=default_properties.id
+		WHERE
+			entries.parent_id=(SELECT parent_id FROM entries WHERE path=?) AND
+			entries.path!=? AND
+			default_properties.name=?
+	`,
+		entPath,
+		entPath,
+		name,
+	)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+	re := regexp.MustCompile("^" + regexp.QuoteMeta(prefix) + `(\d+)` + regexp.QuoteMeta(suffix) + "$")
+	last := 0
+	for rows.Next() {
+		var val string
+		err := rows.Scan(&val)
+		if err != nil {
+			return 0, err
+		}
+		m := re.FindStringSubmatch(val)
+		if m == nil {
+			continue
+		}
+		n, err := strconv.Atoi(m[1])
+		if err != nil {
+			// too big
+			continue
+		}
+		last = max(last, n)
+	}
+	return last + 1, nil
+}
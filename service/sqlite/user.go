@@ -46,6 +46,10 @@ func findUsers(tx *sql.Tx, ctx context.Context, find forge.UserFinder) ([]*forge
 		keys = append(keys, "disabled=?")
 		vals = append(vals, *find.Disabled)
 	}
+	if find.Pending != nil {
+		keys = append(keys, "pending=?")
+		vals = append(vals, *find.Pending)
+	}
 	where := ""
 	if len(keys) != 0 {
 		where = "WHERE " + strings.Join(keys, " AND ")
@@ -55,7 +59,8 @@ func findUsers(tx *sql.Tx, ctx context.Context, find forge.UserFinder) ([]*forge
 			id,
 			name,
 			called,
-			disabled
+			disabled,
+			pending
 		FROM accessors
 		`+where+`
 		ORDER BY id ASC
@@ -74,6 +79,7 @@ func findUsers(tx *sql.Tx, ctx context.Context, find forge.UserFinder) ([]*forge
 			&u.Name,
 			&u.Called,
 			&u.Disabled,
+			&u.Pending,
 		)
 		if err != nil {
 			return nil, err
@@ -187,24 +193,30 @@ func addUser(tx *sql.Tx, ctx context.Context, u *forge.User) error {
 	firstUser := false
 	if len(users) == 0 {
 		firstUser = true
+		// there is no one who can approve the first user.
+		u.Pending = false
 	}
 	_, domain, err := splitUserName(u.Name)
 	if err != nil {
 		return err
 	}
+	// a pending user cannot do anything until approved.
+	u.Disabled = u.Pending
 	_, err = tx.ExecContext(ctx, `
 		INSERT INTO accessors (
 			is_group,
 			name,
 			called,
-			disabled
+			disabled,
+			pending
 		)
-		VALUES (?, ?, ?, ?)
+		VALUES (?, ?, ?, ?, ?)
 	`,
 		false,
 		u.Name,
 		u.Called,
-		false,
+		u.Disabled,
+		u.Pending,
 	)
 	if err != nil {
 		return err
@@ -220,9 +232,18 @@ func addUser(tx *sql.Tx, ctx context.Context, u *forge.User) error {
 			return err
 		}
 	}
-	// add everyone group if the user is first one who is signed with this domain.
+	err = ensureDomainGroup(tx, ctx, domain)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// ensureDomainGroup adds everyone group of the domain,
+// if the user is first one who is signed with this domain.
+func ensureDomainGroup(tx *sql.Tx, ctx context.Context, domain string) error {
 	everyone := "everyone@" + domain
-	_, err = getGroup(tx, ctx, everyone)
+	_, err := getGroup(tx, ctx, everyone)
 	if err != nil {
 		var e *forge.NotFoundError
 		if !errors.As(err, &e) {
@@ -293,6 +314,9 @@ func updateUser(tx *sql.Tx, ctx context.Context, upd forge.UserUpdater) error {
 			return fmt.Errorf("admin user cannot be disabled: %v", upd.Name) // or enabled, too
 		}
 		disabled := *upd.Disabled
+		if !disabled && u.Pending {
+			return fmt.Errorf("user is pending, approve or reject the user instead: %v", upd.Name)
+		}
 		if disabled != u.Disabled {
 			keys = append(keys, "disabled=?")
 			vals = append(vals, disabled)
@@ -321,3 +345,373 @@ func updateUser(tx *sql.Tx, ctx context.Context, upd forge.UserUpdater) error {
 	}
 	return nil
 }
+
+func RenameUser(db *sql.DB, ctx context.Context, name, newName string) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	user := forge.UserNameFromContext(ctx)
+	if user == "" {
+		return forge.Unauthorized("context user unspecified")
+	}
+	yes, err := isAdmin(tx, ctx, user)
+	if err != nil {
+		return err
+	}
+	if !yes {
+		return forge.Unauthorized("user doesn't have permission to rename user: %v", user)
+	}
+	err = renameUser(tx, ctx, name, newName)
+	if err != nil {
+		return err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// renameUser renames a user, when the user's email is changed for example.
+// The user keeps the accessor id, so properties, access controls, group memberships
+// and settings of the user aren't affected. Logs of the user will have the new name.
+func renameUser(tx *sql.Tx, ctx context.Context, name, newName string) error {
+	u, err := getUser(tx, ctx, name)
+	if err != nil {
+		return err
+	}
+	_, domain, err := splitUserName(newName)
+	if err != nil {
+		return err
+	}
+	_, err = getAccessor(tx, ctx, newName)
+	if err == nil {
+		return fmt.Errorf("accessor already exists: %v", newName)
+	}
+	var e *forge.NotFoundError
+	if !errors.As(err, &e) {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `
+		UPDATE accessors
+		SET name=?
+		WHERE is_group=0 AND id=?
+	`,
+		newName,
+		u.ID,
+	)
+	if err != nil {
+		return err
+	}
+	// logs save the name of users.
+	_, err = tx.ExecContext(ctx, `UPDATE logs SET user=? WHERE user=?`, newName, name)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `UPDATE logs SET impersonator=? WHERE impersonator=?`, newName, name)
+	if err != nil {
+		return err
+	}
+	err = ensureDomainGroup(tx, ctx, domain)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func MergeUsers(db *sql.DB, ctx context.Context, from, to string) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	user := forge.UserNameFromContext(ctx)
+	if user == "" {
+		return forge.Unauthorized("context user unspecified")
+	}
+	yes, err := isAdmin(tx, ctx, user)
+	if err != nil {
+		return err
+	}
+	if !yes {
+		return forge.Unauthorized("user doesn't have permission to merge users: %v", user)
+	}
+	if from == user {
+		return fmt.Errorf("cannot merge the context user into another user: %v", user)
+	}
+	err = mergeUsers(tx, ctx, from, to)
+	if err != nil {
+		return err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// mergeUsers merges a user into another user, then removes the user.
+// It re-points properties, access controls, group memberships, settings and data of the user.
+// When both users have one of them, the one of the target user is kept.
+// Logs of the removed user are re-pointed to the target user like renameUser does,
+// and a log of the merge is added to keep the former name in the history.
+func mergeUsers(tx *sql.Tx, ctx context.Context, from, to string) error {
+	if from == to {
+		return fmt.Errorf("cannot merge a user into the same user: %v", from)
+	}
+	fu, err := getUser(tx, ctx, from)
+	if err != nil {
+		return err
+	}
+	tu, err := getUser(tx, ctx, to)
+	if err != nil {
+		return err
+	}
+	props, err := accessorProperties(tx, ctx, fu.ID)
+	if err != nil {
+		return err
+	}
+	for _, p := range props {
+		err := replaceAccessorInProperty(tx, ctx, p, fu.ID, tu.ID)
+		if err != nil {
+			return err
+		}
+	}
+	// the tables have an unique constraint with the user id, and the other columns below.
+	repoints := []struct {
+		table  string
+		column string
+		others []string
+	}{
+		{table: "access_controls", column: "accessor_id", others: []string{"entry_id"}},
+		{table: "default_accesses", column: "accessor_id", others: []string{"entry_type_id"}},
+		{table: "group_members", column: "member_id", others: []string{"group_id"}},
+		{table: "user_settings", column: "user_id", others: []string{"key"}},
+		{table: "user_data", column: "user_id", others: []string{"section", "key"}},
+	}
+	for _, r := range repoints {
+		// delete ones those the target user has already.
+		match := make([]string, 0, len(r.others))
+		for _, o := range r.others {
+			match = append(match, "t."+o+"="+r.table+"."+o)
+		}
+		_, err := tx.ExecContext(ctx, `
+			DELETE FROM `+r.table+`
+			WHERE `+r.column+`=? AND EXISTS (
+				SELECT 1 FROM `+r.table+` AS t
+				WHERE t.`+r.column+`=? AND `+strings.Join(match, " AND ")+`
+			)
+		`,
+			fu.ID,
+			tu.ID,
+		)
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, `
+			UPDATE `+r.table+`
+			SET `+r.column+`=?
+			WHERE `+r.column+`=?
+		`,
+			tu.ID,
+			fu.ID,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	result, err := tx.ExecContext(ctx, `
+		DELETE FROM accessors
+		WHERE is_group=0 AND id=?
+	`,
+		fu.ID,
+	)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n != 1 {
+		return fmt.Errorf("want 1 user affected, got %v", n)
+	}
+	// logs save the name of users.
+	_, err = tx.ExecContext(ctx, `UPDATE logs SET user=? WHERE user=?`, to, from)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `UPDATE logs SET impersonator=? WHERE impersonator=?`, to, from)
+	if err != nil {
+		return err
+	}
+	err = addLog(tx, ctx, &forge.Log{
+		User:     forge.UserNameFromContext(ctx),
+		Action:   "merge",
+		Category: "user",
+		Name:     to,
+		Type:     "merge",
+		Value:    from,
+	})
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func SignUpUser(db *sql.DB, ctx context.Context, u *forge.User, groups []string) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	err = addUser(tx, ctx, u)
+	if err != nil {
+		return err
+	}
+	if !u.Pending {
+		err = addDefaultGroups(tx, ctx, u.Name, groups)
+		if err != nil {
+			return err
+		}
+	}
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func ApproveUser(db *sql.DB, ctx context.Context, name string, groups []string) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	user := forge.UserNameFromContext(ctx)
+	if user == "" {
+		return forge.Unauthorized("context user unspecified")
+	}
+	yes, err := isAdmin(tx, ctx, user)
+	if err != nil {
+		return err
+	}
+	if !yes {
+		return forge.Unauthorized("user doesn't have permission to approve user: %v", user)
+	}
+	err = approveUser(tx, ctx, name, groups)
+	if err != nil {
+		return err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// approveUser enables a pending user, and adds the user to the groups.
+func approveUser(tx *sql.Tx, ctx context.Context, name string, groups []string) error {
+	u, err := getUser(tx, ctx, name)
+	if err != nil {
+		return err
+	}
+	if !u.Pending {
+		return fmt.Errorf("user is not pending: %v", name)
+	}
+	_, err = tx.ExecContext(ctx, `
+		UPDATE accessors
+		SET disabled=0, pending=0
+		WHERE id=?
+	`,
+		u.ID,
+	)
+	if err != nil {
+		return err
+	}
+	err = addDefaultGroups(tx, ctx, name, groups)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func RejectUser(db *sql.DB, ctx context.Context, name string) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	user := forge.UserNameFromContext(ctx)
+	if user == "" {
+		return forge.Unauthorized("context user unspecified")
+	}
+	yes, err := isAdmin(tx, ctx, user)
+	if err != nil {
+		return err
+	}
+	if !yes {
+		return forge.Unauthorized("user doesn't have permission to reject user: %v", user)
+	}
+	err = rejectUser(tx, ctx, name)
+	if err != nil {
+		return err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// rejectUser takes a user out of the pending queue.
+// The user remains disabled, so admins can still enable or merge the user later.
+func rejectUser(tx *sql.Tx, ctx context.Context, name string) error {
+	u, err := getUser(tx, ctx, name)
+	if err != nil {
+		return err
+	}
+	if !u.Pending {
+		return fmt.Errorf("user is not pending: %v", name)
+	}
+	_, err = tx.ExecContext(ctx, `
+		UPDATE accessors
+		SET pending=0
+		WHERE id=?
+	`,
+		u.ID,
+	)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// addDefaultGroups adds the user to the groups, those the user isn't a direct member of yet.
+// A group that doesn't exist is ignored, so a stale config doesn't block users.
+func addDefaultGroups(tx *sql.Tx, ctx context.Context, user string, groups []string) error {
+	for _, g := range groups {
+		_, err := getGroup(tx, ctx, g)
+		if err != nil {
+			var e *forge.NotFoundError
+			if !errors.As(err, &e) {
+				return err
+			}
+			continue
+		}
+		mems, err := findGroupMembers(tx, ctx, forge.MemberFinder{Group: g, Member: &user})
+		if err != nil {
+			return err
+		}
+		if len(mems) != 0 {
+			continue
+		}
+		err = addGroupMember(tx, ctx, &forge.Member{Group: g, Member: user})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
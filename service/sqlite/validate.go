@@ -34,13 +34,16 @@ func validateProperty(tx *sql.Tx, ctx context.Context, p, old *forge.Property) e
 		"timecode":   validateTimecode,
 		"text":       validateText,
 		"user":       validateUser,
+		"users":      validateUsers,
 		"entry_path": validateEntryPath,
 		"entry_name": validateEntryName,
 		"entry_link": validateEntryLink,
+		"lookup":     validateLookup,
 		"date":       validateDate,
 		"int":        validateInt,
 		"tag":        validateTag,
 		"search":     validateSearch,
+		"formula":    validateFormula,
 	}
 	validate := validateFn[p.Type]
 	if validate == nil {
@@ -146,35 +149,203 @@ func validateUser(tx *sql.Tx, ctx context.Context, p, old *forge.Property) error
 	return nil
 }
 
+// validateUsers validates users property which has an ordered list of users or groups.
+// It accepts +/- operations for each line like tag. ex) +someone@imagvfx.com
+func validateUsers(tx *sql.Tx, ctx context.Context, p, old *forge.Property) error {
+	ids := make([]int, 0)
+	if old != nil {
+		for _, v := range strings.Split(old.RawValue, "\n") {
+			v = strings.TrimSpace(v)
+			if v == "" || v == "[" || v == "]" {
+				continue
+			}
+			id, err := strconv.Atoi(v)
+			if err != nil {
+				return fmt.Errorf("invalid accessor id in users property: %v", v)
+			}
+			ids = append(ids, id)
+		}
+	}
+	val := ""
+	lines := strings.Split(p.Value, "\n")
+	for _, ln := range lines {
+		ln = strings.TrimSpace(ln)
+		if len(ln) == 0 {
+			continue
+		}
+		op := ln[0]
+		if op != '+' && op != '-' {
+			return fmt.Errorf("users property line should start with '+' or '-': %v", ln)
+		}
+		name := strings.TrimSpace(ln[1:])
+		if name == "" {
+			continue
+		}
+		a, err := getAccessor(tx, ctx, name)
+		if err != nil {
+			return err
+		}
+		idx := -1
+		for i, id := range ids {
+			if id == a.ID {
+				idx = i
+				break
+			}
+		}
+		switch op {
+		case '+':
+			// add, keep the order when it already exists.
+			val += "+" + name + "\n"
+			if idx < 0 {
+				ids = append(ids, a.ID)
+			}
+		case '-':
+			// remove
+			val += "-" + name + "\n"
+			if idx >= 0 {
+				ids = append(ids[:idx], ids[idx+1:]...)
+			}
+		}
+	}
+	// update p.Value so it only logs differences, not everything.
+	p.Value = strings.TrimSpace(val)
+	newlines := make([]string, 0, len(ids))
+	for _, id := range ids {
+		newlines = append(newlines, strconv.Itoa(id))
+	}
+	rawVal := strings.Join(newlines, "\n")
+	if rawVal != "" {
+		// for line matching search in sqlite.
+		// see validateTag for the reason of '[' and ']'.
+		rawVal = "[\n" + rawVal + "\n]"
+	}
+	p.RawValue = rawVal
+	return nil
+}
+
 func validateTimecode(tx *sql.Tx, ctx context.Context, p, old *forge.Property) error {
 	if p.Value == "" {
 		p.RawValue = ""
 		return nil
 	}
-	// Need 8 digits in what ever form.
-	isDigit := map[string]bool{
-		"0": true, "1": true, "2": true, "3": true, "4": true,
-		"5": true, "6": true, "7": true, "8": true, "9": true,
+	rate, err := entryFrameRate(tx, ctx, p.EntryPath)
+	if err != nil {
+		return err
+	}
+	// if the value starts with + or -, it will change the current timecode
+	// by frames (ex. +24) or by a timecode duration (ex. +00:00:01:00).
+	val := p.Value
+	possiblePrefix := rune(val[0])
+	if possiblePrefix == '+' || possiblePrefix == '-' {
+		amount := strings.TrimSpace(val[1:])
+		n, err := strconv.Atoi(amount)
+		if err != nil {
+			tc, ok := formalTimecode(amount)
+			if !ok {
+				return fmt.Errorf("invalid timecode operation: +/- operation needs frames or timecode, got: %v", amount)
+			}
+			// duration doesn't skip frame numbers even with drop frame rate.
+			n, err = forge.TimecodeToFrame(tc, forge.FrameRate{Nominal: rate.Nominal})
+			if err != nil {
+				return fmt.Errorf("invalid timecode operation: %v", err)
+			}
+		}
+		if possiblePrefix == '-' {
+			n *= -1
+		}
+		frame := 0
+		if old != nil && old.Value != "" {
+			frame, err = forge.TimecodeToFrame(old.Value, rate)
+			if err != nil {
+				return fmt.Errorf("invalid timecode string: %v", err)
+			}
+		}
+		tc, err := forge.FrameToTimecode(frame+n, rate)
+		if err != nil {
+			return fmt.Errorf("invalid timecode operation: %v", err)
+		}
+		p.Value = tc
+		p.RawValue = p.Value
+		return nil
+	}
+	tc, ok := formalTimecode(val)
+	if !ok {
+		return fmt.Errorf("invalid timecode string: %v", p.Value)
+	}
+	frame, err := forge.TimecodeToFrame(tc, rate)
+	if err != nil {
+		return fmt.Errorf("invalid timecode string: %v", err)
+	}
+	// make the value a formal form of timecode for the frame rate.
+	// ex) 00:00:00:00 or 00:00:00;00 for drop frame
+	p.Value, err = forge.FrameToTimecode(frame, rate)
+	if err != nil {
+		return err
+	}
+	p.RawValue = p.Value
+	return nil
+}
+
+// formalTimecode makes a formal form of timecode string (ex. 00:00:00:00) from a string.
+// It needs 8 digits in what ever form, or it will return false.
+func formalTimecode(s string) (string, bool) {
+	isDigit := map[rune]bool{
+		'0': true, '1': true, '2': true, '3': true, '4': true,
+		'5': true, '6': true, '7': true, '8': true, '9': true,
 	}
 	tc := ""
-	for _, r := range p.Value {
-		ch := string(r)
-		if isDigit[ch] {
-			tc += ch
+	for _, r := range s {
+		if isDigit[r] {
+			tc += string(r)
 		}
 	}
 	if len(tc) != 8 {
-		return fmt.Errorf("invalid timecode string: %v", p.Value)
+		return "", false
 	}
-	// make the value a formal form of timecode. ex) 00:00:00:00
-	p.Value = strings.Join(
+	tc = strings.Join(
 		[]string{
 			tc[0:2], tc[2:4], tc[4:6], tc[6:8],
 		},
 		":",
 	)
-	p.RawValue = p.Value
-	return nil
+	return tc, true
+}
+
+// entryFrameRate returns frame rate of an entry used for the timecode properties.
+// It checks FPS environ of the entry first, then fps global of the entry type.
+// It will return forge.DefaultFrameRate when neither of them defined.
+func entryFrameRate(tx *sql.Tx, ctx context.Context, path string) (forge.FrameRate, error) {
+	envs, err := entryEnvirons(tx, ctx, path)
+	if err != nil {
+		return forge.FrameRate{}, err
+	}
+	for _, e := range envs {
+		if e.Name != "FPS" || e.Value == "" {
+			continue
+		}
+		rate, err := forge.ParseFrameRate(e.Value)
+		if err != nil {
+			return forge.FrameRate{}, fmt.Errorf("FPS environ: %v", err)
+		}
+		return rate, nil
+	}
+	typ, err := getEntryType(tx, ctx, path)
+	if err != nil {
+		return forge.FrameRate{}, err
+	}
+	g, err := getGlobal(tx, ctx, typ, "fps")
+	if err != nil {
+		var e *forge.NotFoundError
+		if !errors.As(err, &e) {
+			return forge.FrameRate{}, err
+		}
+		return forge.DefaultFrameRate, nil
+	}
+	rate, err := forge.ParseFrameRate(g.Value)
+	if err != nil {
+		return forge.FrameRate{}, fmt.Errorf("fps global: %v", err)
+	}
+	return rate, nil
 }
 
 func validateEntryPath(tx *sql.Tx, ctx context.Context, p, old *forge.Property) error {
@@ -207,6 +378,75 @@ func validateEntryName(tx *sql.Tx, ctx context.Context, p, old *forge.Property)
 	return validateEntryPath(tx, ctx, p, old)
 }
 
+// validateLookup validates a reference to a property of another entry.
+//
+// It accepts one of these forms.
+//
+//	<entry path>.<property>      ex) /show/asset/char/yb.status, ../0010.due
+//	<entry_path property>.<property>   ex) asset.status
+//	^<ancestor type>.<property>  ex) ^shot.due
+//
+// An entry path will be saved as it's id like entry_path, so it follows the entry when it is renamed.
+func validateLookup(tx *sql.Tx, ctx context.Context, p, old *forge.Property) error {
+	if p.Value == "" {
+		p.RawValue = ""
+		return nil
+	}
+	ref, name, err := splitLookup(p.Value)
+	if err != nil {
+		return err
+	}
+	if strings.HasPrefix(ref, "^") {
+		_, err := getEntryTypeID(tx, ctx, ref[1:])
+		if err != nil {
+			return fmt.Errorf("invalid lookup: %v", err)
+		}
+		p.RawValue = p.Value
+		return nil
+	}
+	if !strings.HasPrefix(ref, "/") && !strings.HasPrefix(ref, ".") {
+		// property of the entry that has path of the referenced entry.
+		p.RawValue = p.Value
+		return nil
+	}
+	if ref == "." {
+		// "." indicates the entry itself, as like entry_path.
+		p.RawValue = "0." + name
+		return nil
+	}
+	pth := ref
+	if !path.IsAbs(ref) {
+		pth = path.Join(p.EntryPath, ref)
+	}
+	id, err := getEntryID(tx, ctx, pth)
+	if err != nil {
+		return fmt.Errorf("invalid lookup: %v", err)
+	}
+	p.RawValue = strconv.Itoa(id) + "." + name
+	return nil
+}
+
+// splitLookup splits a lookup value into the entry reference and the property name.
+// Entry names cannot have a dot in it, so the last dot separates them.
+//
+// Hidden properties, those names start with a dot, cannot be looked up,
+// as a lookup like '...note' is ambiguous between '..'.'note' and '.'.'.note'.
+func splitLookup(v string) (string, string, error) {
+	idx := strings.LastIndex(v, ".")
+	if idx <= 0 || idx == len(v)-1 {
+		return "", "", fmt.Errorf("invalid lookup: want <entry>.<property>, got %v", v)
+	}
+	ref, name := v[:idx], v[idx+1:]
+	if ref == "^" {
+		return "", "", fmt.Errorf("invalid lookup: ancestor type not specified: %v", v)
+	}
+	last := ref[strings.LastIndex(ref, "/")+1:]
+	if strings.HasSuffix(last, ".") && last != "." && last != ".." {
+		return "", "", fmt.Errorf("invalid lookup: hidden property cannot be looked up: %v", v)
+	}
+	return ref, name, nil
+}
+
 func validateEntryLink(tx *sql.Tx, ctx context.Context, p, old *forge.Property) error {
 	have := make(map[string]bool)
 	if old != nil {
@@ -404,6 +644,16 @@ func validateTag(tx *sql.Tx, ctx context.Context, p, old *forge.Property) error
 	return nil
 }
 
+// validateFormula doesn't allow users to modify a formula property.
+// The value is evaluated from the formula defined in the default, see refreshFormulas.
+func validateFormula(tx *sql.Tx, ctx context.Context, p, old *forge.Property) error {
+	if old != nil {
+		return fmt.Errorf("formula property is read-only: %v", p.Name)
+	}
+	p.RawValue = ""
+	return nil
+}
+
 func validateSearch(tx *sql.Tx, ctx context.Context, p, old *forge.Property) error {
 	// search can have multiple search queries.
 	// part before '|' is name of a search query, after it is the query.
@@ -0,0 +1,128 @@
+package forge
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// IsValueTemplate checks whether a default value should be expanded at creation of an entry.
+//
+// Templates are opt-in with '@' prefix. A value template is one of the special values,
+// @today, @today+n, @today-n and @user, or has {key} parts after '@' those will be replaced
+// with the values of the new entry, like @{parent.name}_{name}.
+// Other values are literal even if they have braces, like {}.
+func IsValueTemplate(v string) bool {
+	v = strings.TrimSpace(v)
+	if v == "@user" || strings.HasPrefix(v, "@today") {
+		return true
+	}
+	tmpl, ok := strings.CutPrefix(v, "@")
+	return ok && strings.ContainsAny(tmpl, "{}")
+}
+
+// ExpandTemplate replaces {key} parts of a template with values returned by fn.
+// Write {{ or }} to have a literal brace.
+func ExpandTemplate(tmpl string, fn func(key string) (string, error)) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(tmpl); i++ {
+		c := tmpl[i]
+		if c == '}' {
+			if i+1 < len(tmpl) && tmpl[i+1] == '}' {
+				b.WriteByte('}')
+				i++
+				continue
+			}
+			return "", fmt.Errorf("invalid template: unopened '}': %v", tmpl)
+		}
+		if c != '{' {
+			b.WriteByte(c)
+			continue
+		}
+		if i+1 < len(tmpl) && tmpl[i+1] == '{' {
+			b.WriteByte('{')
+			i++
+			continue
+		}
+		end := strings.IndexByte(tmpl[i:], '}')
+		if end == -1 {
+			return "", fmt.Errorf("invalid template: unclosed '{': %v", tmpl)
+		}
+		key := strings.TrimSpace(tmpl[i+1 : i+end])
+		if key == "" {
+			return "", fmt.Errorf("invalid template: empty key: %v", tmpl)
+		}
+		v, err := fn(key)
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(v)
+		i += end
+	}
+	return b.String(), nil
+}
+
+// CheckTemplateKey checks a key of a default value template is known one.
+//
+//	name, path, type               of the new entry
+//	parent.name, parent.path,
+//	parent.type, parent.{prop}     of the parent entry
+//	env.{name}                     environ of the new entry
+//	seq, seq:{width}               next number among the values of sibling entries
+func CheckTemplateKey(key string) error {
+	switch key {
+	case "name", "path", "type", "seq":
+		return nil
+	}
+	if width, ok := strings.CutPrefix(key, "seq:"); ok {
+		_, err := SequenceWidth(width)
+		return err
+	}
+	if prop, ok := strings.CutPrefix(key, "parent."); ok && prop != "" {
+		return nil
+	}
+	if env, ok := strings.CutPrefix(key, "env."); ok && env != "" {
+		return nil
+	}
+	return fmt.Errorf("invalid template: unknown key: %v", key)
+}
+
+// SequenceWidth parses width of a sequence key.
+func SequenceWidth(s string) (int, error) {
+	n, err := strconv.Atoi(s)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid template: sequence width should be a positive integer: %v", s)
+	}
+	return n, nil
+}
+
+// CheckValueTemplate checks a default value template is valid.
+// It is fine to call it with a value that is not a template.
+func CheckValueTemplate(v string) error {
+	if !IsValueTemplate(v) {
+		return nil
+	}
+	v = strings.TrimSpace(v)
+	if v == "@user" || strings.HasPrefix(v, "@today") {
+		return nil
+	}
+	v = strings.TrimPrefix(v, "@")
+	nSeq := 0
+	_, err := ExpandTemplate(v, func(key string) (string, error) {
+		err := CheckTemplateKey(key)
+		if err != nil {
+			return "", err
+		}
+		if key == "seq" || strings.HasPrefix(key, "seq:") {
+			nSeq++
+		}
+		return "", nil
+	})
+	if err != nil {
+		return err
+	}
+	if nSeq > 1 {
+		return fmt.Errorf("invalid template: only one sequence is allowed: %v", v)
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package forge
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestExpandTemplate(t *testing.T) {
+	vars := map[string]string{
+		"name":        "0010",
+		"parent.name": "cg",
+	}
+	fn := func(key string) (string, error) {
+		v, ok := vars[key]
+		if !ok {
+			return "", errors.New("unknown key: " + key)
+		}
+		return v, nil
+	}
+	cases := []struct {
+		tmpl    string
+		want    string
+		wantErr error
+	}{
+		{tmpl: "", want: ""},
+		{tmpl: "plain", want: "plain"},
+		{tmpl: "{parent.name}_{name}", want: "cg_0010"},
+		{tmpl: "{ name }", want: "0010"},
+		{tmpl: "{{name}}", want: "{name}"},
+		{tmpl: "{{{name}}}", want: "{0010}"},
+		{tmpl: "{name", wantErr: errors.New("invalid template: unclosed '{': {name")},
+		{tmpl: "name}", wantErr: errors.New("invalid template: unopened '}': name}")},
+		{tmpl: "{}", wantErr: errors.New("invalid template: empty key: {}")},
+		{tmpl: "{lol}", wantErr: errors.New("unknown key: lol")},
+	}
+	for _, c := range cases {
+		got, err := ExpandTemplate(c.tmpl, fn)
+		if !equalError(c.wantErr, err) {
+			t.Fatalf("%q: want err %v, got %v", c.tmpl, c.wantErr, err)
+		}
+		if got != c.want {
+			t.Fatalf("%q: want %q, got %q", c.tmpl, c.want, got)
+		}
+	}
+}
+
+func TestCheckValueTemplate(t *testing.T) {
+	cases := []struct {
+		v       string
+		wantErr error
+	}{
+		{v: "plain"},
+		{v: "@user"},
+		{v: "@today+14"},
+		{v: "@{env.SHOW}_{parent.name}_{name}"},
+		{v: "@{parent.status}"},
+		{v: "@SH{seq:4}"},
+		{v: "@{seq}{seq:2}", wantErr: errors.New("invalid template: only one sequence is allowed: {seq}{seq:2}")},
+		{v: "@{seq:0}", wantErr: errors.New("invalid template: sequence width should be a positive integer: 0")},
+		{v: "@{env.}", wantErr: errors.New("invalid template: unknown key: env.")},
+		{v: "@{lol}", wantErr: errors.New("invalid template: unknown key: lol")},
+		// templates are opt-in, values without '@' are literal.
+		{v: "{lol}"},
+		{v: "{}"},
+		{v: `{"a": 1}`},
+	}
+	for _, c := range cases {
+		err := CheckValueTemplate(c.v)
+		if !equalError(c.wantErr, err) {
+			t.Fatalf("%q: want err %v, got %v", c.v, c.wantErr, err)
+		}
+	}
+	for _, v := range []string{"plain", "{}", "{name}", "@", "@plain", "a@{name}"} {
+		if IsValueTemplate(v) {
+			t.Fatalf("%q shouldn't be a template", v)
+		}
+	}
+}
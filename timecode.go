@@ -0,0 +1,152 @@
+package forge
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// FrameRate is a frame rate that a timecode is counted with.
+//
+// Timecode counts frames with the nominal rate, which is the actual rate rounded to an integer.
+// So 23.976 fps counts frames as 24 fps, and 29.97 fps counts them as 30 fps.
+//
+// Drop frame timecode skips some frame numbers every minute to keep the timecode
+// close to the wall clock. It is only valid for 29.97 and 59.94 fps.
+type FrameRate struct {
+	Nominal int
+	Drop    bool
+}
+
+// DefaultFrameRate is the frame rate used when an entry doesn't specify it.
+var DefaultFrameRate = FrameRate{Nominal: 24}
+
+// ParseFrameRate parses a frame rate string.
+//
+// It accepts an integer or a decimal rate like "24", "25", "23.976", "29.97" and "59.94".
+// 29.97 and 59.94 are drop frame rates unless the string has "ndf" suffix. (eg. "29.97ndf")
+// A "df" suffix forces drop frame, which is only valid for 30 and 60 nominal rates.
+func ParseFrameRate(s string) (FrameRate, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	drop := ""
+	if strings.HasSuffix(s, "ndf") {
+		drop = "ndf"
+	} else if strings.HasSuffix(s, "df") {
+		drop = "df"
+	}
+	v := strings.TrimSpace(strings.TrimSuffix(s, drop))
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil || f <= 0 || math.IsInf(f, 0) {
+		return FrameRate{}, fmt.Errorf("invalid frame rate: %v", s)
+	}
+	nominal := int(math.Round(f))
+	if nominal == 0 {
+		return FrameRate{}, fmt.Errorf("invalid frame rate: %v", s)
+	}
+	// drop frame timecode is only defined for 29.97 and 59.94 fps.
+	canDrop := nominal == 30 || nominal == 60
+	rate := FrameRate{Nominal: nominal}
+	switch drop {
+	case "df":
+		if !canDrop {
+			return FrameRate{}, fmt.Errorf("drop frame is only valid for 29.97 or 59.94 fps: %v", s)
+		}
+		rate.Drop = true
+	case "":
+		rate.Drop = canDrop && f != float64(nominal)
+	}
+	return rate, nil
+}
+
+// String returns string representation of the frame rate.
+func (r FrameRate) String() string {
+	if !r.Drop {
+		return strconv.Itoa(r.Nominal)
+	}
+	// drop frame rates are always 1000/1001 of the nominal rate.
+	return strconv.FormatFloat(float64(r.Nominal)*1000/1001, 'f', 2, 64)
+}
+
+// dropFrames returns number of frame numbers skipped at each minute, except every 10th minute.
+func (r FrameRate) dropFrames() int {
+	if !r.Drop {
+		return 0
+	}
+	return r.Nominal / 15
+}
+
+// maxTimecodeFrame returns the first frame that cannot be represented as a timecode with the rate.
+func (r FrameRate) maxTimecodeFrame() int {
+	return 24 * (r.Nominal*3600 - r.dropFrames()*54)
+}
+
+// TimecodeToFrame converts a timecode string to a frame number counted from 00:00:00:00.
+//
+// The timecode should be a formal form of timecode. ex) 01:00:00:00
+// It accepts both ':' and ';' as the frame separator, regardless of the rate.
+func TimecodeToFrame(tc string, rate FrameRate) (int, error) {
+	if rate.Nominal <= 0 {
+		return 0, fmt.Errorf("invalid frame rate: %v", rate.Nominal)
+	}
+	if len(tc) != 11 {
+		return 0, fmt.Errorf("invalid timecode: %v", tc)
+	}
+	if tc[2] != ':' || tc[5] != ':' || (tc[8] != ':' && tc[8] != ';') {
+		return 0, fmt.Errorf("invalid timecode: %v", tc)
+	}
+	n := make([]int, 4)
+	for i := range n {
+		v, err := strconv.Atoi(tc[i*3 : i*3+2])
+		if err != nil || v < 0 {
+			return 0, fmt.Errorf("invalid timecode: %v", tc)
+		}
+		n[i] = v
+	}
+	hh, mm, ss, ff := n[0], n[1], n[2], n[3]
+	if hh >= 24 || mm >= 60 || ss >= 60 || ff >= rate.Nominal {
+		return 0, fmt.Errorf("timecode out of range for %v fps: %v", rate, tc)
+	}
+	drop := rate.dropFrames()
+	if drop != 0 && ss == 0 && mm%10 != 0 && ff < drop {
+		return 0, fmt.Errorf("timecode doesn't exist in drop frame: %v", tc)
+	}
+	mins := hh*60 + mm
+	frame := (hh*3600+mm*60+ss)*rate.Nominal + ff
+	frame -= drop * (mins - mins/10)
+	return frame, nil
+}
+
+// FrameToTimecode converts a frame number counted from 00:00:00:00 to a timecode string.
+//
+// Drop frame timecode uses ';' as the frame separator. ex) 00:01:00;02
+func FrameToTimecode(frame int, rate FrameRate) (string, error) {
+	if rate.Nominal <= 0 {
+		return "", fmt.Errorf("invalid frame rate: %v", rate.Nominal)
+	}
+	if frame < 0 || frame >= rate.maxTimecodeFrame() {
+		return "", fmt.Errorf("frame out of timecode range for %v fps: %v", rate, frame)
+	}
+	drop := rate.dropFrames()
+	if drop != 0 {
+		// Get back the skipped frame numbers, then it can be calculated like non-drop.
+		per10Min := rate.Nominal*600 - drop*9
+		perMin := rate.Nominal*60 - drop
+		d := frame / per10Min
+		m := frame % per10Min
+		frame += drop * 9 * d
+		if m > drop {
+			frame += drop * ((m - drop) / perMin)
+		}
+	}
+	ff := frame % rate.Nominal
+	ss := frame / rate.Nominal % 60
+	mm := frame / rate.Nominal / 60 % 60
+	hh := frame / rate.Nominal / 3600
+	sep := ":"
+	if rate.Drop {
+		sep = ";"
+	}
+	tc := fmt.Sprintf("%02d:%02d:%02d%s%02d", hh, mm, ss, sep, ff)
+	return tc, nil
+}
@@ -0,0 +1,140 @@
+package forge
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseFrameRate(t *testing.T) {
+	cases := []struct {
+		s       string
+		want    FrameRate
+		wantErr error
+	}{
+		{s: "24", want: FrameRate{Nominal: 24}},
+		{s: "23.976", want: FrameRate{Nominal: 24}},
+		{s: "25", want: FrameRate{Nominal: 25}},
+		{s: "30", want: FrameRate{Nominal: 30}},
+		{s: "29.97", want: FrameRate{Nominal: 30, Drop: true}},
+		{s: "29.97ndf", want: FrameRate{Nominal: 30}},
+		{s: "30df", want: FrameRate{Nominal: 30, Drop: true}},
+		{s: "59.94", want: FrameRate{Nominal: 60, Drop: true}},
+		{s: " 59.94 NDF ", want: FrameRate{Nominal: 60}},
+		{s: "24df", wantErr: errors.New("drop frame is only valid for 29.97 or 59.94 fps: 24df")},
+		{s: "90df", wantErr: errors.New("drop frame is only valid for 29.97 or 59.94 fps: 90df")},
+		{s: "120df", wantErr: errors.New("drop frame is only valid for 29.97 or 59.94 fps: 120df")},
+		{s: "119.88", want: FrameRate{Nominal: 120}},
+		{s: "", wantErr: errors.New("invalid frame rate: ")},
+		{s: "0", wantErr: errors.New("invalid frame rate: 0")},
+		{s: "-24", wantErr: errors.New("invalid frame rate: -24")},
+		{s: "fast", wantErr: errors.New("invalid frame rate: fast")},
+	}
+	for _, c := range cases {
+		got, err := ParseFrameRate(c.s)
+		if !equalError(c.wantErr, err) {
+			t.Fatalf("%q: want err %v, got %v", c.s, c.wantErr, err)
+		}
+		if got != c.want {
+			t.Fatalf("%q: want %v, got %v", c.s, c.want, got)
+		}
+	}
+}
+
+func TestTimecodeFrameConversion(t *testing.T) {
+	ndf24 := FrameRate{Nominal: 24}
+	ndf30 := FrameRate{Nominal: 30}
+	df30 := FrameRate{Nominal: 30, Drop: true}
+	df60 := FrameRate{Nominal: 60, Drop: true}
+	cases := []struct {
+		label string
+		rate  FrameRate
+		tc    string
+		frame int
+	}{
+		{label: "zero", rate: ndf24, tc: "00:00:00:00", frame: 0},
+		{label: "last frame of a second", rate: ndf24, tc: "00:00:00:23", frame: 23},
+		{label: "one second", rate: ndf24, tc: "00:00:01:00", frame: 24},
+		{label: "one hour", rate: ndf24, tc: "01:00:00:00", frame: 86400},
+		{label: "last timecode of a day", rate: ndf24, tc: "23:59:59:23", frame: 2073599},
+		{label: "one minute ndf", rate: ndf30, tc: "00:01:00:00", frame: 1800},
+		{label: "last frame before drop", rate: df30, tc: "00:00:59;29", frame: 1799},
+		{label: "first frame after drop", rate: df30, tc: "00:01:00;02", frame: 1800},
+		{label: "two minutes", rate: df30, tc: "00:02:00;02", frame: 3598},
+		{label: "no drop at 10th minute", rate: df30, tc: "00:10:00;00", frame: 17982},
+		{label: "one hour df", rate: df30, tc: "01:00:00;00", frame: 107892},
+		{label: "last timecode of a day df", rate: df30, tc: "23:59:59;29", frame: 2589407},
+		{label: "first frame after drop 59.94", rate: df60, tc: "00:01:00;04", frame: 3600},
+		{label: "no drop at 10th minute 59.94", rate: df60, tc: "00:10:00;00", frame: 35964},
+	}
+	for _, c := range cases {
+		frame, err := TimecodeToFrame(c.tc, c.rate)
+		if err != nil {
+			t.Fatalf("%v: %v", c.label, err)
+		}
+		if frame != c.frame {
+			t.Fatalf("%v: timecode to frame: want %v, got %v", c.label, c.frame, frame)
+		}
+		tc, err := FrameToTimecode(c.frame, c.rate)
+		if err != nil {
+			t.Fatalf("%v: %v", c.label, err)
+		}
+		if tc != c.tc {
+			t.Fatalf("%v: frame to timecode: want %v, got %v", c.label, c.tc, tc)
+		}
+	}
+}
+
+func TestTimecodeToFrameError(t *testing.T) {
+	ndf24 := FrameRate{Nominal: 24}
+	df30 := FrameRate{Nominal: 30, Drop: true}
+	cases := []struct {
+		rate    FrameRate
+		tc      string
+		wantErr error
+	}{
+		{rate: ndf24, tc: "00:00", wantErr: errors.New("invalid timecode: 00:00")},
+		{rate: ndf24, tc: "00-00-00-00", wantErr: errors.New("invalid timecode: 00-00-00-00")},
+		{rate: ndf24, tc: "00:00:00-00", wantErr: errors.New("invalid timecode: 00:00:00-00")},
+		{rate: ndf24, tc: "00:0a:00:00", wantErr: errors.New("invalid timecode: 00:0a:00:00")},
+		{rate: ndf24, tc: "00:00:00:24", wantErr: errors.New("timecode out of range for 24 fps: 00:00:00:24")},
+		{rate: ndf24, tc: "00:60:00:00", wantErr: errors.New("timecode out of range for 24 fps: 00:60:00:00")},
+		{rate: ndf24, tc: "24:00:00:00", wantErr: errors.New("timecode out of range for 24 fps: 24:00:00:00")},
+		{rate: df30, tc: "00:01:00;00", wantErr: errors.New("timecode doesn't exist in drop frame: 00:01:00;00")},
+		{rate: df30, tc: "00:01:00;01", wantErr: errors.New("timecode doesn't exist in drop frame: 00:01:00;01")},
+		{rate: FrameRate{}, tc: "00:00:00:00", wantErr: errors.New("invalid frame rate: 0")},
+	}
+	for _, c := range cases {
+		_, err := TimecodeToFrame(c.tc, c.rate)
+		if !equalError(c.wantErr, err) {
+			t.Fatalf("%q: want err %v, got %v", c.tc, c.wantErr, err)
+		}
+	}
+}
+
+func TestFrameToTimecodeError(t *testing.T) {
+	cases := []struct {
+		rate    FrameRate
+		frame   int
+		wantErr error
+	}{
+		{rate: FrameRate{Nominal: 24}, frame: -1, wantErr: errors.New("frame out of timecode range for 24 fps: -1")},
+		{rate: FrameRate{Nominal: 24}, frame: 2073600, wantErr: errors.New("frame out of timecode range for 24 fps: 2073600")},
+		{rate: FrameRate{Nominal: 30, Drop: true}, frame: 2589408, wantErr: errors.New("frame out of timecode range for 29.97 fps: 2589408")},
+	}
+	for _, c := range cases {
+		_, err := FrameToTimecode(c.frame, c.rate)
+		if !equalError(c.wantErr, err) {
+			t.Fatalf("%v: want err %v, got %v", c.frame, c.wantErr, err)
+		}
+	}
+}
+
+func equalError(a, b error) bool {
+	if a == nil {
+		return b == nil
+	}
+	if b == nil {
+		return false
+	}
+	return a.Error() == b.Error()
+}